@@ -0,0 +1,48 @@
+package main
+
+import (
+	"code-challenge/pkg/apikeys"
+	"code-challenge/pkg/config"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// Creates an API key directly in the database of the API, it bootstraps the first admin key of the deployments
+// without identity provider:
+//
+//	DATABASE_URL=... go run ./cmd/apikeys -organization acme -name operations -scopes admin,analytics
+func main() {
+	organization := flag.String("organization", "", "organization the calls of the key are accounted to")
+	name := flag.String("name", "", "name telling the key apart")
+	scopes := flag.String("scopes", "chat", "comma separated scopes of the key: "+strings.Join(apikeys.Scopes, ", "))
+	rateLimit := flag.Int("rate-limit", apikeys.DefaultRateLimit, "requests per minute allowed to the key")
+	flag.Parse()
+
+	url := os.Getenv(config.EnvDatabaseURL)
+	if url == "" {
+		log.Fatalln("DATABASE_URL must be set, the keys of the API are stored in Postgres")
+	}
+
+	ctx := context.Background()
+	store, err := apikeys.Open(ctx, url)
+	if err != nil {
+		log.Fatalln("Unable to open API keys", err)
+	}
+
+	secret, key, err := apikeys.NewService(store).Create(ctx, apikeys.NewKey{
+		Organization: *organization,
+		Name:         *name,
+		Scopes:       strings.Split(*scopes, ","),
+		RateLimit:    *rateLimit,
+	})
+	if err != nil {
+		log.Fatalln("Unable to create API key", err)
+	}
+
+	fmt.Printf("Created key %s of %s with scopes %s, its secret is shown once:\n%s\n",
+		key.ID, key.Organization, strings.Join(key.Scopes, ","), secret)
+}
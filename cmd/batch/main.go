@@ -0,0 +1,123 @@
+package main
+
+import (
+	"code-challenge/pkg/batch"
+	"code-challenge/pkg/config"
+	"code-challenge/pkg/operations"
+	"code-challenge/pkg/temporalclient"
+	codingchallenge "code-challenge/pkg/workflow"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/client"
+	"log"
+	"os"
+)
+
+// usage lists the commands
+const usage = `Usage:
+  batch start -input location -output location [-concurrency n] [-user-prefix prefix] [-wait]
+  batch status id`
+
+// Answers the questions of a JSON lines file with the BatchQuestionsWorkflow and reads the progress of the batches:
+//
+//	go run ./cmd/batch start -input questions.jsonl -output answers.jsonl -concurrency 10
+//	go run ./cmd/batch status batch-<uuid>
+//
+// The locations are paths in the batch directory of the workers or http and https URLs, such as presigned S3 URLs.
+// It connects to Temporal with the configuration of the API, from CONFIG_FILE and the environment.
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatalln(usage)
+	}
+
+	// Connect to Temporal as the API does
+	cfg, err := config.Load("batch", nil)
+	if err != nil {
+		log.Fatalln("Invalid configuration", err)
+	}
+	options, err := temporalclient.Options(cfg.Temporal)
+	if err != nil {
+		log.Fatalln("Unable to configure the connection to Temporal", err)
+	}
+	c, err := client.Dial(options)
+	if err != nil {
+		log.Fatalln("Unable to initialize client", err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "start":
+		start(ctx, c, cfg.Temporal.TaskQueues.Chat, cfg.Worker.BatchHosts, args)
+	case "status":
+		status(ctx, c, args)
+	default:
+		log.Fatalln(usage)
+	}
+}
+
+// start starts a batch and optionally waits for its questions to be answered, its URLs must be on the allowed hosts
+func start(ctx context.Context, c client.Client, taskQueue string, hosts []string, args []string) {
+	flags := flag.NewFlagSet("start", flag.ExitOnError)
+	input := flags.String("input", "", "JSON lines of the questions, a path in the batch directory of the workers or a URL")
+	output := flags.String("output", "", "where the results are written as JSON lines, a path in the batch directory of the workers or a URL")
+	concurrency := flags.Int("concurrency", codingchallenge.DefaultBatchConcurrency, "number of questions answered at once")
+	userPrefix := flags.String("user-prefix", "", "prefix of the users of the questions, such as acme: for an organization")
+	wait := flags.Bool("wait", false, "wait for the results to be written")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 0 || *input == "" || *output == "" {
+		log.Fatalln(usage)
+	}
+	for _, location := range []string{*input, *output} {
+		if err := batch.ValidLocation(location); err != nil {
+			log.Fatalln("The input and the output", err)
+		}
+		if err := batch.AllowedHost(location, hosts); err != nil {
+			log.Fatalln("The URLs must be on the hosts of worker.batch_hosts", err)
+		}
+	}
+	if *input == *output {
+		log.Fatalln("The output must differ from the input")
+	}
+	if *concurrency < 1 || *concurrency > codingchallenge.MaxBatchConcurrency {
+		log.Fatalln("The concurrency must be between 1 and", codingchallenge.MaxBatchConcurrency)
+	}
+
+	batchID := "batch-" + uuid.NewString()
+	err := operations.StartQuestionBatch(ctx, c, taskQueue, batchID, codingchallenge.BatchInput{
+		Input: *input, Output: *output, Concurrency: *concurrency, UserPrefix: *userPrefix,
+	})
+	if err != nil {
+		log.Fatalln("Unable to start batch", err)
+	}
+	fmt.Println("Started batch", batchID)
+
+	// The result follows the runs of the workflow continuing as new
+	if *wait {
+		var progress codingchallenge.BatchProgress
+		if err := c.GetWorkflow(ctx, batchID, "").Get(ctx, &progress); err != nil {
+			log.Fatalln("The batch failed", err)
+		}
+		fmt.Printf("%d questions: %d answered, %d queued, %d failed, results written to %s\n",
+			progress.Questions, progress.Answered, progress.Queued, progress.Failed, progress.Output)
+	}
+}
+
+// status prints the status and the progress of the batch
+func status(ctx context.Context, c client.Client, args []string) {
+	if len(args) != 1 {
+		log.Fatalln(usage)
+	}
+	questions, err := operations.DescribeQuestionBatch(ctx, c, args[0])
+	if err != nil {
+		log.Fatalln("Unable to read batch", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(questions)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"code-challenge/pkg/audit"
+	"code-challenge/pkg/config"
+	"code-challenge/pkg/deadletter"
+	"code-challenge/pkg/openai"
+	"code-challenge/pkg/operations"
+	"code-challenge/pkg/temporalclient"
+	codingchallenge "code-challenge/pkg/workflow"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go.temporal.io/sdk/client"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// usage lists the commands
+const usage = `Usage:
+  dlq list [-user user] [-error-type type] [-from YYYY-MM-DD] [-to YYYY-MM-DD] [-pending] [-limit n]
+  dlq show id
+  dlq replay -reason reason [-model model] [-prompt-version version] [-wait] id`
+
+// Inspects and replays the questions that could not be answered, recorded by the workers in the database:
+//
+//	DATABASE_URL=... go run ./cmd/dlq list -pending -error-type AllProvidersFailed
+//	DATABASE_URL=... go run ./cmd/dlq replay -reason "provider outage" -model gpt-4o-mini <id>
+//
+// The replays connect to Temporal with the configuration of the API, from CONFIG_FILE and the environment.
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatalln(usage)
+	}
+	url := os.Getenv(config.EnvDatabaseURL)
+	if url == "" {
+		log.Fatalln("DATABASE_URL must be set, the dead letters are stored in Postgres")
+	}
+
+	ctx := context.Background()
+	store, err := deadletter.Open(ctx, url)
+	if err != nil {
+		log.Fatalln("Unable to open dead letters", err)
+	}
+
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "list":
+		list(ctx, store, args)
+	case "show":
+		show(ctx, store, args)
+	case "replay":
+		replay(ctx, store, args)
+	default:
+		log.Fatalln(usage)
+	}
+}
+
+// list prints the letters matching the filters, most recent failure first
+func list(ctx context.Context, store deadletter.Store, args []string) {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	user := flags.String("user", "", "user who asked the questions")
+	errorType := flags.String("error-type", "", "type of the failure, such as AllProvidersFailed or Timeout")
+	from := flags.String("from", "", "first day of the failures, YYYY-MM-DD")
+	to := flags.String("to", "", "last day of the failures, YYYY-MM-DD")
+	pending := flags.Bool("pending", false, "only the questions not replayed yet")
+	limit := flags.Int("limit", 50, "maximum number of questions")
+	_ = flags.Parse(args)
+
+	filter := deadletter.Filter{User: *user, ErrorType: *errorType, Pending: *pending, Limit: *limit}
+	if *from != "" {
+		filter.From = day(*from)
+	}
+	if *to != "" {
+		filter.To = day(*to).Add(24 * time.Hour)
+	}
+
+	letters, err := store.List(ctx, filter)
+	if err != nil {
+		log.Fatalln("Unable to list dead letters", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tFAILED AT\tUSER\tERROR TYPE\tATTEMPTS\tREPLAYED BY\tQUESTION")
+	for _, letter := range letters {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", letter.ID, letter.FailedAt.Format(time.RFC3339), letter.User,
+			letter.ErrorType, letter.Attempts, letter.ReplayWorkflowID, truncate(letter.Question, 60))
+	}
+	_ = w.Flush()
+}
+
+// show prints the letter with the input of its workflow
+func show(ctx context.Context, store deadletter.Store, args []string) {
+	if len(args) != 1 {
+		log.Fatalln(usage)
+	}
+	letter, err := store.Get(ctx, args[0])
+	if err != nil {
+		log.Fatalln("Unable to read dead letter", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(letter)
+}
+
+// replay asks the question of the letter again through a new workflow and records the replay in the audit log
+func replay(ctx context.Context, store deadletter.Store, args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	reason := flags.String("reason", "", "why the question is replayed, recorded in the audit log")
+	model := flags.String("model", "", "configured model answering the question, only its providers are tried")
+	promptVersion := flags.String("prompt-version", "", "version of the system prompt: "+strings.Join(codingchallenge.PromptVersions(), ", "))
+	wait := flags.Bool("wait", false, "wait for the answer of the workflow")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 || strings.TrimSpace(*reason) == "" {
+		log.Fatalln(usage)
+	}
+	if !codingchallenge.ValidPromptVersion(*promptVersion) {
+		log.Fatalln("The prompt version must be one of", strings.Join(codingchallenge.PromptVersions(), ", "))
+	}
+	letter, err := store.Get(ctx, flags.Arg(0))
+	if err != nil {
+		log.Fatalln("Unable to read dead letter", err)
+	}
+
+	// Connect to Temporal as the API does
+	cfg, err := config.Load("dlq", nil)
+	if err != nil {
+		log.Fatalln("Invalid configuration", err)
+	}
+	openai.Configure(cfg.LLM)
+	if *model != "" && !slices.Contains(openai.Models(), *model) {
+		log.Fatalln("The model must be one of", strings.Join(openai.Models(), ", "))
+	}
+	options, err := temporalclient.Options(cfg.Temporal)
+	if err != nil {
+		log.Fatalln("Unable to configure the connection to Temporal", err)
+	}
+	c, err := client.Dial(options)
+	if err != nil {
+		log.Fatalln("Unable to initialize client", err)
+	}
+	defer c.Close()
+
+	workflowID, err := operations.Replay(ctx, c, store, cfg.Temporal.TaskQueues.Chat, letter,
+		operations.ReplayOptions{Model: *model, PromptVersion: *promptVersion})
+	record(ctx, audit.Entry{Action: audit.ActionReplay, Target: letter.ID, Reason: strings.TrimSpace(*reason), Detail: "workflow " + workflowID}, err)
+	if workflowID == "" {
+		log.Fatalln("Unable to replay dead letter", err)
+	}
+	fmt.Println("Replaying", letter.ID, "with workflow", workflowID)
+
+	if *wait {
+		var answer codingchallenge.ChatBotAnswer
+		if err := c.GetWorkflow(ctx, workflowID, "").Get(ctx, &answer); err != nil {
+			log.Fatalln("The replay failed", err)
+		}
+		fmt.Printf("%s: %s\n", answer.Status, answer.Answer)
+	}
+}
+
+// record adds the replay to the audit log of the admin operations, the actor is the user running the command
+func record(ctx context.Context, entry audit.Entry, err error) {
+	entry.Time, entry.Actor = time.Now().UTC(), "dlq:"+os.Getenv("USER")
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	store, openErr := audit.Open(ctx, os.Getenv(config.EnvDatabaseURL))
+	if openErr == nil {
+		openErr = store.Record(ctx, entry)
+	}
+	if openErr != nil {
+		log.Println("Unable to record the replay in the audit log", openErr)
+	}
+}
+
+// day parses a day formatted as YYYY-MM-DD
+func day(value string) time.Time {
+	parsed, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		log.Fatalln("Dates must be formatted as YYYY-MM-DD", err)
+	}
+	return parsed
+}
+
+// truncate shortens the text to the number of characters
+func truncate(text string, length int) string {
+	if runes := []rune(text); len(runes) > length {
+		return string(runes[:length-1]) + "…"
+	}
+	return text
+}
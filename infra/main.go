@@ -176,7 +176,8 @@ func main() {
 				Replicas: pulumi.Int(1),
 				Template: &corev1.PodTemplateSpecArgs{
 					Metadata: &metav1.ObjectMetaArgs{
-						Labels: appLabelsWorker,
+						Labels:      appLabelsWorker,
+						Annotations: prometheusAnnotations(9090),
 					},
 					Spec: &corev1.PodSpecArgs{
 						Containers: corev1.ContainerArray{
@@ -193,6 +194,18 @@ func main() {
 										Value: pulumi.String("default"),
 									},
 								},
+								Ports: corev1.ContainerPortArray{
+									corev1.ContainerPortArgs{
+										Name:          pulumi.String("http"),
+										ContainerPort: pulumi.Int(7239),
+									},
+									corev1.ContainerPortArgs{
+										Name:          pulumi.String("metrics"),
+										ContainerPort: pulumi.Int(9090),
+									},
+								},
+								LivenessProbe:  httpProbe("/healthz", 7239),
+								ReadinessProbe: httpProbe("/readyz", 7239),
 							},
 						},
 					},
@@ -214,7 +227,8 @@ func main() {
 				Replicas: pulumi.Int(1),
 				Template: &corev1.PodTemplateSpecArgs{
 					Metadata: &metav1.ObjectMetaArgs{
-						Labels: appLabelsApi,
+						Labels:      appLabelsApi,
+						Annotations: prometheusAnnotations(3002),
 					},
 					Spec: &corev1.PodSpecArgs{
 						Containers: corev1.ContainerArray{
@@ -231,6 +245,14 @@ func main() {
 										Value: pulumi.String("default"),
 									},
 								},
+								Ports: corev1.ContainerPortArray{
+									corev1.ContainerPortArgs{
+										Name:          pulumi.String("http"),
+										ContainerPort: pulumi.Int(3002),
+									},
+								},
+								LivenessProbe:  httpProbe("/healthz", 3002),
+								ReadinessProbe: httpProbe("/readyz", 3002),
 							},
 						},
 					},
@@ -372,6 +394,29 @@ func generateKubeconfig(clusterEndpoint pulumi.StringOutput, certData pulumi.Str
     }`, clusterEndpoint, certData, clusterName)
 }
 
+// httpProbe probes the path of the container port, the checks of the services time out after 3 seconds
+func httpProbe(path string, port int) *corev1.ProbeArgs {
+	return &corev1.ProbeArgs{
+		HttpGet: &corev1.HTTPGetActionArgs{
+			Path: pulumi.String(path),
+			Port: pulumi.Int(port),
+		},
+		InitialDelaySeconds: pulumi.Int(5),
+		PeriodSeconds:       pulumi.Int(10),
+		TimeoutSeconds:      pulumi.Int(5),
+		FailureThreshold:    pulumi.Int(3),
+	}
+}
+
+// prometheusAnnotations let Prometheus discover the /metrics endpoint of the pods on the port
+func prometheusAnnotations(port int) pulumi.StringMap {
+	return pulumi.StringMap{
+		"prometheus.io/scrape": pulumi.String("true"),
+		"prometheus.io/port":   pulumi.String(fmt.Sprint(port)),
+		"prometheus.io/path":   pulumi.String("/metrics"),
+	}
+}
+
 func toPulumiStringArray(a []string) pulumi.StringArrayInput {
 	var res []pulumi.StringInput
 	for _, s := range a {
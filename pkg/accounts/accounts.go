@@ -0,0 +1,294 @@
+package accounts
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Lifetimes of the tokens issued to the users
+const (
+	sessionTTL           = 30 * 24 * time.Hour
+	resetTokenTTL        = time.Hour
+	verificationTokenTTL = 24 * time.Hour
+)
+
+// Password rules, bcrypt ignores the bytes after the 72nd
+const (
+	minPasswordLength = 8
+	maxPasswordBytes  = 72
+)
+
+// SessionPrefix starts every session token so they can be told apart from the other bearer tokens
+const SessionPrefix = "session_"
+
+// Errors returned by the accounts service
+var (
+	ErrInvalidInput = errors.New("invalid account input")
+	ErrEmailTaken   = errors.New("email already registered")
+	ErrInvalidLogin = errors.New("invalid email or password")
+	ErrInvalidToken = errors.New("invalid or expired token")
+	ErrNotFound     = errors.New("not found")
+)
+
+// Account is a first-party user of the API
+type Account struct {
+	ID           string    `json:"id"`
+	Email        string    `json:"email"`
+	Name         string    `json:"name"`
+	Locale       string    `json:"locale"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	// EmailVerified is set once the account confirmed a token sent to its email, only then the answers are emailed to it
+	EmailVerified bool `json:"email_verified"`
+}
+
+// Session is a login of an account, only the hash of its token is stored
+type Session struct {
+	TokenHash string
+	AccountID string
+	ExpiresAt time.Time
+}
+
+// ResetToken is a one-time token allowing to choose a new password, only its hash is stored
+type ResetToken struct {
+	TokenHash string
+	AccountID string
+	ExpiresAt time.Time
+}
+
+// VerificationToken is a one-time token confirming the email of the account, only its hash is stored
+type VerificationToken struct {
+	TokenHash string
+	AccountID string
+	ExpiresAt time.Time
+}
+
+// Signup is the input creating an account
+type Signup struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Name     string `json:"name"`
+	Locale   string `json:"locale"`
+}
+
+// Mailer sends the password reset and the email verification emails
+type Mailer interface {
+	SendEmail(to, subject, body string) error
+}
+
+// Service manages the accounts, their sessions and their password resets
+type Service struct {
+	Store  Store
+	Mailer Mailer
+
+	// ResetURL is the page of the frontend choosing a new password, the token is appended to it
+	ResetURL string
+
+	// now returns the current time, it is replaced in tests
+	now func() time.Time
+}
+
+// NewService creates the accounts service
+func NewService(store Store, mailer Mailer, resetURL string) *Service {
+	return &Service{Store: store, Mailer: mailer, ResetURL: resetURL, now: time.Now}
+}
+
+// dummyHash is compared when the email is unknown so a login takes the same time whether the account exists or not
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// Signup creates an account
+func (s *Service) Signup(ctx context.Context, input Signup) (*Account, error) {
+	address, err := mail.ParseAddress(input.Email)
+	if err != nil || address.Address != strings.TrimSpace(input.Email) {
+		return nil, fmt.Errorf("%w: email must be an email address", ErrInvalidInput)
+	}
+	if err := validatePassword(input.Password); err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	account := &Account{
+		ID:           uuid.NewString(),
+		Email:        strings.ToLower(address.Address),
+		Name:         strings.TrimSpace(input.Name),
+		Locale:       strings.TrimSpace(input.Locale),
+		PasswordHash: string(hash),
+		CreatedAt:    s.now().UTC(),
+	}
+	if err := s.Store.CreateAccount(ctx, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// Login checks the password of the account and returns a new session token
+func (s *Service) Login(ctx context.Context, email, password string) (string, *Account, error) {
+	account, err := s.Store.AccountByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if errors.Is(err, ErrNotFound) {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return "", nil, ErrInvalidLogin
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte(password)); err != nil {
+		return "", nil, ErrInvalidLogin
+	}
+
+	token, err := newToken(SessionPrefix)
+	if err != nil {
+		return "", nil, err
+	}
+	err = s.Store.CreateSession(ctx, &Session{TokenHash: hashToken(token), AccountID: account.ID, ExpiresAt: s.now().Add(sessionTTL)})
+	if err != nil {
+		return "", nil, err
+	}
+	return token, account, nil
+}
+
+// Authenticate returns the account of a valid session token
+func (s *Service) Authenticate(ctx context.Context, token string) (*Account, error) {
+	session, err := s.Store.SessionByHash(ctx, hashToken(token))
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !s.now().Before(session.ExpiresAt) {
+		_ = s.Store.DeleteSession(ctx, session.TokenHash)
+		return nil, ErrInvalidToken
+	}
+	return s.Store.AccountByID(ctx, session.AccountID)
+}
+
+// Logout ends the session
+func (s *Service) Logout(ctx context.Context, token string) error {
+	return s.Store.DeleteSession(ctx, hashToken(token))
+}
+
+// RequestPasswordReset emails a one-time reset token to the account.
+// Nothing is sent for an unknown email and no error is returned, so the existence of accounts is not disclosed.
+func (s *Service) RequestPasswordReset(ctx context.Context, email string) error {
+	account, err := s.Store.AccountByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := newToken("")
+	if err != nil {
+		return err
+	}
+	err = s.Store.CreateResetToken(ctx, &ResetToken{TokenHash: hashToken(token), AccountID: account.ID, ExpiresAt: s.now().Add(resetTokenTTL)})
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hello %s,\n\nUse the link below to choose a new password, it is valid for %s:\n\n%s%s\n\n"+
+		"If you did not ask to reset your password you can ignore this email.", account.Name, resetTokenTTL, s.ResetURL, token)
+	return s.Mailer.SendEmail(account.Email, "Reset your password", body)
+}
+
+// ResetPassword sets the new password of the account of the reset token and ends all its sessions.
+// The token can only be used once.
+func (s *Service) ResetPassword(ctx context.Context, token, password string) error {
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+
+	reset, err := s.Store.ConsumeResetToken(ctx, hashToken(token))
+	if errors.Is(err, ErrNotFound) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+	if !s.now().Before(reset.ExpiresAt) {
+		return ErrInvalidToken
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.Store.UpdatePassword(ctx, reset.AccountID, string(hash)); err != nil {
+		return err
+	}
+	return s.Store.DeleteSessions(ctx, reset.AccountID)
+}
+
+// RequestEmailVerification emails a one-time token confirming the email of the account
+func (s *Service) RequestEmailVerification(ctx context.Context, account *Account) error {
+	token, err := newToken("")
+	if err != nil {
+		return err
+	}
+	err = s.Store.CreateVerificationToken(ctx, &VerificationToken{TokenHash: hashToken(token), AccountID: account.ID, ExpiresAt: s.now().Add(verificationTokenTTL)})
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hello %s,\n\nConfirm your email with the token below, it is valid for %s:\n\n%s\n\n"+
+		"The answers to your questions can then be sent to this email.", account.Name, verificationTokenTTL, token)
+	return s.Mailer.SendEmail(account.Email, "Confirm your email", body)
+}
+
+// VerifyEmail marks the email of the account of the verification token as verified. The token can only be used once.
+func (s *Service) VerifyEmail(ctx context.Context, token string) error {
+	verification, err := s.Store.ConsumeVerificationToken(ctx, hashToken(token))
+	if errors.Is(err, ErrNotFound) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+	if !s.now().Before(verification.ExpiresAt) {
+		return ErrInvalidToken
+	}
+	return s.Store.VerifyEmail(ctx, verification.AccountID)
+}
+
+// validatePassword checks the password rules
+func validatePassword(password string) error {
+	switch {
+	case len([]rune(password)) < minPasswordLength:
+		return fmt.Errorf("%w: password must have at least %d characters", ErrInvalidInput, minPasswordLength)
+	case len(password) > maxPasswordBytes:
+		return fmt.Errorf("%w: password must have at most %d bytes", ErrInvalidInput, maxPasswordBytes)
+	}
+	return nil
+}
+
+// newToken returns a random token with the prefix
+func newToken(prefix string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hash under which a token is stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,169 @@
+package accounts
+
+import (
+	"code-challenge/pkg/auth"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mailer records the emails instead of sending them
+type mailer struct {
+	to, subject, body string
+}
+
+func (m *mailer) SendEmail(to, subject, body string) error {
+	m.to, m.subject, m.body = to, subject, body
+	return nil
+}
+
+// newTestService creates a service with an in memory store and a clock the test controls
+func newTestService() (*Service, *mailer, *time.Time) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	m := &mailer{}
+	service := NewService(NewMemoryStore(), m, "https://app.example.com/reset?token=")
+	service.now = func() time.Time { return now }
+	return service, m, &now
+}
+
+// bearer returns a request carrying the token
+func bearer(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func Test_Signup(t *testing.T) {
+	ctx := context.Background()
+	service, _, _ := newTestService()
+
+	account, err := service.Signup(ctx, Signup{Email: "Thiago@Example.com", Password: "correct horse", Name: "Thiago", Locale: "pt-BR"})
+	require.NoError(t, err)
+	assert.Equal(t, "thiago@example.com", account.Email)
+	assert.NotContains(t, account.PasswordHash, "correct horse")
+
+	_, err = service.Signup(ctx, Signup{Email: "thiago@example.com", Password: "another password"})
+	assert.ErrorIs(t, err, ErrEmailTaken)
+
+	invalid := []Signup{
+		{Email: "not an email", Password: "correct horse"},
+		{Email: "Thiago <other@example.com>", Password: "correct horse"},
+		{Email: "other@example.com", Password: "short"},
+		{Email: "other@example.com", Password: strings.Repeat("a", 73)},
+	}
+	for _, input := range invalid {
+		_, err := service.Signup(ctx, input)
+		assert.ErrorIs(t, err, ErrInvalidInput, input.Email)
+	}
+}
+
+func Test_Sessions(t *testing.T) {
+	ctx := context.Background()
+	service, _, now := newTestService()
+	account, err := service.Signup(ctx, Signup{Email: "thiago@example.com", Password: "correct horse", Name: "Thiago", Locale: "pt-BR"})
+	require.NoError(t, err)
+
+	_, _, err = service.Login(ctx, "thiago@example.com", "wrong password")
+	assert.ErrorIs(t, err, ErrInvalidLogin)
+	_, _, err = service.Login(ctx, "unknown@example.com", "correct horse")
+	assert.ErrorIs(t, err, ErrInvalidLogin)
+
+	token, _, err := service.Login(ctx, " THIAGO@example.com", "correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, SessionPrefix))
+
+	// The session authenticates the account
+	authenticator := Authenticator{Service: service}
+	identity, err := authenticator.Authenticate(bearer(token))
+	require.NoError(t, err)
+	assert.Equal(t, &auth.Identity{Subject: account.ID, Name: "Thiago", Locale: "pt-BR", Scopes: []string{auth.ScopeChat}}, identity)
+
+	// Other bearer tokens are left to the other authenticators
+	_, err = authenticator.Authenticate(bearer("eyJhbGciOiJIUzI1NiJ9.e30.sig"))
+	assert.ErrorIs(t, err, auth.ErrNoCredentials)
+
+	// The session ends with the logout
+	require.NoError(t, service.Logout(ctx, token))
+	_, err = authenticator.Authenticate(bearer(token))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	// The session expires
+	token, _, err = service.Login(ctx, "thiago@example.com", "correct horse")
+	require.NoError(t, err)
+	*now = now.Add(sessionTTL)
+	_, err = service.Authenticate(ctx, token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func Test_PasswordReset(t *testing.T) {
+	ctx := context.Background()
+	service, m, now := newTestService()
+	_, err := service.Signup(ctx, Signup{Email: "thiago@example.com", Password: "correct horse", Name: "Thiago"})
+	require.NoError(t, err)
+	session, _, err := service.Login(ctx, "thiago@example.com", "correct horse")
+	require.NoError(t, err)
+
+	// Unknown emails are silently ignored
+	require.NoError(t, service.RequestPasswordReset(ctx, "unknown@example.com"))
+	assert.Empty(t, m.to)
+
+	require.NoError(t, service.RequestPasswordReset(ctx, "thiago@example.com"))
+	assert.Equal(t, "thiago@example.com", m.to)
+	_, token, found := strings.Cut(m.body, "https://app.example.com/reset?token=")
+	require.True(t, found)
+	token = strings.Fields(token)[0]
+
+	require.NoError(t, service.ResetPassword(ctx, token, "battery staple"))
+
+	// The token is single use and the previous sessions ended
+	assert.ErrorIs(t, service.ResetPassword(ctx, token, "another password"), ErrInvalidToken)
+	_, err = service.Authenticate(ctx, session)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, _, err = service.Login(ctx, "thiago@example.com", "correct horse")
+	assert.ErrorIs(t, err, ErrInvalidLogin)
+	_, _, err = service.Login(ctx, "thiago@example.com", "battery staple")
+	assert.NoError(t, err)
+
+	// Expired tokens are rejected
+	require.NoError(t, service.RequestPasswordReset(ctx, "thiago@example.com"))
+	_, token, _ = strings.Cut(m.body, "https://app.example.com/reset?token=")
+	*now = now.Add(resetTokenTTL)
+	assert.ErrorIs(t, service.ResetPassword(ctx, strings.Fields(token)[0], "third password"), ErrInvalidToken)
+}
+
+func Test_EmailVerification(t *testing.T) {
+	ctx := context.Background()
+	service, m, now := newTestService()
+	account, err := service.Signup(ctx, Signup{Email: "thiago@example.com", Password: "correct horse", Name: "Thiago"})
+	require.NoError(t, err)
+	assert.False(t, account.EmailVerified)
+
+	require.NoError(t, service.RequestEmailVerification(ctx, account))
+	assert.Equal(t, "thiago@example.com", m.to)
+	token := strings.Fields(strings.Split(m.body, "\n\n")[2])[0]
+
+	// The token is single use
+	require.NoError(t, service.VerifyEmail(ctx, token))
+	assert.ErrorIs(t, service.VerifyEmail(ctx, token), ErrInvalidToken)
+	account, err = service.Store.AccountByID(ctx, account.ID)
+	require.NoError(t, err)
+	assert.True(t, account.EmailVerified)
+
+	// The sessions of the account then carry its verified email
+	session, _, err := service.Login(ctx, "thiago@example.com", "correct horse")
+	require.NoError(t, err)
+	identity, err := Authenticator{Service: service}.Authenticate(bearer(session))
+	require.NoError(t, err)
+	assert.Equal(t, "thiago@example.com", identity.Email)
+
+	// Expired tokens are rejected
+	require.NoError(t, service.RequestEmailVerification(ctx, account))
+	*now = now.Add(verificationTokenTTL)
+	assert.ErrorIs(t, service.VerifyEmail(ctx, strings.Fields(strings.Split(m.body, "\n\n")[2])[0]), ErrInvalidToken)
+}
@@ -0,0 +1,46 @@
+package accounts
+
+import (
+	"code-challenge/pkg/auth"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Authenticator authenticates the requests carrying a session token as bearer token
+type Authenticator struct {
+	Service *Service
+}
+
+// Authenticate returns the identity of the account of the session.
+// The bearer tokens that are not session tokens are left to the other authenticators.
+func (a Authenticator) Authenticate(r *http.Request) (*auth.Identity, error) {
+	token, ok := SessionToken(r)
+	if !ok {
+		return nil, auth.ErrNoCredentials
+	}
+
+	account, err := a.Service.Authenticate(r.Context(), token)
+	if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: %v", auth.ErrInvalidCredentials, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	identity := &auth.Identity{Subject: account.ID, Name: account.Name, Locale: account.Locale, Scopes: []string{auth.ScopeChat}}
+	if account.EmailVerified {
+		identity.Email = account.Email
+	}
+	return identity, nil
+}
+
+// SessionToken returns the session token sent as bearer token
+func SessionToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	token = strings.TrimSpace(token)
+	if !ok || !strings.EqualFold(scheme, "Bearer") || !strings.HasPrefix(token, SessionPrefix) {
+		return "", false
+	}
+	return token, true
+}
@@ -0,0 +1,200 @@
+package accounts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// schema creates the account tables when they do not exist yet
+const schema = `
+CREATE TABLE IF NOT EXISTS accounts (
+	id            TEXT PRIMARY KEY,
+	email         TEXT NOT NULL UNIQUE,
+	name          TEXT NOT NULL DEFAULT '',
+	locale        TEXT NOT NULL DEFAULT '',
+	password_hash TEXT NOT NULL,
+	created_at    TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS sessions (
+	token_hash TEXT PRIMARY KEY,
+	account_id TEXT NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sessions_account_id ON sessions (account_id);
+CREATE TABLE IF NOT EXISTS password_resets (
+	token_hash TEXT PRIMARY KEY,
+	account_id TEXT NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT false;
+CREATE TABLE IF NOT EXISTS email_verifications (
+	token_hash TEXT PRIMARY KEY,
+	account_id TEXT NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+`
+
+// uniqueViolation is the Postgres error code of a unique constraint violation
+const uniqueViolation = "23505"
+
+// PostgresStore keeps the accounts in Postgres
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// OpenPostgres connects to the database and creates the account tables
+func OpenPostgres(ctx context.Context, url string) (*PostgresStore, error) {
+	db, err := sql.Open("pgx", url)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// CreateAccount records the account
+func (s *PostgresStore) CreateAccount(ctx context.Context, account *Account) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO accounts (id, email, name, locale, password_hash, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		account.ID, account.Email, account.Name, account.Locale, account.PasswordHash, account.CreatedAt)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return ErrEmailTaken
+	}
+	return err
+}
+
+// AccountByEmail returns the account registered with the email
+func (s *PostgresStore) AccountByEmail(ctx context.Context, email string) (*Account, error) {
+	return s.account(ctx, `WHERE email = $1`, email)
+}
+
+// AccountByID returns the account with the ID
+func (s *PostgresStore) AccountByID(ctx context.Context, id string) (*Account, error) {
+	return s.account(ctx, `WHERE id = $1`, id)
+}
+
+// account returns the account matching the condition
+func (s *PostgresStore) account(ctx context.Context, condition string, arg string) (*Account, error) {
+	var account Account
+	err := s.db.QueryRowContext(ctx, `SELECT id, email, name, locale, password_hash, created_at, email_verified FROM accounts `+condition, arg).
+		Scan(&account.ID, &account.Email, &account.Name, &account.Locale, &account.PasswordHash, &account.CreatedAt, &account.EmailVerified)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// UpdatePassword replaces the password hash of the account
+func (s *PostgresStore) UpdatePassword(ctx context.Context, id, passwordHash string) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE accounts SET password_hash = $2 WHERE id = $1`, id, passwordHash)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// VerifyEmail marks the email of the account as verified
+func (s *PostgresStore) VerifyEmail(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE accounts SET email_verified = true WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CreateSession records the session
+func (s *PostgresStore) CreateSession(ctx context.Context, session *Session) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO sessions (token_hash, account_id, expires_at) VALUES ($1, $2, $3)`,
+		session.TokenHash, session.AccountID, session.ExpiresAt)
+	return err
+}
+
+// SessionByHash returns the session with the token hash
+func (s *PostgresStore) SessionByHash(ctx context.Context, tokenHash string) (*Session, error) {
+	var session Session
+	err := s.db.QueryRowContext(ctx, `SELECT token_hash, account_id, expires_at FROM sessions WHERE token_hash = $1`, tokenHash).
+		Scan(&session.TokenHash, &session.AccountID, &session.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// DeleteSession deletes the session with the token hash
+func (s *PostgresStore) DeleteSession(ctx context.Context, tokenHash string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE token_hash = $1`, tokenHash)
+	return err
+}
+
+// DeleteSessions deletes every session of the account
+func (s *PostgresStore) DeleteSessions(ctx context.Context, accountID string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE account_id = $1`, accountID)
+	return err
+}
+
+// CreateResetToken records the reset token
+func (s *PostgresStore) CreateResetToken(ctx context.Context, token *ResetToken) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO password_resets (token_hash, account_id, expires_at) VALUES ($1, $2, $3)`,
+		token.TokenHash, token.AccountID, token.ExpiresAt)
+	return err
+}
+
+// ConsumeResetToken deletes the reset token and returns it, concurrent uses of the same token only succeed once
+func (s *PostgresStore) ConsumeResetToken(ctx context.Context, tokenHash string) (*ResetToken, error) {
+	var token ResetToken
+	err := s.db.QueryRowContext(ctx, `DELETE FROM password_resets WHERE token_hash = $1 RETURNING token_hash, account_id, expires_at`, tokenHash).
+		Scan(&token.TokenHash, &token.AccountID, &token.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// CreateVerificationToken records the verification token
+func (s *PostgresStore) CreateVerificationToken(ctx context.Context, token *VerificationToken) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO email_verifications (token_hash, account_id, expires_at) VALUES ($1, $2, $3)`,
+		token.TokenHash, token.AccountID, token.ExpiresAt)
+	return err
+}
+
+// ConsumeVerificationToken deletes the verification token and returns it, concurrent uses of the same token only succeed once
+func (s *PostgresStore) ConsumeVerificationToken(ctx context.Context, tokenHash string) (*VerificationToken, error) {
+	var token VerificationToken
+	err := s.db.QueryRowContext(ctx, `DELETE FROM email_verifications WHERE token_hash = $1 RETURNING token_hash, account_id, expires_at`, tokenHash).
+		Scan(&token.TokenHash, &token.AccountID, &token.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Close closes the database connections
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,212 @@
+package accounts
+
+import (
+	"context"
+	"sync"
+)
+
+// Store keeps the accounts, their sessions and their reset and verification tokens
+type Store interface {
+	// CreateAccount returns ErrEmailTaken when the email is already registered
+	CreateAccount(ctx context.Context, account *Account) error
+	AccountByEmail(ctx context.Context, email string) (*Account, error)
+	AccountByID(ctx context.Context, id string) (*Account, error)
+	UpdatePassword(ctx context.Context, id, passwordHash string) error
+	VerifyEmail(ctx context.Context, id string) error
+
+	CreateSession(ctx context.Context, session *Session) error
+	SessionByHash(ctx context.Context, tokenHash string) (*Session, error)
+	DeleteSession(ctx context.Context, tokenHash string) error
+	DeleteSessions(ctx context.Context, accountID string) error
+
+	CreateResetToken(ctx context.Context, token *ResetToken) error
+
+	// ConsumeResetToken deletes the token and returns it, so it can only be used once
+	ConsumeResetToken(ctx context.Context, tokenHash string) (*ResetToken, error)
+
+	CreateVerificationToken(ctx context.Context, token *VerificationToken) error
+
+	// ConsumeVerificationToken deletes the token and returns it, so it can only be used once
+	ConsumeVerificationToken(ctx context.Context, tokenHash string) (*VerificationToken, error)
+}
+
+// Open returns the Postgres store when the URL of the database is set and an in memory store otherwise
+func Open(ctx context.Context, url string) (Store, error) {
+	if url == "" {
+		return NewMemoryStore(), nil
+	}
+	return OpenPostgres(ctx, url)
+}
+
+// MemoryStore keeps the accounts in memory, they are lost when the API stops
+type MemoryStore struct {
+	mu                 sync.Mutex
+	accounts           map[string]Account
+	sessions           map[string]Session
+	resetTokens        map[string]ResetToken
+	verificationTokens map[string]VerificationToken
+}
+
+// NewMemoryStore creates an empty in memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		accounts:           map[string]Account{},
+		sessions:           map[string]Session{},
+		resetTokens:        map[string]ResetToken{},
+		verificationTokens: map[string]VerificationToken{},
+	}
+}
+
+// CreateAccount records the account
+func (s *MemoryStore) CreateAccount(_ context.Context, account *Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.accounts {
+		if existing.Email == account.Email {
+			return ErrEmailTaken
+		}
+	}
+	s.accounts[account.ID] = *account
+	return nil
+}
+
+// AccountByEmail returns the account registered with the email
+func (s *MemoryStore) AccountByEmail(_ context.Context, email string) (*Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, account := range s.accounts {
+		if account.Email == email {
+			return &account, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// AccountByID returns the account with the ID
+func (s *MemoryStore) AccountByID(_ context.Context, id string) (*Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &account, nil
+}
+
+// UpdatePassword replaces the password hash of the account
+func (s *MemoryStore) UpdatePassword(_ context.Context, id, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[id]
+	if !ok {
+		return ErrNotFound
+	}
+	account.PasswordHash = passwordHash
+	s.accounts[id] = account
+	return nil
+}
+
+// VerifyEmail marks the email of the account as verified
+func (s *MemoryStore) VerifyEmail(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[id]
+	if !ok {
+		return ErrNotFound
+	}
+	account.EmailVerified = true
+	s.accounts[id] = account
+	return nil
+}
+
+// CreateSession records the session
+func (s *MemoryStore) CreateSession(_ context.Context, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[session.TokenHash] = *session
+	return nil
+}
+
+// SessionByHash returns the session with the token hash
+func (s *MemoryStore) SessionByHash(_ context.Context, tokenHash string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[tokenHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &session, nil
+}
+
+// DeleteSession deletes the session with the token hash
+func (s *MemoryStore) DeleteSession(_ context.Context, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, tokenHash)
+	return nil
+}
+
+// DeleteSessions deletes every session of the account
+func (s *MemoryStore) DeleteSessions(_ context.Context, accountID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, session := range s.sessions {
+		if session.AccountID == accountID {
+			delete(s.sessions, hash)
+		}
+	}
+	return nil
+}
+
+// CreateResetToken records the reset token
+func (s *MemoryStore) CreateResetToken(_ context.Context, token *ResetToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.resetTokens[token.TokenHash] = *token
+	return nil
+}
+
+// ConsumeResetToken deletes the reset token and returns it
+func (s *MemoryStore) ConsumeResetToken(_ context.Context, tokenHash string) (*ResetToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.resetTokens[tokenHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(s.resetTokens, tokenHash)
+	return &token, nil
+}
+
+// CreateVerificationToken records the verification token
+func (s *MemoryStore) CreateVerificationToken(_ context.Context, token *VerificationToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.verificationTokens[token.TokenHash] = *token
+	return nil
+}
+
+// ConsumeVerificationToken deletes the verification token and returns it
+func (s *MemoryStore) ConsumeVerificationToken(_ context.Context, tokenHash string) (*VerificationToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.verificationTokens[tokenHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(s.verificationTokens, tokenHash)
+	return &token, nil
+}
@@ -0,0 +1,140 @@
+package answers
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SchemaName is the name of the answer schema sent to the model
+const SchemaName = "immigration_answer"
+
+// ErrInvalidAnswer is returned when the model output does not match the answer schema
+var ErrInvalidAnswer = errors.New("invalid structured answer")
+
+// rawSchema is the JSON schema every structured answer must match
+//
+//go:embed schema.json
+var rawSchema []byte
+
+// answerSchema is the parsed answer schema
+var answerSchema = mustParseSchema(rawSchema)
+
+// Step is a numbered step of the process the user has to follow
+type Step struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	Details string `json:"details"`
+}
+
+// Fee is an estimated fee of the process
+type Fee struct {
+	Description string  `json:"description"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+}
+
+// StructuredAnswer is an answer the frontend can render section by section
+type StructuredAnswer struct {
+	Summary           string   `json:"summary"`
+	Steps             []Step   `json:"steps"`
+	RequiredDocuments []string `json:"required_documents"`
+	EstimatedFees     []Fee    `json:"estimated_fees"`
+	EstimatedTimeline string   `json:"estimated_timeline"`
+	Disclaimer        string   `json:"disclaimer"`
+	FollowUpQuestions []string `json:"follow_up_questions"`
+}
+
+// mustParseSchema parses the embedded schema, the schema is part of the binary so errors are programming errors
+func mustParseSchema(content []byte) *schema {
+	var s schema
+	if err := json.Unmarshal(content, &s); err != nil {
+		panic(fmt.Errorf("answer schema: %w", err))
+	}
+	return &s
+}
+
+// Schema returns the answer schema as sent to the model
+func Schema() json.RawMessage {
+	content, err := json.Marshal(answerSchema.withoutValidationKeywords())
+	if err != nil {
+		panic(err)
+	}
+	return content
+}
+
+// Parse validates the model output against the answer schema and decodes it
+func Parse(content string) (*StructuredAnswer, error) {
+	// Decode numbers as json.Number so integers can be told apart from decimals
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("%w: not valid JSON: %v", ErrInvalidAnswer, err)
+	}
+	if err := answerSchema.validate(value, "answer"); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAnswer, err)
+	}
+
+	var answer StructuredAnswer
+	if err := json.Unmarshal([]byte(content), &answer); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAnswer, err)
+	}
+
+	// The schema cannot express that steps are numbered in order
+	for i, step := range answer.Steps {
+		if step.Number != i+1 {
+			return nil, fmt.Errorf("%w: answer.steps[%d].number must be %d", ErrInvalidAnswer, i, i+1)
+		}
+	}
+	return &answer, nil
+}
+
+// Text renders the answer as plain text for the clients that do not render the structured form
+func (a *StructuredAnswer) Text() string {
+	var b bytes.Buffer
+	b.WriteString(a.Summary)
+
+	if len(a.Steps) > 0 {
+		b.WriteString("\n\nSteps:")
+		for _, step := range a.Steps {
+			b.WriteString("\n" + strconv.Itoa(step.Number) + ". " + step.Title)
+			if step.Details != "" {
+				b.WriteString(": " + step.Details)
+			}
+		}
+	}
+
+	if len(a.RequiredDocuments) > 0 {
+		b.WriteString("\n\nRequired documents:")
+		for _, document := range a.RequiredDocuments {
+			b.WriteString("\n- " + document)
+		}
+	}
+
+	if len(a.EstimatedFees) > 0 {
+		b.WriteString("\n\nEstimated fees:")
+		for _, fee := range a.EstimatedFees {
+			b.WriteString(fmt.Sprintf("\n- %s: %s %s", fee.Description, strconv.FormatFloat(fee.Amount, 'f', -1, 64), fee.Currency))
+		}
+	}
+
+	if a.EstimatedTimeline != "" {
+		b.WriteString("\n\nEstimated timeline: " + a.EstimatedTimeline)
+	}
+
+	if len(a.FollowUpQuestions) > 0 {
+		b.WriteString("\n\nYou may also want to ask:")
+		for _, question := range a.FollowUpQuestions {
+			b.WriteString("\n- " + question)
+		}
+	}
+
+	b.WriteString("\n\n" + a.Disclaimer)
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package answers
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+const validAnswer = `{
+	"summary": "You can apply through Express Entry.",
+	"steps": [
+		{"number": 1, "title": "Take a language test", "details": "IELTS or CELPIP for English."},
+		{"number": 2, "title": "Create a profile", "details": ""}
+	],
+	"required_documents": ["Passport", "Language test results"],
+	"estimated_fees": [{"description": "Processing fee", "amount": 950, "currency": "CAD"}],
+	"estimated_timeline": "6 months",
+	"disclaimer": "This is not legal advice.",
+	"follow_up_questions": ["How is the CRS score calculated?"]
+}`
+
+func Test_Parse_Valid(t *testing.T) {
+	answer, err := Parse(validAnswer)
+	require.NoError(t, err)
+
+	assert.Equal(t, "You can apply through Express Entry.", answer.Summary)
+	assert.Len(t, answer.Steps, 2)
+	assert.Equal(t, 950.0, answer.EstimatedFees[0].Amount)
+
+	text := answer.Text()
+	assert.True(t, strings.HasPrefix(text, "You can apply through Express Entry."))
+	assert.Contains(t, text, "1. Take a language test: IELTS or CELPIP for English.")
+	assert.Contains(t, text, "2. Create a profile\n")
+	assert.Contains(t, text, "- Processing fee: 950 CAD")
+	assert.Contains(t, text, "Estimated timeline: 6 months")
+	assert.True(t, strings.HasSuffix(text, "This is not legal advice."))
+}
+
+func Test_Parse_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"not json":               `You can apply through Express Entry.`,
+		"missing field":          `{"summary": "ok"}`,
+		"unknown field":          strings.Replace(validAnswer, `"summary"`, `"extra": 1, "summary"`, 1),
+		"empty summary":          strings.Replace(validAnswer, `"You can apply through Express Entry."`, `""`, 1),
+		"decimal step number":    strings.Replace(validAnswer, `"number": 1`, `"number": 1.5`, 1),
+		"steps out of order":     strings.Replace(validAnswer, `"number": 2`, `"number": 3`, 1),
+		"negative fee":           strings.Replace(validAnswer, `"amount": 950`, `"amount": -1`, 1),
+		"invalid currency":       strings.Replace(validAnswer, `"CAD"`, `"dollars"`, 1),
+		"wrong type":             strings.Replace(validAnswer, `["Passport", "Language test results"]`, `"Passport"`, 1),
+		"too many follow-ups":    strings.Replace(validAnswer, `["How is the CRS score calculated?"]`, `["a", "b", "c", "d", "e", "f"]`, 1),
+		"missing step attribute": strings.Replace(validAnswer, `"title": "Create a profile", `, ``, 1),
+	}
+
+	for name, content := range tests {
+		_, err := Parse(content)
+		assert.ErrorIs(t, err, ErrInvalidAnswer, name)
+	}
+}
+
+func Test_Schema(t *testing.T) {
+	var model map[string]any
+	require.NoError(t, json.Unmarshal(Schema(), &model))
+
+	// The schema sent to the model keeps the structure without the validation only keywords
+	assert.Len(t, model["required"], 7)
+	assert.NotContains(t, string(Schema()), "minLength")
+	assert.NotContains(t, string(Schema()), "maxItems")
+	assert.Contains(t, string(Schema()), "ISO 4217 currency code.")
+}
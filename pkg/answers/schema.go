@@ -0,0 +1,139 @@
+package answers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// schema is the subset of JSON schema used by the answer schema
+type schema struct {
+	Type                 string             `json:"type"`
+	Description          string             `json:"description,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+// withoutValidationKeywords returns a copy of the schema without the keywords
+// the model provider does not accept in strict mode, they are still enforced by validate
+func (s *schema) withoutValidationKeywords() *schema {
+	if s == nil {
+		return nil
+	}
+
+	stripped := *s
+	stripped.MinLength, stripped.MaxLength, stripped.Minimum, stripped.MaxItems = nil, nil, nil, nil
+	stripped.Items = s.Items.withoutValidationKeywords()
+	if s.Properties != nil {
+		stripped.Properties = map[string]*schema{}
+		for name, property := range s.Properties {
+			stripped.Properties[name] = property.withoutValidationKeywords()
+		}
+	}
+	return &stripped
+}
+
+// validate checks the decoded JSON value against the schema, path locates the value in error messages
+func (s *schema) validate(value any, path string) error {
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s must be an object", path)
+		}
+		return s.validateObject(object, path)
+
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s must be an array", path)
+		}
+		if s.MaxItems != nil && len(array) > *s.MaxItems {
+			return fmt.Errorf("%s must have at most %d items", path, *s.MaxItems)
+		}
+		for i, item := range array {
+			if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", path)
+		}
+		if s.MinLength != nil && utf8.RuneCountInString(str) < *s.MinLength {
+			return fmt.Errorf("%s must have at least %d characters", path, *s.MinLength)
+		}
+		if s.MaxLength != nil && utf8.RuneCountInString(str) > *s.MaxLength {
+			return fmt.Errorf("%s must have at most %d characters", path, *s.MaxLength)
+		}
+		return nil
+
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s must be a %s", path, s.Type)
+		}
+		if s.Type == "integer" {
+			if _, err := number.Int64(); err != nil {
+				return fmt.Errorf("%s must be an integer", path)
+			}
+		}
+		float, err := number.Float64()
+		if err != nil {
+			return fmt.Errorf("%s must be a number", path)
+		}
+		if s.Minimum != nil && float < *s.Minimum {
+			return fmt.Errorf("%s must be at least %v", path, *s.Minimum)
+		}
+		return nil
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", path)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("%s has unsupported schema type %q", path, s.Type)
+	}
+}
+
+// validateObject checks the required and the additional properties of an object
+func (s *schema) validateObject(object map[string]any, path string) error {
+	for _, name := range s.Required {
+		if _, ok := object[name]; !ok {
+			return fmt.Errorf("%s.%s is required", path, name)
+		}
+	}
+
+	// Validate the properties in a stable order so the same input always reports the same error
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property, ok := s.Properties[name]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				return fmt.Errorf("%s.%s is not allowed", path, name)
+			}
+			continue
+		}
+		if err := property.validate(object[name], path+"."+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
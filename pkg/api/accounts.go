@@ -0,0 +1,174 @@
+package main
+
+import (
+	"code-challenge/pkg/accounts"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// loginInput is the body of the login route
+type loginInput struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// loginOutput is the session returned by the login route
+type loginOutput struct {
+	Token   string            `json:"token"`
+	Account *accounts.Account `json:"account"`
+}
+
+// passwordResetInput is the body of the password reset request route
+type passwordResetInput struct {
+	Email string `json:"email"`
+}
+
+// passwordResetConfirmInput is the body of the password reset confirmation route
+type passwordResetConfirmInput struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// emailVerificationConfirmInput is the body of the email verification confirmation route
+type emailVerificationConfirmInput struct {
+	Token string `json:"token"`
+}
+
+// signupHandler creates an account
+func (s *Server) signupHandler(w http.ResponseWriter, r *http.Request) {
+	var input accounts.Signup
+	if problem := decodeJSON(w, r, &input); problem != nil {
+		writeProblem(w, r, problem)
+		return
+	}
+
+	account, err := s.Accounts.Signup(r.Context(), input)
+	if err != nil {
+		writeAccountError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, account)
+}
+
+// loginHandler checks the password of the account and returns a session token
+func (s *Server) loginHandler(w http.ResponseWriter, r *http.Request) {
+	var input loginInput
+	if problem := decodeJSON(w, r, &input); problem != nil {
+		writeProblem(w, r, problem)
+		return
+	}
+
+	token, account, err := s.Accounts.Login(r.Context(), input.Email, input.Password)
+	if err != nil {
+		writeAccountError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, loginOutput{Token: token, Account: account})
+}
+
+// logoutHandler ends the session of the request
+func (s *Server) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	token, ok := accounts.SessionToken(r)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "Only sessions can be logged out")
+		return
+	}
+
+	if err := s.Accounts.Logout(r.Context(), token); err != nil {
+		writeAccountError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// passwordResetHandler emails a reset token, it always succeeds so the existence of accounts is not disclosed
+func (s *Server) passwordResetHandler(w http.ResponseWriter, r *http.Request) {
+	var input passwordResetInput
+	if problem := decodeJSON(w, r, &input); problem != nil {
+		writeProblem(w, r, problem)
+		return
+	}
+
+	if err := s.Accounts.RequestPasswordReset(r.Context(), input.Email); err != nil {
+		writeAccountError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// passwordResetConfirmHandler sets the new password with a reset token
+func (s *Server) passwordResetConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	var input passwordResetConfirmInput
+	if problem := decodeJSON(w, r, &input); problem != nil {
+		writeProblem(w, r, problem)
+		return
+	}
+
+	if err := s.Accounts.ResetPassword(r.Context(), input.Token, input.Password); err != nil {
+		writeAccountError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// emailVerificationHandler emails a verification token to the account of the session
+func (s *Server) emailVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	token, ok := accounts.SessionToken(r)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "Only sessions can verify their email")
+		return
+	}
+
+	account, err := s.Accounts.Authenticate(r.Context(), token)
+	if err == nil {
+		err = s.Accounts.RequestEmailVerification(r.Context(), account)
+	}
+	if err != nil {
+		writeAccountError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// emailVerificationConfirmHandler marks the email of the account of the verification token as verified
+func (s *Server) emailVerificationConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	var input emailVerificationConfirmInput
+	if problem := decodeJSON(w, r, &input); problem != nil {
+		writeProblem(w, r, problem)
+		return
+	}
+
+	if err := s.Accounts.VerifyEmail(r.Context(), input.Token); err != nil {
+		writeAccountError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeAccountError maps the errors of the accounts service to problems
+func writeAccountError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, accounts.ErrInvalidInput):
+		writeError(w, r, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, accounts.ErrEmailTaken):
+		writeError(w, r, http.StatusConflict, "The email is already registered")
+	case errors.Is(err, accounts.ErrInvalidLogin):
+		writeError(w, r, http.StatusUnauthorized, "The email or the password is not valid")
+	case errors.Is(err, accounts.ErrInvalidToken):
+		writeError(w, r, http.StatusBadRequest, "The token is not valid or has expired")
+	default:
+		slog.ErrorContext(r.Context(), "Accounts error", "Error", err)
+		writeError(w, r, http.StatusInternalServerError, "The request could not be processed")
+	}
+}
+
+// writeJSON writes the value as the JSON response with the status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Error parsing response to api", "Error", err)
+	}
+}
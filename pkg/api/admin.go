@@ -0,0 +1,64 @@
+package main
+
+import (
+	"code-challenge/pkg/search"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// Page sizes of the conversation search
+const (
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 100
+)
+
+// conversationWorkflowTypes are the workflows of the conversations: the questions and the questions queued during outages
+var conversationWorkflowTypes = []string{"ChatBotWorkflow", "DeferredAnswerWorkflow"}
+
+// conversationPage is a page of the conversations found by the search
+type conversationPage struct {
+	Conversations []search.Conversation `json:"conversations"`
+
+	// NextPageToken is passed as page_token to read the next page, it is empty on the last page
+	NextPageToken string `json:"next_page_token,omitempty"`
+}
+
+// searchConversationsHandler lists the conversations matching the filters of the query with the Temporal visibility,
+// such as ?query=user:alice outcome:failed country:canada from:2024-05-01. The API keys only find the conversations
+// of the users of their organization.
+func (s *Server) searchConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter, err := search.ParseQuery(query.Get("query"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.WorkflowTypes = conversationWorkflowTypes
+	if organization := callerOrganization(r); organization != "" {
+		filter.UserPrefix = organization + ":"
+	}
+
+	pageSize := defaultSearchPageSize
+	if value := query.Get("page_size"); value != "" {
+		pageSize, err = strconv.Atoi(value)
+		if err != nil || pageSize < 1 || pageSize > maxSearchPageSize {
+			writeError(w, r, http.StatusBadRequest, "page_size must be between 1 and "+strconv.Itoa(maxSearchPageSize))
+			return
+		}
+	}
+	pageToken, err := base64.RawURLEncoding.DecodeString(query.Get("page_token"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "page_token must be the next_page_token of the previous page")
+		return
+	}
+
+	conversations, next, err := search.List(r.Context(), s.Temporal, filter, pageSize, pageToken)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Unable to search conversations", "Query", filter.Query(), "Error", err)
+		writeProblem(w, r, searchProblem(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, conversationPage{Conversations: conversations, NextPageToken: base64.RawURLEncoding.EncodeToString(next)})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"code-challenge/pkg/apikeys"
+	"code-challenge/pkg/auth"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// apiKeyOutput is a key with its secret, returned once when the key is created or rotated
+type apiKeyOutput struct {
+	Secret string       `json:"secret"`
+	Key    *apikeys.Key `json:"key"`
+}
+
+// createAPIKeyHandler creates a key, the admin keys of an organization only create keys of their organization
+func (s *Server) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var input apikeys.NewKey
+	if problem := decodeJSON(w, r, &input); problem != nil {
+		writeProblem(w, r, problem)
+		return
+	}
+
+	if organization := callerOrganization(r); organization != "" {
+		if input.Organization != "" && input.Organization != organization {
+			writeError(w, r, http.StatusForbidden, "Keys cannot be created for another organization")
+			return
+		}
+		input.Organization = organization
+	}
+
+	secret, key, err := s.APIKeys.Create(r.Context(), input)
+	if err != nil {
+		writeAPIKeyError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, apiKeyOutput{Secret: secret, Key: key})
+}
+
+// listAPIKeysHandler returns the keys of the organization of the query, or of the caller when it is an API key
+func (s *Server) listAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	organization := r.URL.Query().Get("organization")
+	if caller := callerOrganization(r); caller != "" {
+		organization = caller
+	}
+
+	keys, err := s.APIKeys.List(r.Context(), organization)
+	if err != nil {
+		writeAPIKeyError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// revokeAPIKeyHandler revokes the key of the route
+func (s *Server) revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.canManageKey(w, r) {
+		return
+	}
+
+	if _, err := s.APIKeys.Revoke(r.Context(), r.PathValue("id")); err != nil {
+		writeAPIKeyError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// rotateAPIKeyHandler replaces the secret of the key of the route and returns the new one
+func (s *Server) rotateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.canManageKey(w, r) {
+		return
+	}
+
+	secret, key, err := s.APIKeys.Rotate(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIKeyError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, apiKeyOutput{Secret: secret, Key: key})
+}
+
+// canManageKey checks the key of the route exists and belongs to the organization of the caller when it is an API key.
+// The keys of the other organizations are reported as not found.
+func (s *Server) canManageKey(w http.ResponseWriter, r *http.Request) bool {
+	key, err := s.APIKeys.Get(r.Context(), r.PathValue("id"))
+	if err == nil {
+		if organization := callerOrganization(r); organization != "" && key.Organization != organization {
+			err = apikeys.ErrNotFound
+		}
+	}
+	if err != nil {
+		writeAPIKeyError(w, r, err)
+		return false
+	}
+	return true
+}
+
+// callerOrganization returns the organization of the API key of the request, it is empty for the users
+func callerOrganization(r *http.Request) string {
+	if identity, ok := auth.FromContext(r.Context()); ok && identity.KeyID != "" {
+		return identity.Organization
+	}
+	return ""
+}
+
+// writeAPIKeyError maps the errors of the API keys service to problems
+func writeAPIKeyError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, apikeys.ErrInvalidInput):
+		writeError(w, r, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, apikeys.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, "The API key does not exist")
+	default:
+		slog.ErrorContext(r.Context(), "API keys error", "Error", err)
+		writeError(w, r, http.StatusInternalServerError, "The request could not be processed")
+	}
+}
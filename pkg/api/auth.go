@@ -0,0 +1,132 @@
+package main
+
+import (
+	"code-challenge/pkg/auth"
+	"code-challenge/pkg/ratelimit"
+	"code-challenge/pkg/usage"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// requireAuth rejects the requests without valid credentials and adds the identity of the caller to the request context.
+// The requests are not authenticated when the server has no authenticator.
+func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.Auth == nil {
+			next(w, r)
+			return
+		}
+
+		identity, err := s.Auth.Authenticate(r)
+		switch {
+		case errors.Is(err, auth.ErrNoCredentials):
+			w.Header().Set("WWW-Authenticate", `Bearer realm="chatbot"`)
+			w.Header().Add("WWW-Authenticate", `ApiKey realm="chatbot"`)
+			writeError(w, r, http.StatusUnauthorized, "Authentication is required")
+			return
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			w.Header().Set("WWW-Authenticate", `Bearer realm="chatbot", error="invalid_token"`)
+			writeError(w, r, http.StatusUnauthorized, "The credentials are not valid")
+			return
+		case err != nil:
+			slog.ErrorContext(r.Context(), "Unable to authenticate request", "Error", err)
+			writeError(w, r, http.StatusServiceUnavailable, "Unable to authenticate the request")
+			return
+		}
+
+		next(w, r.WithContext(auth.WithIdentity(r.Context(), identity)))
+	}
+}
+
+// requireScope authenticates the request and rejects the callers that were not granted the scope.
+// Without authenticator only the chat scope is open, the admin and analytics routes are refused to the anonymous callers.
+// The calls of the API keys are limited to the rate of the key and accounted to its organization.
+func (s *Server) requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return s.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := auth.FromContext(r.Context())
+		if !ok && scope != auth.ScopeChat {
+			writeError(w, r, http.StatusForbidden, "The "+scope+" scope requires authentication, which is disabled")
+			return
+		}
+		if !ok {
+			next(w, r)
+			return
+		}
+
+		if identity.KeyID != "" {
+			recorder := &statusRecorder{ResponseWriter: w}
+			defer s.recordUsage(r, identity, recorder)
+			w = recorder
+		}
+
+		if !identity.HasScope(scope) {
+			writeError(w, r, http.StatusForbidden, "The credentials were not granted the "+scope+" scope")
+			return
+		}
+
+		// The API keys have their own rate, the users share the rate of the users
+		if identity.KeyID != "" && identity.RateLimit > 0 && !s.allow(w, r, "apikey:"+identity.KeyID, ratelimit.PerMinute(identity.RateLimit)) {
+			return
+		}
+		if identity.KeyID == "" && !s.allow(w, r, "user:"+identity.Subject, s.UserLimit) {
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// recordUsage accounts the call to the organization of the key, failures do not fail the request
+func (s *Server) recordUsage(r *http.Request, identity *auth.Identity, recorder *statusRecorder) {
+	if s.Usage == nil {
+		return
+	}
+
+	// The usage is recorded even when the client disconnected
+	err := s.Usage.Record(context.WithoutCancel(r.Context()), usage.Record{
+		Organization: identity.Organization,
+		KeyID:        identity.KeyID,
+		Route:        r.Pattern,
+		Status:       recorder.Status(),
+		CreatedAt:    time.Now().UTC(),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Unable to record usage", "Organization", identity.Organization, "Error", err)
+	}
+}
+
+// statusRecorder remembers the status of the response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap gives the http.ResponseController access to the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Status returns the status of the response, 200 when nothing was written
+func (r *statusRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
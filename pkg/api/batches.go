@@ -0,0 +1,111 @@
+package main
+
+import (
+	"code-challenge/pkg/batch"
+	"code-challenge/pkg/operations"
+	codingchallenge "code-challenge/pkg/workflow"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go.temporal.io/api/serviceerror"
+	"net/http"
+	"strings"
+)
+
+// batchInput is the body of a batch of questions, the locations are paths in the batch directory of the workers
+// or http and https URLs of an object store
+type batchInput struct {
+	Input       string `json:"input"`
+	Output      string `json:"output"`
+	Concurrency int    `json:"concurrency"`
+}
+
+// batchOutput is the BatchQuestionsWorkflow answering the questions of the batch
+type batchOutput struct {
+	BatchID     string `json:"batch_id"`
+	Input       string `json:"input"`
+	Output      string `json:"output"`
+	Concurrency int    `json:"concurrency"`
+}
+
+// createBatchHandler starts a workflow answering the questions of the JSON lines input and writing their results
+// to the output. The questions of the API keys are asked for the users of their organization.
+func (s *Server) createBatchHandler(w http.ResponseWriter, r *http.Request) {
+	var input batchInput
+	if problem := decodeJSON(w, r, &input); problem != nil {
+		writeProblem(w, r, problem)
+		return
+	}
+	input.Input, input.Output = strings.TrimSpace(input.Input), strings.TrimSpace(input.Output)
+	if input.Concurrency == 0 {
+		input.Concurrency = codingchallenge.DefaultBatchConcurrency
+	}
+	organization := callerOrganization(r)
+	if problem := input.validate(organization != "", s.BatchHosts); problem != nil {
+		writeProblem(w, r, problem)
+		return
+	}
+
+	batchID := "batch-" + uuid.NewString()
+	workflowInput := codingchallenge.BatchInput{Input: input.Input, Output: input.Output, Concurrency: input.Concurrency}
+	if organization != "" {
+		batchID = organization + ":" + batchID
+		workflowInput.UserPrefix = organization + ":"
+	}
+	if err := operations.StartQuestionBatch(r.Context(), s.Temporal, s.TaskQueue, batchID, workflowInput); err != nil {
+		writeOperationError(w, r, err, "The batch does not exist")
+		return
+	}
+	w.Header().Set("Location", "/v1/batches/"+batchID)
+	writeJSON(w, http.StatusAccepted, batchOutput{BatchID: batchID, Input: input.Input, Output: input.Output, Concurrency: input.Concurrency})
+}
+
+// batchHandler returns the status and the progress of the batch of the route, the API keys only read the batches of their organization
+func (s *Server) batchHandler(w http.ResponseWriter, r *http.Request) {
+	const notFound = "The batch does not exist"
+	batchID := r.PathValue("id")
+	if organization := callerOrganization(r); organization != "" && !strings.HasPrefix(batchID, organization+":") {
+		writeError(w, r, http.StatusNotFound, notFound)
+		return
+	}
+
+	questions, err := operations.DescribeQuestionBatch(r.Context(), s.Temporal, batchID)
+	if errors.Is(err, operations.ErrNotQuestionBatch) {
+		err = serviceerror.NewNotFound(err.Error())
+	}
+	if err != nil {
+		writeOperationError(w, r, err, notFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, questions)
+}
+
+// validate checks the locations and the concurrency of the batch, the URLs must be on the allowed hosts.
+// The API keys only read and write URLs as the batch directory is shared by the organizations.
+func (input batchInput) validate(urlsOnly bool, hosts []string) *Problem {
+	var errs []FieldError
+	for _, field := range []struct{ name, location string }{{"input", input.Input}, {"output", input.Output}} {
+		switch {
+		case field.location == "":
+			errs = append(errs, FieldError{Field: field.name, Detail: "must not be empty"})
+		case batch.ValidLocation(field.location) != nil:
+			errs = append(errs, FieldError{Field: field.name, Detail: batch.ValidLocation(field.location).Error()})
+		case urlsOnly && !batch.IsURL(field.location):
+			errs = append(errs, FieldError{Field: field.name, Detail: "must be an http or https URL"})
+		case batch.AllowedHost(field.location, hosts) != nil:
+			errs = append(errs, FieldError{Field: field.name, Detail: "must be a URL of one of the allowed hosts: " + strings.Join(hosts, ", ")})
+		}
+	}
+	if input.Input != "" && input.Input == input.Output {
+		errs = append(errs, FieldError{Field: "output", Detail: "must differ from the input"})
+	}
+	if input.Concurrency < 1 || input.Concurrency > codingchallenge.MaxBatchConcurrency {
+		errs = append(errs, FieldError{Field: "concurrency", Detail: fmt.Sprintf("must be between 1 and %d", codingchallenge.MaxBatchConcurrency)})
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	problem := newProblem(http.StatusUnprocessableEntity, "The request has invalid fields")
+	problem.Errors = errs
+	return problem
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"code-challenge/pkg/calculators"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// Handles the calculator requests, the request body is the calculator input and the response is the computed score
+func calculatorHandler(w http.ResponseWriter, r *http.Request) {
+	// Find the calculator named in the route
+	calculator, err := calculators.Get(r.PathValue("name"))
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+
+	// Read the calculator input from the request body, it is validated by the calculator
+	var input json.RawMessage
+	if problem := decodeJSON(w, r, &input); problem != nil {
+		writeProblem(w, r, problem)
+		return
+	}
+
+	// Compute the score, invalid inputs are reported to the caller
+	result, err := calculator.Calculate(input)
+	if errors.Is(err, calculators.ErrInvalidInput) {
+		writeError(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Unable to run calculator", "Error", err)
+		writeError(w, r, http.StatusInternalServerError, "Unable to run calculator")
+		return
+	}
+
+	// Encode the result into the response writer as JSON
+	w.Header().Add("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		slog.ErrorContext(r.Context(), "Error parsing response to api", "Error", err)
+	}
+}
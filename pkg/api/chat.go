@@ -0,0 +1,237 @@
+package main
+
+import (
+	"code-challenge/pkg/answers"
+	"code-challenge/pkg/auth"
+	"code-challenge/pkg/conversations"
+	"code-challenge/pkg/logging"
+	"code-challenge/pkg/notify"
+	codingchallenge "code-challenge/pkg/workflow"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	client2 "go.temporal.io/sdk/client"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ChatBotRequestInput is the body of the chat routes
+type ChatBotRequestInput struct {
+	Question string `json:"question"`
+
+	// User is taken from the credentials when the request is authenticated, it may then be omitted
+	User   string `json:"user"`
+	Format string `json:"format"`
+
+	// Notify is where the answer is delivered when the question is queued during an outage
+	Notify notify.Channel `json:"notify"`
+}
+
+// chatHandler answers the questions of the deprecated /chat route with the answer of the workflow
+func (s *Server) chatHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", `</v1/chat>; rel="successor-version"`)
+
+	result, _, ok := s.answer(w, r)
+	if !ok {
+		return
+	}
+
+	// Set the response content type to application/json
+	w.Header().Add("content-type", "application/json")
+
+	// Encode the result into the response writer as JSON
+	err := json.NewEncoder(w).Encode(result)
+
+	// Check if there was an error encoding the response
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error parsing response to api", "Error", err)
+	}
+}
+
+// chatV1Handler answers the question of the request
+func (s *Server) chatV1Handler(w http.ResponseWriter, r *http.Request) {
+	result, id, ok := s.answer(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, newChatResponse(id, result))
+}
+
+// ChatResponse is the answer returned by POST /v1/chat
+type ChatResponse struct {
+	// ID identifies the question, it is the ID of its workflow
+	ID     string `json:"id"`
+	User   string `json:"user"`
+	Answer string `json:"answer"`
+
+	// Status is answered, or queued when the answer is delivered later by the deferred workflow
+	Status             string `json:"status"`
+	DeferredWorkflowID string `json:"deferred_workflow_id,omitempty"`
+
+	// Structured is set when the structured format was requested and the model output matched the schema
+	Structured *answers.StructuredAnswer `json:"structured,omitempty"`
+
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+}
+
+// newChatResponse returns the response of the answer of the workflow
+func newChatResponse(id string, answer *codingchallenge.ChatBotAnswer) ChatResponse {
+	if answer == nil {
+		return ChatResponse{ID: id}
+	}
+	return ChatResponse{
+		ID:                 id,
+		User:               answer.User,
+		Answer:             answer.Answer,
+		Status:             answer.Status,
+		DeferredWorkflowID: answer.DeferredWorkflowID,
+		Structured:         answer.Structured,
+		Provider:           answer.Provider,
+		Model:              answer.Model,
+	}
+}
+
+// answer executes the temporal workflow passing the question and user of the request as input and waits for its answer.
+// The problems are written to the response, ok is false when the request was answered with one.
+func (s *Server) answer(w http.ResponseWriter, r *http.Request) (result *codingchallenge.ChatBotAnswer, id string, ok bool) {
+	// Only questions sent as a JSON body are accepted
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, r, http.StatusMethodNotAllowed, "Questions must be sent with POST")
+		return nil, "", false
+	}
+
+	// Decode the incoming JSON request body into a ChatBotRequestInput struct and validate it
+	var chatBotRequest ChatBotRequestInput
+	if problem := decodeJSON(w, r, &chatBotRequest); problem != nil {
+		writeProblem(w, r, problem)
+		return nil, "", false
+	}
+
+	// The authenticated user asks the question, the body cannot name another one.
+	// The partner apps ask on behalf of their users, named "{organization}:{user}" to keep the organizations apart.
+	identity, authenticated := auth.FromContext(r.Context())
+	switch {
+	case authenticated && identity.KeyID != "" && strings.TrimSpace(chatBotRequest.User) != "":
+		chatBotRequest.User = identity.Subject + ":" + strings.TrimSpace(chatBotRequest.User)
+	case authenticated && chatBotRequest.User != "" && chatBotRequest.User != identity.Subject:
+		writeError(w, r, http.StatusForbidden, "Questions cannot be asked on behalf of another user")
+		return nil, "", false
+	case authenticated:
+		chatBotRequest.User = identity.Subject
+	}
+
+	var verifiedEmail string
+	if authenticated {
+		verifiedEmail = identity.Email
+	}
+	if problem := chatBotRequest.validate(s.WebhookHosts, verifiedEmail); problem != nil {
+		writeProblem(w, r, problem)
+		return nil, "", false
+	}
+
+	// Define workflow options including a unique ID per question and the TaskQueue
+	// The memo shows the request ID in the Temporal UI
+	requestID := logging.CorrelationFrom(r.Context()).RequestID
+	wfOpts := client2.StartWorkflowOptions{
+		ID:        "chat_bot_workflow_" + uuid.NewString(),
+		TaskQueue: s.TaskQueue,
+		Memo:      map[string]interface{}{logging.MemoRequestID: requestID},
+	}
+
+	// The span of the request links the trace to the workflow, the Temporal interceptor continues it in the worker
+	trace.SpanFromContext(r.Context()).SetAttributes(
+		attribute.String("temporal.workflow_id", wfOpts.ID),
+		attribute.String("temporal.task_queue", wfOpts.TaskQueue),
+	)
+
+	question := codingchallenge.ChatBotQuestion{
+		Question: strings.TrimSpace(chatBotRequest.Question),
+		User:     strings.TrimSpace(chatBotRequest.User),
+		Format:   chatBotRequest.Format,
+		Notify:   chatBotRequest.Notify,
+	}
+	if question.Notify.Email != "" {
+		question.Notify.Email = verifiedEmail
+	}
+
+	// The question is answered synchronously: the workflow is bound to the request and canceled with it.
+	// The request ID and the user are propagated in the headers of the workflow to the logs of the worker.
+	ctx, cancel := context.WithTimeout(logging.WithUser(r.Context(), question.User), s.HTTP.AnswerTimeout)
+	defer cancel()
+
+	// The profile of the authenticated user personalizes the answer
+	if authenticated {
+		question.Name, question.Locale = identity.Name, identity.Locale
+	}
+
+	// Execute the workflow with the provided question and user from the request
+	wr, err := s.Temporal.ExecuteWorkflow(ctx, wfOpts, codingchallenge.ChatBotWorkflow, question)
+
+	// Check if there was an error executing the workflow
+	if err != nil {
+		slog.ErrorContext(ctx, "Unable to execute workflow", "WorkflowID", wfOpts.ID, "Error", err)
+		writeProblem(w, r, workflowProblem(err))
+		return nil, "", false
+	}
+
+	// Retrieve the result of the workflow execution
+	err = wr.Get(ctx, &result)
+
+	// The client disconnected or the deadline passed, stop the workflow so it does not keep calling the model
+	if ctx.Err() != nil {
+		s.cancelWorkflow(ctx, wr)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			writeError(w, r, http.StatusGatewayTimeout, "The question could not be answered in time")
+		}
+		return nil, "", false
+	}
+
+	// Check if there was an error getting the workflow result
+	if err != nil {
+		slog.ErrorContext(ctx, "Unable to get workflow result", "WorkflowID", wr.GetID(), "Error", err)
+		writeProblem(w, r, workflowProblem(err))
+		return nil, "", false
+	}
+
+	// Authenticated users keep the history of their questions, the queued ones are recorded by the DeferredAnswerWorkflow
+	if authenticated && result != nil && result.Status == codingchallenge.StatusAnswered {
+		s.saveHistory(ctx, wr.GetID(), question, result)
+	}
+
+	return result, wfOpts.ID, true
+}
+
+// cancelWorkflow requests the cancellation of the workflow, the request context is already done so it is detached from it
+func (s *Server) cancelWorkflow(ctx context.Context, wr client2.WorkflowRun) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelTimeout)
+	defer cancel()
+
+	slog.InfoContext(ctx, "Canceling workflow", "WorkflowID", wr.GetID())
+	if err := s.Temporal.CancelWorkflow(ctx, wr.GetID(), wr.GetRunID()); err != nil {
+		slog.ErrorContext(ctx, "Unable to cancel workflow", "WorkflowID", wr.GetID(), "Error", err)
+	}
+}
+
+// saveHistory records the answered question in the conversation history of the user, failures do not fail the request
+func (s *Server) saveHistory(ctx context.Context, id string, question codingchallenge.ChatBotQuestion, answer *codingchallenge.ChatBotAnswer) {
+	err := s.Store.Save(ctx, conversations.Entry{
+		ID:        id,
+		User:      question.User,
+		Question:  question.Question,
+		Answer:    answer.Answer,
+		Status:    answer.Status,
+		Model:     answer.Model,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Unable to save conversation history", "WorkflowID", id, "Error", err)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"code-challenge/pkg/auth"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// historyHandler returns the conversation history of the user named in the route, oldest first
+func (s *Server) historyHandler(w http.ResponseWriter, r *http.Request) {
+	// Authenticated users only read their own history, the partner apps the history of their users
+	user := r.PathValue("user")
+	if identity, ok := auth.FromContext(r.Context()); ok && !identity.Owns(user) {
+		writeError(w, r, http.StatusForbidden, "The history of another user cannot be read")
+		return
+	}
+
+	history, err := s.Store.History(r.Context(), user)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Unable to read conversation history", "Error", err)
+		writeError(w, r, http.StatusInternalServerError, "Unable to read conversation history")
+		return
+	}
+
+	// Encode the history into the response writer as JSON
+	w.Header().Add("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(history); err != nil {
+		slog.ErrorContext(r.Context(), "Error parsing response to api", "Error", err)
+	}
+}
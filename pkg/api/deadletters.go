@@ -0,0 +1,166 @@
+package main
+
+import (
+	"code-challenge/pkg/audit"
+	"code-challenge/pkg/deadletter"
+	"code-challenge/pkg/openai"
+	"code-challenge/pkg/operations"
+	codingchallenge "code-challenge/pkg/workflow"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Number of dead letters listed
+const (
+	defaultDeadLetterLimit = 50
+	maxDeadLetterLimit     = 200
+)
+
+// replayInput is the body of the replay of a dead letter, the empty model and prompt version keep the ones of the question
+type replayInput struct {
+	Reason        string `json:"reason"`
+	Model         string `json:"model"`
+	PromptVersion string `json:"prompt_version"`
+}
+
+// replayOutput is the workflow asking the question of the dead letter again
+type replayOutput struct {
+	WorkflowID string `json:"workflow_id"`
+}
+
+// deadLettersHandler lists the questions that could not be answered matching the user, error_type, from, to and pending
+// filters of the query, most recent failure first. The API keys only read the questions of the users of their organization.
+func (s *Server) deadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := deadletter.Filter{
+		User:      query.Get("user"),
+		ErrorType: query.Get("error_type"),
+		Pending:   query.Get("pending") == "true",
+		Limit:     defaultDeadLetterLimit,
+	}
+	if organization := callerOrganization(r); organization != "" {
+		filter.UserPrefix = organization + ":"
+	}
+
+	// The period is open when from or to is not set, to is inclusive
+	for name, date := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.DateOnly, value)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, name+" must be a date formatted as YYYY-MM-DD")
+				return
+			}
+			*date = parsed
+		}
+	}
+	if !filter.To.IsZero() {
+		filter.To = filter.To.Add(24 * time.Hour)
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxDeadLetterLimit {
+			writeError(w, r, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxDeadLetterLimit))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	letters, err := s.DeadLetters.List(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Unable to list dead letters", "Error", err)
+		writeError(w, r, http.StatusInternalServerError, "Unable to read the dead letters")
+		return
+	}
+	writeJSON(w, http.StatusOK, letters)
+}
+
+// deadLetterHandler returns the dead letter of the route with the input of its workflow
+func (s *Server) deadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	letter, ok := s.deadLetter(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, letter)
+}
+
+// replayDeadLetterHandler asks the question of the dead letter of the route again through a new workflow,
+// optionally with another model or prompt version. The answer is the result of the workflow.
+func (s *Server) replayDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	var input replayInput
+	if problem := decodeOperation(w, r, &input, &input.Reason); problem != nil {
+		writeProblem(w, r, problem)
+		return
+	}
+	input.Model = strings.TrimSpace(input.Model)
+	if input.Model != "" && !slices.Contains(openai.Models(), input.Model) {
+		problem := newProblem(http.StatusBadRequest, "The model is not configured")
+		problem.Errors = []FieldError{{Field: "model", Detail: fmt.Sprintf("must be one of %s", strings.Join(openai.Models(), ", "))}}
+		writeProblem(w, r, problem)
+		return
+	}
+	if problem := input.validate(); problem != nil {
+		writeProblem(w, r, problem)
+		return
+	}
+	letter, ok := s.deadLetter(w, r)
+	if !ok {
+		return
+	}
+
+	options := operations.ReplayOptions{Model: input.Model, PromptVersion: input.PromptVersion}
+	workflowID, err := operations.Replay(r.Context(), s.Temporal, s.DeadLetters, s.TaskQueue, letter, options)
+	entry := audit.Entry{Action: audit.ActionReplay, Target: letter.ID, Reason: input.Reason}
+	if workflowID != "" {
+		entry.Detail = "workflow " + workflowID
+	}
+	s.recordAction(r, entry, err)
+
+	// The workflow may be started even though the letter could not be marked replayed
+	if workflowID == "" {
+		writeOperationError(w, r, err, "The dead letter does not exist")
+		return
+	}
+	w.Header().Set("Location", "/v1/admin/conversations/"+workflowID)
+	writeJSON(w, http.StatusAccepted, replayOutput{WorkflowID: workflowID})
+}
+
+// validate checks the prompt version of the replay, the model is checked against the configured ones beforehand
+func (input replayInput) validate() *Problem {
+	var errs []FieldError
+	if !codingchallenge.ValidPromptVersion(input.PromptVersion) {
+		errs = append(errs, FieldError{Field: "prompt_version", Detail: fmt.Sprintf("must be one of %s", strings.Join(codingchallenge.PromptVersions(), ", "))})
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	problem := newProblem(http.StatusUnprocessableEntity, "The request has invalid fields")
+	problem.Errors = errs
+	return problem
+}
+
+// deadLetter returns the dead letter of the route, the API keys do not find the questions of the users of other organizations
+func (s *Server) deadLetter(w http.ResponseWriter, r *http.Request) (*deadletter.Letter, bool) {
+	const notFound = "The dead letter does not exist"
+	letter, err := s.DeadLetters.Get(r.Context(), r.PathValue("id"))
+	if errors.Is(err, deadletter.ErrNotFound) {
+		writeError(w, r, http.StatusNotFound, notFound)
+		return nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Unable to read dead letter", "ID", r.PathValue("id"), "Error", err)
+		writeError(w, r, http.StatusInternalServerError, "Unable to read the dead letter")
+		return nil, false
+	}
+	if organization := callerOrganization(r); organization != "" && !strings.HasPrefix(letter.User, organization+":") {
+		writeError(w, r, http.StatusNotFound, notFound)
+		return nil, false
+	}
+	return letter, true
+}
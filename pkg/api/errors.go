@@ -0,0 +1,84 @@
+package main
+
+import (
+	"code-challenge/pkg/operations"
+	"context"
+	"errors"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/temporal"
+	"net/http"
+)
+
+// workflowProblem maps an error starting or running a workflow to the problem returned to the caller.
+// The details of internal errors are logged and not returned.
+func workflowProblem(err error) *Problem {
+	var (
+		alreadyStarted    *serviceerror.WorkflowExecutionAlreadyStarted
+		invalidArgument   *serviceerror.InvalidArgument
+		unavailable       *serviceerror.Unavailable
+		resourceExhausted *serviceerror.ResourceExhausted
+		deadlineExceeded  *serviceerror.DeadlineExceeded
+		timeoutErr        *temporal.TimeoutError
+		canceledErr       *temporal.CanceledError
+	)
+
+	switch {
+	case errors.As(err, &alreadyStarted):
+		return newProblem(http.StatusConflict, "The question is already being answered")
+	case errors.As(err, &invalidArgument):
+		return newProblem(http.StatusBadRequest, invalidArgument.Error())
+	case errors.As(err, &resourceExhausted):
+		return newProblem(http.StatusTooManyRequests, "Too many questions are being answered, try again later")
+	case errors.As(err, &unavailable):
+		return newProblem(http.StatusServiceUnavailable, "The chat service is not available")
+	case errors.As(err, &deadlineExceeded), errors.As(err, &timeoutErr), errors.Is(err, context.DeadlineExceeded):
+		return newProblem(http.StatusGatewayTimeout, "The question could not be answered in time")
+	case errors.As(err, &canceledErr), errors.Is(err, context.Canceled):
+		return newProblem(http.StatusServiceUnavailable, "The question was canceled before it was answered")
+	default:
+		return newProblem(http.StatusInternalServerError, "The question could not be answered")
+	}
+}
+
+// searchProblem maps an error of a visibility query to the problem returned to the caller
+func searchProblem(err error) *Problem {
+	var (
+		invalidArgument *serviceerror.InvalidArgument
+		unavailable     *serviceerror.Unavailable
+	)
+
+	switch {
+	case errors.As(err, &invalidArgument):
+		return newProblem(http.StatusBadRequest, invalidArgument.Error())
+	case errors.As(err, &unavailable):
+		return newProblem(http.StatusServiceUnavailable, "The search is not available")
+	default:
+		return newProblem(http.StatusInternalServerError, "Unable to search conversations")
+	}
+}
+
+// operationProblem maps an error of an admin operation to the problem returned to the caller,
+// notFound is the detail of the workflows or of the batch jobs that do not exist
+func operationProblem(err error, notFound string) *Problem {
+	var (
+		notFoundErr        *serviceerror.NotFound
+		invalidArgument    *serviceerror.InvalidArgument
+		failedPrecondition *serviceerror.FailedPrecondition
+		unavailable        *serviceerror.Unavailable
+	)
+
+	switch {
+	case errors.Is(err, operations.ErrNothingToRetry):
+		return newProblem(http.StatusUnprocessableEntity, "No failed conversation matches the query")
+	case errors.As(err, &notFoundErr):
+		return newProblem(http.StatusNotFound, notFound)
+	case errors.As(err, &invalidArgument):
+		return newProblem(http.StatusBadRequest, invalidArgument.Error())
+	case errors.As(err, &failedPrecondition):
+		return newProblem(http.StatusConflict, failedPrecondition.Error())
+	case errors.As(err, &unavailable):
+		return newProblem(http.StatusServiceUnavailable, "Temporal is not available")
+	default:
+		return newProblem(http.StatusInternalServerError, "The operation could not be completed")
+	}
+}
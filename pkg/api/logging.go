@@ -0,0 +1,31 @@
+package main
+
+import (
+	"code-challenge/pkg/logging"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// logRequests gives each request an ID, taken from the X-Request-ID header of the caller when it is valid, returns it in
+// the response and adds it to the logs of the request, then logs the request once served. The probes and the scrapes
+// of the metrics are only logged at the debug level.
+func (s *Server) logRequests(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := logging.RequestID(r.Header.Get(logging.HeaderRequestID))
+		w.Header().Set(logging.HeaderRequestID, requestID)
+		r = r.WithContext(logging.WithRequestID(r.Context(), requestID))
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		level := slog.LevelInfo
+		if unlimitedPaths[r.URL.Path] {
+			level = slog.LevelDebug
+		}
+		_, route := mux.Handler(r)
+		slog.Log(r.Context(), level, "Request served",
+			"Method", r.Method, "Route", route, "Status", recorder.Status(), "DurationMs", time.Since(start).Milliseconds())
+	})
+}
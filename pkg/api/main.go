@@ -1,80 +1,158 @@
 package main
 
 import (
-	codingchallenge "code-challenge/pkg/workflow"
+	"code-challenge/pkg/accounts"
+	"code-challenge/pkg/apikeys"
+	"code-challenge/pkg/audit"
+	"code-challenge/pkg/auth"
+	"code-challenge/pkg/config"
+	"code-challenge/pkg/conversations"
+	"code-challenge/pkg/deadletter"
+	"code-challenge/pkg/health"
+	"code-challenge/pkg/logging"
+	"code-challenge/pkg/metrics"
+	"code-challenge/pkg/notify"
+	"code-challenge/pkg/openai"
+	"code-challenge/pkg/ratelimit"
+	"code-challenge/pkg/temporalclient"
+	"code-challenge/pkg/tracing"
+	"code-challenge/pkg/usage"
 	"context"
-	"encoding/json"
+	"errors"
+	"flag"
 	client2 "go.temporal.io/sdk/client"
-	"log"
-	"net/http"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/workflow"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
-type ChatBotRequestInput struct {
-	Question string `json:"question"`
-	User     string `json:"user"`
-}
+// Starts the API server at the configured address, port 3002 by default, and stops it gracefully on SIGINT or SIGTERM
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Log JSON lines correlated by request ID, the Temporal client and the workers log through the same logger
+	logger := logging.Setup()
+
+	// Load the configuration from the YAML file, the environment and the flags, the errors list every invalid value
+	cfg, err := config.Load("chatbot-api", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		logging.Fatal("Invalid configuration", "Error", err)
+	}
+	openai.Configure(cfg.LLM)
+
+	// Trace the questions from the HTTP request to the calls to the model
+	shutdownTracing, err := tracing.Setup(context.Background(), "chatbot-api")
+	if err != nil {
+		logging.Fatal("Unable to configure tracing", "Error", err)
+	}
+	defer shutdownTracing(context.Background())
+	tracingInterceptor, err := tracing.TemporalInterceptor()
+	if err != nil {
+		logging.Fatal("Unable to configure tracing", "Error", err)
+	}
+
+	// Connect to Temporal with TLS and the API key of Temporal Cloud when they are configured,
+	// the rotated certificates and API key are reloaded without restarting
+	options, err := temporalclient.Options(cfg.Temporal)
+	if err != nil {
+		logging.Fatal("Unable to configure the connection to Temporal", "Error", err)
+	}
+
+	// The metrics of the Temporal client are served on /metrics with the metrics of the API
+	metricsHandler, metricsCloser := metrics.TemporalHandler()
+	defer metricsCloser.Close()
+	options.MetricsHandler = metricsHandler
+	options.Interceptors = []interceptor.ClientInterceptor{tracingInterceptor}
+	options.Logger = logging.TemporalLogger(logger)
+	options.ContextPropagators = []workflow.ContextPropagator{logging.NewPropagator()}
 
-// Handles the incoming request and executes the temporal workflow passing the question and user as input
-func handler(w http.ResponseWriter, r *http.Request) {
-	// Decode the incoming JSON request body into a ChatBotRequestInput struct
-	var chatBotRequest ChatBotRequestInput
-	_ = json.NewDecoder(r.Body).Decode(&chatBotRequest)
-
-	// Initialize a new Temporal client with lazy loading
-	client, err := client2.NewLazyClient(client2.Options{
-		HostPort:  os.Getenv("TEMPORAL_HOST_PORT"),
-		Namespace: os.Getenv("TEMPORAL_NAMESPACE"),
-	})
+	// Initialize the Temporal client shared by every request, it connects lazily on first use
+	client, err := client2.NewLazyClient(options)
+	if err != nil {
+		logging.Fatal("Unable to initialize temporal client", "Error", err)
+	}
 	defer client.Close()
 
-	// Check if there was an error initializing the Temporal client
+	// Open the conversation history holding the answers of the queued questions
+	store, err := conversations.Open(ctx, cfg.DatabaseURL)
 	if err != nil {
-		log.Fatal("Unable to initialize temporal client", err)
+		logging.Fatal("Unable to open conversation history", "Error", err)
 	}
 
-	// Define workflow options including ID and TaskQueue
-	wfOpts := client2.StartWorkflowOptions{
-		ID:        "chat_bot_workflow_ID",
-		TaskQueue: "chat_bot_workflow_task_queue",
+	// Open the first-party accounts
+	accountStore, err := accounts.Open(ctx, cfg.DatabaseURL)
+	if err != nil {
+		logging.Fatal("Unable to open accounts", "Error", err)
 	}
+	accountService := accounts.NewService(accountStore, notify.FromEnv(), cfg.Server.PasswordResetURL)
 
-	// Execute the workflow with the provided question and user from the request
-	wr, err := client.ExecuteWorkflow(context.Background(), wfOpts, codingchallenge.ChatBotWorkflow, codingchallenge.ChatBotQuestion{
-		Question: chatBotRequest.Question,
-		User:     chatBotRequest.User,
-	})
+	// Open the API keys of the partner apps and the usage accounted to their organizations
+	keyStore, err := apikeys.Open(ctx, cfg.DatabaseURL)
+	if err != nil {
+		logging.Fatal("Unable to open API keys", "Error", err)
+	}
+	keyService := apikeys.NewService(keyStore)
 
-	// Check if there was an error executing the workflow
+	usageStore, err := usage.Open(ctx, cfg.DatabaseURL)
 	if err != nil {
-		log.Fatal("Unable to execute workflow", err)
+		logging.Fatal("Unable to open usage", "Error", err)
 	}
 
-	// Retrieve the result of the workflow execution
-	var result *codingchallenge.ChatBotAnswer
-	err = wr.Get(context.Background(), &result)
+	// Open the audit log of the admin operations on the workflows
+	auditStore, err := audit.Open(ctx, cfg.DatabaseURL)
+	if err != nil {
+		logging.Fatal("Unable to open audit log", "Error", err)
+	}
 
-	// Check if there was an error getting the workflow result
+	// Open the questions that could not be answered, recorded by the workers
+	deadLetters, err := deadletter.Open(ctx, cfg.DatabaseURL)
 	if err != nil {
-		log.Fatalln("Unable to get workflow result", err)
+		logging.Fatal("Unable to open dead letters", "Error", err)
 	}
 
-	// Set the response content type to application/json
-	w.Header().Add("content-type", "application/json")
+	// Authenticate the callers with bearer tokens and API keys unless the authentication mode is none
+	authenticator, err := auth.New(cfg.Server.AuthMode, accounts.Authenticator{Service: accountService}, apikeys.Authenticator{Service: keyService})
+	if err != nil {
+		logging.Fatal("Unable to configure authentication", "Error", err)
+	}
+	if authenticator == nil {
+		slog.Warn("Authentication is disabled, the user is taken from the request body")
+	}
 
-	// Encode the result into the response writer as JSON
-	err = json.NewEncoder(w).Encode(result)
+	server := NewServer(client, store, authenticator)
+	server.TaskQueue, server.Namespace, server.HTTP = cfg.Temporal.TaskQueues.Chat, cfg.Temporal.Namespace, cfg.Server
+	server.Audit, server.DeadLetters = auditStore, deadLetters
+	server.BatchHosts, server.WebhookHosts = cfg.Worker.BatchHosts, cfg.Worker.WebhookHosts
+	if authenticator != nil {
+		server.Accounts = accountService
+		server.APIKeys = keyService
+		server.Usage = usageStore
+	}
 
-	// Check if there was an error encoding the response
+	// Rate limit the clients, the buckets are shared by the replicas when the database is set
+	limiter, err := ratelimit.Open(ctx, cfg.DatabaseURL)
 	if err != nil {
-		log.Fatalln("Error parting response to api")
-		return
+		logging.Fatal("Unable to open rate limiter", "Error", err)
+	}
+	server.Limiter = limiter
+	server.setRateLimits(cfg.Server.RateLimits)
+	if postgres, ok := limiter.(*ratelimit.PostgresLimiter); ok {
+		go pruneBuckets(ctx, postgres)
 	}
-}
 
-// Starts the API server at port 3002
-func main() {
-	http.HandleFunc("/chat", handler)
-	log.Println("Server started at http://localhost:3002")
-	log.Fatal(http.ListenAndServe(":3002", nil))
+	// The readiness probes check Temporal, the pools polling the task queues and the LLM providers
+	server.Health = health.NewChecker(health.Dependencies(client, cfg.Temporal.Namespace,
+		health.WorkflowQueue(cfg.Temporal.TaskQueues.Chat), health.ActivityQueue(cfg.Temporal.TaskQueues.LLM))...)
+
+	if err := server.Run(ctx, cfg.Server.Addr); err != nil {
+		logging.Fatal("Server failed", "Error", err)
+	}
+	slog.Info("Server stopped")
 }
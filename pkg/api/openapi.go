@@ -0,0 +1,20 @@
+package main
+
+import (
+	_ "embed"
+	"log/slog"
+	"net/http"
+)
+
+// openAPIDocument is the OpenAPI 3 description of the routes, the contract tests check it matches the handlers
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// openAPIHandler serves the OpenAPI document
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(openAPIDocument); err != nil {
+		slog.ErrorContext(r.Context(), "Error writing OpenAPI document", "Error", err)
+	}
+}
@@ -0,0 +1,511 @@
+package main
+
+import (
+	"code-challenge/pkg/accounts"
+	"code-challenge/pkg/answers"
+	"code-challenge/pkg/apikeys"
+	"code-challenge/pkg/audit"
+	"code-challenge/pkg/auth"
+	"code-challenge/pkg/calculators"
+	"code-challenge/pkg/conversations"
+	"code-challenge/pkg/deadletter"
+	"code-challenge/pkg/health"
+	"code-challenge/pkg/notify"
+	"code-challenge/pkg/operations"
+	"code-challenge/pkg/ratelimit"
+	"code-challenge/pkg/search"
+	"code-challenge/pkg/usage"
+	codingchallenge "code-challenge/pkg/workflow"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/mocks"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"math"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openAPI is the decoded OpenAPI document
+type openAPI struct {
+	Paths      map[string]map[string]operation `json:"paths"`
+	Components struct {
+		Responses map[string]response `json:"responses"`
+		Schemas   map[string]schema   `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	Responses map[string]response `json:"responses"`
+}
+
+type response struct {
+	Ref     string `json:"$ref"`
+	Content map[string]struct {
+		Schema schema `json:"schema"`
+	} `json:"content"`
+}
+
+// schema is the subset of the OpenAPI schema object checked by the contract tests
+type schema struct {
+	Ref                  string            `json:"$ref"`
+	Type                 string            `json:"type"`
+	Properties           map[string]schema `json:"properties"`
+	Required             []string          `json:"required"`
+	Items                *schema           `json:"items"`
+	Enum                 []any             `json:"enum"`
+	AllOf                []schema          `json:"allOf"`
+	Nullable             bool              `json:"nullable"`
+	AdditionalProperties any               `json:"additionalProperties"`
+}
+
+// schemaTypes binds the schemas of the document to the Go types encoded or decoded by the handlers
+var schemaTypes = map[string]any{
+	"ChatRequest":              ChatBotRequestInput{},
+	"NotifyChannel":            notify.Channel{},
+	"ChatResponse":             ChatResponse{},
+	"LegacyChatAnswer":         codingchallenge.ChatBotAnswer{},
+	"StructuredAnswer":         answers.StructuredAnswer{},
+	"Step":                     answers.Step{},
+	"Fee":                      answers.Fee{},
+	"CalculatorResult":         calculators.Result{},
+	"CalculatorSection":        calculators.Section{},
+	"CalculatorFactor":         calculators.Factor{},
+	"ConversationEntry":        conversations.Entry{},
+	"Signup":                   accounts.Signup{},
+	"Account":                  accounts.Account{},
+	"Login":                    loginInput{},
+	"Session":                  loginOutput{},
+	"PasswordReset":            passwordResetInput{},
+	"PasswordResetConfirm":     passwordResetConfirmInput{},
+	"EmailVerificationConfirm": emailVerificationConfirmInput{},
+	"NewAPIKey":                apikeys.NewKey{},
+	"APIKey":                   apikeys.Key{},
+	"APIKeyWithSecret":         apiKeyOutput{},
+	"UsageSummary":             usage.Summary{},
+	"ConversationPage":         conversationPage{},
+	"ConversationSummary":      search.Conversation{},
+	"ConversationExecution":    operations.Execution{},
+	"PendingActivity":          operations.PendingActivity{},
+	"OperationReason":          reasonInput{},
+	"ResetRequest":             resetInput{},
+	"ResetResult":              resetOutput{},
+	"RetryRequest":             retryInput{},
+	"RetryJob":                 retryOutput{},
+	"Retry":                    operations.Batch{},
+	"AuditEntry":               audit.Entry{},
+	"DeadLetter":               deadletter.Letter{},
+	"ReplayRequest":            replayInput{},
+	"ReplayResult":             replayOutput{},
+	"BatchRequest":             batchInput{},
+	"BatchJob":                 batchOutput{},
+	"Batch":                    operations.QuestionBatch{},
+	"BatchProgress":            operations.QuestionProgress{},
+	"HealthReport":             health.Report{},
+	"HealthCheck":              health.Result{},
+	"Problem":                  Problem{},
+	"FieldError":               FieldError{},
+}
+
+// loadOpenAPI decodes the document served by the API
+func loadOpenAPI(t *testing.T) *openAPI {
+	var doc openAPI
+	require.NoError(t, json.Unmarshal(openAPIDocument, &doc))
+	return &doc
+}
+
+// newContractServer creates a server serving every route
+func newContractServer(t *testing.T) (*Server, *mocks.Client) {
+	server, temporal := newTestServer(t)
+	server.Accounts = accounts.NewService(accounts.NewMemoryStore(), &mailer{}, "")
+	server.APIKeys = apikeys.NewService(apikeys.NewMemoryStore())
+	server.Usage = usage.NewMemoryStore()
+	return server, temporal
+}
+
+// operationOf returns the method and the path of the document of a ServeMux pattern, the patterns without method accept POST
+func operationOf(pattern string) (string, string) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		return http.MethodPost, pattern
+	}
+	return method, path
+}
+
+func Test_OpenAPI_Routes(t *testing.T) {
+	doc := loadOpenAPI(t)
+	server, _ := newContractServer(t)
+
+	var served []string
+	for _, route := range server.routes() {
+		method, path := operationOf(route.pattern)
+		served = append(served, method+" "+path)
+	}
+
+	var documented []string
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	sort.Strings(served)
+	sort.Strings(documented)
+	assert.Equal(t, documented, served, "every route must be documented and every documented route served")
+}
+
+func Test_OpenAPI_Schemas(t *testing.T) {
+	doc := loadOpenAPI(t)
+
+	for name, s := range doc.Components.Schemas {
+		value, ok := schemaTypes[name]
+		if !ok {
+			assert.True(t, s.AdditionalProperties == true, "schema %s is not bound to a Go type", name)
+			continue
+		}
+
+		typ := reflect.TypeOf(value)
+		fields := jsonFields(typ)
+		assert.ElementsMatch(t, keys(s.Properties), keys(fields), "properties of %s must match %s", name, typ)
+
+		for property, field := range fields {
+			if propertySchema, ok := s.Properties[property]; ok {
+				assert.True(t, compatible(doc, propertySchema, field.Type), "%s.%s is documented as %+v but is %s", name, property, propertySchema, field.Type)
+			}
+		}
+
+		// The required properties are always encoded
+		for _, property := range s.Required {
+			field, ok := fields[property]
+			if assert.True(t, ok, "%s requires the unknown property %s", name, property) {
+				assert.NotContains(t, field.Tag.Get("json"), "omitempty", "%s.%s is required but omitted when empty", name, property)
+			}
+		}
+	}
+}
+
+func Test_OpenAPI_Responses(t *testing.T) {
+	doc := loadOpenAPI(t)
+	server, temporal := newContractServer(t)
+	server.UserLimit = ratelimit.Limit{}
+	server.Auth = auth.Chain{accounts.Authenticator{Service: server.Accounts}, apikeys.Authenticator{Service: server.APIKeys}}
+
+	run := mocks.NewWorkflowRun(t)
+	run.On("GetID").Return("chat_bot_workflow_1")
+	run.On("Get", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(1).(**codingchallenge.ChatBotAnswer) = &codingchallenge.ChatBotAnswer{
+			User: "acme:alice", Answer: "Paris", Status: codingchallenge.StatusAnswered, Provider: "openai", Model: "gpt-4o",
+		}
+	}).Return(nil)
+	temporal.On("ExecuteWorkflow", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(run, nil)
+	temporal.On("ListWorkflow", mock.Anything, mock.Anything).Return(&workflowservice.ListWorkflowExecutionsResponse{
+		Executions: []*workflowpb.WorkflowExecutionInfo{{
+			Execution: &commonpb.WorkflowExecution{WorkflowId: "chat_bot_workflow_1", RunId: "run_1"},
+			Type:      &commonpb.WorkflowType{Name: "ChatBotWorkflow"},
+			Status:    enums.WORKFLOW_EXECUTION_STATUS_COMPLETED,
+			StartTime: timestamppb.Now(),
+			CloseTime: timestamppb.Now(),
+		}},
+		NextPageToken: []byte("next"),
+	}, nil)
+	server.BatchHosts = []string{"bucket.example.com"}
+	mockQuestionBatch(temporal, "acme:batch-1")
+	mockConversationOperations(temporal)
+	require.NoError(t, server.DeadLetters.Record(context.Background(), deadLetter("run_1", "acme:alice")))
+
+	adminSecret, _, err := server.APIKeys.Create(context.Background(), apikeys.NewKey{Organization: "acme", Scopes: apikeys.Scopes})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	for _, route := range server.routes() {
+		mux.HandleFunc(route.pattern, func(http.ResponseWriter, *http.Request) {})
+	}
+
+	var session loginOutput
+	var created apiKeyOutput
+	var retry retryOutput
+	requests := []struct {
+		method, path, authorization, body string
+		decode                            any
+	}{
+		{http.MethodPost, "/v1/chat", "ApiKey " + adminSecret, `{"question": "What is the capital of France?", "user": "alice"}`, nil},
+		{http.MethodPost, "/v1/chat", "ApiKey " + adminSecret, `{"question": "", "format": "html"}`, nil},
+		{http.MethodPost, "/v1/chat", "", `{"question": "What is the capital of France?"}`, nil},
+		{http.MethodPost, "/chat", "ApiKey " + adminSecret, `{"question": "What is the capital of France?", "user": "alice"}`, nil},
+		{http.MethodPost, "/v1/calculators/crs", "", `{"age": 29, "education": "masters", "canadian_work_years": 1,
+			"first_language": {"language": "english", "reading": 9, "writing": 9, "speaking": 9, "listening": 9}}`, nil},
+		{http.MethodPost, "/v1/calculators/unknown", "", `{}`, nil},
+		{http.MethodGet, "/v1/conversations/acme:alice", "ApiKey " + adminSecret, "", nil},
+		{http.MethodGet, "/v1/conversations/other:alice", "ApiKey " + adminSecret, "", nil},
+		{http.MethodGet, "/v1/openapi.json", "", "", nil},
+		{http.MethodGet, "/healthz", "", "", nil},
+		{http.MethodGet, "/readyz", "", "", nil},
+		{http.MethodGet, "/metrics", "", "", nil},
+		{http.MethodPost, "/v1/accounts", "", `{"email": "thiago@example.com", "password": "correct horse", "name": "Thiago"}`, nil},
+		{http.MethodPost, "/v1/accounts", "", `{"email": "thiago@example.com", "password": "correct horse"}`, nil},
+		{http.MethodPost, "/v1/sessions", "", `{"email": "thiago@example.com", "password": "correct horse"}`, &session},
+		{http.MethodPost, "/v1/password-resets", "", `{"email": "thiago@example.com"}`, nil},
+		{http.MethodPost, "/v1/password-resets/confirm", "", `{"token": "invalid", "password": "battery staple"}`, nil},
+		{http.MethodPost, "/v1/email-verifications/confirm", "", `{"token": "invalid"}`, nil},
+		{http.MethodPost, "/v1/api-keys", "ApiKey " + adminSecret, `{"name": "portal", "scopes": ["chat"]}`, &created},
+		{http.MethodPost, "/v1/api-keys", "ApiKey " + adminSecret, `{"scopes": ["root"]}`, nil},
+		{http.MethodGet, "/v1/api-keys", "ApiKey " + adminSecret, "", nil},
+		{http.MethodGet, "/v1/usage", "ApiKey " + adminSecret, "", nil},
+		{http.MethodGet, "/v1/usage?from=yesterday", "ApiKey " + adminSecret, "", nil},
+		{http.MethodGet, "/v1/admin/conversations?query=outcome:answered", "ApiKey " + adminSecret, "", nil},
+		{http.MethodGet, "/v1/admin/conversations?query=color:blue", "ApiKey " + adminSecret, "", nil},
+		{http.MethodGet, "/v1/admin/conversations/chat_1", "ApiKey " + adminSecret, "", nil},
+		{http.MethodGet, "/v1/admin/conversations/unknown", "ApiKey " + adminSecret, "", nil},
+		{http.MethodPost, "/v1/admin/conversations/chat_1/cancel", "ApiKey " + adminSecret, `{"reason": "stuck on the provider"}`, nil},
+		{http.MethodPost, "/v1/admin/conversations/chat_1/cancel", "ApiKey " + adminSecret, `{"reason": ""}`, nil},
+		{http.MethodPost, "/v1/admin/conversations/chat_1/terminate", "ApiKey " + adminSecret, `{"reason": "spam"}`, nil},
+		{http.MethodPost, "/v1/admin/conversations/chat_2/terminate", "ApiKey " + adminSecret, `{"reason": "spam"}`, nil},
+		{http.MethodPost, "/v1/admin/conversations/chat_1/reset", "ApiKey " + adminSecret, `{"reason": "bad answer", "event_id": 4}`, nil},
+		{http.MethodPost, "/v1/admin/retries", "ApiKey " + adminSecret, `{"query": "intent:work", "reason": "provider outage"}`, &retry},
+		{http.MethodPost, "/v1/admin/retries", "ApiKey " + adminSecret, `{"query": "status:failed", "reason": "provider outage"}`, nil},
+		{http.MethodGet, "/v1/admin/retries/other:retry-1", "ApiKey " + adminSecret, "", nil},
+		{http.MethodGet, "/v1/admin/audit", "ApiKey " + adminSecret, "", nil},
+		{http.MethodGet, "/v1/admin/dead-letters?error_type=AllProvidersFailed&pending=true", "ApiKey " + adminSecret, "", nil},
+		{http.MethodGet, "/v1/admin/dead-letters?limit=1000", "ApiKey " + adminSecret, "", nil},
+		{http.MethodGet, "/v1/admin/dead-letters/run_1", "ApiKey " + adminSecret, "", nil},
+		{http.MethodGet, "/v1/admin/dead-letters/run_2", "ApiKey " + adminSecret, "", nil},
+		{http.MethodPost, "/v1/admin/dead-letters/run_1/replay", "ApiKey " + adminSecret, `{"reason": "provider outage", "model": "gpt-4o-mini", "prompt_version": "v2"}`, nil},
+		{http.MethodPost, "/v1/admin/dead-letters/run_1/replay", "ApiKey " + adminSecret, `{"reason": "provider outage", "model": "gpt-4.1"}`, nil},
+		{http.MethodPost, "/v1/admin/dead-letters/run_1/replay", "ApiKey " + adminSecret, `{"reason": "provider outage", "prompt_version": "v0"}`, nil},
+		{http.MethodPost, "/v1/batches", "ApiKey " + adminSecret, `{"input": "https://bucket.example.com/in.jsonl", "output": "https://bucket.example.com/out.jsonl"}`, nil},
+		{http.MethodPost, "/v1/batches", "ApiKey " + adminSecret, `{"input": "in.jsonl", "output": "in.jsonl", "concurrency": 100}`, nil},
+		{http.MethodGet, "/v1/batches/acme:batch-1", "ApiKey " + adminSecret, "", nil},
+		{http.MethodGet, "/v1/batches/other:batch-1", "ApiKey " + adminSecret, "", nil},
+	}
+
+	// Every route is called at least once
+	called := map[string]bool{}
+	validateResponse := func(method, path, authorization, body string, decode any) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+
+		name := fmt.Sprintf("%s %s returned %d", method, path, rec.Code)
+		_, pattern := mux.Handler(req)
+		called[pattern] = true
+		documentedMethod, documentedPath := operationOf(pattern)
+		op, ok := doc.Paths[documentedPath][strings.ToLower(documentedMethod)]
+		require.True(t, ok, name)
+
+		res, ok := op.Responses[fmt.Sprint(rec.Code)]
+		require.True(t, ok, "%s: status is not documented", name)
+		if res.Ref != "" {
+			res = doc.Components.Responses[strings.TrimPrefix(res.Ref, "#/components/responses/")]
+		}
+
+		if len(res.Content) == 0 {
+			assert.Empty(t, rec.Body.String(), name)
+			return
+		}
+		mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+		content, ok := res.Content[mediaType]
+		require.True(t, ok, "%s: content type %s is not documented", name, mediaType)
+		if !strings.HasSuffix(mediaType, "json") {
+			return
+		}
+
+		var value any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &value), name)
+		assert.Empty(t, validateSchema(doc, content.Schema, value, "body"), name)
+
+		if decode != nil {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), decode), name)
+		}
+	}
+
+	for _, r := range requests {
+		validateResponse(r.method, r.path, r.authorization, r.body, r.decode)
+	}
+
+	// The routes needing the resources created above
+	validateResponse(http.MethodPost, "/v1/api-keys/"+created.Key.ID+"/rotate", "ApiKey "+adminSecret, "", nil)
+	validateResponse(http.MethodDelete, "/v1/api-keys/"+created.Key.ID, "ApiKey "+adminSecret, "", nil)
+	validateResponse(http.MethodDelete, "/v1/api-keys/unknown", "ApiKey "+adminSecret, "", nil)
+	validateResponse(http.MethodGet, "/v1/admin/retries/"+retry.JobID, "ApiKey "+adminSecret, "", nil)
+	validateResponse(http.MethodPost, "/v1/email-verifications", "Bearer "+session.Token, "", nil)
+	validateResponse(http.MethodDelete, "/v1/sessions/current", "Bearer "+session.Token, "", nil)
+	validateResponse(http.MethodGet, "/v1/conversations/"+session.Account.ID, "Bearer "+session.Token, "", nil)
+
+	for _, route := range server.routes() {
+		assert.True(t, called[route.pattern], "%s is not checked against the document", route.pattern)
+	}
+}
+
+// jsonFields returns the struct fields of the type by JSON name
+func jsonFields(typ reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch {
+		case name == "-" || !field.IsExported():
+			continue
+		case name == "":
+			name = field.Name
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// compatible returns whether the Go type is encoded as the schema
+func compatible(doc *openAPI, s schema, typ reflect.Type) bool {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	s = resolve(doc, s)
+	if len(s.AllOf) == 1 {
+		s = resolve(doc, s.AllOf[0])
+	}
+
+	switch {
+	case typ == reflect.TypeOf(time.Time{}):
+		return s.Type == "string"
+	case typ == reflect.TypeOf(json.RawMessage{}):
+		return s.Type == "object"
+	case typ.Kind() == reflect.String:
+		return s.Type == "string"
+	case typ.Kind() == reflect.Bool:
+		return s.Type == "boolean"
+	case typ.Kind() >= reflect.Int && typ.Kind() <= reflect.Uint64:
+		return s.Type == "integer"
+	case typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64:
+		return s.Type == "number"
+	case typ.Kind() == reflect.Slice:
+		return s.Type == "array" && s.Items != nil && compatible(doc, *s.Items, typ.Elem())
+	case typ.Kind() == reflect.Map:
+		values, ok := additionalProperties(s)
+		return s.Type == "object" && ok && compatible(doc, values, typ.Elem())
+	case typ.Kind() == reflect.Struct:
+		return s.Type == "object" && slices.Equal(keys(s.Properties), keys(jsonFields(typ)))
+	}
+	return false
+}
+
+// resolve returns the schema referenced by s
+func resolve(doc *openAPI, s schema) schema {
+	if s.Ref != "" {
+		return doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// additionalProperties returns the schema of the additional properties of the object, ok is false when it is not a schema
+func additionalProperties(s schema) (schema, bool) {
+	if _, ok := s.AdditionalProperties.(map[string]any); !ok {
+		return schema{}, false
+	}
+	encoded, err := json.Marshal(s.AdditionalProperties)
+	if err != nil {
+		return schema{}, false
+	}
+	var values schema
+	return values, json.Unmarshal(encoded, &values) == nil
+}
+
+// validateSchema returns the errors of the decoded JSON value against the schema
+func validateSchema(doc *openAPI, s schema, value any, path string) []string {
+	if s.Ref != "" {
+		return validateSchema(doc, resolve(doc, s), value, path)
+	}
+	if value == nil {
+		if s.Nullable {
+			return nil
+		}
+		return []string{path + " must not be null"}
+	}
+
+	var errs []string
+	for _, sub := range s.AllOf {
+		errs = append(errs, validateSchema(doc, sub, value, path)...)
+	}
+	if len(s.Enum) > 0 && !slices.Contains(s.Enum, value) {
+		errs = append(errs, fmt.Sprintf("%s must be one of %v", path, s.Enum))
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return append(errs, path+" must be an object")
+		}
+		for _, property := range s.Required {
+			if _, ok := object[property]; !ok {
+				errs = append(errs, path+"."+property+" is required")
+			}
+		}
+		for property, v := range object {
+			propertySchema, ok := s.Properties[property]
+			if values, isSchema := additionalProperties(s); !ok && isSchema {
+				propertySchema, ok = values, true
+			}
+			if !ok {
+				if s.AdditionalProperties == nil && len(s.Properties) > 0 {
+					errs = append(errs, path+"."+property+" is not documented")
+				}
+				continue
+			}
+			errs = append(errs, validateSchema(doc, propertySchema, v, path+"."+property)...)
+		}
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return append(errs, path+" must be an array")
+		}
+		for i, item := range array {
+			errs = append(errs, validateSchema(doc, *s.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			errs = append(errs, path+" must be a string")
+		}
+	case "integer":
+		if number, ok := value.(float64); !ok || number != math.Trunc(number) {
+			errs = append(errs, path+" must be an integer")
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			errs = append(errs, path+" must be a number")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			errs = append(errs, path+" must be a boolean")
+		}
+	}
+	return errs
+}
+
+// keys returns the sorted keys of the map
+func keys[V any](m map[string]V) []string {
+	k := make([]string, 0, len(m))
+	for key := range m {
+		k = append(k, key)
+	}
+	sort.Strings(k)
+	return k
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"code-challenge/pkg/audit"
+	"code-challenge/pkg/auth"
+	"code-challenge/pkg/operations"
+	"code-challenge/pkg/search"
+	"context"
+	"fmt"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxReasonLength limits the number of characters of the reason of an operation
+const maxReasonLength = 500
+
+// reasonInput is the body of the cancellation and of the termination of a conversation
+type reasonInput struct {
+	Reason string `json:"reason"`
+}
+
+// resetInput is the body of the reset of a conversation
+type resetInput struct {
+	Reason string `json:"reason"`
+
+	// EventID is the ID of the WorkflowTaskCompleted event the new run starts from, the first workflow task when zero
+	EventID int64 `json:"event_id,omitempty"`
+}
+
+// resetOutput is the new run started by a reset
+type resetOutput struct {
+	RunID string `json:"run_id"`
+}
+
+// retryInput is the body of the retry of the failed conversations
+type retryInput struct {
+	// Query selects the failed conversations to retry with the filters of the search, every one of them when empty
+	Query  string `json:"query"`
+	Reason string `json:"reason"`
+}
+
+// retryOutput is the batch job retrying the failed conversations
+type retryOutput struct {
+	JobID string `json:"job_id"`
+	Query string `json:"query"`
+}
+
+// describeConversationHandler returns the state of the conversation workflow of the route
+func (s *Server) describeConversationHandler(w http.ResponseWriter, r *http.Request) {
+	execution, ok := s.describeConversation(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, execution)
+}
+
+// cancelConversationHandler requests the cancellation of the running conversation of the route
+func (s *Server) cancelConversationHandler(w http.ResponseWriter, r *http.Request) {
+	var input reasonInput
+	if problem := decodeOperation(w, r, &input, &input.Reason); problem != nil {
+		writeProblem(w, r, problem)
+		return
+	}
+	execution, ok := s.describeRunningConversation(w, r)
+	if !ok {
+		return
+	}
+
+	conversation := execution.Conversation
+	err := operations.Cancel(r.Context(), s.Temporal, s.Namespace, conversation.WorkflowID, conversation.RunID, input.Reason)
+	s.recordAction(r, audit.Entry{Action: audit.ActionCancel, Target: conversation.WorkflowID, RunID: conversation.RunID, Reason: input.Reason}, err)
+	if err != nil {
+		writeOperationError(w, r, err, "The conversation does not exist")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// terminateConversationHandler terminates the running conversation of the route at once
+func (s *Server) terminateConversationHandler(w http.ResponseWriter, r *http.Request) {
+	var input reasonInput
+	if problem := decodeOperation(w, r, &input, &input.Reason); problem != nil {
+		writeProblem(w, r, problem)
+		return
+	}
+	execution, ok := s.describeRunningConversation(w, r)
+	if !ok {
+		return
+	}
+
+	conversation := execution.Conversation
+	err := operations.Terminate(r.Context(), s.Temporal, conversation.WorkflowID, conversation.RunID, input.Reason)
+	s.recordAction(r, audit.Entry{Action: audit.ActionTerminate, Target: conversation.WorkflowID, RunID: conversation.RunID, Reason: input.Reason}, err)
+	if err != nil {
+		writeOperationError(w, r, err, "The conversation does not exist")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resetConversationHandler starts a new run of the conversation of the route from an event of its history
+func (s *Server) resetConversationHandler(w http.ResponseWriter, r *http.Request) {
+	var input resetInput
+	if problem := decodeOperation(w, r, &input, &input.Reason); problem != nil {
+		writeProblem(w, r, problem)
+		return
+	}
+	execution, ok := s.describeConversation(w, r)
+	if !ok {
+		return
+	}
+
+	conversation := execution.Conversation
+	runID, err := operations.Reset(r.Context(), s.Temporal, s.Namespace, conversation.WorkflowID, conversation.RunID, input.EventID, input.Reason)
+	entry := audit.Entry{Action: audit.ActionReset, Target: conversation.WorkflowID, RunID: conversation.RunID, Reason: input.Reason}
+	if err == nil {
+		entry.Detail = "new run " + runID
+	}
+	s.recordAction(r, entry, err)
+	if err != nil {
+		writeOperationError(w, r, err, "The conversation does not exist")
+		return
+	}
+	writeJSON(w, http.StatusOK, resetOutput{RunID: runID})
+}
+
+// retryConversationsHandler starts a batch job running again the failed conversations matching the query.
+// The API keys only retry the conversations of the users of their organization.
+func (s *Server) retryConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	var input retryInput
+	if problem := decodeOperation(w, r, &input, &input.Reason); problem != nil {
+		writeProblem(w, r, problem)
+		return
+	}
+	filter, err := search.ParseQuery(input.Query)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, ok := filter.Values["ExecutionStatus"]; ok {
+		writeError(w, r, http.StatusBadRequest, "status cannot be filtered, only the failed conversations are retried")
+		return
+	}
+
+	filter.WorkflowTypes = conversationWorkflowTypes
+	prefix := "retry-"
+	if organization := callerOrganization(r); organization != "" {
+		filter.UserPrefix = organization + ":"
+		prefix = organization + ":" + prefix
+	}
+
+	jobID, err := operations.RetryFailed(r.Context(), s.Temporal, s.Namespace, filter, prefix, input.Reason)
+	s.recordAction(r, audit.Entry{Action: audit.ActionRetry, Target: jobID, Reason: input.Reason, Detail: input.Query}, err)
+	if err != nil {
+		writeOperationError(w, r, err, "The conversation does not exist")
+		return
+	}
+	w.Header().Set("Location", "/v1/admin/retries/"+jobID)
+	writeJSON(w, http.StatusAccepted, retryOutput{JobID: jobID, Query: input.Query})
+}
+
+// retryHandler returns the progress of the retry of the route, the API keys only read the retries of their organization
+func (s *Server) retryHandler(w http.ResponseWriter, r *http.Request) {
+	const notFound = "The retry does not exist"
+	jobID := r.PathValue("id")
+	if organization := callerOrganization(r); organization != "" && !strings.HasPrefix(jobID, organization+":") {
+		writeError(w, r, http.StatusNotFound, notFound)
+		return
+	}
+
+	batch, err := operations.DescribeBatch(r.Context(), s.Temporal, s.Namespace, jobID)
+	if err != nil {
+		writeOperationError(w, r, err, notFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, batch)
+}
+
+// auditHandler returns the actions of the operators between the from and to dates of the query, both inclusive,
+// most recent first. The API keys only read the actions of their organization.
+func (s *Server) auditHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	organization := query.Get("organization")
+	if caller := callerOrganization(r); caller != "" {
+		organization = caller
+	}
+
+	from, to, problem := parsePeriod(query)
+	if problem != nil {
+		writeProblem(w, r, problem)
+		return
+	}
+
+	entries, err := s.Audit.List(r.Context(), organization, from, to)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Unable to read audit log", "Error", err)
+		writeError(w, r, http.StatusInternalServerError, "Unable to read the audit log")
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// describeConversation returns the run of the run_id query of the conversation of the route, or its latest run.
+// The other workflows, and for the API keys the conversations of the users of other organizations, are not found.
+func (s *Server) describeConversation(w http.ResponseWriter, r *http.Request) (*operations.Execution, bool) {
+	execution, err := operations.Describe(r.Context(), s.Temporal, r.PathValue("id"), r.URL.Query().Get("run_id"))
+	if err == nil && !slices.Contains(conversationWorkflowTypes, execution.Conversation.WorkflowType) {
+		err = serviceerror.NewNotFound("not a conversation workflow")
+	}
+	if organization := callerOrganization(r); err == nil && organization != "" && !strings.HasPrefix(execution.Conversation.User, organization+":") {
+		err = serviceerror.NewNotFound("conversation of another organization")
+	}
+	if err != nil {
+		writeOperationError(w, r, err, "The conversation does not exist")
+		return nil, false
+	}
+	return execution, true
+}
+
+// describeRunningConversation returns the conversation of the route, the closed conversations are a conflict
+func (s *Server) describeRunningConversation(w http.ResponseWriter, r *http.Request) (*operations.Execution, bool) {
+	execution, ok := s.describeConversation(w, r)
+	if ok && execution.Conversation.Status != enums.WORKFLOW_EXECUTION_STATUS_RUNNING.String() {
+		writeError(w, r, http.StatusConflict, "The conversation is not running, it is "+execution.Conversation.Status)
+		return nil, false
+	}
+	return execution, ok
+}
+
+// decodeOperation reads the body of an operation, its reason is required to audit it
+func decodeOperation(w http.ResponseWriter, r *http.Request, v any, reason *string) *Problem {
+	if problem := decodeJSON(w, r, v); problem != nil {
+		return problem
+	}
+
+	var detail string
+	switch *reason = strings.TrimSpace(*reason); {
+	case *reason == "":
+		detail = "must not be empty"
+	case utf8.RuneCountInString(*reason) > maxReasonLength:
+		detail = fmt.Sprintf("must have at most %d characters", maxReasonLength)
+	default:
+		return nil
+	}
+	problem := newProblem(http.StatusUnprocessableEntity, "The request has invalid fields")
+	problem.Errors = []FieldError{{Field: "reason", Detail: detail}}
+	return problem
+}
+
+// recordAction adds the action of the caller to the audit log with its error, a failure to record it is logged
+func (s *Server) recordAction(r *http.Request, entry audit.Entry, err error) {
+	entry.Time = time.Now().UTC()
+	entry.Actor = "anonymous"
+	if identity, ok := auth.FromContext(r.Context()); ok {
+		entry.Actor, entry.Organization = identity.Subject, identity.Organization
+		if identity.KeyID != "" {
+			entry.Actor = "apikey:" + identity.KeyID
+		}
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	slog.InfoContext(r.Context(), "Admin action", "Action", entry.Action, "Target", entry.Target, "RunID", entry.RunID,
+		"Actor", entry.Actor, "Reason", entry.Reason, "Error", entry.Error)
+
+	// The action is recorded even when the client disconnected
+	if err := s.Audit.Record(context.WithoutCancel(r.Context()), entry); err != nil {
+		slog.ErrorContext(r.Context(), "Unable to record admin action", "Action", entry.Action, "Target", entry.Target, "Error", err)
+	}
+}
+
+// writeOperationError writes the problem of the error of an operation, notFound is the detail of the missing resources
+func writeOperationError(w http.ResponseWriter, r *http.Request, err error, notFound string) {
+	problem := operationProblem(err, notFound)
+	if problem.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "Admin operation failed", "Error", err)
+	}
+	writeProblem(w, r, problem)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// problemContentType is the media type of the error responses, see RFC 7807
+const problemContentType = "application/problem+json"
+
+// FieldError describes an invalid field of the request body
+type FieldError struct {
+	Field  string `json:"field"`
+	Detail string `json:"detail"`
+}
+
+// Problem is an RFC 7807 problem details error response
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// newProblem creates a problem for the status, its title is the status text
+func newProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// writeProblem writes the problem as the response to the request
+func writeProblem(w http.ResponseWriter, r *http.Request, problem *Problem) {
+	problem.Instance = r.URL.Path
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(problem.Status)
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		slog.ErrorContext(r.Context(), "Error parsing response to api", "Error", err)
+	}
+}
+
+// writeError writes a problem with the status and the detail as the response to the request
+func writeError(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	writeProblem(w, r, newProblem(status, detail))
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"code-challenge/pkg/config"
+	"code-challenge/pkg/ratelimit"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setRateLimits configures the rates of the client IPs and of the users and the trusted proxies,
+// the invalid networks are rejected by config.Validate
+func (s *Server) setRateLimits(limits config.RateLimits) {
+	s.IPLimit, s.UserLimit = ratelimit.PerMinute(limits.IPPerMinute), ratelimit.PerMinute(limits.UserPerMinute)
+	s.TrustedProxies = nil
+	for _, network := range limits.TrustedProxies {
+		if prefix, err := netip.ParsePrefix(network); err == nil {
+			s.TrustedProxies = append(s.TrustedProxies, prefix)
+		}
+	}
+}
+
+// bucketsPruneInterval is how often the buckets full again are deleted from Postgres, the limits refill within a minute
+const bucketsPruneInterval = time.Hour
+
+// pruneBuckets deletes the idle buckets until the context is done
+func pruneBuckets(ctx context.Context, limiter *ratelimit.PostgresLimiter) {
+	ticker := time.NewTicker(bucketsPruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := limiter.Prune(ctx, now.Add(-bucketsPruneInterval)); err != nil {
+				slog.ErrorContext(ctx, "Unable to prune rate limit buckets", "Error", err)
+			}
+		}
+	}
+}
+
+// unlimitedPaths are the paths of the kubelet probes and of the Prometheus scrapes, they are not rate limited
+// as they would fail the pod or lose the metrics when the IP of the node is limited
+var unlimitedPaths = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// limitIP rejects the requests of the client IPs exceeding their rate, it protects the public routes as well
+func (s *Server) limitIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unlimitedPaths[r.URL.Path] || s.allow(w, r, "ip:"+s.clientIP(r), s.IPLimit) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// clientIP returns the IP of the client of the request. Behind the trusted proxies it is the right-most address
+// of X-Forwarded-For that is not a trusted proxy, the addresses at its left may have been forged by the client.
+func (s *Server) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remote, err := netip.ParseAddr(host)
+	if err != nil || !s.trusted(remote) {
+		return host
+	}
+
+	client := remote
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		client = addr.Unmap()
+		if !s.trusted(client) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// trusted returns whether the address is one of a trusted proxy
+func (s *Server) trusted(addr netip.Addr) bool {
+	for _, prefix := range s.TrustedProxies {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// allow takes a request from the bucket of the key and writes the rate limit headers of the most restrictive limit.
+// The request is rejected with 429 when the limit is reached, and allowed when the limit is disabled or the limiter fails.
+func (s *Server) allow(w http.ResponseWriter, r *http.Request, key string, limit ratelimit.Limit) bool {
+	if !limit.Enabled() || s.Limiter == nil {
+		return true
+	}
+
+	decision, err := s.Limiter.Allow(r.Context(), key, limit)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Unable to check rate limit", "Error", err)
+		return true
+	}
+
+	// A previous limit of the request with fewer requests left is kept in the headers
+	remaining, err := strconv.Atoi(w.Header().Get("RateLimit-Remaining"))
+	if err != nil || !decision.Allowed || decision.Remaining < remaining {
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(decision.Reset)))
+	}
+
+	if !decision.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(seconds(decision.RetryAfter)))
+		writeError(w, r, http.StatusTooManyRequests, "The rate limit was reached, retry later")
+	}
+	return decision.Allowed
+}
+
+// seconds rounds the duration up to whole seconds, as expected by the rate limit headers
+func seconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"code-challenge/pkg/auth"
+	"code-challenge/pkg/config"
+	"code-challenge/pkg/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func Test_ClientIP(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"direct client", "203.0.113.7:4321", nil, "203.0.113.7"},
+		{"untrusted proxy is ignored", "203.0.113.7:4321", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"behind the ingress", "10.0.1.5:4321", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"forged addresses are skipped", "10.0.1.5:4321", []string{"1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"chained proxies", "10.0.1.5:4321", []string{"198.51.100.1, 10.0.2.9"}, "198.51.100.1"},
+		{"multiple headers", "10.0.1.5:4321", []string{"1.1.1.1", "198.51.100.1"}, "198.51.100.1"},
+		{"only proxies", "10.0.1.5:4321", []string{"10.0.2.9"}, "10.0.2.9"},
+		{"invalid address", "10.0.1.5:4321", []string{"198.51.100.1, unknown"}, "10.0.1.5"},
+		{"ipv6", "[2001:db8::1]:4321", nil, "2001:db8::1"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = test.remoteAddr
+		for _, value := range test.forwarded {
+			r.Header.Add("X-Forwarded-For", value)
+		}
+		assert.Equal(t, test.want, server.clientIP(r), test.name)
+	}
+}
+
+func Test_RateLimit(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.IPLimit = ratelimit.PerMinute(2)
+	server.UserLimit = ratelimit.PerMinute(1)
+
+	calculate := func(forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/calculators/unknown", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.RemoteAddr = "10.0.1.5:4321"
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The public routes are limited per client IP
+	rec := calculate("198.51.100.1")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", rec.Header().Get("RateLimit-Reset"))
+
+	calculate("198.51.100.1")
+	rec = calculate("198.51.100.1")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, problemContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+
+	// Each client IP has its own bucket
+	rec = calculate("198.51.100.2")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// The authenticated users are limited whatever their IP
+	history := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/conversations/user-1", nil)
+		req.RemoteAddr = ip + ":4321"
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+	server.Auth = authenticatorFunc(func(r *http.Request) (*auth.Identity, error) {
+		return &auth.Identity{Subject: "user-1", Scopes: []string{auth.ScopeChat}}, nil
+	})
+
+	rec = history("203.0.113.1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	rec = history("203.0.113.2")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+}
+
+func Test_SetRateLimits(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.setRateLimits(config.RateLimits{IPPerMinute: 0, UserPerMinute: 10, TrustedProxies: []string{"100.64.0.0/10"}})
+	assert.False(t, server.IPLimit.Enabled())
+	assert.Equal(t, ratelimit.PerMinute(10), server.UserLimit)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("100.64.0.0/10")}, server.TrustedProxies)
+}
+
+// authenticatorFunc authenticates the requests with a function
+type authenticatorFunc func(r *http.Request) (*auth.Identity, error)
+
+func (f authenticatorFunc) Authenticate(r *http.Request) (*auth.Identity, error) {
+	return f(r)
+}
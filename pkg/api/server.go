@@ -0,0 +1,207 @@
+package main
+
+import (
+	"code-challenge/pkg/accounts"
+	"code-challenge/pkg/apikeys"
+	"code-challenge/pkg/audit"
+	"code-challenge/pkg/auth"
+	"code-challenge/pkg/config"
+	"code-challenge/pkg/conversations"
+	"code-challenge/pkg/deadletter"
+	"code-challenge/pkg/health"
+	"code-challenge/pkg/metrics"
+	"code-challenge/pkg/ratelimit"
+	"code-challenge/pkg/tracing"
+	"code-challenge/pkg/usage"
+	"context"
+	"errors"
+	"go.temporal.io/sdk/client"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"time"
+)
+
+// cancelTimeout limits the time spent requesting the cancellation of a workflow
+const cancelTimeout = 5 * time.Second
+
+// Server is the HTTP API, its dependencies are shared by every request
+type Server struct {
+	// Temporal is the client used to run the workflows, it is created once and shared by the handlers
+	Temporal client.Client
+
+	// Store holds the conversation history
+	Store conversations.Store
+
+	// TaskQueue is the task queue the workflows are started on
+	TaskQueue string
+
+	// Namespace is the Temporal namespace of the workflows, the admin operations act on it
+	Namespace string
+
+	// HTTP holds the timeouts of the HTTP server and of the synchronous questions
+	HTTP config.Server
+
+	// Auth authenticates the callers of the private routes, they are not authenticated when it is nil
+	Auth auth.Authenticator
+
+	// Accounts manages the first-party accounts, the account routes are only served when it is set
+	Accounts *accounts.Service
+
+	// APIKeys manages the keys of the partner apps, the key routes are only served when it is set
+	APIKeys *apikeys.Service
+
+	// Audit records the admin operations on the workflows
+	Audit audit.Store
+
+	// DeadLetters holds the questions that could not be answered, the admins replay them
+	DeadLetters deadletter.Store
+
+	// BatchHosts are the hosts of the URLs the batches may read and write, the workers refuse the others
+	BatchHosts []string
+
+	// WebhookHosts are the hosts the answers of the queued questions may be posted to, the workers refuse the others
+	WebhookHosts []string
+
+	// Usage accounts the calls of the API keys to their organization, they are not accounted when it is nil
+	Usage usage.Store
+
+	// Limiter applies the rate limits of the client IPs, the users and the API keys
+	Limiter ratelimit.Limiter
+
+	// IPLimit is the rate of each client IP and UserLimit of each authenticated user, the zero limits are disabled
+	IPLimit   ratelimit.Limit
+	UserLimit ratelimit.Limit
+
+	// TrustedProxies are the networks of the proxies whose X-Forwarded-For header gives the client IP
+	TrustedProxies []netip.Prefix
+
+	// Health checks the dependencies of the API for the readiness probes
+	Health *health.Checker
+}
+
+// NewServer creates the API server with its dependencies
+func NewServer(temporal client.Client, store conversations.Store, authenticator auth.Authenticator) *Server {
+	s := &Server{
+		Temporal:    temporal,
+		Store:       store,
+		TaskQueue:   config.Default().Temporal.TaskQueues.Chat,
+		Namespace:   config.Default().Temporal.Namespace,
+		HTTP:        config.Default().Server,
+		Auth:        authenticator,
+		Audit:       audit.NewMemoryStore(),
+		Limiter:     ratelimit.NewMemoryLimiter(),
+		Health:      health.NewChecker(),
+		DeadLetters: deadletter.NewMemoryStore(),
+	}
+	s.setRateLimits(s.HTTP.RateLimits)
+	return s
+}
+
+// route is a route of the API, each of them is described in the OpenAPI document
+type route struct {
+	// pattern is the ServeMux pattern, the routes without method only accept POST
+	pattern string
+	handler http.HandlerFunc
+}
+
+// routes returns the routes served with the dependencies of the server,
+// only the calculators, the OpenAPI document, the probes, the metrics and the account routes giving credentials are public
+func (s *Server) routes() []route {
+	routes := []route{
+		{"/chat", s.requireScope(auth.ScopeChat, s.chatHandler)},
+		{"POST /v1/chat", s.requireScope(auth.ScopeChat, s.chatV1Handler)},
+		{"POST /v1/calculators/{name}", calculatorHandler},
+		{"GET /v1/conversations/{user}", s.requireScope(auth.ScopeChat, s.historyHandler)},
+		{"GET /v1/admin/conversations", s.requireScope(auth.ScopeAdmin, s.searchConversationsHandler)},
+		{"GET /v1/admin/conversations/{id}", s.requireScope(auth.ScopeAdmin, s.describeConversationHandler)},
+		{"POST /v1/admin/conversations/{id}/cancel", s.requireScope(auth.ScopeAdmin, s.cancelConversationHandler)},
+		{"POST /v1/admin/conversations/{id}/terminate", s.requireScope(auth.ScopeAdmin, s.terminateConversationHandler)},
+		{"POST /v1/admin/conversations/{id}/reset", s.requireScope(auth.ScopeAdmin, s.resetConversationHandler)},
+		{"POST /v1/admin/retries", s.requireScope(auth.ScopeAdmin, s.retryConversationsHandler)},
+		{"GET /v1/admin/retries/{id}", s.requireScope(auth.ScopeAdmin, s.retryHandler)},
+		{"GET /v1/admin/audit", s.requireScope(auth.ScopeAdmin, s.auditHandler)},
+		{"GET /v1/admin/dead-letters", s.requireScope(auth.ScopeAdmin, s.deadLettersHandler)},
+		{"GET /v1/admin/dead-letters/{id}", s.requireScope(auth.ScopeAdmin, s.deadLetterHandler)},
+		{"POST /v1/admin/dead-letters/{id}/replay", s.requireScope(auth.ScopeAdmin, s.replayDeadLetterHandler)},
+		{"POST /v1/batches", s.requireScope(auth.ScopeAdmin, s.createBatchHandler)},
+		{"GET /v1/batches/{id}", s.requireScope(auth.ScopeAdmin, s.batchHandler)},
+		{"GET /v1/openapi.json", openAPIHandler},
+		{"GET /healthz", health.Live},
+		{"GET /readyz", s.Health.Ready},
+		{"GET /metrics", metrics.Handler().ServeHTTP},
+	}
+
+	if s.Accounts != nil {
+		routes = append(routes,
+			route{"POST /v1/accounts", s.signupHandler},
+			route{"POST /v1/sessions", s.loginHandler},
+			route{"DELETE /v1/sessions/current", s.requireAuth(s.logoutHandler)},
+			route{"POST /v1/password-resets", s.passwordResetHandler},
+			route{"POST /v1/password-resets/confirm", s.passwordResetConfirmHandler},
+			route{"POST /v1/email-verifications", s.requireAuth(s.emailVerificationHandler)},
+			route{"POST /v1/email-verifications/confirm", s.emailVerificationConfirmHandler},
+		)
+	}
+
+	if s.APIKeys != nil {
+		routes = append(routes,
+			route{"POST /v1/api-keys", s.requireScope(auth.ScopeAdmin, s.createAPIKeyHandler)},
+			route{"GET /v1/api-keys", s.requireScope(auth.ScopeAdmin, s.listAPIKeysHandler)},
+			route{"DELETE /v1/api-keys/{id}", s.requireScope(auth.ScopeAdmin, s.revokeAPIKeyHandler)},
+			route{"POST /v1/api-keys/{id}/rotate", s.requireScope(auth.ScopeAdmin, s.rotateAPIKeyHandler)},
+		)
+	}
+
+	if s.Usage != nil {
+		routes = append(routes, route{"GET /v1/usage", s.requireScope(auth.ScopeAnalytics, s.usageHandler)})
+	}
+	return routes
+}
+
+// Handler returns the routes of the API, every route but the probes and the metrics is rate limited per client IP.
+// The requests are traced, counted and logged by route, including the rate limited ones.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, route := range s.routes() {
+		mux.HandleFunc(route.pattern, route.handler)
+	}
+	return tracing.Instrument(mux, metrics.Instrument(mux, s.logRequests(mux, s.limitIP(mux))))
+}
+
+// Run serves the API on the address until the context is done, then waits for the in-flight requests to complete
+func (s *Server) Run(ctx context.Context, addr string) error {
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: s.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       s.HTTP.ReadTimeout,
+		WriteTimeout:      s.HTTP.WriteTimeout,
+		IdleTimeout:       s.HTTP.IdleTimeout,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		slog.Info("Server started", "Addr", addr)
+		errs <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Stop accepting connections and drain the in-flight requests
+	slog.Info("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.HTTP.ShutdownTimeout)
+	defer cancel()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
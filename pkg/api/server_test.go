@@ -0,0 +1,871 @@
+package main
+
+import (
+	"code-challenge/pkg/accounts"
+	"code-challenge/pkg/apikeys"
+	"code-challenge/pkg/audit"
+	"code-challenge/pkg/auth"
+	"code-challenge/pkg/conversations"
+	"code-challenge/pkg/deadletter"
+	"code-challenge/pkg/health"
+	"code-challenge/pkg/logging"
+	"code-challenge/pkg/notify"
+	"code-challenge/pkg/ratelimit"
+	"code-challenge/pkg/search"
+	"code-challenge/pkg/usage"
+	codingchallenge "code-challenge/pkg/workflow"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/mocks"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer creates a server with a mocked Temporal client and an in memory history
+func newTestServer(t *testing.T) (*Server, *mocks.Client) {
+	temporal := mocks.NewClient(t)
+	return NewServer(temporal, conversations.NewMemoryStore(), nil), temporal
+}
+
+// postQuestion sends the question to the chat route of the server
+func postQuestion(server *Server, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func Test_Chat(t *testing.T) {
+	server, temporal := newTestServer(t)
+
+	run := mocks.NewWorkflowRun(t)
+	run.On("Get", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(1).(**codingchallenge.ChatBotAnswer) = &codingchallenge.ChatBotAnswer{User: "test_user", Answer: "Paris", Status: codingchallenge.StatusAnswered}
+	}).Return(nil)
+
+	// Each question is started as its own workflow with the trimmed question
+	temporal.On("ExecuteWorkflow", mock.Anything, mock.MatchedBy(func(options client.StartWorkflowOptions) bool {
+		return strings.HasPrefix(options.ID, "chat_bot_workflow_") && options.TaskQueue == server.TaskQueue
+	}), mock.Anything, codingchallenge.ChatBotQuestion{User: "test_user", Question: "What is the capital of France?"}).Return(run, nil)
+
+	rec := postQuestion(server, `{"question": " What is the capital of France? ", "user": "test_user"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var answer codingchallenge.ChatBotAnswer
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&answer))
+	assert.Equal(t, "Paris", answer.Answer)
+}
+
+func Test_Chat_TemporalUnavailable(t *testing.T) {
+	server, temporal := newTestServer(t)
+
+	temporal.On("ExecuteWorkflow", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, serviceerror.NewUnavailable("connection refused"))
+
+	rec := postQuestion(server, `{"question": "What is the capital of France?", "user": "test_user"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, problemContentType, rec.Header().Get("Content-Type"))
+	assert.NotContains(t, rec.Body.String(), "connection refused")
+}
+
+func Test_History(t *testing.T) {
+	server, _ := newTestServer(t)
+	require.NoError(t, server.Store.Save(context.Background(), conversations.Entry{
+		ID: "chat_1", User: "test_user", Question: "What is the capital of France?", Answer: "Paris", CreatedAt: time.Now(),
+	}))
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/conversations/test_user", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var history []conversations.Entry
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&history))
+	require.Len(t, history, 1)
+	assert.Equal(t, "Paris", history[0].Answer)
+}
+
+func Test_Run_Shutdown(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	// The server stops without error once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Run(ctx, "127.0.0.1:0") }()
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop")
+	}
+}
+
+func Test_Chat_ClientDisconnectCancelsWorkflow(t *testing.T) {
+	server, temporal := newTestServer(t)
+
+	// The client disconnects while the workflow is running
+	ctx, disconnect := context.WithCancel(context.Background())
+	run := mocks.NewWorkflowRun(t)
+	run.On("GetID").Return("chat_bot_workflow_1")
+	run.On("GetRunID").Return("run_1")
+	run.On("Get", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		disconnect()
+	}).Return(context.Canceled)
+
+	temporal.On("ExecuteWorkflow", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(run, nil)
+	temporal.On("CancelWorkflow", mock.Anything, "chat_bot_workflow_1", "run_1").Return(nil).Once()
+
+	req := httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(`{"question": "What is the capital of France?", "user": "test_user"}`))
+	req.Header.Set("Content-Type", "application/json")
+	server.Handler().ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+
+	temporal.AssertExpectations(t)
+}
+
+func Test_Chat_Authentication(t *testing.T) {
+	secret := []byte("test-secret")
+	server, temporal := newTestServer(t)
+	server.Auth = &auth.JWTAuthenticator{Secret: secret}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		Name:             "Thiago",
+		Locale:           "pt-BR",
+	}).SignedString(secret)
+	require.NoError(t, err)
+
+	ask := func(authorization, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Unauthenticated requests are rejected
+	rec := ask("", `{"question": "What is the capital of France?", "user": "user-1"}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, `Bearer realm="chatbot"`, rec.Header().Get("WWW-Authenticate"))
+
+	rec = ask("Bearer invalid", `{"question": "What is the capital of France?"}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// The body cannot impersonate another user
+	rec = ask("Bearer "+token, `{"question": "What is the capital of France?", "user": "user-2"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// The identity of the token is sent to the workflow
+	run := mocks.NewWorkflowRun(t)
+	run.On("Get", mock.Anything, mock.Anything).Return(nil)
+	temporal.On("ExecuteWorkflow", mock.Anything, mock.Anything, mock.Anything, codingchallenge.ChatBotQuestion{
+		User:     "user-1",
+		Question: "What is the capital of France?",
+		Name:     "Thiago",
+		Locale:   "pt-BR",
+	}).Return(run, nil).Once()
+
+	rec = ask("Bearer "+token, `{"question": "What is the capital of France?"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// The calculators are public
+	req := httptest.NewRequest(http.MethodPost, "/v1/calculators/unknown", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Authenticated users only read their own history
+	req = httptest.NewRequest(http.MethodGet, "/v1/conversations/user-2", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// The tokens cannot be checked while the JWKS endpoint is down
+	jwks := httptest.NewServer(http.NotFoundHandler())
+	jwks.Close()
+	server.Auth = &auth.JWTAuthenticator{Keys: auth.NewRemoteJWKS(jwks.URL)}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaToken := jwt.NewWithClaims(jwt.SigningMethodRS256, auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	})
+	rsaToken.Header["kid"] = "key-1"
+	signed, err := rsaToken.SignedString(key)
+	require.NoError(t, err)
+	rec = ask("Bearer "+signed, `{"question": "What is the capital of France?"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+// mailer records the emails instead of sending them
+type mailer struct {
+	body string
+}
+
+func (m *mailer) SendEmail(to, subject, body string) error {
+	m.body = body
+	return nil
+}
+
+func Test_Accounts(t *testing.T) {
+	server, temporal := newTestServer(t)
+	m := &mailer{}
+	server.Accounts = accounts.NewService(accounts.NewMemoryStore(), m, "")
+	server.Auth = auth.Chain{accounts.Authenticator{Service: server.Accounts}}
+
+	send := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send(http.MethodPost, "/v1/accounts", "", `{"email": "thiago@example.com", "password": "correct horse", "name": "Thiago"}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "password")
+
+	rec = send(http.MethodPost, "/v1/accounts", "", `{"email": "thiago@example.com", "password": "correct horse"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = send(http.MethodPost, "/v1/sessions", "", `{"email": "thiago@example.com", "password": "wrong password"}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = send(http.MethodPost, "/v1/sessions", "", `{"email": "thiago@example.com", "password": "correct horse"}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var session struct {
+		Token   string            `json:"token"`
+		Account *accounts.Account `json:"account"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&session))
+
+	// The session authenticates the questions and their answers are kept in the history
+	run := mocks.NewWorkflowRun(t)
+	run.On("GetID").Return("chat_bot_workflow_1")
+	run.On("Get", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(1).(**codingchallenge.ChatBotAnswer) = &codingchallenge.ChatBotAnswer{User: session.Account.ID, Answer: "Paris", Status: codingchallenge.StatusAnswered}
+	}).Return(nil)
+	temporal.On("ExecuteWorkflow", mock.Anything, mock.Anything, mock.Anything, codingchallenge.ChatBotQuestion{
+		User:     session.Account.ID,
+		Question: "What is the capital of France?",
+		Name:     "Thiago",
+	}).Return(run, nil).Once()
+
+	rec = send(http.MethodPost, "/chat", session.Token, `{"question": "What is the capital of France?"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	history, err := server.Store.History(context.Background(), session.Account.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "Paris", history[0].Answer)
+
+	// The answers are only emailed once the email is verified, to its address only
+	notifyByEmail := `{"question": "What is the capital of Italy?", "notify": {"email": "Thiago <THIAGO@example.com>"}}`
+	rec = send(http.MethodPost, "/chat", session.Token, notifyByEmail)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = send(http.MethodPost, "/v1/email-verifications", session.Token, "")
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	rec = send(http.MethodPost, "/v1/email-verifications/confirm", "", `{"token": "`+strings.Fields(strings.Split(m.body, "\n\n")[2])[0]+`"}`)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	temporal.On("ExecuteWorkflow", mock.Anything, mock.Anything, mock.Anything, codingchallenge.ChatBotQuestion{
+		User:     session.Account.ID,
+		Question: "What is the capital of Italy?",
+		Name:     "Thiago",
+		Notify:   notify.Channel{Email: "thiago@example.com"},
+	}).Return(run, nil).Once()
+	rec = send(http.MethodPost, "/chat", session.Token, notifyByEmail)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// The session ends with the logout
+	rec = send(http.MethodDelete, "/v1/sessions/current", session.Token, "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = send(http.MethodPost, "/chat", session.Token, `{"question": "What is the capital of France?"}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// Password resets do not disclose whether the email is registered
+	rec = send(http.MethodPost, "/v1/password-resets", "", `{"email": "unknown@example.com"}`)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	rec = send(http.MethodPost, "/v1/password-resets/confirm", "", `{"token": "invalid", "password": "battery staple"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func Test_APIKeys(t *testing.T) {
+	secret := []byte("test-secret")
+	server, temporal := newTestServer(t)
+	server.APIKeys = apikeys.NewService(apikeys.NewMemoryStore())
+	server.Usage = usage.NewMemoryStore()
+	server.Auth = auth.Chain{apikeys.Authenticator{Service: server.APIKeys}, &auth.JWTAuthenticator{Secret: secret}}
+
+	admin, err := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "admin-1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		Scope:            "admin",
+	}).SignedString(secret)
+	require.NoError(t, err)
+
+	send := func(method, path, authorization, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", authorization)
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+	create := func(authorization, body string) (int, apiKeyOutput) {
+		rec := send(http.MethodPost, "/v1/api-keys", authorization, body)
+		var output apiKeyOutput
+		_ = json.NewDecoder(rec.Body).Decode(&output)
+		return rec.Code, output
+	}
+
+	// The admins create the keys of the organizations
+	code, partner := create("Bearer "+admin, `{"organization": "acme", "name": "portal", "scopes": ["chat"], "rate_limit": 1}`)
+	require.Equal(t, http.StatusCreated, code)
+	code, analytics := create("Bearer "+admin, `{"organization": "acme", "name": "reports", "scopes": ["analytics", "admin"]}`)
+	require.Equal(t, http.StatusCreated, code)
+
+	// The keys without the admin scope cannot manage keys, and the admin keys only manage their organization
+	code, _ = create("ApiKey "+partner.Secret, `{"scopes": ["chat"]}`)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = create("ApiKey "+analytics.Secret, `{"organization": "other", "scopes": ["chat"]}`)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, other := create("Bearer "+admin, `{"organization": "other", "scopes": ["chat"]}`)
+	require.Equal(t, http.StatusCreated, code)
+	rec := send(http.MethodPost, "/v1/api-keys/"+other.Key.ID+"/rotate", "ApiKey "+analytics.Secret, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// The partner asks on behalf of its users, kept apart from the users of the other organizations
+	run := mocks.NewWorkflowRun(t)
+	run.On("Get", mock.Anything, mock.Anything).Return(nil)
+	temporal.On("ExecuteWorkflow", mock.Anything, mock.Anything, mock.Anything, codingchallenge.ChatBotQuestion{
+		User:     "acme:alice",
+		Question: "What is the capital of France?",
+	}).Return(run, nil).Once()
+
+	rec = send(http.MethodPost, "/chat", "ApiKey "+partner.Secret, `{"question": "What is the capital of France?", "user": "alice"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+
+	// The key is limited to its rate
+	rec = send(http.MethodPost, "/chat", "ApiKey "+partner.Secret, `{"question": "What is the capital of France?", "user": "alice"}`)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+
+	// The calls are accounted to the organization of the key
+	rec = send(http.MethodGet, "/v1/usage", "ApiKey "+analytics.Secret, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var summaries []usage.Summary
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&summaries))
+	require.Len(t, summaries, 3)
+	assert.Equal(t, usage.Summary{Organization: "acme", Day: summaries[0].Day, Route: "/chat", Requests: 2, Errors: 1}, summaries[0])
+	assert.Equal(t, "POST /v1/api-keys", summaries[1].Route)
+
+	rec = send(http.MethodGet, "/v1/usage", "ApiKey "+partner.Secret, "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// Revoked keys stop working
+	rec = send(http.MethodDelete, "/v1/api-keys/"+partner.Key.ID, "ApiKey "+analytics.Secret, "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = send(http.MethodPost, "/chat", "ApiKey "+partner.Secret, `{"question": "What is the capital of France?", "user": "alice"}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func Test_SearchConversations(t *testing.T) {
+	server, temporal := newTestServer(t)
+	server.APIKeys = apikeys.NewService(apikeys.NewMemoryStore())
+	server.Auth = apikeys.Authenticator{Service: server.APIKeys}
+
+	admin, _, err := server.APIKeys.Create(context.Background(), apikeys.NewKey{Organization: "acme", Scopes: []string{auth.ScopeAdmin}})
+	require.NoError(t, err)
+	partner, _, err := server.APIKeys.Create(context.Background(), apikeys.NewKey{Organization: "acme", Scopes: []string{auth.ScopeChat}})
+	require.NoError(t, err)
+
+	search := func(authorization, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/admin/conversations?"+query, nil)
+		req.Header.Set("Authorization", authorization)
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The keys only find the conversations of the users of their organization
+	temporal.On("ListWorkflow", mock.Anything, &workflowservice.ListWorkflowExecutionsRequest{
+		PageSize:      5,
+		NextPageToken: []byte("page-2"),
+		Query: "WorkflowType IN ('ChatBotWorkflow', 'DeferredAnswerWorkflow') AND Outcome = 'failed' AND " +
+			"User STARTS_WITH 'acme:'",
+	}).Return(&workflowservice.ListWorkflowExecutionsResponse{
+		Executions: []*workflowpb.WorkflowExecutionInfo{{
+			Execution: &commonpb.WorkflowExecution{WorkflowId: "chat_1", RunId: "run_1"},
+			Type:      &commonpb.WorkflowType{Name: "ChatBotWorkflow"},
+			Status:    enums.WORKFLOW_EXECUTION_STATUS_FAILED,
+			StartTime: timestamppb.Now(),
+		}},
+		NextPageToken: []byte("page-3"),
+	}, nil).Once()
+
+	rec := search("ApiKey "+admin, "query=outcome:failed&page_size=5&page_token="+base64.RawURLEncoding.EncodeToString([]byte("page-2")))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var page conversationPage
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+	require.Len(t, page.Conversations, 1)
+	assert.Equal(t, "chat_1", page.Conversations[0].WorkflowID)
+	assert.Equal(t, "Failed", page.Conversations[0].Status)
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString([]byte("page-3")), page.NextPageToken)
+
+	// The invalid searches are rejected before reaching Temporal
+	assert.Equal(t, http.StatusBadRequest, search("ApiKey "+admin, "query=color:blue").Code)
+	assert.Equal(t, http.StatusBadRequest, search("ApiKey "+admin, "page_size=500").Code)
+	assert.Equal(t, http.StatusBadRequest, search("ApiKey "+admin, "page_token=%21").Code)
+	assert.Equal(t, http.StatusForbidden, search("ApiKey "+partner, "").Code)
+
+	// The visibility store may be unavailable
+	temporal.On("ListWorkflow", mock.Anything, mock.Anything).Return(nil, serviceerror.NewUnavailable("connection refused")).Once()
+	rec = search("ApiKey "+admin, "")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "connection refused")
+}
+
+// workflowService answers the requests of the admin operations missing from the Temporal client
+type workflowService struct {
+	workflowservice.WorkflowServiceClient
+	cancel *workflowservice.RequestCancelWorkflowExecutionRequest
+	batch  *workflowservice.StartBatchOperationRequest
+}
+
+func (s *workflowService) RequestCancelWorkflowExecution(_ context.Context, req *workflowservice.RequestCancelWorkflowExecutionRequest, _ ...grpc.CallOption) (*workflowservice.RequestCancelWorkflowExecutionResponse, error) {
+	s.cancel = req
+	return &workflowservice.RequestCancelWorkflowExecutionResponse{}, nil
+}
+
+func (s *workflowService) StartBatchOperation(_ context.Context, req *workflowservice.StartBatchOperationRequest, _ ...grpc.CallOption) (*workflowservice.StartBatchOperationResponse, error) {
+	s.batch = req
+	return &workflowservice.StartBatchOperationResponse{}, nil
+}
+
+func (s *workflowService) DescribeBatchOperation(_ context.Context, req *workflowservice.DescribeBatchOperationRequest, _ ...grpc.CallOption) (*workflowservice.DescribeBatchOperationResponse, error) {
+	if s.batch == nil || req.GetJobId() != s.batch.GetJobId() {
+		return nil, serviceerror.NewNotFound("batch operation not found")
+	}
+	return &workflowservice.DescribeBatchOperationResponse{
+		JobId:               req.GetJobId(),
+		State:               enums.BATCH_OPERATION_STATE_RUNNING,
+		Reason:              s.batch.GetReason(),
+		StartTime:           timestamppb.Now(),
+		TotalOperationCount: 2,
+	}, nil
+}
+
+// mockConversationOperations mocks the admin operations on the conversations of acme: chat_1 is running, chat_2 failed
+// and chat_3 belongs to another organization. It returns the service recording the cancellations and the batch jobs.
+func mockConversationOperations(temporal *mocks.Client) *workflowService {
+	describe := func(workflowID, user string, status enums.WorkflowExecutionStatus) {
+		payload, _ := converter.GetDefaultDataConverter().ToPayload(user)
+		temporal.On("DescribeWorkflowExecution", mock.Anything, workflowID, "").Return(&workflowservice.DescribeWorkflowExecutionResponse{
+			WorkflowExecutionInfo: &workflowpb.WorkflowExecutionInfo{
+				Execution:        &commonpb.WorkflowExecution{WorkflowId: workflowID, RunId: workflowID + "_run"},
+				Type:             &commonpb.WorkflowType{Name: "ChatBotWorkflow"},
+				Status:           status,
+				StartTime:        timestamppb.Now(),
+				SearchAttributes: &commonpb.SearchAttributes{IndexedFields: map[string]*commonpb.Payload{search.User.GetName(): payload}},
+			},
+		}, nil).Maybe()
+	}
+	describe("chat_1", "acme:alice", enums.WORKFLOW_EXECUTION_STATUS_RUNNING)
+	describe("chat_2", "acme:alice", enums.WORKFLOW_EXECUTION_STATUS_FAILED)
+	describe("chat_3", "other:bob", enums.WORKFLOW_EXECUTION_STATUS_RUNNING)
+	temporal.On("DescribeWorkflowExecution", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, serviceerror.NewNotFound("workflow not found")).Maybe()
+
+	service := &workflowService{}
+	temporal.On("WorkflowService").Return(service).Maybe()
+	temporal.On("TerminateWorkflow", mock.Anything, "chat_1", "chat_1_run", mock.Anything).Return(nil).Maybe()
+	temporal.On("ResetWorkflowExecution", mock.Anything, mock.Anything).
+		Return(&workflowservice.ResetWorkflowExecutionResponse{RunId: "chat_1_reset"}, nil).Maybe()
+	temporal.On("CountWorkflow", mock.Anything, mock.Anything).Return(&workflowservice.CountWorkflowExecutionsResponse{Count: 2}, nil).Maybe()
+	return service
+}
+
+func Test_AdminOperations(t *testing.T) {
+	server, temporal := newTestServer(t)
+	server.APIKeys = apikeys.NewService(apikeys.NewMemoryStore())
+	server.Auth = apikeys.Authenticator{Service: server.APIKeys}
+	service := mockConversationOperations(temporal)
+
+	admin, key, err := server.APIKeys.Create(context.Background(), apikeys.NewKey{Organization: "acme", Scopes: []string{auth.ScopeAdmin}})
+	require.NoError(t, err)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "ApiKey "+admin)
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The conversations of the other organizations are not found
+	rec := send(http.MethodGet, "/v1/admin/conversations/chat_1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"user":"acme:alice"`)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodGet, "/v1/admin/conversations/chat_3", "").Code)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodPost, "/v1/admin/conversations/chat_3/terminate", `{"reason": "spam"}`).Code)
+
+	// The running conversations are canceled with the reason, the closed ones cannot be
+	rec = send(http.MethodPost, "/v1/admin/conversations/chat_1/cancel", `{"reason": " stuck on the provider "}`)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "chat_1_run", service.cancel.GetWorkflowExecution().GetRunId())
+	assert.Equal(t, "stuck on the provider", service.cancel.GetReason())
+	assert.Equal(t, http.StatusConflict, send(http.MethodPost, "/v1/admin/conversations/chat_2/cancel", `{"reason": "stuck"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, send(http.MethodPost, "/v1/admin/conversations/chat_1/cancel", `{}`).Code)
+
+	// The failed conversation is reset to its first workflow task
+	rec = send(http.MethodPost, "/v1/admin/conversations/chat_2/reset", `{"reason": "bad answer", "event_id": 4}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"run_id": "chat_1_reset"}`, rec.Body.String())
+
+	// The retries are restricted to the failed conversations of the organization
+	rec = send(http.MethodPost, "/v1/admin/retries", `{"query": "intent:work", "reason": "provider outage"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	var retry retryOutput
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&retry))
+	assert.Equal(t, "WorkflowType IN ('ChatBotWorkflow', 'DeferredAnswerWorkflow') AND ExecutionStatus = 'Failed' AND "+
+		"Intent = 'work' AND User STARTS_WITH 'acme:'", service.batch.GetVisibilityQuery())
+	assert.Equal(t, "/v1/admin/retries/"+retry.JobID, rec.Header().Get("Location"))
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/v1/admin/retries/"+retry.JobID, "").Code)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodGet, "/v1/admin/retries/other:retry-1", "").Code)
+
+	// Every action is audited, the most recent first
+	rec = send(http.MethodGet, "/v1/admin/audit", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var entries []audit.Entry
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&entries))
+	require.Len(t, entries, 3)
+	assert.Equal(t, audit.ActionRetry, entries[0].Action)
+	assert.Equal(t, retry.JobID, entries[0].Target)
+	assert.Equal(t, audit.ActionReset, entries[1].Action)
+	assert.Equal(t, "new run chat_1_reset", entries[1].Detail)
+	assert.Equal(t, audit.Entry{
+		Time: entries[2].Time, Actor: "apikey:" + key.ID, Organization: "acme", Action: audit.ActionCancel,
+		Target: "chat_1", RunID: "chat_1_run", Reason: "stuck on the provider",
+	}, entries[2])
+}
+
+func Test_AdminOperations_AuthDisabled(t *testing.T) {
+	// Without authenticator the questions stay open but the operations are refused, nothing reaches Temporal
+	server, _ := newTestServer(t)
+	server.Usage = usage.NewMemoryStore()
+
+	for _, r := range []struct{ method, path, body string }{
+		{http.MethodGet, "/v1/admin/conversations/chat_1", ""},
+		{http.MethodPost, "/v1/admin/conversations/chat_1/terminate", `{"reason": "spam"}`},
+		{http.MethodPost, "/v1/admin/retries", `{"reason": "provider outage"}`},
+		{http.MethodGet, "/v1/admin/audit", ""},
+		{http.MethodPost, "/v1/batches", `{"input": "in.jsonl", "output": "out.jsonl"}`},
+		{http.MethodGet, "/v1/usage", ""},
+	} {
+		req := httptest.NewRequest(r.method, r.path, strings.NewReader(r.body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code, r.path)
+		assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"), r.path)
+	}
+}
+
+// deadLetter is a question of the user that no provider could answer
+func deadLetter(id, user string) deadletter.Letter {
+	question := codingchallenge.ChatBotQuestion{User: user, Question: "How do I renew my visa?"}
+	input, _ := json.Marshal(question)
+	return deadletter.Letter{
+		ID: id, WorkflowID: "chat_bot_workflow_" + id, User: user, Question: question.Question, Input: input,
+		ErrorType: codingchallenge.AllProvidersFailedErrorType, Error: "no provider could answer", Attempts: 51,
+		StartedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), FailedAt: time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func Test_DeadLetters(t *testing.T) {
+	server, temporal := newTestServer(t)
+	server.APIKeys = apikeys.NewService(apikeys.NewMemoryStore())
+	server.Auth = apikeys.Authenticator{Service: server.APIKeys}
+	require.NoError(t, server.DeadLetters.Record(context.Background(), deadLetter("run_1", "acme:alice")))
+	require.NoError(t, server.DeadLetters.Record(context.Background(), deadLetter("run_2", "other:bob")))
+
+	admin, _, err := server.APIKeys.Create(context.Background(), apikeys.NewKey{Organization: "acme", Scopes: []string{auth.ScopeAdmin}})
+	require.NoError(t, err)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "ApiKey "+admin)
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The questions of the other organizations are not listed nor found
+	rec := send(http.MethodGet, "/v1/admin/dead-letters?from=2024-05-02&to=2024-05-02", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var letters []deadletter.Letter
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&letters))
+	require.Len(t, letters, 1)
+	assert.Equal(t, "run_1", letters[0].ID)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodGet, "/v1/admin/dead-letters/run_2", "").Code)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodPost, "/v1/admin/dead-letters/run_2/replay", `{"reason": "outage"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/v1/admin/dead-letters?from=yesterday", "").Code)
+
+	// The question is replayed by a new workflow with the fallback model and the other prompt version
+	run := mocks.NewWorkflowRun(t)
+	run.On("GetID").Return("chat_bot_workflow_2")
+	temporal.On("ExecuteWorkflow", mock.Anything, mock.Anything, mock.Anything, codingchallenge.ChatBotQuestion{
+		User: "acme:alice", Question: "How do I renew my visa?", Model: "gpt-4o-mini", PromptVersion: codingchallenge.PromptV2,
+	}).Return(run, nil).Once()
+
+	assert.Equal(t, http.StatusUnprocessableEntity, send(http.MethodPost, "/v1/admin/dead-letters/run_1/replay", `{"reason": "outage", "prompt_version": "v0"}`).Code)
+
+	// Only the configured models are accepted, the models label the metrics of the calls
+	rec = send(http.MethodPost, "/v1/admin/dead-letters/run_1/replay", `{"reason": "outage", "model": "gpt-4.1"}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	var problem Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, []FieldError{{Field: "model", Detail: "must be one of gpt-4o-2024-05-13, gpt-4o-mini"}}, problem.Errors)
+
+	rec = send(http.MethodPost, "/v1/admin/dead-letters/run_1/replay", `{"reason": "outage", "model": "gpt-4o-mini", "prompt_version": "v2"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	assert.JSONEq(t, `{"workflow_id": "chat_bot_workflow_2"}`, rec.Body.String())
+	assert.Equal(t, "/v1/admin/conversations/chat_bot_workflow_2", rec.Header().Get("Location"))
+
+	// The replayed questions are no longer pending and the replay is audited
+	rec = send(http.MethodGet, "/v1/admin/dead-letters?pending=true", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+
+	entries, err := server.Audit.List(context.Background(), "acme", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, audit.ActionReplay, entries[0].Action)
+	assert.Equal(t, "run_1", entries[0].Target)
+	assert.Equal(t, "workflow chat_bot_workflow_2", entries[0].Detail)
+}
+
+func Test_ChatV1(t *testing.T) {
+	server, temporal := newTestServer(t)
+
+	run := mocks.NewWorkflowRun(t)
+	run.On("Get", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(1).(**codingchallenge.ChatBotAnswer) = &codingchallenge.ChatBotAnswer{User: "test_user", Answer: "Paris", Status: codingchallenge.StatusAnswered}
+	}).Return(nil)
+	temporal.On("ExecuteWorkflow", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(run, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat", strings.NewReader(`{"question": "What is the capital of France?", "user": "test_user"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Deprecation"))
+
+	var answer ChatResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&answer))
+	assert.True(t, strings.HasPrefix(answer.ID, "chat_bot_workflow_"))
+	assert.Equal(t, "Paris", answer.Answer)
+	assert.Equal(t, codingchallenge.StatusAnswered, answer.Status)
+
+	// The legacy route is deprecated in favor of the versioned one
+	rec = postQuestion(server, `{"question": "What is the capital of France?", "user": "test_user"}`)
+	assert.Equal(t, "true", rec.Header().Get("Deprecation"))
+	assert.Equal(t, `</v1/chat>; rel="successor-version"`, rec.Header().Get("Link"))
+}
+
+func Test_Health(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.IPLimit = ratelimit.PerMinute(1)
+
+	probe := func(path string) (*httptest.ResponseRecorder, health.Report) {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var report health.Report
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		return rec, report
+	}
+
+	// A failing LLM provider degrades the API but keeps it ready
+	temporalErr := error(nil)
+	server.Health = health.NewChecker(
+		health.Check{Name: "temporal", Critical: true, Run: func(context.Context) error { return temporalErr }},
+		health.Check{Name: "llm:openai", Run: func(context.Context) error { return errors.New("connection refused") }},
+	)
+	rec, report := probe("/readyz")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, health.StatusDegraded, report.Status)
+	assert.Equal(t, health.StatusOK, report.Checks["temporal"].Status)
+	assert.Equal(t, "connection refused", report.Checks["llm:openai"].Error)
+
+	// Temporal is critical
+	temporalErr = errors.New("namespace not found")
+	rec, report = probe("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, health.StatusFail, report.Status)
+
+	// The liveness does not depend on Temporal, and the probes are not rate limited
+	for range 3 {
+		rec, report = probe("/healthz")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, health.StatusOK, report.Status)
+		assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func Test_Metrics(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.IPLimit = ratelimit.PerMinute(1)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/calculators/unknown", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	server.Handler().ServeHTTP(httptest.NewRecorder(), req)
+
+	// The metrics are labeled with the route pattern and are not rate limited
+	for range 2 {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `chatbot_http_requests_total{method="POST",route="POST /v1/calculators/{name}",status="404"}`)
+	}
+}
+
+func Test_RequestID(t *testing.T) {
+	server, temporal := newTestServer(t)
+
+	run := mocks.NewWorkflowRun(t)
+	run.On("Get", mock.Anything, mock.Anything).Return(nil)
+
+	// The request ID of the caller is kept in the memo of the workflow and in the context propagated to the worker
+	temporal.On("ExecuteWorkflow", mock.MatchedBy(func(ctx context.Context) bool {
+		correlation := logging.CorrelationFrom(ctx)
+		return correlation.RequestID == "req-42" && correlation.User == "test_user"
+	}), mock.MatchedBy(func(options client.StartWorkflowOptions) bool {
+		return options.Memo[logging.MemoRequestID] == "req-42"
+	}), mock.Anything, mock.Anything).Return(run, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat", strings.NewReader(`{"question": "What is the capital of France?", "user": "test_user"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(logging.HeaderRequestID, "req-42")
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "req-42", rec.Header().Get(logging.HeaderRequestID))
+
+	// An invalid request ID is replaced by a generated one
+	req = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set(logging.HeaderRequestID, "not a valid id\n")
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	assert.Regexp(t, `^[0-9a-f-]{36}$`, rec.Header().Get(logging.HeaderRequestID))
+}
+
+// mockQuestionBatch mocks the running batch of the ID halfway through its questions
+func mockQuestionBatch(temporal *mocks.Client, batchID string) {
+	temporal.On("DescribeWorkflowExecution", mock.Anything, batchID, "").Return(&workflowservice.DescribeWorkflowExecutionResponse{
+		WorkflowExecutionInfo: &workflowpb.WorkflowExecutionInfo{
+			Execution: &commonpb.WorkflowExecution{WorkflowId: batchID, RunId: batchID + "_run"},
+			Type:      &commonpb.WorkflowType{Name: "BatchQuestionsWorkflow"},
+			Status:    enums.WORKFLOW_EXECUTION_STATUS_RUNNING,
+			StartTime: timestamppb.Now(),
+		},
+	}, nil).Maybe()
+
+	value := &mocks.Value{}
+	value.On("Get", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*codingchallenge.BatchProgress) = codingchallenge.BatchProgress{
+			Input: "in.jsonl", Output: "out.jsonl", Questions: 100, Answered: 90, Queued: 2, Failed: 3, Running: 5,
+		}
+	}).Return(nil)
+	temporal.On("QueryWorkflow", mock.Anything, batchID, "", codingchallenge.BatchProgressQuery).Return(value, nil).Maybe()
+}
+
+func Test_Batches(t *testing.T) {
+	server, temporal := newTestServer(t)
+	server.APIKeys = apikeys.NewService(apikeys.NewMemoryStore())
+	server.Auth = apikeys.Authenticator{Service: server.APIKeys}
+	server.BatchHosts = []string{"bucket.example.com"}
+	mockQuestionBatch(temporal, "acme:batch-1")
+
+	admin, _, err := server.APIKeys.Create(context.Background(), apikeys.NewKey{Organization: "acme", Scopes: []string{auth.ScopeAdmin}})
+	require.NoError(t, err)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "ApiKey "+admin)
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The API keys do not read nor write the batch directory shared by the organizations
+	rec := send(http.MethodPost, "/v1/batches", `{"input": "in.jsonl", "output": "../out.jsonl", "concurrency": -1}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	var problem Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, []FieldError{
+		{Field: "input", Detail: "must be an http or https URL"},
+		{Field: "output", Detail: "must be a relative path in the batch directory or an http or https URL"},
+		{Field: "concurrency", Detail: "must be between 1 and 50"},
+	}, problem.Errors)
+
+	// The workers do not reach the other hosts, such as the metadata endpoint of the cloud
+	rec = send(http.MethodPost, "/v1/batches", `{"input": "http://169.254.169.254/latest/meta-data/", "output": "https://bucket.example.com/out.jsonl"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, []FieldError{{Field: "input", Detail: "must be a URL of one of the allowed hosts: bucket.example.com"}}, problem.Errors)
+
+	// The questions of the batch are asked for the users of the organization
+	run := mocks.NewWorkflowRun(t)
+	temporal.On("ExecuteWorkflow", mock.Anything, mock.MatchedBy(func(options client.StartWorkflowOptions) bool {
+		return strings.HasPrefix(options.ID, "acme:batch-")
+	}), mock.Anything, codingchallenge.BatchInput{
+		Input: "https://bucket.example.com/in.jsonl", Output: "https://bucket.example.com/out.jsonl", Concurrency: 5, UserPrefix: "acme:",
+	}).Return(run, nil).Once()
+
+	rec = send(http.MethodPost, "/v1/batches", `{"input": "https://bucket.example.com/in.jsonl", "output": "https://bucket.example.com/out.jsonl"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	var started batchOutput
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &started))
+	assert.Equal(t, "/v1/batches/"+started.BatchID, rec.Header().Get("Location"))
+	assert.Equal(t, 5, started.Concurrency)
+
+	// The progress of the running batch is queried, the batches of the other organizations are not found
+	rec = send(http.MethodGet, "/v1/batches/acme:batch-1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"progress":{"input":"in.jsonl","output":"out.jsonl","questions":100,"answered":90,"queued":2,"failed":3,"running":5,"done":false}`)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodGet, "/v1/batches/other:batch-1", "").Code)
+}
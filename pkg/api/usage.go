@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultUsagePeriod is the period summarized when the query has no from date
+const defaultUsagePeriod = 30 * 24 * time.Hour
+
+// usageHandler returns the daily usage of the organizations between the from and to dates of the query, both inclusive.
+// The API keys only read the usage of their organization.
+func (s *Server) usageHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	organization := query.Get("organization")
+	if caller := callerOrganization(r); caller != "" {
+		organization = caller
+	}
+
+	from, to, problem := parsePeriod(query)
+	if problem != nil {
+		writeProblem(w, r, problem)
+		return
+	}
+
+	summaries, err := s.Usage.Summary(r.Context(), organization, from, to)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Unable to read usage", "Error", err)
+		writeError(w, r, http.StatusInternalServerError, "Unable to read usage")
+		return
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+// parsePeriod returns the period between the from and to dates of the query, both inclusive, the returned to is the
+// end of its day. The period defaults to the last defaultUsagePeriod.
+func parsePeriod(query url.Values) (from, to time.Time, problem *Problem) {
+	to = time.Now().UTC().Truncate(24 * time.Hour)
+	from = to.Add(-defaultUsagePeriod)
+	for name, date := range map[string]*time.Time{"from": &from, "to": &to} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.DateOnly, value)
+			if err != nil {
+				return from, to, newProblem(http.StatusBadRequest, name+" must be a date formatted as YYYY-MM-DD")
+			}
+			*date = parsed
+		}
+	}
+	return from, to.Add(24 * time.Hour), nil
+}
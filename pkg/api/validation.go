@@ -0,0 +1,102 @@
+package main
+
+import (
+	"code-challenge/pkg/notify"
+	codingchallenge "code-challenge/pkg/workflow"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxBodyBytes limits the size of the request bodies
+const maxBodyBytes = 64 << 10
+
+// maxQuestionLength limits the number of characters of a question
+const maxQuestionLength = 2000
+
+// maxUserLength limits the number of characters of a user name
+const maxUserLength = 200
+
+// decodeJSON reads the JSON request body into v.
+// The body must be sent as application/json, fit in maxBodyBytes and only hold known fields.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) *Problem {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return newProblem(http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return newProblem(http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body must not be larger than %d bytes", maxBodyBytes))
+		}
+		if errors.Is(err, io.EOF) {
+			return newProblem(http.StatusBadRequest, "Request body must not be empty")
+		}
+		return newProblem(http.StatusBadRequest, "Request body is not valid JSON: "+err.Error())
+	}
+
+	// The body must hold a single JSON value
+	if decoder.More() {
+		return newProblem(http.StatusBadRequest, "Request body must hold a single JSON object")
+	}
+	return nil
+}
+
+// validate checks the fields of the chat request, the answers are only posted to the webhooks on the hosts
+// and emailed to the verified email of the caller
+func (c ChatBotRequestInput) validate(webhookHosts []string, verifiedEmail string) *Problem {
+	var errs []FieldError
+
+	switch question := strings.TrimSpace(c.Question); {
+	case question == "":
+		errs = append(errs, FieldError{Field: "question", Detail: "must not be empty"})
+	case utf8.RuneCountInString(question) > maxQuestionLength:
+		errs = append(errs, FieldError{Field: "question", Detail: fmt.Sprintf("must have at most %d characters", maxQuestionLength)})
+	}
+
+	switch user := strings.TrimSpace(c.User); {
+	case user == "":
+		errs = append(errs, FieldError{Field: "user", Detail: "must not be empty"})
+	case utf8.RuneCountInString(user) > maxUserLength:
+		errs = append(errs, FieldError{Field: "user", Detail: fmt.Sprintf("must have at most %d characters", maxUserLength)})
+	}
+
+	switch c.Format {
+	case "", codingchallenge.FormatText, codingchallenge.FormatStructured:
+	default:
+		errs = append(errs, FieldError{Field: "format", Detail: fmt.Sprintf("must be %q or %q", codingchallenge.FormatText, codingchallenge.FormatStructured)})
+	}
+
+	if c.Notify.Webhook != "" {
+		if u, err := url.Parse(c.Notify.Webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, FieldError{Field: "notify.webhook", Detail: "must be an http or https URL"})
+		} else if notify.AllowedWebhook(c.Notify.Webhook, webhookHosts) != nil {
+			errs = append(errs, FieldError{Field: "notify.webhook", Detail: "must be on an allowed host"})
+		}
+	}
+	if c.Notify.Email != "" {
+		if address, err := mail.ParseAddress(c.Notify.Email); err != nil {
+			errs = append(errs, FieldError{Field: "notify.email", Detail: "must be an email address"})
+		} else if verifiedEmail == "" || !strings.EqualFold(address.Address, verifiedEmail) {
+			errs = append(errs, FieldError{Field: "notify.email", Detail: "must be the verified email of the authenticated user"})
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	problem := newProblem(http.StatusUnprocessableEntity, "The request has invalid fields")
+	problem.Errors = errs
+	return problem
+}
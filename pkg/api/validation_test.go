@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/temporal"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serve sends the request to the chat handler and decodes the problem returned
+func serve(t *testing.T, method, contentType, body string) (*httptest.ResponseRecorder, Problem) {
+	req := httptest.NewRequest(method, "/chat", strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	server, _ := newTestServer(t)
+	server.Handler().ServeHTTP(rec, req)
+
+	var problem Problem
+	assert.Equal(t, problemContentType, rec.Header().Get("Content-Type"))
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&problem))
+	assert.Equal(t, rec.Code, problem.Status)
+	assert.Equal(t, "/chat", problem.Instance)
+	return rec, problem
+}
+
+func Test_Handler_Validation(t *testing.T) {
+	tests := map[string]struct {
+		method      string
+		contentType string
+		body        string
+		status      int
+	}{
+		"method":          {http.MethodGet, "application/json", `{"question": "Hi", "user": "u"}`, http.StatusMethodNotAllowed},
+		"content type":    {http.MethodPost, "text/plain", `{"question": "Hi", "user": "u"}`, http.StatusUnsupportedMediaType},
+		"no content type": {http.MethodPost, "", `{"question": "Hi", "user": "u"}`, http.StatusUnsupportedMediaType},
+		"empty body":      {http.MethodPost, "application/json", ``, http.StatusBadRequest},
+		"invalid json":    {http.MethodPost, "application/json", `{"question": `, http.StatusBadRequest},
+		"unknown field":   {http.MethodPost, "application/json", `{"question": "Hi", "user": "u", "admin": true}`, http.StatusBadRequest},
+		"two values":      {http.MethodPost, "application/json", `{"question": "Hi", "user": "u"} {}`, http.StatusBadRequest},
+		"too large":       {http.MethodPost, "application/json", `{"question": "` + strings.Repeat("a", maxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge},
+		"missing fields":  {http.MethodPost, "application/json; charset=utf-8", `{"question": "  "}`, http.StatusUnprocessableEntity},
+	}
+
+	for name, test := range tests {
+		rec, problem := serve(t, test.method, test.contentType, test.body)
+		assert.Equal(t, test.status, rec.Code, name)
+		assert.Equal(t, http.StatusText(test.status), problem.Title, name)
+	}
+}
+
+func Test_Handler_FieldErrors(t *testing.T) {
+	body := `{"question": "` + strings.Repeat("é", maxQuestionLength+1) + `", "user": "", "format": "html",
+		"notify": {"webhook": "ftp://example.com", "email": "not an email"}}`
+
+	_, problem := serve(t, http.MethodPost, "application/json", body)
+	assert.Equal(t, []FieldError{
+		{Field: "question", Detail: "must have at most 2000 characters"},
+		{Field: "user", Detail: "must not be empty"},
+		{Field: "format", Detail: `must be "text" or "structured"`},
+		{Field: "notify.webhook", Detail: "must be an http or https URL"},
+		{Field: "notify.email", Detail: "must be an email address"},
+	}, problem.Errors)
+
+	// The answers are only posted to the allowed hosts and emailed to the verified email of the authenticated user
+	body = `{"question": "Hi", "user": "u", "notify": {"webhook": "http://169.254.169.254/latest", "email": "Thiago <thiago@example.com>"}}`
+	_, problem = serve(t, http.MethodPost, "application/json", body)
+	assert.Equal(t, []FieldError{
+		{Field: "notify.webhook", Detail: "must be on an allowed host"},
+		{Field: "notify.email", Detail: "must be the verified email of the authenticated user"},
+	}, problem.Errors)
+}
+
+func Test_WorkflowProblem(t *testing.T) {
+	tests := map[int]error{
+		http.StatusConflict:            serviceerror.NewWorkflowExecutionAlreadyStarted("started", "", ""),
+		http.StatusServiceUnavailable:  serviceerror.NewUnavailable("down"),
+		http.StatusTooManyRequests:     serviceerror.NewResourceExhausted(0, "busy"),
+		http.StatusGatewayTimeout:      serviceerror.NewDeadlineExceeded("late"),
+		http.StatusInternalServerError: temporal.NewApplicationError("failed", "Internal"),
+	}
+
+	for status, err := range tests {
+		assert.Equal(t, status, workflowProblem(err).Status, err.Error())
+	}
+	assert.Equal(t, http.StatusInternalServerError, workflowProblem(errors.New("boom")).Status)
+}
@@ -0,0 +1,227 @@
+package apikeys
+
+import (
+	"code-challenge/pkg/auth"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+	"time"
+)
+
+// KeyPrefix starts every API key so leaked keys can be found by secret scanners
+const KeyPrefix = "ck_"
+
+// Rules of the keys
+const (
+	// DefaultRateLimit is the number of requests per minute of the keys created without limit
+	DefaultRateLimit = 60
+	maxRateLimit     = 10000
+	maxNameLength    = 100
+
+	// displayedLength is the number of characters of the secret shown to tell the keys apart
+	displayedLength = len(KeyPrefix) + 8
+
+	// rotationGrace is how long the previous secret of a rotated key keeps working, so the partners can deploy the new one
+	rotationGrace = 24 * time.Hour
+)
+
+// Scopes that can be granted to the keys
+var Scopes = []string{auth.ScopeChat, auth.ScopeAdmin, auth.ScopeAnalytics}
+
+// Errors returned by the API keys service
+var (
+	ErrInvalidInput = errors.New("invalid API key input")
+	ErrInvalidKey   = errors.New("invalid or revoked API key")
+	ErrNotFound     = errors.New("not found")
+)
+
+// Key is an API key of a partner organization, only the hash of its secret is stored
+type Key struct {
+	ID           string   `json:"id"`
+	Organization string   `json:"organization"`
+	Name         string   `json:"name"`
+	Scopes       []string `json:"scopes"`
+
+	// RateLimit is the number of requests per minute allowed to the key
+	RateLimit int `json:"rate_limit"`
+
+	// Prefix is the beginning of the secret, it tells the keys apart without disclosing them
+	Prefix     string `json:"prefix"`
+	SecretHash string `json:"-"`
+
+	// PreviousSecretHash is the hash of the secret replaced by the last rotation, it works until PreviousExpiresAt
+	PreviousSecretHash string     `json:"-"`
+	PreviousExpiresAt  *time.Time `json:"previous_expires_at,omitempty"`
+
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// NewKey is the input creating a key
+type NewKey struct {
+	Organization string   `json:"organization"`
+	Name         string   `json:"name"`
+	Scopes       []string `json:"scopes"`
+	RateLimit    int      `json:"rate_limit"`
+}
+
+// Service manages the API keys
+type Service struct {
+	Store Store
+
+	// now returns the current time, it is replaced in tests
+	now func() time.Time
+}
+
+// NewService creates the API keys service
+func NewService(store Store) *Service {
+	return &Service{Store: store, now: time.Now}
+}
+
+// Create creates a key and returns its secret, the secret cannot be read again
+func (s *Service) Create(ctx context.Context, input NewKey) (string, *Key, error) {
+	if err := input.validate(); err != nil {
+		return "", nil, err
+	}
+	if input.RateLimit == 0 {
+		input.RateLimit = DefaultRateLimit
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := &Key{
+		ID:           uuid.NewString(),
+		Organization: strings.TrimSpace(input.Organization),
+		Name:         strings.TrimSpace(input.Name),
+		Scopes:       input.Scopes,
+		RateLimit:    input.RateLimit,
+		Prefix:       secret[:displayedLength],
+		SecretHash:   hashSecret(secret),
+		CreatedAt:    s.now().UTC(),
+	}
+	if err := s.Store.CreateKey(ctx, key); err != nil {
+		return "", nil, err
+	}
+	return secret, key, nil
+}
+
+// List returns the keys of the organization, or the keys of every organization when it is empty
+func (s *Service) List(ctx context.Context, organization string) ([]Key, error) {
+	return s.Store.Keys(ctx, organization)
+}
+
+// Get returns the key with the ID
+func (s *Service) Get(ctx context.Context, id string) (*Key, error) {
+	return s.Store.KeyByID(ctx, id)
+}
+
+// Revoke stops the key and its previous secret from working
+func (s *Service) Revoke(ctx context.Context, id string) (*Key, error) {
+	key, err := s.Store.KeyByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt == nil {
+		now := s.now().UTC()
+		key.RevokedAt = &now
+		if err := s.Store.UpdateKey(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// Rotate replaces the secret of the key and returns the new one.
+// The previous secret keeps working for a grace period so the partner can deploy the new one.
+func (s *Service) Rotate(ctx context.Context, id string) (string, *Key, error) {
+	key, err := s.Store.KeyByID(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+	if key.RevokedAt != nil {
+		return "", nil, fmt.Errorf("%w: revoked keys cannot be rotated", ErrInvalidInput)
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return "", nil, err
+	}
+
+	expiresAt := s.now().Add(rotationGrace).UTC()
+	key.PreviousSecretHash, key.PreviousExpiresAt = key.SecretHash, &expiresAt
+	key.SecretHash, key.Prefix = hashSecret(secret), secret[:displayedLength]
+	if err := s.Store.UpdateKey(ctx, key); err != nil {
+		return "", nil, err
+	}
+	return secret, key, nil
+}
+
+// Authenticate returns the key of the secret, its previous secret is accepted until the end of the grace period
+func (s *Service) Authenticate(ctx context.Context, secret string) (*Key, error) {
+	hash := hashSecret(secret)
+	key, err := s.Store.KeyByHash(ctx, hash)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if key.RevokedAt != nil {
+		return nil, ErrInvalidKey
+	}
+	if hash == key.PreviousSecretHash && !s.now().Before(*key.PreviousExpiresAt) {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// validate checks the rules of the keys
+func (k NewKey) validate() error {
+	organization := strings.TrimSpace(k.Organization)
+	switch {
+	case organization == "":
+		return fmt.Errorf("%w: organization is required", ErrInvalidInput)
+	case strings.Contains(organization, ":"):
+		// The users of the organizations are named "{organization}:{user}"
+		return fmt.Errorf("%w: organization must not contain ':'", ErrInvalidInput)
+	case len([]rune(k.Name)) > maxNameLength:
+		return fmt.Errorf("%w: name must have at most %d characters", ErrInvalidInput, maxNameLength)
+	case len(k.Scopes) == 0:
+		return fmt.Errorf("%w: scopes must not be empty", ErrInvalidInput)
+	case k.RateLimit < 0 || k.RateLimit > maxRateLimit:
+		return fmt.Errorf("%w: rate_limit must be between 1 and %d requests per minute", ErrInvalidInput, maxRateLimit)
+	}
+
+	for _, scope := range k.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return fmt.Errorf("%w: scope must be one of %s", ErrInvalidInput, strings.Join(Scopes, ", "))
+		}
+	}
+	return nil
+}
+
+// newSecret returns a random secret
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return KeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSecret returns the hash under which a secret is stored, the secrets are random so a fast hash is enough
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
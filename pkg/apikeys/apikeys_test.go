@@ -0,0 +1,127 @@
+package apikeys
+
+import (
+	"code-challenge/pkg/auth"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestService creates a service with an in memory store and a clock the test controls
+func newTestService() (*Service, *time.Time) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	service := NewService(NewMemoryStore())
+	service.now = func() time.Time { return now }
+	return service, &now
+}
+
+// withKey returns a request carrying the API key
+func withKey(secret string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "ApiKey "+secret)
+	return r
+}
+
+func Test_Create(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestService()
+
+	secret, key, err := service.Create(ctx, NewKey{Organization: "acme", Name: "portal", Scopes: []string{auth.ScopeChat}})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, KeyPrefix))
+	assert.True(t, strings.HasPrefix(secret, key.Prefix))
+	assert.NotContains(t, key.SecretHash, secret)
+	assert.Equal(t, DefaultRateLimit, key.RateLimit)
+
+	keys, err := service.List(ctx, "acme")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, key.ID, keys[0].ID)
+
+	keys, err = service.List(ctx, "other")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	invalid := []NewKey{
+		{Scopes: []string{auth.ScopeChat}},
+		{Organization: "acme:partner", Scopes: []string{auth.ScopeChat}},
+		{Organization: "acme"},
+		{Organization: "acme", Scopes: []string{"root"}},
+		{Organization: "acme", Scopes: []string{auth.ScopeChat}, RateLimit: -1},
+	}
+	for _, input := range invalid {
+		_, _, err := service.Create(ctx, input)
+		assert.ErrorIs(t, err, ErrInvalidInput, input)
+	}
+}
+
+func Test_Authenticate(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newTestService()
+	authenticator := Authenticator{Service: service}
+
+	secret, key, err := service.Create(ctx, NewKey{Organization: "acme", Scopes: []string{auth.ScopeChat, auth.ScopeAnalytics}, RateLimit: 10})
+	require.NoError(t, err)
+
+	identity, err := authenticator.Authenticate(withKey(secret))
+	require.NoError(t, err)
+	assert.Equal(t, &auth.Identity{
+		Subject:      "acme",
+		Organization: "acme",
+		KeyID:        key.ID,
+		RateLimit:    10,
+		Scopes:       []string{auth.ScopeChat, auth.ScopeAnalytics},
+	}, identity)
+	assert.True(t, identity.Owns("acme:alice"))
+	assert.False(t, identity.Owns("other:alice"))
+
+	_, err = authenticator.Authenticate(withKey(KeyPrefix + "unknown"))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	// The bearer tokens are left to the other authenticators
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+secret)
+	_, err = authenticator.Authenticate(r)
+	assert.ErrorIs(t, err, auth.ErrNoCredentials)
+
+	// Revoked keys stop working
+	_, err = service.Revoke(ctx, key.ID)
+	require.NoError(t, err)
+	_, err = authenticator.Authenticate(withKey(secret))
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	_, _, err = service.Rotate(ctx, key.ID)
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
+
+func Test_Rotate(t *testing.T) {
+	ctx := context.Background()
+	service, now := newTestService()
+
+	previous, key, err := service.Create(ctx, NewKey{Organization: "acme", Scopes: []string{auth.ScopeChat}})
+	require.NoError(t, err)
+
+	secret, rotated, err := service.Rotate(ctx, key.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, previous, secret)
+	assert.Equal(t, key.ID, rotated.ID)
+
+	// Both secrets work during the grace period
+	for _, s := range []string{previous, secret} {
+		found, err := service.Authenticate(ctx, s)
+		require.NoError(t, err)
+		assert.Equal(t, key.ID, found.ID)
+	}
+
+	// Only the new secret works after it
+	*now = now.Add(rotationGrace)
+	_, err = service.Authenticate(ctx, previous)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = service.Authenticate(ctx, secret)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,41 @@
+package apikeys
+
+import (
+	"code-challenge/pkg/auth"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Scheme is the authorization scheme of the API keys, "Authorization: ApiKey {key}"
+const Scheme = "ApiKey"
+
+// Authenticator authenticates the requests of the partner apps carrying an API key
+type Authenticator struct {
+	Service *Service
+}
+
+// Authenticate returns the identity of the key, the subject is its organization
+func (a Authenticator) Authenticate(r *http.Request) (*auth.Identity, error) {
+	scheme, secret, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, Scheme) {
+		return nil, auth.ErrNoCredentials
+	}
+
+	key, err := a.Service.Authenticate(r.Context(), strings.TrimSpace(secret))
+	if errors.Is(err, ErrInvalidKey) {
+		return nil, fmt.Errorf("%w: %v", auth.ErrInvalidCredentials, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth.Identity{
+		Subject:      key.Organization,
+		Organization: key.Organization,
+		KeyID:        key.ID,
+		RateLimit:    key.RateLimit,
+		Scopes:       key.Scopes,
+	}, nil
+}
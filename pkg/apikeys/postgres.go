@@ -0,0 +1,136 @@
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"strings"
+)
+
+// schema creates the API keys table when it does not exist yet, the scopes are stored space separated
+const schema = `
+CREATE TABLE IF NOT EXISTS api_keys (
+	id                   TEXT PRIMARY KEY,
+	organization         TEXT NOT NULL,
+	name                 TEXT NOT NULL DEFAULT '',
+	scopes               TEXT NOT NULL,
+	rate_limit           INTEGER NOT NULL,
+	prefix               TEXT NOT NULL,
+	secret_hash          TEXT NOT NULL UNIQUE,
+	previous_secret_hash TEXT,
+	previous_expires_at  TIMESTAMPTZ,
+	created_at           TIMESTAMPTZ NOT NULL,
+	revoked_at           TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS api_keys_organization ON api_keys (organization, created_at);
+CREATE INDEX IF NOT EXISTS api_keys_previous_secret_hash ON api_keys (previous_secret_hash);
+`
+
+// columns are the columns scanned by scan
+const columns = `id, organization, name, scopes, rate_limit, prefix, secret_hash,
+	COALESCE(previous_secret_hash, ''), previous_expires_at, created_at, revoked_at`
+
+// PostgresStore keeps the API keys in Postgres
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// OpenPostgres connects to the database and creates the API keys table
+func OpenPostgres(ctx context.Context, url string) (*PostgresStore, error) {
+	db, err := sql.Open("pgx", url)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// CreateKey records the key
+func (s *PostgresStore) CreateKey(ctx context.Context, key *Key) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO api_keys (id, organization, name, scopes, rate_limit, prefix, secret_hash, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		key.ID, key.Organization, key.Name, strings.Join(key.Scopes, " "), key.RateLimit, key.Prefix, key.SecretHash, key.CreatedAt)
+	return err
+}
+
+// Keys returns the keys of the organization, or of every organization when it is empty, oldest first
+func (s *PostgresStore) Keys(ctx context.Context, organization string) ([]Key, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+columns+` FROM api_keys
+		WHERE $1 = '' OR organization = $1 ORDER BY created_at, id`, organization)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []Key{}
+	for rows.Next() {
+		key, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// KeyByID returns the key with the ID
+func (s *PostgresStore) KeyByID(ctx context.Context, id string) (*Key, error) {
+	return s.key(ctx, `WHERE id = $1`, id)
+}
+
+// KeyByHash returns the key whose secret or previous secret has the hash
+func (s *PostgresStore) KeyByHash(ctx context.Context, secretHash string) (*Key, error) {
+	return s.key(ctx, `WHERE secret_hash = $1 OR previous_secret_hash = $1`, secretHash)
+}
+
+// key returns the key matching the condition
+func (s *PostgresStore) key(ctx context.Context, condition string, arg string) (*Key, error) {
+	key, err := scan(s.db.QueryRowContext(ctx, `SELECT `+columns+` FROM api_keys `+condition, arg))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return key, err
+}
+
+// UpdateKey replaces the secrets and the revocation of the key
+func (s *PostgresStore) UpdateKey(ctx context.Context, key *Key) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE api_keys SET prefix = $2, secret_hash = $3, previous_secret_hash = NULLIF($4, ''), previous_expires_at = $5, revoked_at = $6
+		WHERE id = $1`,
+		key.ID, key.Prefix, key.SecretHash, key.PreviousSecretHash, key.PreviousExpiresAt, key.RevokedAt)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Close closes the database connections
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
+
+// scanner is a row of a query
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scan reads the key of the row
+func scan(row scanner) (*Key, error) {
+	var key Key
+	var scopes string
+	err := row.Scan(&key.ID, &key.Organization, &key.Name, &scopes, &key.RateLimit, &key.Prefix, &key.SecretHash,
+		&key.PreviousSecretHash, &key.PreviousExpiresAt, &key.CreatedAt, &key.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	key.Scopes = strings.Fields(scopes)
+	return &key, nil
+}
@@ -0,0 +1,116 @@
+package apikeys
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Store keeps the API keys
+type Store interface {
+	CreateKey(ctx context.Context, key *Key) error
+
+	// Keys returns the keys of the organization, or of every organization when it is empty, oldest first
+	Keys(ctx context.Context, organization string) ([]Key, error)
+	KeyByID(ctx context.Context, id string) (*Key, error)
+
+	// KeyByHash returns the key whose secret or previous secret has the hash
+	KeyByHash(ctx context.Context, secretHash string) (*Key, error)
+
+	// UpdateKey replaces the secrets and the revocation of the key
+	UpdateKey(ctx context.Context, key *Key) error
+}
+
+// Open returns the Postgres store when the URL of the database is set and an in memory store otherwise
+func Open(ctx context.Context, url string) (Store, error) {
+	if url == "" {
+		return NewMemoryStore(), nil
+	}
+	return OpenPostgres(ctx, url)
+}
+
+// MemoryStore keeps the keys in memory, they are lost when the API stops
+type MemoryStore struct {
+	mu   sync.Mutex
+	keys map[string]Key
+}
+
+// NewMemoryStore creates an empty in memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: map[string]Key{}}
+}
+
+// CreateKey records the key
+func (s *MemoryStore) CreateKey(_ context.Context, key *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key.ID] = clone(*key)
+	return nil
+}
+
+// Keys returns the keys of the organization, or of every organization when it is empty, oldest first
+func (s *MemoryStore) Keys(_ context.Context, organization string) ([]Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := []Key{}
+	for _, key := range s.keys {
+		if organization == "" || key.Organization == organization {
+			keys = append(keys, clone(key))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// KeyByID returns the key with the ID
+func (s *MemoryStore) KeyByID(_ context.Context, id string) (*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	key = clone(key)
+	return &key, nil
+}
+
+// KeyByHash returns the key whose secret or previous secret has the hash
+func (s *MemoryStore) KeyByHash(_ context.Context, secretHash string) (*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.keys {
+		if key.SecretHash == secretHash || key.PreviousSecretHash == secretHash {
+			key = clone(key)
+			return &key, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// UpdateKey replaces the key
+func (s *MemoryStore) UpdateKey(_ context.Context, key *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[key.ID]; !ok {
+		return ErrNotFound
+	}
+	s.keys[key.ID] = clone(*key)
+	return nil
+}
+
+// clone copies the scopes so the stored keys are not changed through the returned ones
+func clone(key Key) Key {
+	key.Scopes = slices.Clone(key.Scopes)
+	return key
+}
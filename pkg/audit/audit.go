@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Actions of the operators on the conversation workflows
+const (
+	ActionCancel    = "cancel"
+	ActionTerminate = "terminate"
+	ActionReset     = "reset"
+	ActionRetry     = "retry"
+	ActionReplay    = "replay"
+)
+
+// Entry is an action of an operator, recorded whether it succeeded or not
+type Entry struct {
+	Time time.Time `json:"time"`
+
+	// Actor is the subject of the user or "apikey:{id}" for the API keys
+	Actor        string `json:"actor"`
+	Organization string `json:"organization,omitempty"`
+	Action       string `json:"action"`
+
+	// Target is the ID of the workflow, of the batch job for the retries or of the dead letter for the replays
+	Target string `json:"target"`
+	RunID  string `json:"run_id,omitempty"`
+	Reason string `json:"reason"`
+
+	// Detail describes what the action did, such as the new run of a reset or the query of a retry
+	Detail string `json:"detail,omitempty"`
+
+	// Error is the error of the failed actions
+	Error string `json:"error,omitempty"`
+}
+
+// Store keeps the audit log
+type Store interface {
+	Record(ctx context.Context, entry Entry) error
+
+	// List returns the entries between from, inclusive, and to, exclusive, of the organization,
+	// or of every organization when it is empty, most recent first
+	List(ctx context.Context, organization string, from, to time.Time) ([]Entry, error)
+}
+
+// Open returns the Postgres store when the URL of the database is set and an in memory store otherwise
+func Open(ctx context.Context, url string) (Store, error) {
+	if url == "" {
+		return NewMemoryStore(), nil
+	}
+	return OpenPostgres(ctx, url)
+}
+
+// MemoryStore keeps the audit log in memory, it is lost when the API stops
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// NewMemoryStore creates an empty in memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Record records the entry
+func (s *MemoryStore) Record(_ context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+// List returns the entries of the organization between from and to
+func (s *MemoryStore) List(_ context.Context, organization string, from, to time.Time) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := []Entry{}
+	for _, entry := range s.entries {
+		if (organization != "" && entry.Organization != organization) || entry.Time.Before(from) || !entry.Time.Before(to) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.After(entries[j].Time) })
+	return entries, nil
+}
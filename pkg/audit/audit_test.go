@@ -0,0 +1,35 @@
+package audit
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_MemoryStore_List(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	entries := []Entry{
+		{Time: day.Add(time.Hour), Actor: "apikey:key-1", Organization: "acme", Action: ActionCancel, Target: "chat_1", Reason: "stuck"},
+		{Time: day.Add(2 * time.Hour), Actor: "admin-1", Action: ActionRetry, Target: "retry-1", Reason: "provider outage"},
+		{Time: day.Add(3 * time.Hour), Actor: "apikey:key-1", Organization: "acme", Action: ActionTerminate, Target: "chat_2", Reason: "spam"},
+		{Time: day.Add(25 * time.Hour), Actor: "apikey:key-1", Organization: "acme", Action: ActionReset, Target: "chat_3", Reason: "bad answer"},
+	}
+	for _, entry := range entries {
+		require.NoError(t, store.Record(ctx, entry))
+	}
+
+	// The most recent entries come first
+	listed, err := store.List(ctx, "acme", day, day.Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{entries[2], entries[0]}, listed)
+
+	// Every organization is listed when none is given
+	listed, err = store.List(ctx, "", day, day.Add(48*time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, listed, 4)
+}
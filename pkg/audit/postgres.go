@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"time"
+)
+
+// schema creates the audit table when it does not exist yet
+const schema = `
+CREATE TABLE IF NOT EXISTS audit_log (
+	id           BIGSERIAL PRIMARY KEY,
+	time         TIMESTAMPTZ NOT NULL,
+	actor        TEXT NOT NULL,
+	organization TEXT NOT NULL,
+	action       TEXT NOT NULL,
+	target       TEXT NOT NULL,
+	run_id       TEXT NOT NULL,
+	reason       TEXT NOT NULL,
+	detail       TEXT NOT NULL,
+	error        TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS audit_log_organization_time ON audit_log (organization, time);
+`
+
+// PostgresStore keeps the audit log in Postgres so it is shared by the replicas of the API
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// OpenPostgres connects to the database and creates the audit table
+func OpenPostgres(ctx context.Context, url string) (*PostgresStore, error) {
+	db, err := sql.Open("pgx", url)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// Record records the entry
+func (s *PostgresStore) Record(ctx context.Context, entry Entry) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO audit_log (time, actor, organization, action, target, run_id, reason, detail, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		entry.Time, entry.Actor, entry.Organization, entry.Action, entry.Target, entry.RunID, entry.Reason, entry.Detail, entry.Error)
+	return err
+}
+
+// List returns the entries of the organization between from and to
+func (s *PostgresStore) List(ctx context.Context, organization string, from, to time.Time) ([]Entry, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT time, actor, organization, action, target, run_id, reason, detail, error
+		FROM audit_log
+		WHERE ($1 = '' OR organization = $1) AND time >= $2 AND time < $3
+		ORDER BY time DESC, id DESC`, organization, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var entry Entry
+		err := rows.Scan(&entry.Time, &entry.Actor, &entry.Organization, &entry.Action, &entry.Target, &entry.RunID,
+			&entry.Reason, &entry.Detail, &entry.Error)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Close closes the database connections
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ErrNoCredentials is returned by an authenticator when the request does not carry its kind of credentials
+var ErrNoCredentials = errors.New("no credentials")
+
+// ErrInvalidCredentials is returned when the credentials of the request are not valid
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Scopes granted to the callers, the users are granted the chat scope and the API keys the scopes they were created with
+const (
+	ScopeChat      = "chat"
+	ScopeAdmin     = "admin"
+	ScopeAnalytics = "analytics"
+)
+
+// Identity is the authenticated caller of the API
+type Identity struct {
+	// Subject identifies the user, it is used as ChatBotQuestion.User.
+	// It is the organization of the key for the API keys.
+	Subject string
+	Name    string
+	Locale  string
+
+	// Email is the verified email of the user, the answers are only emailed to it. It is empty when not verified.
+	Email string
+
+	// Organization is the organization the calls are accounted to, it is set for the API keys
+	Organization string
+
+	// KeyID is the ID of the API key of the call, it is empty for the users
+	KeyID string
+
+	// RateLimit is the number of requests per minute allowed to the API key, it is not limited when zero
+	RateLimit int
+
+	Scopes []string
+}
+
+// HasScope returns whether the identity was granted the scope
+func (i *Identity) HasScope(scope string) bool {
+	return slices.Contains(i.Scopes, scope)
+}
+
+// Owns returns whether the identity may act as the user.
+// The users only act as themselves, the API keys act as the users of their organization, named "{organization}:{user}".
+// Only those names contain ':', the JWT subjects, the account IDs and the organizations cannot.
+func (i *Identity) Owns(user string) bool {
+	if user == i.Subject {
+		return true
+	}
+	return i.KeyID != "" && strings.HasPrefix(user, i.Subject+":")
+}
+
+// Authenticator finds the identity of the caller of a request
+type Authenticator interface {
+	// Authenticate returns ErrNoCredentials when the request does not carry the credentials it handles
+	// and an error wrapping ErrInvalidCredentials when they are not valid
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// Chain tries each authenticator in turn, the first one finding credentials authenticates the request
+type Chain []Authenticator
+
+// Authenticate returns the identity of the first authenticator finding credentials in the request
+func (c Chain) Authenticate(r *http.Request) (*Identity, error) {
+	for _, authenticator := range c {
+		identity, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return identity, err
+	}
+	return nil, ErrNoCredentials
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of the context holding the identity
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// FromContext returns the identity held by the context
+func FromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok
+}
+
+// New creates the authenticator of the mode, local authenticates the first-party credentials,
+// the sessions of the accounts and the API keys:
+//   - jwt, the default, accepts the first-party credentials and the JWT bearer tokens
+//   - accounts only accepts the first-party credentials, for the teams without identity provider
+//   - none returns nil, the API then trusts the user sent in the request body
+func New(mode string, local ...Authenticator) (Authenticator, error) {
+	switch mode {
+	case "none":
+		return nil, nil
+	case "accounts":
+		return Chain(local), nil
+	case "", "jwt":
+		jwt, err := JWTFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return append(Chain(local), jwt), nil
+	default:
+		return nil, fmt.Errorf("unknown authentication mode %q", mode)
+	}
+}
@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var secret = []byte("test-secret")
+
+// claims returns valid claims for the subject
+func claims(subject string) Claims {
+	return Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			Issuer:    "https://issuer.example.com",
+			Audience:  jwt.ClaimStrings{"chatbot"},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Name:   "Thiago",
+		Locale: "pt-BR",
+	}
+}
+
+// bearer returns a request carrying the token
+func bearer(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+// sign signs the claims with the method and the key, kid is set in the header when not empty
+func sign(t *testing.T, method jwt.SigningMethod, key any, kid string, c Claims) string {
+	token := jwt.NewWithClaims(method, c)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+// jwks returns the JSON Web Key Set of the public key
+func jwks(kid string, key *rsa.PublicKey) []byte {
+	content, _ := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	return content
+}
+
+func Test_JWT_HS256(t *testing.T) {
+	authenticator := &JWTAuthenticator{Secret: secret, Issuer: "https://issuer.example.com", Audience: "chatbot"}
+
+	identity, err := authenticator.Authenticate(bearer(sign(t, jwt.SigningMethodHS256, secret, "", claims("user-1"))))
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Subject: "user-1", Name: "Thiago", Locale: "pt-BR", Scopes: []string{ScopeChat}}, identity)
+
+	// The scope claim grants scopes in addition to chat
+	admin := claims("user-1")
+	admin.Scope = "admin analytics"
+	identity, err = authenticator.Authenticate(bearer(sign(t, jwt.SigningMethodHS256, secret, "", admin)))
+	require.NoError(t, err)
+	assert.True(t, identity.HasScope(ScopeChat))
+	assert.True(t, identity.HasScope(ScopeAdmin))
+	assert.True(t, identity.HasScope(ScopeAnalytics))
+
+	// Only the verified emails are kept
+	verified := claims("user-1")
+	verified.Email = "thiago@example.com"
+	identity, err = authenticator.Authenticate(bearer(sign(t, jwt.SigningMethodHS256, secret, "", verified)))
+	require.NoError(t, err)
+	assert.Empty(t, identity.Email)
+	verified.EmailVerified = true
+	identity, err = authenticator.Authenticate(bearer(sign(t, jwt.SigningMethodHS256, secret, "", verified)))
+	require.NoError(t, err)
+	assert.Equal(t, "thiago@example.com", identity.Email)
+
+	// The users only act as themselves
+	assert.True(t, identity.Owns("user-1"))
+	assert.False(t, identity.Owns("user-1:other"))
+}
+
+func Test_JWT_Invalid(t *testing.T) {
+	authenticator := &JWTAuthenticator{Secret: secret, Issuer: "https://issuer.example.com", Audience: "chatbot"}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	expired := claims("user-1")
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	noExpiration := claims("user-1")
+	noExpiration.ExpiresAt = nil
+	otherIssuer := claims("user-1")
+	otherIssuer.Issuer = "https://other.example.com"
+	otherAudience := claims("user-1")
+	otherAudience.Audience = jwt.ClaimStrings{"other"}
+
+	tests := map[string]string{
+		"malformed":          "not-a-token",
+		"wrong secret":       sign(t, jwt.SigningMethodHS256, []byte("other-secret"), "", claims("user-1")),
+		"expired":            sign(t, jwt.SigningMethodHS256, secret, "", expired),
+		"no expiration":      sign(t, jwt.SigningMethodHS256, secret, "", noExpiration),
+		"other issuer":       sign(t, jwt.SigningMethodHS256, secret, "", otherIssuer),
+		"other audience":     sign(t, jwt.SigningMethodHS256, secret, "", otherAudience),
+		"no subject":         sign(t, jwt.SigningMethodHS256, secret, "", claims("")),
+		"organization user":  sign(t, jwt.SigningMethodHS256, secret, "", claims("acme:bob")),
+		"rs256 without jwks": sign(t, jwt.SigningMethodRS256, key, "key-1", claims("user-1")),
+		"none algorithm":     sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", claims("user-1")),
+	}
+
+	for name, token := range tests {
+		_, err := authenticator.Authenticate(bearer(token))
+		assert.ErrorIs(t, err, ErrInvalidCredentials, name)
+	}
+
+	// Requests without a bearer token are left to the other authenticators
+	_, err = authenticator.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.ErrorIs(t, err, ErrNoCredentials)
+}
+
+func Test_JWT_RS256_JWKSFile(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwks("key-1", &key.PublicKey), 0o600))
+
+	t.Setenv("JWT_HS256_SECRET", "")
+	t.Setenv("JWT_JWKS_FILE", path)
+	authenticator, err := New("jwt", Chain{})
+	require.NoError(t, err)
+
+	identity, err := authenticator.Authenticate(bearer(sign(t, jwt.SigningMethodRS256, key, "key-1", claims("user-1"))))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", identity.Subject)
+
+	// HS256 tokens are rejected when no secret is configured
+	_, err = authenticator.Authenticate(bearer(sign(t, jwt.SigningMethodHS256, secret, "", claims("user-1"))))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func Test_JWT_RS256_JWKSURL_Rotation(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	// The identity provider rotates its key after the first fetch
+	current := jwks("old", &oldKey.PublicKey)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(current)
+	}))
+	defer server.Close()
+
+	keys := NewRemoteJWKS(server.URL)
+	authenticator := &JWTAuthenticator{Keys: keys}
+
+	_, err = authenticator.Authenticate(bearer(sign(t, jwt.SigningMethodRS256, oldKey, "old", claims("user-1"))))
+	require.NoError(t, err)
+
+	// The unknown key triggers a new fetch once the minimum refresh interval elapsed
+	current = jwks("new", &newKey.PublicKey)
+	keys.fetchedAt = keys.fetchedAt.Add(-jwksMinRefreshInterval)
+	identity, err := authenticator.Authenticate(bearer(sign(t, jwt.SigningMethodRS256, newKey, "new", claims("user-2"))))
+	require.NoError(t, err)
+	assert.Equal(t, "user-2", identity.Subject)
+}
+
+func Test_JWT_RS256_JWKSURL_Down(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// The outage of the identity provider is not mistaken for invalid credentials
+	authenticator := &JWTAuthenticator{Keys: NewRemoteJWKS(server.URL)}
+	_, err = authenticator.Authenticate(bearer(sign(t, jwt.SigningMethodRS256, key, "key-1", claims("user-1"))))
+	assert.ErrorIs(t, err, ErrKeysUnavailable)
+	assert.NotErrorIs(t, err, ErrInvalidCredentials)
+
+	server.Close()
+	_, err = authenticator.Authenticate(bearer(sign(t, jwt.SigningMethodRS256, key, "key-1", claims("user-1"))))
+	assert.ErrorIs(t, err, ErrKeysUnavailable)
+	assert.NotErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func Test_New(t *testing.T) {
+	authenticator, err := New("none", Chain{})
+	assert.NoError(t, err)
+	assert.Nil(t, authenticator)
+
+	// JWT authentication is the default and needs keys
+	t.Setenv("JWT_HS256_SECRET", "")
+	t.Setenv("JWT_JWKS_FILE", "")
+	t.Setenv("JWT_JWKS_URL", "")
+	_, err = New("", Chain{})
+	assert.Error(t, err)
+
+	authenticator, err = New("accounts", Chain{})
+	assert.NoError(t, err)
+	assert.NotNil(t, authenticator)
+
+	_, err = New("basic", Chain{})
+	assert.Error(t, err)
+}
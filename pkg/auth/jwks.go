@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// jwksRefreshInterval is the time the keys fetched from a JWKS URL are cached
+const jwksRefreshInterval = 10 * time.Minute
+
+// jwksMinRefreshInterval limits how often an unknown key ID triggers a new fetch of the JWKS URL
+const jwksMinRefreshInterval = 30 * time.Second
+
+// ErrUnknownKey is returned when no key of the JWKS has the key ID of the token
+var ErrUnknownKey = errors.New("unknown key")
+
+// ErrKeysUnavailable is returned when the JWKS could not be fetched, the tokens cannot be checked until it is back
+var ErrKeysUnavailable = errors.New("the keys could not be fetched")
+
+// KeySource returns the RSA public keys validating the RS256 tokens
+type KeySource interface {
+	Key(ctx context.Context, kid string) (*rsa.PublicKey, error)
+}
+
+// jsonWebKey is a key of a JSON Web Key Set, see RFC 7517
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKS is a static set of RSA public keys by key ID
+type JWKS map[string]*rsa.PublicKey
+
+// Key returns the key with the key ID, the only key of the set when the token has no key ID
+func (s JWKS) Key(_ context.Context, kid string) (*rsa.PublicKey, error) {
+	if key, ok := s[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(s) == 1 {
+		for _, key := range s {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+}
+
+// ParseJWKS parses the RSA signing keys of a JSON Web Key Set, the other keys are ignored
+func ParseJWKS(content []byte) (JWKS, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := JWKS{}
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: modulus: %w", key.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: exponent: %w", key.Kid, err)
+		}
+		keys[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
+
+// LoadJWKSFile reads the JSON Web Key Set from the file
+func LoadJWKSFile(path string) (JWKS, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(content)
+}
+
+// RemoteJWKS fetches the JSON Web Key Set from a URL and caches it, the set is fetched again when it is stale
+// or when a token is signed with an unknown key, so rotated keys are picked up
+type RemoteJWKS struct {
+	URL        string
+	HTTPClient *http.Client
+
+	mu        sync.Mutex
+	keys      JWKS
+	fetchedAt time.Time
+}
+
+// NewRemoteJWKS creates a key source fetching the JSON Web Key Set from the URL
+func NewRemoteJWKS(url string) *RemoteJWKS {
+	return &RemoteJWKS{URL: url, HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Key returns the key with the key ID, fetching the set when needed
+func (s *RemoteJWKS) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.keys == nil || time.Since(s.fetchedAt) > jwksRefreshInterval {
+		if err := s.fetch(ctx); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrKeysUnavailable, err)
+		}
+	}
+
+	key, err := s.keys.Key(ctx, kid)
+	if errors.Is(err, ErrUnknownKey) && time.Since(s.fetchedAt) > jwksMinRefreshInterval {
+		if err := s.fetch(ctx); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrKeysUnavailable, err)
+		}
+		return s.keys.Key(ctx, kid)
+	}
+	return key, err
+}
+
+// fetch downloads the key set
+func (s *RemoteJWKS) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("jwks: %s returned status %d", s.URL, resp.StatusCode)
+	}
+
+	var content json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&content); err != nil {
+		return fmt.Errorf("jwks: %w", err)
+	}
+	keys, err := ParseJWKS(content)
+	if err != nil {
+		return err
+	}
+
+	s.keys, s.fetchedAt = keys, time.Now()
+	return nil
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// leeway is the clock skew accepted when checking the expiration and the issue time of the tokens
+const leeway = 30 * time.Second
+
+// Claims are the claims of the bearer tokens, the subject is required
+type Claims struct {
+	jwt.RegisteredClaims
+	Name   string `json:"name,omitempty"`
+	Locale string `json:"locale,omitempty"`
+
+	// Email is only trusted when EmailVerified is set by the identity provider
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified,omitempty"`
+
+	// Scope is the space separated list of the scopes granted in addition to the chat scope, such as "admin analytics"
+	Scope string `json:"scope,omitempty"`
+}
+
+// JWTAuthenticator validates the bearer tokens signed with HS256 using a shared secret or RS256 using the keys of a JWKS
+type JWTAuthenticator struct {
+	// Secret validates the HS256 tokens, they are rejected when it is empty
+	Secret []byte
+
+	// Keys validates the RS256 tokens, they are rejected when it is nil
+	Keys KeySource
+
+	// Issuer and Audience are checked when they are set
+	Issuer   string
+	Audience string
+}
+
+// JWTFromEnv creates the JWT authenticator from JWT_HS256_SECRET, JWT_JWKS_FILE or JWT_JWKS_URL, JWT_ISSUER and JWT_AUDIENCE
+func JWTFromEnv() (*JWTAuthenticator, error) {
+	authenticator := &JWTAuthenticator{
+		Secret:   []byte(os.Getenv("JWT_HS256_SECRET")),
+		Issuer:   os.Getenv("JWT_ISSUER"),
+		Audience: os.Getenv("JWT_AUDIENCE"),
+	}
+
+	switch file, url := os.Getenv("JWT_JWKS_FILE"), os.Getenv("JWT_JWKS_URL"); {
+	case file != "" && url != "":
+		return nil, errors.New("JWT_JWKS_FILE and JWT_JWKS_URL are exclusive")
+	case file != "":
+		keys, err := LoadJWKSFile(file)
+		if err != nil {
+			return nil, err
+		}
+		authenticator.Keys = keys
+	case url != "":
+		authenticator.Keys = NewRemoteJWKS(url)
+	}
+
+	if len(authenticator.Secret) == 0 && authenticator.Keys == nil {
+		return nil, errors.New("JWT authentication needs JWT_HS256_SECRET, JWT_JWKS_FILE or JWT_JWKS_URL, set server.auth_mode to none to disable it")
+	}
+	return authenticator, nil
+}
+
+// Authenticate validates the bearer token of the request and returns the identity of its claims
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, ErrNoCredentials
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(a.methods()),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(leeway),
+	}
+	if a.Issuer != "" {
+		options = append(options, jwt.WithIssuer(a.Issuer))
+	}
+	if a.Audience != "" {
+		options = append(options, jwt.WithAudience(a.Audience))
+	}
+
+	var claims Claims
+	_, err := jwt.ParseWithClaims(strings.TrimSpace(token), &claims, func(token *jwt.Token) (any, error) {
+		switch token.Method {
+		case jwt.SigningMethodHS256:
+			return a.Secret, nil
+		case jwt.SigningMethodRS256:
+			kid, _ := token.Header["kid"].(string)
+			return a.Keys.Key(r.Context(), kid)
+		default:
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+	}, options...)
+	if errors.Is(err, ErrKeysUnavailable) {
+		// The identity provider is down, the token may be valid
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	switch {
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	case strings.Contains(claims.Subject, ":"):
+		// The users of the API keys are named "{organization}:{user}", a subject must not be mistaken for one of them
+		return nil, fmt.Errorf("%w: subject must not contain ':'", ErrInvalidCredentials)
+	}
+	scopes := append([]string{ScopeChat}, strings.Fields(claims.Scope)...)
+	identity := &Identity{Subject: claims.Subject, Name: claims.Name, Locale: claims.Locale, Scopes: scopes}
+	if claims.EmailVerified {
+		identity.Email = claims.Email
+	}
+	return identity, nil
+}
+
+// methods returns the signing methods accepted with the configured keys
+func (a *JWTAuthenticator) methods() []string {
+	var methods []string
+	if len(a.Secret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if a.Keys != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	return methods
+}
@@ -0,0 +1,42 @@
+package batch
+
+import (
+	"context"
+)
+
+// Result is the outcome of a question of a batch, written as a line of the output
+type Result struct {
+	// Line is the line of the question in the input, the results are written in its order
+	Line       int    `json:"line"`
+	User       string `json:"user,omitempty"`
+	Question   string `json:"question,omitempty"`
+	WorkflowID string `json:"workflow_id,omitempty"`
+
+	// Status is answered, queued when the answer is delivered later by the DeferredWorkflowID workflow, or failed with Error
+	Status             string `json:"status"`
+	Answer             string `json:"answer,omitempty"`
+	Model              string `json:"model,omitempty"`
+	DeferredWorkflowID string `json:"deferred_workflow_id,omitempty"`
+	Error              string `json:"error,omitempty"`
+}
+
+// Store keeps the results of the batches until their output is written, so a batch resumed after a crash keeps them
+type Store interface {
+	// Save adds the results of the batch, the results of the same lines are replaced so the activity can be retried
+	Save(ctx context.Context, batchID string, results []Result) error
+
+	// Results returns the results of the batch ordered by line
+	Results(ctx context.Context, batchID string) ([]Result, error)
+
+	// Delete removes the results of the batch once its output is written
+	Delete(ctx context.Context, batchID string) error
+}
+
+// Open returns the Postgres store when the URL of the database is set and an in memory store otherwise.
+// The in memory store loses the results of the batches running when the worker restarts, it is only meant for local runs.
+func Open(ctx context.Context, url string) (Store, error) {
+	if url == "" {
+		return NewMemoryStore(), nil
+	}
+	return OpenPostgres(ctx, url)
+}
@@ -0,0 +1,284 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MaxLineLength limits the bytes of a line of the input, a longer line is a failed question
+const MaxLineLength = 16 << 10
+
+// ErrRejected is returned when the location does not exist or refuses the request, retrying does not help
+var ErrRejected = errors.New("the location rejected the request")
+
+// Line is a question of the input, Data is its JSON or Error why it is not valid
+type Line struct {
+	Number int
+	Data   json.RawMessage
+	Error  string
+}
+
+// Chunk is the lines read from an offset of the input
+type Chunk struct {
+	Lines []Line
+
+	// Offset is the byte offset and Line the number of the last line read, the next chunk starts from them
+	Offset int64
+	Line   int
+
+	// EOF is set once the input is read to its end
+	EOF bool
+}
+
+// Files reads the questions and writes the results of the batches. A location is either a path relative to Dir
+// or an http or https URL of an object store, read with GET and written with PUT, such as presigned S3 URLs.
+type Files struct {
+	Dir        string
+	HTTPClient *http.Client
+
+	// Hosts are the only hosts of the URLs read and written, including after a redirect, so the workers do not reach
+	// the internal services nor the metadata endpoint of the cloud. The URLs are refused when it is empty.
+	Hosts []string
+}
+
+// AllowedHost returns an ErrRejected error when the location is a URL whose host is not one of the hosts
+func AllowedHost(location string, hosts []string) error {
+	if !IsURL(location) {
+		return nil
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRejected, redact(err))
+	}
+	if !slices.ContainsFunc(hosts, func(host string) bool { return strings.EqualFold(host, u.Hostname()) }) {
+		return fmt.Errorf("%w: the host %s is not allowed", ErrRejected, u.Hostname())
+	}
+	return nil
+}
+
+// ValidLocation returns why the location is not a relative path without .. nor an http or https URL
+func ValidLocation(location string) error {
+	if IsURL(location) {
+		if u, err := url.Parse(location); err != nil || u.Host == "" {
+			return errors.New("must be a valid URL")
+		}
+		return nil
+	}
+	if !filepath.IsLocal(location) {
+		return errors.New("must be a relative path in the batch directory or an http or https URL")
+	}
+	return nil
+}
+
+// IsURL reports whether the location is an http or https URL rather than a path in the batch directory
+func IsURL(location string) bool {
+	u, err := url.Parse(location)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+// Read returns up to limit questions of the location from the byte offset, line is the number of the lines before it.
+// The blank lines are skipped, they are still counted.
+func (f *Files) Read(ctx context.Context, location string, offset int64, line, limit int) (*Chunk, error) {
+	body, err := f.open(ctx, location, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	chunk := &Chunk{Lines: []Line{}, Offset: offset, Line: line}
+	reader := bufio.NewReader(body)
+	for len(chunk.Lines) < limit {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if len(data) > 0 {
+			chunk.Offset += int64(len(data))
+			chunk.Line++
+
+			switch data = bytes.TrimSpace(data); {
+			case len(data) == 0:
+			case len(data) > MaxLineLength:
+				chunk.Lines = append(chunk.Lines, Line{Number: chunk.Line, Error: fmt.Sprintf("the line is longer than %d bytes", MaxLineLength)})
+			case !json.Valid(data):
+				chunk.Lines = append(chunk.Lines, Line{Number: chunk.Line, Error: "the line is not valid JSON"})
+			default:
+				chunk.Lines = append(chunk.Lines, Line{Number: chunk.Line, Data: data})
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			chunk.EOF = true
+			break
+		}
+	}
+	return chunk, nil
+}
+
+// open returns the content of the location from the byte offset
+func (f *Files) open(ctx context.Context, location string, offset int64) (io.ReadCloser, error) {
+	if !IsURL(location) {
+		file, err := os.Open(filepath.Join(f.Dir, location))
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %w", ErrRejected, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		return file, nil
+	}
+
+	if err := AllowedHost(location, f.Hosts); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+	resp, err := f.client().Do(req)
+	if err != nil {
+		return nil, redact(err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		return resp.Body, nil
+	case resp.StatusCode == http.StatusOK:
+		// The server ignored the range, the bytes before the offset are skipped
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			_ = resp.Body.Close()
+			return nil, err
+		}
+		return resp.Body, nil
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// The offset is the end of the input
+		_ = resp.Body.Close()
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	default:
+		_ = resp.Body.Close()
+		err := fmt.Errorf("GET returned status %d", resp.StatusCode)
+		if resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests {
+			err = fmt.Errorf("%w: %w", ErrRejected, err)
+		}
+		return nil, err
+	}
+}
+
+// Write replaces the content of the location with the results as JSON lines.
+// The files are renamed once written so a partial output is never seen.
+func (f *Files) Write(ctx context.Context, location string, results []Result) error {
+	if err := AllowedHost(location, f.Hosts); err != nil {
+		return err
+	}
+	dir := os.TempDir()
+	if !IsURL(location) {
+		dir = filepath.Dir(filepath.Join(f.Dir, location))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.CreateTemp(dir, ".batch-*.jsonl")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, result := range results {
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	if !IsURL(location) {
+		if err := file.Close(); err != nil {
+			return err
+		}
+		return os.Rename(file.Name(), filepath.Join(f.Dir, location))
+	}
+	return f.put(ctx, location, file)
+}
+
+// put uploads the file to the URL, the object stores need its length
+func (f *Files) put(ctx context.Context, location string, file *os.File) error {
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, location, io.NopCloser(file))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := f.client().Do(req)
+	if err != nil {
+		return redact(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("PUT returned status %d", resp.StatusCode)
+		if resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests {
+			err = fmt.Errorf("%w: %w", ErrRejected, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// client returns the HTTP client of the files, it only follows the redirects to the allowed hosts
+func (f *Files) client() *http.Client {
+	client := http.Client{}
+	if f.HTTPClient != nil {
+		client = *f.HTTPClient
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return AllowedHost(req.URL.String(), f.Hosts)
+	}
+	return &client
+}
+
+// redact removes the query of the URL of the error, the presigned URLs hold their signature in it
+func redact(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if u, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+			u.RawQuery = ""
+			urlErr.URL = u.String()
+		}
+	}
+	return err
+}
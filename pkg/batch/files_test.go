@@ -0,0 +1,143 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// input has a blank line, an invalid line and a last line without newline
+const input = `{"user": "alice", "question": "How do I renew my visa?"}
+
+not json
+{"user": "bob", "question": "What is a work permit?"}
+{"user": "carol", "question": "Can I study in Canada?"}`
+
+func Test_ValidLocation(t *testing.T) {
+	assert.NoError(t, ValidLocation("questions.jsonl"))
+	assert.NoError(t, ValidLocation("2024-05/questions.jsonl"))
+	assert.NoError(t, ValidLocation("https://bucket.s3.amazonaws.com/questions.jsonl?X-Amz-Signature=abc"))
+	assert.Error(t, ValidLocation("../etc/passwd"))
+	assert.Error(t, ValidLocation("/etc/passwd"))
+	assert.Error(t, ValidLocation(""))
+	assert.Error(t, ValidLocation("https:///questions.jsonl"))
+}
+
+func Test_Files_Read(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "questions.jsonl"), []byte(input), 0o600))
+	files := &Files{Dir: dir}
+
+	// The chunks resume from the offset of the previous one
+	chunk, err := files.Read(context.Background(), "questions.jsonl", 0, 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []Line{
+		{Number: 1, Data: json.RawMessage(`{"user": "alice", "question": "How do I renew my visa?"}`)},
+		{Number: 3, Error: "the line is not valid JSON"},
+	}, chunk.Lines)
+	assert.Equal(t, 3, chunk.Line)
+	assert.False(t, chunk.EOF)
+
+	chunk, err = files.Read(context.Background(), "questions.jsonl", chunk.Offset, chunk.Line, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []Line{
+		{Number: 4, Data: json.RawMessage(`{"user": "bob", "question": "What is a work permit?"}`)},
+		{Number: 5, Data: json.RawMessage(`{"user": "carol", "question": "Can I study in Canada?"}`)},
+	}, chunk.Lines)
+	assert.True(t, chunk.EOF)
+	assert.Equal(t, int64(len(input)), chunk.Offset)
+
+	_, err = files.Read(context.Background(), "missing.jsonl", 0, 0, 2)
+	assert.ErrorIs(t, err, ErrRejected)
+}
+
+func Test_Files_HTTP(t *testing.T) {
+	var uploaded string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/questions.jsonl" && r.Method == http.MethodGet:
+			http.ServeContent(w, r, "questions.jsonl", time.Time{}, strings.NewReader(input))
+		case r.URL.Path == "/answers.jsonl" && r.Method == http.MethodPut:
+			body, err := io.ReadAll(r.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(len(body)), r.ContentLength)
+			uploaded = string(body)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+	files := &Files{HTTPClient: server.Client(), Hosts: []string{"127.0.0.1"}}
+
+	// The input is read with range requests from the offset
+	chunk, err := files.Read(context.Background(), server.URL+"/questions.jsonl", 0, 0, 1)
+	require.NoError(t, err)
+	chunk, err = files.Read(context.Background(), server.URL+"/questions.jsonl", chunk.Offset, chunk.Line, 10)
+	require.NoError(t, err)
+	require.Len(t, chunk.Lines, 3)
+	assert.Equal(t, 3, chunk.Lines[0].Number)
+	assert.True(t, chunk.EOF)
+
+	chunk, err = files.Read(context.Background(), server.URL+"/questions.jsonl", chunk.Offset, chunk.Line, 10)
+	require.NoError(t, err)
+	assert.Empty(t, chunk.Lines)
+	assert.True(t, chunk.EOF)
+
+	// The output is uploaded with its length
+	require.NoError(t, files.Write(context.Background(), server.URL+"/answers.jsonl", []Result{{Line: 1, Status: "answered", Answer: "Apply online."}}))
+	assert.Equal(t, `{"line":1,"status":"answered","answer":"Apply online."}`+"\n", uploaded)
+
+	err = files.Write(context.Background(), server.URL+"/forbidden.jsonl?X-Amz-Signature=secret", nil)
+	assert.ErrorIs(t, err, ErrRejected)
+}
+
+func Test_Files_Write(t *testing.T) {
+	dir := t.TempDir()
+	files := &Files{Dir: dir}
+
+	results := []Result{
+		{Line: 1, User: "alice", Question: "How do I renew my visa?", Status: "answered", Answer: "Apply online.", Model: "gpt-4o"},
+		{Line: 3, Status: "failed", Error: "the line is not valid JSON"},
+	}
+	require.NoError(t, files.Write(context.Background(), "out/answers.jsonl", results))
+
+	written, err := os.ReadFile(filepath.Join(dir, "out", "answers.jsonl"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"line":1,"user":"alice","question":"How do I renew my visa?","status":"answered","answer":"Apply online.","model":"gpt-4o"}
+{"line":3,"status":"failed","error":"the line is not valid JSON"}
+`, string(written))
+
+	// Only the output is left in the directory
+	entries, err := os.ReadDir(filepath.Join(dir, "out"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func Test_Files_Hosts(t *testing.T) {
+	// The allowed host redirects to the metadata endpoint of the cloud
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer server.Close()
+	files := &Files{HTTPClient: server.Client(), Hosts: []string{"127.0.0.1"}}
+
+	for _, location := range []string{"http://169.254.169.254/latest/meta-data/", "http://localhost:8080/questions.jsonl", server.URL + "/questions.jsonl"} {
+		_, err := files.Read(context.Background(), location, 0, 0, 10)
+		assert.ErrorIs(t, err, ErrRejected, location)
+		assert.ErrorIs(t, files.Write(context.Background(), location, nil), ErrRejected, location)
+	}
+
+	// The URLs are refused without allowed hosts, the paths are not concerned
+	assert.ErrorIs(t, AllowedHost("https://bucket.s3.amazonaws.com/questions.jsonl", nil), ErrRejected)
+	assert.NoError(t, AllowedHost("https://Bucket.s3.amazonaws.com/questions.jsonl", []string{"bucket.s3.amazonaws.com"}))
+	assert.NoError(t, AllowedHost("questions.jsonl", nil))
+}
@@ -0,0 +1,54 @@
+package batch
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryStore keeps the results of the batches in memory
+type MemoryStore struct {
+	mu      sync.Mutex
+	results map[string]map[int]Result
+}
+
+// NewMemoryStore creates an empty in memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{results: map[string]map[int]Result{}}
+}
+
+// Save adds the results of the batch, the results of the same lines are replaced
+func (s *MemoryStore) Save(_ context.Context, batchID string, results []Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.results[batchID] == nil {
+		s.results[batchID] = map[int]Result{}
+	}
+	for _, result := range results {
+		s.results[batchID][result.Line] = result
+	}
+	return nil
+}
+
+// Results returns the results of the batch ordered by line
+func (s *MemoryStore) Results(_ context.Context, batchID string) ([]Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]Result, 0, len(s.results[batchID]))
+	for _, result := range s.results[batchID] {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Line < results[j].Line })
+	return results, nil
+}
+
+// Delete removes the results of the batch
+func (s *MemoryStore) Delete(_ context.Context, batchID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.results, batchID)
+	return nil
+}
@@ -0,0 +1,27 @@
+package batch
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_MemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	// The results of a retried activity replace the previous ones
+	require.NoError(t, store.Save(ctx, "batch-1", []Result{{Line: 3, Status: "failed"}, {Line: 1, Status: "queued"}}))
+	require.NoError(t, store.Save(ctx, "batch-1", []Result{{Line: 1, Status: "answered"}}))
+	require.NoError(t, store.Save(ctx, "batch-2", []Result{{Line: 1, Status: "answered"}}))
+
+	results, err := store.Results(ctx, "batch-1")
+	require.NoError(t, err)
+	assert.Equal(t, []Result{{Line: 1, Status: "answered"}, {Line: 3, Status: "failed"}}, results)
+
+	require.NoError(t, store.Delete(ctx, "batch-1"))
+	results, err = store.Results(ctx, "batch-1")
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
@@ -0,0 +1,94 @@
+package batch
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// schema creates the table of the results when it does not exist yet
+const schema = `
+CREATE TABLE IF NOT EXISTS batch_results (
+	batch_id TEXT NOT NULL,
+	line     INTEGER NOT NULL,
+	result   JSONB NOT NULL,
+	PRIMARY KEY (batch_id, line)
+);
+`
+
+// PostgresStore keeps the results of the batches in Postgres so they survive the restarts of the workers
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// OpenPostgres connects to the database and creates the table of the results
+func OpenPostgres(ctx context.Context, url string) (*PostgresStore, error) {
+	db, err := sql.Open("pgx", url)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// Save adds the results of the batch in a transaction, the results of the same lines are replaced
+func (s *PostgresStore) Save(ctx context.Context, batchID string, results []Result) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, result := range results {
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO batch_results (batch_id, line, result) VALUES ($1, $2, $3)
+			ON CONFLICT (batch_id, line) DO UPDATE SET result = EXCLUDED.result`,
+			batchID, result.Line, string(encoded))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Results returns the results of the batch ordered by line
+func (s *PostgresStore) Results(ctx context.Context, batchID string) ([]Result, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT result FROM batch_results WHERE batch_id = $1 ORDER BY line`, batchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []Result{}
+	for rows.Next() {
+		var encoded []byte
+		if err := rows.Scan(&encoded); err != nil {
+			return nil, err
+		}
+		var result Result
+		if err := json.Unmarshal(encoded, &result); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// Delete removes the results of the batch
+func (s *PostgresStore) Delete(ctx context.Context, batchID string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM batch_results WHERE batch_id = $1`, batchID)
+	return err
+}
+
+// Close closes the database connections
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
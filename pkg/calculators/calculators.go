@@ -0,0 +1,83 @@
+package calculators
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+// data holds the versioned rule tables and input schemas of every calculator
+//
+//go:embed data
+var data embed.FS
+
+// ErrUnknownCalculator is returned when no calculator is registered under the requested name
+var ErrUnknownCalculator = errors.New("unknown calculator")
+
+// ErrInvalidInput is returned when the calculator input does not match its schema
+var ErrInvalidInput = errors.New("invalid calculator input")
+
+// Calculator is a deterministic points grid, such as the Express Entry CRS
+type Calculator interface {
+	// Name is the identifier used in the API route and in the tool exposed to the model
+	Name() string
+
+	// Description explains to the model when the calculator should be used
+	Description() string
+
+	// InputSchema is the JSON schema of the input accepted by Calculate
+	InputSchema() json.RawMessage
+
+	// Calculate scores the JSON encoded input against the rule table it selects
+	Calculate(input json.RawMessage) (*Result, error)
+}
+
+// Result is the score computed by a calculator with the breakdown of every factor
+type Result struct {
+	Calculator string    `json:"calculator"`
+	Version    string    `json:"version"`
+	Total      int       `json:"total"`
+	Sections   []Section `json:"sections"`
+}
+
+// Section groups the factors of a points grid and is capped at Max points
+type Section struct {
+	Name    string   `json:"name"`
+	Points  int      `json:"points"`
+	Max     int      `json:"max"`
+	Factors []Factor `json:"factors"`
+}
+
+// Factor is a single line of the points grid
+type Factor struct {
+	Name   string `json:"name"`
+	Points int    `json:"points"`
+}
+
+// registry holds every available calculator indexed by name
+var registry = map[string]Calculator{}
+
+// register adds the calculator to the registry, it is meant to be called from init functions
+func register(c Calculator) {
+	registry[c.Name()] = c
+}
+
+// Get returns the calculator registered under the given name
+func Get(name string) (Calculator, error) {
+	c, ok := registry[name]
+	if !ok {
+		return nil, ErrUnknownCalculator
+	}
+	return c, nil
+}
+
+// All returns every registered calculator sorted by name so callers get a stable order
+func All() []Calculator {
+	all := make([]Calculator, 0, len(registry))
+	for _, c := range registry {
+		all = append(all, c)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name() < all[j].Name() })
+	return all
+}
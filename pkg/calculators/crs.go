@@ -0,0 +1,587 @@
+package calculators
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Education levels accepted for the principal applicant and the spouse
+const (
+	EducationLessThanSecondary = "less_than_secondary"
+	EducationSecondary         = "secondary"
+	EducationOneYear           = "one_year_post_secondary"
+	EducationTwoYear           = "two_year_post_secondary"
+	EducationBachelors         = "bachelors"
+	EducationTwoOrMore         = "two_or_more_credentials"
+	EducationMasters           = "masters"
+	EducationDoctoral          = "doctoral"
+)
+
+// Official languages accepted in the language scores
+const (
+	LanguageEnglish = "english"
+	LanguageFrench  = "french"
+)
+
+// LanguageScores holds the Canadian Language Benchmark (CLB or NCLC) level of each ability
+type LanguageScores struct {
+	Language  string `json:"language"`
+	Reading   int    `json:"reading"`
+	Writing   int    `json:"writing"`
+	Speaking  int    `json:"speaking"`
+	Listening int    `json:"listening"`
+}
+
+// abilities returns the four abilities in a fixed order
+func (l LanguageScores) abilities() []int {
+	return []int{l.Reading, l.Writing, l.Speaking, l.Listening}
+}
+
+// lowest returns the lowest level among the four abilities
+func (l LanguageScores) lowest() int {
+	lowest := l.Reading
+	for _, level := range l.abilities() {
+		lowest = min(lowest, level)
+	}
+	return lowest
+}
+
+// CRSSpouse describes an accompanying spouse who is not a Canadian citizen or permanent resident
+type CRSSpouse struct {
+	Education         string          `json:"education"`
+	Language          *LanguageScores `json:"language,omitempty"`
+	CanadianWorkYears int             `json:"canadian_work_years"`
+}
+
+// CRSInput is the profile scored by the Express Entry Comprehensive Ranking System calculator
+type CRSInput struct {
+	Version                    string          `json:"version,omitempty"`
+	Age                        int             `json:"age"`
+	Education                  string          `json:"education"`
+	FirstLanguage              LanguageScores  `json:"first_language"`
+	SecondLanguage             *LanguageScores `json:"second_language,omitempty"`
+	CanadianWorkYears          int             `json:"canadian_work_years"`
+	ForeignWorkYears           int             `json:"foreign_work_years"`
+	CertificateOfQualification bool            `json:"certificate_of_qualification"`
+	Spouse                     *CRSSpouse      `json:"spouse,omitempty"`
+	SiblingInCanada            bool            `json:"sibling_in_canada"`
+	CanadianEducation          string          `json:"canadian_education,omitempty"`
+	ArrangedEmployment         string          `json:"arranged_employment,omitempty"`
+	ProvincialNomination       bool            `json:"provincial_nomination"`
+}
+
+// crsSplitTier awards different points depending on whether the applicant has an accompanying spouse
+type crsSplitTier struct {
+	Min           int `json:"min"`
+	Max           int `json:"max"`
+	WithSpouse    int `json:"with_spouse"`
+	WithoutSpouse int `json:"without_spouse"`
+}
+
+// crsTier awards points from a minimum level or number of years
+type crsTier struct {
+	Min    int `json:"min"`
+	Points int `json:"points"`
+}
+
+// crsEducationTier awards skill transferability points by education group
+type crsEducationTier struct {
+	Min           int `json:"min"`
+	PostSecondary int `json:"post_secondary"`
+	Advanced      int `json:"advanced"`
+}
+
+// crsForeignWorkTier awards skill transferability points by years of foreign work experience
+type crsForeignWorkTier struct {
+	Min              int `json:"min"`
+	OneToTwoYears    int `json:"one_to_two_years"`
+	ThreeYearsOrMore int `json:"three_years_or_more"`
+}
+
+// crsTable is a versioned CRS rule table loaded from data/crs
+type crsTable struct {
+	Version       string `json:"version"`
+	EffectiveFrom string `json:"effective_from"`
+	Source        string `json:"source"`
+	Core          struct {
+		MaxWithSpouse    int                     `json:"max_with_spouse"`
+		MaxWithoutSpouse int                     `json:"max_without_spouse"`
+		Age              []crsSplitTier          `json:"age"`
+		Education        map[string]crsSplitTier `json:"education"`
+		FirstLanguage    []crsSplitTier          `json:"first_language"`
+		SecondLanguage   struct {
+			MaxWithSpouse    int       `json:"max_with_spouse"`
+			MaxWithoutSpouse int       `json:"max_without_spouse"`
+			Levels           []crsTier `json:"levels"`
+		} `json:"second_language"`
+		CanadianWork []crsSplitTier `json:"canadian_work"`
+	} `json:"core"`
+	Spouse struct {
+		Max          int            `json:"max"`
+		Education    map[string]int `json:"education"`
+		Language     []crsTier      `json:"language"`
+		CanadianWork []crsTier      `json:"canadian_work"`
+	} `json:"spouse"`
+	SkillTransferability struct {
+		GroupMax                int                  `json:"group_max"`
+		Max                     int                  `json:"max"`
+		EducationLanguage       []crsEducationTier   `json:"education_language"`
+		EducationCanadianWork   []crsEducationTier   `json:"education_canadian_work"`
+		ForeignWorkLanguage     []crsForeignWorkTier `json:"foreign_work_language"`
+		ForeignWorkCanadianWork []crsForeignWorkTier `json:"foreign_work_canadian_work"`
+		CertificateLanguage     []crsTier            `json:"certificate_language"`
+	} `json:"skill_transferability"`
+	Additional struct {
+		Max             int `json:"max"`
+		SiblingInCanada int `json:"sibling_in_canada"`
+		French          struct {
+			MinNCLC           int `json:"min_nclc"`
+			EnglishCLB4OrLess int `json:"english_clb4_or_less"`
+			EnglishCLB5OrMore int `json:"english_clb5_or_more"`
+		} `json:"french"`
+		CanadianEducation    map[string]int `json:"canadian_education"`
+		ArrangedEmployment   map[string]int `json:"arranged_employment"`
+		ProvincialNomination int            `json:"provincial_nomination"`
+	} `json:"additional"`
+}
+
+// crsCalculator scores profiles against the Express Entry Comprehensive Ranking System
+type crsCalculator struct {
+	schema json.RawMessage
+	tables map[string]*crsTable
+	latest string
+}
+
+// crs is the Express Entry Comprehensive Ranking System calculator
+var crs = mustLoadCRS()
+
+func init() {
+	register(crs)
+}
+
+// mustLoadCRS reads the CRS schema and every rule table embedded in data/crs
+func mustLoadCRS() *crsCalculator {
+	c, err := loadCRS(data, "data/crs")
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// loadCRS reads the input schema and the versioned rule tables stored in dir
+func loadCRS(fsys fs.FS, dir string) (*crsCalculator, error) {
+	schema, err := fs.ReadFile(fsys, path.Join(dir, "schema.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &crsCalculator{schema: schema, tables: map[string]*crsTable{}}
+	latestEffective := ""
+	for _, entry := range entries {
+		// Every file other than the schema is a rule table named after its version
+		if entry.Name() == "schema.json" || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var table crsTable
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&table); err != nil {
+			return nil, fmt.Errorf("crs rule table %s: %w", entry.Name(), err)
+		}
+
+		c.tables[table.Version] = &table
+		// Versions are ISO dates so the lexical order is the chronological order
+		if table.EffectiveFrom > latestEffective {
+			c.latest, latestEffective = table.Version, table.EffectiveFrom
+		}
+	}
+
+	if len(c.tables) == 0 {
+		return nil, fmt.Errorf("no crs rule table found in %s", dir)
+	}
+	return c, nil
+}
+
+// Name implements Calculator
+func (c *crsCalculator) Name() string {
+	return "crs"
+}
+
+// Description implements Calculator
+func (c *crsCalculator) Description() string {
+	return "Calculates the Canada Express Entry Comprehensive Ranking System (CRS) score of a candidate. " +
+		"Language levels must be expressed as Canadian Language Benchmark (CLB) levels for English or NCLC levels for French."
+}
+
+// InputSchema implements Calculator
+func (c *crsCalculator) InputSchema() json.RawMessage {
+	return c.schema
+}
+
+// Versions returns the available rule table versions, oldest first
+func (c *crsCalculator) Versions() []string {
+	versions := make([]string, 0, len(c.tables))
+	for version := range c.tables {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// Calculate implements Calculator
+func (c *crsCalculator) Calculate(input json.RawMessage) (*Result, error) {
+	var profile CRSInput
+	decoder := json.NewDecoder(bytes.NewReader(input))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&profile); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	return c.Score(profile)
+}
+
+// Score computes the CRS score of the profile using the rule table it selects, or the latest one
+func (c *crsCalculator) Score(profile CRSInput) (*Result, error) {
+	version := profile.Version
+	if version == "" {
+		version = c.latest
+	}
+
+	table, ok := c.tables[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown version %q, available versions are %s", ErrInvalidInput, version, strings.Join(c.Versions(), ", "))
+	}
+
+	if err := validateCRSInput(table, profile); err != nil {
+		return nil, err
+	}
+
+	sections := []Section{
+		table.core(profile),
+		table.spouse(profile),
+		table.skillTransferability(profile),
+		table.additional(profile),
+	}
+
+	total := 0
+	for _, section := range sections {
+		total += section.Points
+	}
+
+	return &Result{
+		Calculator: c.Name(),
+		Version:    table.Version,
+		Total:      total,
+		Sections:   sections,
+	}, nil
+}
+
+// validateCRSInput checks the enumerations and ranges that decoding cannot enforce
+func validateCRSInput(table *crsTable, profile CRSInput) error {
+	if profile.Age < 0 || profile.Age > 120 {
+		return fmt.Errorf("%w: age must be between 0 and 120", ErrInvalidInput)
+	}
+	if _, ok := table.Core.Education[profile.Education]; !ok {
+		return fmt.Errorf("%w: unknown education %q", ErrInvalidInput, profile.Education)
+	}
+	if profile.CanadianWorkYears < 0 || profile.ForeignWorkYears < 0 {
+		return fmt.Errorf("%w: work experience years cannot be negative", ErrInvalidInput)
+	}
+	if err := validateLanguage("first_language", &profile.FirstLanguage); err != nil {
+		return err
+	}
+	if err := validateLanguage("second_language", profile.SecondLanguage); err != nil {
+		return err
+	}
+	if profile.SecondLanguage != nil && profile.SecondLanguage.Language == profile.FirstLanguage.Language {
+		return fmt.Errorf("%w: second_language must be a different official language than first_language", ErrInvalidInput)
+	}
+
+	switch profile.CanadianEducation {
+	case "", "none", "one_or_two_year", "three_year_or_more":
+	default:
+		return fmt.Errorf("%w: unknown canadian_education %q", ErrInvalidInput, profile.CanadianEducation)
+	}
+
+	switch profile.ArrangedEmployment {
+	case "", "none", "teer_0_major_group_00", "teer_0_1_2_3":
+	default:
+		return fmt.Errorf("%w: unknown arranged_employment %q", ErrInvalidInput, profile.ArrangedEmployment)
+	}
+
+	if profile.Spouse != nil {
+		if _, ok := table.Spouse.Education[profile.Spouse.Education]; !ok {
+			return fmt.Errorf("%w: unknown spouse education %q", ErrInvalidInput, profile.Spouse.Education)
+		}
+		if profile.Spouse.CanadianWorkYears < 0 {
+			return fmt.Errorf("%w: spouse work experience years cannot be negative", ErrInvalidInput)
+		}
+		if err := validateLanguage("spouse.language", profile.Spouse.Language); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateLanguage checks the official language and the range of each ability level
+func validateLanguage(field string, scores *LanguageScores) error {
+	if scores == nil {
+		return nil
+	}
+	if scores.Language != LanguageEnglish && scores.Language != LanguageFrench {
+		return fmt.Errorf("%w: %s.language must be %q or %q", ErrInvalidInput, field, LanguageEnglish, LanguageFrench)
+	}
+	for _, level := range scores.abilities() {
+		if level < 0 || level > 12 {
+			return fmt.Errorf("%w: %s levels must be between 0 and 12", ErrInvalidInput, field)
+		}
+	}
+	return nil
+}
+
+// core computes the core human capital factors
+func (t *crsTable) core(profile CRSInput) Section {
+	withSpouse := profile.Spouse != nil
+	section := Section{Name: "Core / human capital factors", Max: pick(withSpouse, t.Core.MaxWithSpouse, t.Core.MaxWithoutSpouse)}
+
+	age := 0
+	for _, tier := range t.Core.Age {
+		if profile.Age >= tier.Min && profile.Age <= tier.Max {
+			age = pick(withSpouse, tier.WithSpouse, tier.WithoutSpouse)
+		}
+	}
+	section.add("Age", age)
+
+	education := t.Core.Education[profile.Education]
+	section.add("Level of education", pick(withSpouse, education.WithSpouse, education.WithoutSpouse))
+
+	firstLanguage := 0
+	for _, level := range profile.FirstLanguage.abilities() {
+		if tier, ok := highestTier(t.Core.FirstLanguage, level); ok {
+			firstLanguage += pick(withSpouse, tier.WithSpouse, tier.WithoutSpouse)
+		}
+	}
+	section.add("First official language", firstLanguage)
+
+	secondLanguage := 0
+	if profile.SecondLanguage != nil {
+		for _, level := range profile.SecondLanguage.abilities() {
+			secondLanguage += tierFor(t.Core.SecondLanguage.Levels, level)
+		}
+		secondLanguage = min(secondLanguage, pick(withSpouse, t.Core.SecondLanguage.MaxWithSpouse, t.Core.SecondLanguage.MaxWithoutSpouse))
+	}
+	section.add("Second official language", secondLanguage)
+
+	canadianWork := 0
+	if tier, ok := highestTier(t.Core.CanadianWork, profile.CanadianWorkYears); ok {
+		canadianWork = pick(withSpouse, tier.WithSpouse, tier.WithoutSpouse)
+	}
+	section.add("Canadian work experience", canadianWork)
+
+	return section.capped()
+}
+
+// spouse computes the spouse or common-law partner factors
+func (t *crsTable) spouse(profile CRSInput) Section {
+	section := Section{Name: "Spouse or common-law partner factors", Max: t.Spouse.Max}
+	if profile.Spouse == nil {
+		return section
+	}
+
+	section.add("Level of education", t.Spouse.Education[profile.Spouse.Education])
+
+	language := 0
+	if profile.Spouse.Language != nil {
+		for _, level := range profile.Spouse.Language.abilities() {
+			language += tierFor(t.Spouse.Language, level)
+		}
+	}
+	section.add("First official language", language)
+	section.add("Canadian work experience", tierFor(t.Spouse.CanadianWork, profile.Spouse.CanadianWorkYears))
+
+	return section.capped()
+}
+
+// skillTransferability computes the combinations of education, language and work experience
+func (t *crsTable) skillTransferability(profile CRSInput) Section {
+	rules := t.SkillTransferability
+	section := Section{Name: "Skill transferability factors", Max: rules.Max}
+	language := profile.FirstLanguage.lowest()
+
+	// Education combined with language or Canadian work experience, capped as a group
+	educationLanguage, educationWork := 0, 0
+	if group := educationGroup(profile.Education); group != "" {
+		if tier, ok := highestTier(rules.EducationLanguage, language); ok {
+			educationLanguage = tier.points(group)
+		}
+		if tier, ok := highestTier(rules.EducationCanadianWork, profile.CanadianWorkYears); ok {
+			educationWork = tier.points(group)
+		}
+	}
+	section.add("Education and official language", educationLanguage)
+	section.add("Education and Canadian work experience", educationWork)
+	education := min(educationLanguage+educationWork, rules.GroupMax)
+
+	// Foreign work experience combined with language or Canadian work experience, capped as a group
+	foreignLanguage, foreignWork := 0, 0
+	if profile.ForeignWorkYears >= 1 {
+		if tier, ok := highestTier(rules.ForeignWorkLanguage, language); ok {
+			foreignLanguage = tier.points(profile.ForeignWorkYears)
+		}
+		if tier, ok := highestTier(rules.ForeignWorkCanadianWork, profile.CanadianWorkYears); ok {
+			foreignWork = tier.points(profile.ForeignWorkYears)
+		}
+	}
+	section.add("Foreign work experience and official language", foreignLanguage)
+	section.add("Foreign work experience and Canadian work experience", foreignWork)
+	foreign := min(foreignLanguage+foreignWork, rules.GroupMax)
+
+	certificate := 0
+	if profile.CertificateOfQualification {
+		certificate = min(tierFor(rules.CertificateLanguage, language), rules.GroupMax)
+	}
+	section.add("Certificate of qualification and official language", certificate)
+
+	// Each group is capped independently before the section maximum applies
+	section.Points = min(education+foreign+certificate, section.Max)
+	return section
+}
+
+// additional computes the additional points such as provincial nomination or French ability
+func (t *crsTable) additional(profile CRSInput) Section {
+	rules := t.Additional
+	section := Section{Name: "Additional points", Max: rules.Max}
+
+	sibling := 0
+	if profile.SiblingInCanada {
+		sibling = rules.SiblingInCanada
+	}
+	section.add("Brother or sister living in Canada", sibling)
+	section.add("French language skills", t.frenchPoints(profile))
+	section.add("Post-secondary education in Canada", rules.CanadianEducation[profile.CanadianEducation])
+	section.add("Arranged employment", rules.ArrangedEmployment[profile.ArrangedEmployment])
+
+	nomination := 0
+	if profile.ProvincialNomination {
+		nomination = rules.ProvincialNomination
+	}
+	section.add("Provincial or territorial nomination", nomination)
+
+	return section.capped()
+}
+
+// frenchPoints awards the French bonus depending on the French and English results
+func (t *crsTable) frenchPoints(profile CRSInput) int {
+	var french, english *LanguageScores
+	for _, scores := range []*LanguageScores{&profile.FirstLanguage, profile.SecondLanguage} {
+		if scores == nil {
+			continue
+		}
+		switch scores.Language {
+		case LanguageFrench:
+			french = scores
+		case LanguageEnglish:
+			english = scores
+		}
+	}
+
+	rules := t.Additional.French
+	if french == nil || french.lowest() < rules.MinNCLC {
+		return 0
+	}
+	if english == nil || english.lowest() <= 4 {
+		return rules.EnglishCLB4OrLess
+	}
+	return rules.EnglishCLB5OrMore
+}
+
+// add appends a factor to the section and accumulates its points
+func (s *Section) add(name string, points int) {
+	s.Factors = append(s.Factors, Factor{Name: name, Points: points})
+	s.Points += points
+}
+
+// capped returns the section with its points limited to the section maximum
+func (s Section) capped() Section {
+	s.Points = min(s.Points, s.Max)
+	return s
+}
+
+// points returns the points of the tier for the given education group
+func (t crsEducationTier) points(group string) int {
+	if group == "advanced" {
+		return t.Advanced
+	}
+	return t.PostSecondary
+}
+
+// points returns the points of the tier for the given years of foreign work experience
+func (t crsForeignWorkTier) points(years int) int {
+	if years >= 3 {
+		return t.ThreeYearsOrMore
+	}
+	return t.OneToTwoYears
+}
+
+// educationGroup classifies the education level for skill transferability
+func educationGroup(education string) string {
+	switch education {
+	case EducationOneYear, EducationTwoYear, EducationBachelors:
+		return "post_secondary"
+	case EducationTwoOrMore, EducationMasters, EducationDoctoral:
+		return "advanced"
+	default:
+		return ""
+	}
+}
+
+// pick returns the points that apply depending on whether the applicant has an accompanying spouse
+func pick(withSpouse bool, with, without int) int {
+	if withSpouse {
+		return with
+	}
+	return without
+}
+
+// tier is a row of a rule table reached from a minimum level or number of years
+type tier interface {
+	minimum() int
+}
+
+func (t crsSplitTier) minimum() int       { return t.Min }
+func (t crsTier) minimum() int            { return t.Min }
+func (t crsEducationTier) minimum() int   { return t.Min }
+func (t crsForeignWorkTier) minimum() int { return t.Min }
+
+// highestTier returns the highest tier reached by value, tiers are sorted by ascending minimum
+func highestTier[T tier](tiers []T, value int) (T, bool) {
+	var found T
+	ok := false
+	for _, t := range tiers {
+		if value >= t.minimum() {
+			found, ok = t, true
+		}
+	}
+	return found, ok
+}
+
+// tierFor returns the points of the highest tier reached by value, or zero when none is reached
+func tierFor(tiers []crsTier, value int) int {
+	t, _ := highestTier(tiers, value)
+	return t.Points
+}
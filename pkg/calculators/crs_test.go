@@ -0,0 +1,516 @@
+package calculators
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// zeroProfile returns a profile that scores no point on any factor
+func zeroProfile() CRSInput {
+	return CRSInput{
+		Age:           17,
+		Education:     EducationLessThanSecondary,
+		FirstLanguage: LanguageScores{Language: LanguageEnglish},
+	}
+}
+
+// english returns English scores with the same CLB level on every ability
+func english(level int) LanguageScores {
+	return LanguageScores{Language: LanguageEnglish, Reading: level, Writing: level, Speaking: level, Listening: level}
+}
+
+// french returns French scores with the same NCLC level on every ability
+func french(level int) *LanguageScores {
+	return &LanguageScores{Language: LanguageFrench, Reading: level, Writing: level, Speaking: level, Listening: level}
+}
+
+// score computes the profile and fails the test on error
+func score(t *testing.T, profile CRSInput) *Result {
+	t.Helper()
+	result, err := crs.Score(profile)
+	require.NoError(t, err)
+	return result
+}
+
+// factor returns the points of the named factor in the named section
+func factor(t *testing.T, result *Result, section, name string) int {
+	t.Helper()
+	for _, s := range result.Sections {
+		if s.Name != section {
+			continue
+		}
+		for _, f := range s.Factors {
+			if f.Name == name {
+				return f.Points
+			}
+		}
+	}
+	t.Fatalf("factor %q not found in section %q", name, section)
+	return 0
+}
+
+// section returns the points of the named section
+func section(t *testing.T, result *Result, name string) int {
+	t.Helper()
+	for _, s := range result.Sections {
+		if s.Name == name {
+			return s.Points
+		}
+	}
+	t.Fatalf("section %q not found", name)
+	return 0
+}
+
+const (
+	coreSection       = "Core / human capital factors"
+	spouseSection     = "Spouse or common-law partner factors"
+	transferSection   = "Skill transferability factors"
+	additionalSection = "Additional points"
+)
+
+func Test_CRS_ZeroProfile(t *testing.T) {
+	result := score(t, zeroProfile())
+
+	assert.Equal(t, "crs", result.Calculator)
+	assert.Equal(t, "2025-03-25", result.Version)
+	assert.Equal(t, 0, result.Total)
+	assert.Len(t, result.Sections, 4)
+}
+
+func Test_CRS_Age(t *testing.T) {
+	// Points per age, with and without an accompanying spouse
+	expected := map[int][2]int{
+		18: {90, 99}, 19: {95, 105}, 30: {95, 105},
+		31: {90, 99}, 32: {85, 94}, 33: {80, 88}, 34: {75, 83}, 35: {70, 77}, 36: {65, 72},
+		37: {60, 66}, 38: {55, 61}, 39: {50, 55}, 40: {45, 50}, 41: {35, 39}, 42: {25, 28},
+		43: {15, 17}, 44: {5, 6},
+	}
+	for age := 20; age <= 29; age++ {
+		expected[age] = [2]int{100, 110}
+	}
+
+	for age := 0; age <= 60; age++ {
+		points := expected[age]
+
+		profile := zeroProfile()
+		profile.Age = age
+		assert.Equal(t, points[1], factor(t, score(t, profile), coreSection, "Age"), "age %d without spouse", age)
+
+		profile.Spouse = &CRSSpouse{Education: EducationLessThanSecondary}
+		assert.Equal(t, points[0], factor(t, score(t, profile), coreSection, "Age"), "age %d with spouse", age)
+	}
+}
+
+func Test_CRS_Education(t *testing.T) {
+	expected := map[string][2]int{
+		EducationLessThanSecondary: {0, 0},
+		EducationSecondary:         {28, 30},
+		EducationOneYear:           {84, 90},
+		EducationTwoYear:           {91, 98},
+		EducationBachelors:         {112, 120},
+		EducationTwoOrMore:         {119, 128},
+		EducationMasters:           {126, 135},
+		EducationDoctoral:          {140, 150},
+	}
+
+	for education, points := range expected {
+		profile := zeroProfile()
+		profile.Education = education
+		assert.Equal(t, points[1], factor(t, score(t, profile), coreSection, "Level of education"), education)
+
+		profile.Spouse = &CRSSpouse{Education: EducationLessThanSecondary}
+		assert.Equal(t, points[0], factor(t, score(t, profile), coreSection, "Level of education"), education)
+	}
+}
+
+func Test_CRS_FirstLanguage(t *testing.T) {
+	// Points per ability for each CLB level, with and without an accompanying spouse
+	expected := [][2]int{
+		{0, 0}, {0, 0}, {0, 0}, {0, 0}, {6, 6}, {6, 6}, {8, 9}, {16, 17}, {22, 23}, {29, 31}, {32, 34}, {32, 34}, {32, 34},
+	}
+
+	for level, points := range expected {
+		profile := zeroProfile()
+		profile.FirstLanguage = english(level)
+		assert.Equal(t, 4*points[1], factor(t, score(t, profile), coreSection, "First official language"), "CLB %d", level)
+
+		profile.Spouse = &CRSSpouse{Education: EducationLessThanSecondary}
+		assert.Equal(t, 4*points[0], factor(t, score(t, profile), coreSection, "First official language"), "CLB %d", level)
+	}
+
+	// Each ability is scored on its own level
+	profile := zeroProfile()
+	profile.FirstLanguage = LanguageScores{Language: LanguageEnglish, Reading: 10, Writing: 7, Speaking: 6, Listening: 4}
+	assert.Equal(t, 34+17+9+6, factor(t, score(t, profile), coreSection, "First official language"))
+}
+
+func Test_CRS_SecondLanguage(t *testing.T) {
+	expected := []int{0, 0, 0, 0, 0, 1, 1, 3, 3, 6, 6, 6, 6}
+
+	for level, points := range expected {
+		profile := zeroProfile()
+		profile.SecondLanguage = french(level)
+
+		// Four abilities at CLB 9 or more reach the maximum of 24 without spouse and are capped at 22 with spouse
+		assert.Equal(t, 4*points, factor(t, score(t, profile), coreSection, "Second official language"), "NCLC %d", level)
+
+		profile.Spouse = &CRSSpouse{Education: EducationLessThanSecondary}
+		assert.Equal(t, min(4*points, 22), factor(t, score(t, profile), coreSection, "Second official language"), "NCLC %d", level)
+	}
+}
+
+func Test_CRS_CanadianWork(t *testing.T) {
+	expected := [][2]int{{0, 0}, {35, 40}, {46, 53}, {56, 64}, {63, 72}, {70, 80}, {70, 80}, {70, 80}}
+
+	for years, points := range expected {
+		profile := zeroProfile()
+		profile.CanadianWorkYears = years
+		assert.Equal(t, points[1], factor(t, score(t, profile), coreSection, "Canadian work experience"), "%d years", years)
+
+		profile.Spouse = &CRSSpouse{Education: EducationLessThanSecondary}
+		assert.Equal(t, points[0], factor(t, score(t, profile), coreSection, "Canadian work experience"), "%d years", years)
+	}
+}
+
+func Test_CRS_CoreMaximum(t *testing.T) {
+	profile := zeroProfile()
+	profile.Age = 25
+	profile.Education = EducationDoctoral
+	profile.FirstLanguage = english(12)
+	profile.SecondLanguage = french(12)
+	profile.CanadianWorkYears = 10
+	assert.Equal(t, 500, section(t, score(t, profile), coreSection))
+
+	profile.Spouse = &CRSSpouse{Education: EducationLessThanSecondary}
+	assert.Equal(t, 460, section(t, score(t, profile), coreSection))
+}
+
+func Test_CRS_Spouse(t *testing.T) {
+	educations := map[string]int{
+		EducationLessThanSecondary: 0,
+		EducationSecondary:         2,
+		EducationOneYear:           6,
+		EducationTwoYear:           7,
+		EducationBachelors:         8,
+		EducationTwoOrMore:         9,
+		EducationMasters:           10,
+		EducationDoctoral:          10,
+	}
+	for education, points := range educations {
+		profile := zeroProfile()
+		profile.Spouse = &CRSSpouse{Education: education}
+		assert.Equal(t, points, factor(t, score(t, profile), spouseSection, "Level of education"), education)
+	}
+
+	languages := []int{0, 0, 0, 0, 0, 1, 1, 3, 3, 5, 5, 5, 5}
+	for level, points := range languages {
+		scores := english(level)
+		profile := zeroProfile()
+		profile.Spouse = &CRSSpouse{Education: EducationLessThanSecondary, Language: &scores}
+		assert.Equal(t, 4*points, factor(t, score(t, profile), spouseSection, "First official language"), "CLB %d", level)
+	}
+
+	works := []int{0, 5, 7, 8, 9, 10, 10}
+	for years, points := range works {
+		profile := zeroProfile()
+		profile.Spouse = &CRSSpouse{Education: EducationLessThanSecondary, CanadianWorkYears: years}
+		assert.Equal(t, points, factor(t, score(t, profile), spouseSection, "Canadian work experience"), "%d years", years)
+	}
+
+	// The spouse section never exceeds 40 points and is empty without spouse
+	scores := english(12)
+	profile := zeroProfile()
+	profile.Spouse = &CRSSpouse{Education: EducationDoctoral, Language: &scores, CanadianWorkYears: 9}
+	assert.Equal(t, 40, section(t, score(t, profile), spouseSection))
+	assert.Equal(t, 0, section(t, score(t, zeroProfile()), spouseSection))
+}
+
+func Test_CRS_SkillTransferability_Education(t *testing.T) {
+	tests := []struct {
+		education    string
+		language     int
+		canadianWork int
+		withLanguage int
+		withWork     int
+		section      int
+	}{
+		{EducationSecondary, 10, 3, 0, 0, 0},
+		{EducationOneYear, 6, 0, 0, 0, 0},
+		{EducationOneYear, 7, 0, 13, 0, 13},
+		{EducationTwoYear, 8, 1, 13, 13, 26},
+		{EducationBachelors, 9, 0, 25, 0, 25},
+		{EducationBachelors, 9, 2, 25, 25, 50},
+		{EducationTwoOrMore, 7, 0, 25, 0, 25},
+		{EducationMasters, 9, 0, 50, 0, 50},
+		{EducationMasters, 9, 1, 50, 25, 50},
+		{EducationDoctoral, 5, 1, 0, 25, 25},
+		{EducationDoctoral, 10, 5, 50, 50, 50},
+	}
+
+	for _, test := range tests {
+		name := fmt.Sprintf("%s CLB %d %d years", test.education, test.language, test.canadianWork)
+		profile := zeroProfile()
+		profile.Education = test.education
+		profile.FirstLanguage = english(test.language)
+		profile.CanadianWorkYears = test.canadianWork
+
+		result := score(t, profile)
+		assert.Equal(t, test.withLanguage, factor(t, result, transferSection, "Education and official language"), name)
+		assert.Equal(t, test.withWork, factor(t, result, transferSection, "Education and Canadian work experience"), name)
+		assert.Equal(t, test.section, section(t, result, transferSection), name)
+	}
+}
+
+func Test_CRS_SkillTransferability_ForeignWork(t *testing.T) {
+	tests := []struct {
+		foreignWork  int
+		language     int
+		canadianWork int
+		withLanguage int
+		withWork     int
+		section      int
+	}{
+		{0, 10, 3, 0, 0, 0},
+		{1, 6, 0, 0, 0, 0},
+		{1, 7, 0, 13, 0, 13},
+		{2, 9, 0, 25, 0, 25},
+		{2, 5, 1, 0, 13, 13},
+		{2, 5, 2, 0, 25, 25},
+		{3, 7, 0, 25, 0, 25},
+		{3, 9, 0, 50, 0, 50},
+		{3, 5, 1, 0, 25, 25},
+		{4, 8, 2, 25, 50, 50},
+		{6, 10, 4, 50, 50, 50},
+	}
+
+	for _, test := range tests {
+		name := fmt.Sprintf("%d foreign years CLB %d %d canadian years", test.foreignWork, test.language, test.canadianWork)
+		profile := zeroProfile()
+		profile.ForeignWorkYears = test.foreignWork
+		profile.FirstLanguage = english(test.language)
+		profile.CanadianWorkYears = test.canadianWork
+
+		result := score(t, profile)
+		assert.Equal(t, test.withLanguage, factor(t, result, transferSection, "Foreign work experience and official language"), name)
+		assert.Equal(t, test.withWork, factor(t, result, transferSection, "Foreign work experience and Canadian work experience"), name)
+		assert.Equal(t, test.section, section(t, result, transferSection), name)
+	}
+}
+
+func Test_CRS_SkillTransferability_Certificate(t *testing.T) {
+	expected := []int{0, 0, 0, 0, 0, 25, 25, 50, 50, 50, 50, 50, 50}
+
+	for level, points := range expected {
+		profile := zeroProfile()
+		profile.FirstLanguage = english(level)
+		profile.CertificateOfQualification = true
+		assert.Equal(t, points, factor(t, score(t, profile), transferSection, "Certificate of qualification and official language"), "CLB %d", level)
+
+		profile.CertificateOfQualification = false
+		assert.Equal(t, 0, factor(t, score(t, profile), transferSection, "Certificate of qualification and official language"), "CLB %d", level)
+	}
+
+	// The lowest ability decides the language level
+	profile := zeroProfile()
+	profile.FirstLanguage = LanguageScores{Language: LanguageEnglish, Reading: 9, Writing: 9, Speaking: 9, Listening: 6}
+	profile.CertificateOfQualification = true
+	assert.Equal(t, 25, factor(t, score(t, profile), transferSection, "Certificate of qualification and official language"))
+}
+
+func Test_CRS_SkillTransferability_Maximum(t *testing.T) {
+	profile := zeroProfile()
+	profile.Education = EducationDoctoral
+	profile.FirstLanguage = english(10)
+	profile.CanadianWorkYears = 3
+	profile.ForeignWorkYears = 3
+	profile.CertificateOfQualification = true
+
+	assert.Equal(t, 100, section(t, score(t, profile), transferSection))
+}
+
+func Test_CRS_Additional(t *testing.T) {
+	profile := zeroProfile()
+	profile.SiblingInCanada = true
+	assert.Equal(t, 15, factor(t, score(t, profile), additionalSection, "Brother or sister living in Canada"))
+
+	for value, points := range map[string]int{"": 0, "none": 0, "one_or_two_year": 15, "three_year_or_more": 30} {
+		profile := zeroProfile()
+		profile.CanadianEducation = value
+		assert.Equal(t, points, factor(t, score(t, profile), additionalSection, "Post-secondary education in Canada"), value)
+	}
+
+	profile = zeroProfile()
+	profile.ProvincialNomination = true
+	assert.Equal(t, 600, factor(t, score(t, profile), additionalSection, "Provincial or territorial nomination"))
+
+	// The section is capped at 600 points
+	profile.SiblingInCanada = true
+	profile.CanadianEducation = "three_year_or_more"
+	assert.Equal(t, 600, section(t, score(t, profile), additionalSection))
+}
+
+func Test_CRS_French(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  LanguageScores
+		second *LanguageScores
+		points int
+	}{
+		{"no french", english(10), nil, 0},
+		{"french below NCLC 7", english(10), french(6), 0},
+		{"french without english", *french(7), nil, 25},
+		{"french and english CLB 4", *french(9), &LanguageScores{Language: LanguageEnglish, Reading: 4, Writing: 4, Speaking: 4, Listening: 4}, 25},
+		{"french and english with one ability under CLB 5", *french(7), &LanguageScores{Language: LanguageEnglish, Reading: 8, Writing: 8, Speaking: 4, Listening: 8}, 25},
+		{"french and english CLB 5", *french(7), &LanguageScores{Language: LanguageEnglish, Reading: 5, Writing: 5, Speaking: 5, Listening: 5}, 50},
+		{"english first and french second", english(9), french(7), 50},
+		{"french with one ability under NCLC 7", english(9), &LanguageScores{Language: LanguageFrench, Reading: 9, Writing: 6, Speaking: 9, Listening: 9}, 0},
+	}
+
+	for _, test := range tests {
+		profile := zeroProfile()
+		profile.FirstLanguage = test.first
+		profile.SecondLanguage = test.second
+		assert.Equal(t, test.points, factor(t, score(t, profile), additionalSection, "French language skills"), test.name)
+	}
+}
+
+func Test_CRS_ArrangedEmployment_Versions(t *testing.T) {
+	tests := []struct {
+		version string
+		value   string
+		points  int
+	}{
+		{"2022-11-16", "none", 0},
+		{"2022-11-16", "teer_0_major_group_00", 200},
+		{"2022-11-16", "teer_0_1_2_3", 50},
+		{"2025-03-25", "teer_0_major_group_00", 0},
+		{"2025-03-25", "teer_0_1_2_3", 0},
+	}
+
+	for _, test := range tests {
+		profile := zeroProfile()
+		profile.Version = test.version
+		profile.ArrangedEmployment = test.value
+
+		result := score(t, profile)
+		assert.Equal(t, test.version, result.Version)
+		assert.Equal(t, test.points, factor(t, result, additionalSection, "Arranged employment"), test.version+" "+test.value)
+	}
+}
+
+func Test_CRS_Profiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile CRSInput
+		total   int
+	}{
+		{
+			// Core 110+135+124+40, transferability 50+50
+			name: "single master's graduate with Canadian experience",
+			profile: CRSInput{
+				Age:               29,
+				Education:         EducationMasters,
+				FirstLanguage:     english(9),
+				CanadianWorkYears: 1,
+				ForeignWorkYears:  3,
+			},
+			total: 509,
+		},
+		{
+			// Core 90+112+88+12+0, spouse 8+12+0, transferability 13+13, additional 50
+			name: "married bachelor's graduate speaking French",
+			profile: CRSInput{
+				Age:              31,
+				Education:        EducationBachelors,
+				FirstLanguage:    english(8),
+				SecondLanguage:   french(7),
+				ForeignWorkYears: 2,
+				Spouse: &CRSSpouse{
+					Education: EducationBachelors,
+					Language:  &LanguageScores{Language: LanguageEnglish, Reading: 7, Writing: 7, Speaking: 7, Listening: 7},
+				},
+			},
+			total: 90 + 112 + 88 + 12 + 8 + 12 + 13 + 13 + 50,
+		},
+		{
+			// Core 77+98+68+0+64, transferability 38+38+50 capped at 100, additional 600+15+15+200 capped at 600
+			name: "nominated tradesperson with a job offer under the 2022 rules",
+			profile: CRSInput{
+				Version:                    "2022-11-16",
+				Age:                        35,
+				Education:                  EducationTwoYear,
+				FirstLanguage:              english(7),
+				CanadianWorkYears:          3,
+				ForeignWorkYears:           1,
+				CertificateOfQualification: true,
+				SiblingInCanada:            true,
+				CanadianEducation:          "one_or_two_year",
+				ArrangedEmployment:         "teer_0_major_group_00",
+				ProvincialNomination:       true,
+			},
+			total: 77 + 98 + 68 + 64 + 100 + 600,
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.total, score(t, test.profile).Total, test.name)
+	}
+}
+
+func Test_CRS_Calculate_JSON(t *testing.T) {
+	input := json.RawMessage(`{
+		"age": 29,
+		"education": "masters",
+		"first_language": {"language": "english", "reading": 9, "writing": 9, "speaking": 9, "listening": 9},
+		"canadian_work_years": 1,
+		"foreign_work_years": 3
+	}`)
+
+	result, err := crs.Calculate(input)
+	require.NoError(t, err)
+	assert.Equal(t, 509, result.Total)
+}
+
+func Test_CRS_InvalidInput(t *testing.T) {
+	inputs := []string{
+		`not json`,
+		`{"age": 29, "education": "masters", "unknown": true}`,
+		`{"age": 29, "education": "phd", "first_language": {"language": "english"}}`,
+		`{"age": -1, "education": "masters", "first_language": {"language": "english"}}`,
+		`{"age": 29, "education": "masters", "first_language": {"language": "spanish"}}`,
+		`{"age": 29, "education": "masters", "first_language": {"language": "english", "reading": 13}}`,
+		`{"age": 29, "education": "masters", "first_language": {"language": "english"}, "second_language": {"language": "english"}}`,
+		`{"age": 29, "education": "masters", "first_language": {"language": "english"}, "canadian_work_years": -2}`,
+		`{"age": 29, "education": "masters", "first_language": {"language": "english"}, "canadian_education": "phd"}`,
+		`{"age": 29, "education": "masters", "first_language": {"language": "english"}, "arranged_employment": "teer_4"}`,
+		`{"age": 29, "education": "masters", "first_language": {"language": "english"}, "spouse": {"education": "phd"}}`,
+		`{"age": 29, "education": "masters", "first_language": {"language": "english"}, "version": "1999-01-01"}`,
+	}
+
+	for _, input := range inputs {
+		_, err := crs.Calculate(json.RawMessage(input))
+		assert.True(t, errors.Is(err, ErrInvalidInput), input)
+	}
+}
+
+func Test_CRS_Versions(t *testing.T) {
+	assert.Equal(t, []string{"2022-11-16", "2025-03-25"}, crs.Versions())
+
+	var schema map[string]any
+	assert.NoError(t, json.Unmarshal(crs.InputSchema(), &schema))
+}
+
+func Test_Registry(t *testing.T) {
+	c, err := Get("crs")
+	require.NoError(t, err)
+	assert.Equal(t, "crs", c.Name())
+
+	_, err = Get("unknown")
+	assert.ErrorIs(t, err, ErrUnknownCalculator)
+
+	assert.Len(t, All(), 1)
+}
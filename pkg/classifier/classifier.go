@@ -0,0 +1,114 @@
+package classifier
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Intents of the questions
+const (
+	IntentPoints             = "points"
+	IntentFees               = "fees"
+	IntentProcessingTime     = "processing_time"
+	IntentPermanentResidence = "permanent_residence"
+	IntentWork               = "work"
+	IntentStudy              = "study"
+	IntentVisit              = "visit"
+	IntentOther              = "other"
+)
+
+// Unknown is the destination country or the language of a question that names none
+const Unknown = "unknown"
+
+// Classification is the topic of a question, searchable in the conversations
+type Classification struct {
+	Intent             string
+	DestinationCountry string
+	Language           string
+}
+
+// keywords maps a value to the words and phrases of the questions about it, the first value matched wins
+type keywords []struct {
+	value string
+	words []string
+}
+
+// intents are matched from the most specific to the most general one: a question about the fees of a work permit is about fees
+var intents = keywords{
+	{IntentPoints, []string{"crs", "points", "score", "puntos", "pontos", "pontuação", "pontuacao"}},
+	{IntentFees, []string{"fee", "fees", "cost", "costs", "price", "how much", "taxa", "taxas", "custo", "tarifa", "frais"}},
+	{IntentProcessingTime, []string{"processing time", "processing times", "how long", "wait", "prazo", "demora", "délai"}},
+	{IntentPermanentResidence, []string{"permanent residence", "permanent resident", "pr", "green card", "express entry", "immigrate",
+		"residência permanente", "residencia permanente", "résidence permanente"}},
+	{IntentWork, []string{"work", "job", "employment", "work permit", "trabalho", "trabalhar", "trabajo", "trabajar", "travail"}},
+	{IntentStudy, []string{"study", "student", "university", "college", "estudar", "estudiar", "étudier", "études"}},
+	{IntentVisit, []string{"visit", "tourist", "tourism", "travel", "vacation", "turismo", "visitar"}},
+}
+
+// countries are the identifiers of the visa catalog
+var countries = keywords{
+	{"canada", []string{"canada", "canadá", "canadian"}},
+	{"united_states", []string{"united states", "usa", "america", "american", "green card", "estados unidos", "eua", "états unis"}},
+	{"united_kingdom", []string{"united kingdom", "uk", "britain", "england", "british", "reino unido", "royaume uni"}},
+	{"australia", []string{"australia", "austrália", "australian", "australie"}},
+}
+
+// languages are recognized by their most common words, the ties go to the first language
+var languages = keywords{
+	{"en", []string{"the", "is", "what", "how", "can", "i", "to", "for", "my", "do", "and", "of"}},
+	{"fr", []string{"le", "les", "est", "je", "pour", "comment", "quel", "quelle", "une", "des", "et", "mon"}},
+	{"es", []string{"el", "los", "es", "cómo", "qué", "para", "una", "y", "mi", "puedo", "del"}},
+	{"pt", []string{"o", "os", "é", "como", "que", "para", "uma", "e", "meu", "minha", "posso", "não", "do"}},
+	{"de", []string{"der", "die", "das", "ist", "wie", "ich", "für", "und", "ein", "eine", "mein", "kann"}},
+}
+
+// Classify returns the intent, the destination country and the language of the question, the locale of the user
+// gives the language when it is known. It is deterministic so the workflows can call it.
+func Classify(question, locale string) Classification {
+	text := normalize(question)
+
+	classification := Classification{
+		Intent:             intents.first(text, IntentOther),
+		DestinationCountry: countries.first(text, Unknown),
+		Language:           strings.ToLower(strings.SplitN(strings.ReplaceAll(locale, "_", "-"), "-", 2)[0]),
+	}
+	if classification.Language == "" {
+		classification.Language = languages.most(text)
+	}
+	return classification
+}
+
+// normalize returns the lower case words of the text separated and surrounded by single spaces
+func normalize(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return " " + strings.Join(words, " ") + " "
+}
+
+// first returns the first value with a word in the text, or fallback
+func (k keywords) first(text, fallback string) string {
+	for _, entry := range k {
+		for _, word := range entry.words {
+			if strings.Contains(text, " "+word+" ") {
+				return entry.value
+			}
+		}
+	}
+	return fallback
+}
+
+// most returns the value with the most words in the text, Unknown when none of them is
+func (k keywords) most(text string) string {
+	best, bestCount := Unknown, 0
+	for _, entry := range k {
+		count := 0
+		for _, word := range entry.words {
+			count += strings.Count(text, " "+word+" ")
+		}
+		if count > bestCount {
+			best, bestCount = entry.value, count
+		}
+	}
+	return best
+}
@@ -0,0 +1,32 @@
+package classifier
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Classify(t *testing.T) {
+	tests := []struct {
+		question, locale string
+		expected         Classification
+	}{
+		{"What is my CRS score for Express Entry?", "", Classification{IntentPoints, Unknown, "en"}},
+		{"How much are the fees of a work permit in Canada?", "", Classification{IntentFees, "canada", "en"}},
+		{"How long does a UK student visa take?", "", Classification{IntentProcessingTime, "united_kingdom", "en"}},
+		{"Can I get a green card?", "", Classification{IntentPermanentResidence, "united_states", "en"}},
+		{"Quero trabalhar no Canadá, como faço?", "", Classification{IntentWork, "canada", "pt"}},
+		{"Comment étudier en Australie ?", "", Classification{IntentStudy, "australia", "fr"}},
+		{"Necesito una visa de turismo para los Estados Unidos", "", Classification{IntentVisit, "united_states", "es"}},
+		{"Hello", "", Classification{IntentOther, Unknown, Unknown}},
+
+		// The locale of the user is preferred to the detection
+		{"What is the capital of France?", "pt-BR", Classification{IntentOther, Unknown, "pt"}},
+		{"What is the capital of France?", "fr_CA", Classification{IntentOther, Unknown, "fr"}},
+
+		// The words are matched whole, the "pr" of prizes is not a permanent residence
+		{"Tell me about the prizes", "", Classification{IntentOther, Unknown, "en"}},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, Classify(test.question, test.locale), test.question)
+	}
+}
@@ -0,0 +1,257 @@
+package config
+
+import (
+	"slices"
+	"time"
+)
+
+// Names of the LLM providers that can be tried first, the other configured providers are their fallbacks
+const (
+	ProviderOpenAI = "openai"
+	ProviderLocal  = "local"
+)
+
+// Config is the configuration of the API and of the worker, each binary reads the sections it needs
+type Config struct {
+	Server   Server   `yaml:"server"`
+	Worker   Worker   `yaml:"worker"`
+	Temporal Temporal `yaml:"temporal"`
+	LLM      LLM      `yaml:"llm"`
+	Policies Policies `yaml:"policies"`
+
+	// DatabaseURL is the Postgres database of the stores, it is a secret only read from DATABASE_URL.
+	// The stores are kept in memory when it is empty.
+	DatabaseURL string `yaml:"-"`
+}
+
+// Authentication modes of the API
+const (
+	AuthJWT      = "jwt"
+	AuthAccounts = "accounts"
+	AuthNone     = "none"
+)
+
+// Server configures the HTTP server of the API
+type Server struct {
+	Addr string `yaml:"addr"`
+
+	// Timeouts of the HTTP server, the write timeout leaves time for the workflow to answer synchronously
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+
+	// AnswerTimeout is the time a synchronous question has to be answered, the workflow is canceled after it
+	AnswerTimeout time.Duration `yaml:"answer_timeout"`
+
+	// ShutdownTimeout is the time given to the in-flight requests to complete once the server is stopped
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+	// AuthMode authenticates the callers with the JWT bearer tokens and the first-party credentials, jwt, with the
+	// first-party credentials only, accounts, or not at all, none, the API then trusts the user sent in the request body
+	AuthMode string `yaml:"auth_mode"`
+
+	// PasswordResetURL is the link of the password reset emails, the token is appended to it
+	PasswordResetURL string `yaml:"password_reset_url"`
+
+	RateLimits RateLimits `yaml:"rate_limits"`
+}
+
+// RateLimits are the rates, in requests per minute, of the client IPs on every route and of the authenticated users
+// on the private routes, 0 disables a limit
+type RateLimits struct {
+	IPPerMinute   int `yaml:"ip_per_minute"`
+	UserPerMinute int `yaml:"user_per_minute"`
+
+	// TrustedProxies are the networks of the proxies whose X-Forwarded-For header gives the client IP
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// Names of the worker pools, a worker process runs any subset of them
+const (
+	PoolWorkflows = "workflows"
+	PoolLLM       = "llm"
+)
+
+// Worker configures the worker processes: the addresses of their probes and of their Prometheus metrics,
+// and the pools they run
+type Worker struct {
+	HealthAddr  string `yaml:"health_addr"`
+	MetricsAddr string `yaml:"metrics_addr"`
+
+	// Pools are the pools run by the process, the LLM pool can then be scaled independently of the workflows
+	Pools []string `yaml:"pools"`
+
+	// StickyCacheSize is the number of workflows kept in memory by the process to not replay their history
+	StickyCacheSize int `yaml:"sticky_cache_size"`
+
+	// BuildID identifies the code of the worker, such as the git commit of its image. With UseBuildIDVersioning
+	// the workflows only run on the workers of the build ID they started on, or of a build ID marked compatible with it
+	BuildID              string `yaml:"build_id"`
+	UseBuildIDVersioning bool   `yaml:"use_build_id_versioning"`
+
+	// RegisterSearchAttributes adds the missing search attributes of the conversations to the namespace at startup,
+	// it needs the operator permissions that the API keys of Temporal Cloud do not have. Otherwise they are only checked.
+	RegisterSearchAttributes bool `yaml:"register_search_attributes"`
+
+	// Workflows runs the workflows and their activities but the calls to the models, LLM runs the calls to the models
+	Workflows Pool `yaml:"workflows"`
+	LLM       Pool `yaml:"llm"`
+
+	// BatchDir is the directory of the input and output files of the batches given as relative paths
+	BatchDir string `yaml:"batch_dir"`
+
+	// BatchHosts are the only hosts of the URLs the batches read and write, the URLs are refused when it is empty
+	BatchHosts []string `yaml:"batch_hosts"`
+
+	// WebhookHosts are the only hosts the answers of the queued questions are posted to, the webhooks are refused when it is empty
+	WebhookHosts []string `yaml:"webhook_hosts"`
+}
+
+// Runs reports whether the process runs the pool
+func (w Worker) Runs(pool string) bool {
+	return slices.Contains(w.Pools, pool)
+}
+
+// Pool configures the worker polling a task queue, the zero values keep the defaults of the SDK
+type Pool struct {
+	MaxConcurrentWorkflowTasks int `yaml:"max_concurrent_workflow_tasks"`
+	MaxConcurrentActivities    int `yaml:"max_concurrent_activities"`
+
+	// WorkerActivitiesPerSecond limits the activities started by the worker of the process and
+	// TaskQueueActivitiesPerSecond by all the workers of the task queue, such as the rate limit of the OpenAI organization
+	WorkerActivitiesPerSecond    float64 `yaml:"worker_activities_per_second"`
+	TaskQueueActivitiesPerSecond float64 `yaml:"task_queue_activities_per_second"`
+}
+
+// Temporal configures the connection to the Temporal server
+type Temporal struct {
+	HostPort   string     `yaml:"host_port"`
+	Namespace  string     `yaml:"namespace"`
+	TLS        TLS        `yaml:"tls"`
+	TaskQueues TaskQueues `yaml:"task_queues"`
+
+	// APIKey authenticates the client to Temporal Cloud, it is a secret only read from TEMPORAL_API_KEY.
+	// APIKeyFile is read instead when it is set, the key is then reloaded when the file is rotated.
+	APIKey     string `yaml:"-"`
+	APIKeyFile string `yaml:"api_key_file"`
+}
+
+// TaskQueues are the task queues polled by the pools of the worker
+type TaskQueues struct {
+	// Chat is the task queue the workflows are started on by the API, with their activities but the calls to the models
+	Chat string `yaml:"chat"`
+
+	// LLM is the task queue of the calls to the models
+	LLM string `yaml:"llm"`
+}
+
+// TLS configures the TLS connection to Temporal, the client certificate authenticates the client with mTLS
+type TLS struct {
+	Enabled bool `yaml:"enabled"`
+
+	// CertFile and KeyFile are the client certificate, CAFile the CA bundle of the server when it is not a public one.
+	// The files are reloaded when they are rotated.
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	CAFile   string `yaml:"ca_file"`
+
+	// ServerName overrides the name verified in the certificate of the server
+	ServerName string `yaml:"server_name"`
+}
+
+// LLM configures the models answering the questions, the API keys are secrets only read from the environment
+type LLM struct {
+	// Provider is the provider tried first, openai or local
+	Provider string `yaml:"provider"`
+
+	// Model is the OpenAI model and FallbackModel the cheaper one tried when it fails, empty to not fall back
+	Model         string `yaml:"model"`
+	FallbackModel string `yaml:"fallback_model"`
+
+	// Temperature and MaxTokens of the completions, 0 keeps the default of the provider
+	Temperature float32 `yaml:"temperature"`
+	MaxTokens   int     `yaml:"max_tokens"`
+
+	Local Local `yaml:"local"`
+}
+
+// Local configures the local OpenAI compatible model, it is not used without BaseURL
+type Local struct {
+	BaseURL string `yaml:"base_url"`
+	Model   string `yaml:"model"`
+}
+
+// Policies are the timeouts and the retries of the activities
+type Policies struct {
+	// Chat applies to the calls to each provider, once its attempts are exhausted the next provider is tried
+	Chat Activity `yaml:"chat"`
+}
+
+// Activity holds the timeouts and the retry policy of an activity
+type Activity struct {
+	StartToCloseTimeout    time.Duration `yaml:"start_to_close_timeout"`
+	ScheduleToCloseTimeout time.Duration `yaml:"schedule_to_close_timeout"`
+	Retry                  Retry         `yaml:"retry"`
+}
+
+// Retry is the retry policy of an activity
+type Retry struct {
+	InitialInterval    time.Duration `yaml:"initial_interval"`
+	BackoffCoefficient float64       `yaml:"backoff_coefficient"`
+	MaximumInterval    time.Duration `yaml:"maximum_interval"`
+	MaximumAttempts    int32         `yaml:"maximum_attempts"`
+}
+
+// Default returns the configuration used when no source overrides it
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:              ":3002",
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      5 * time.Minute,
+			IdleTimeout:       2 * time.Minute,
+			AnswerTimeout:     4 * time.Minute,
+			ShutdownTimeout:   60 * time.Second,
+			AuthMode:          AuthJWT,
+			RateLimits: RateLimits{
+				IPPerMinute:   120,
+				UserPerMinute: 30,
+				// The ELB ingress runs in the private networks of the VPC
+				TrustedProxies: []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "127.0.0.0/8", "::1/128", "fc00::/7"},
+			},
+		},
+		Worker: Worker{
+			HealthAddr:      ":7239",
+			MetricsAddr:     ":9090",
+			Pools:           []string{PoolWorkflows, PoolLLM},
+			StickyCacheSize: 10000,
+			LLM:             Pool{MaxConcurrentActivities: 50},
+			BatchDir:        "batches",
+		},
+		Temporal: Temporal{
+			HostPort:   "localhost:7233",
+			Namespace:  "default",
+			TaskQueues: TaskQueues{Chat: "chat_bot_workflow_task_queue", LLM: "chat_bot_llm_task_queue"},
+		},
+		LLM: LLM{
+			Provider:      ProviderOpenAI,
+			Model:         "gpt-4o-2024-05-13",
+			FallbackModel: "gpt-4o-mini",
+			Local:         Local{Model: "llama3.1"},
+		},
+		Policies: Policies{
+			Chat: Activity{
+				StartToCloseTimeout:    30 * time.Second,
+				ScheduleToCloseTimeout: 90 * time.Second,
+				Retry: Retry{
+					InitialInterval:    time.Second,
+					BackoffCoefficient: 2.0,
+					MaximumInterval:    10 * time.Second,
+					MaximumAttempts:    3,
+				},
+			},
+		},
+	}
+}
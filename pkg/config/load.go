@@ -0,0 +1,196 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvFile is the environment variable naming the YAML file, the -config flag takes precedence
+const EnvFile = "CONFIG_FILE"
+
+// EnvTemporalAPIKey is the environment variable of the API key of Temporal Cloud, the secrets have no flag
+const EnvTemporalAPIKey = "TEMPORAL_API_KEY"
+
+// EnvDatabaseURL is the environment variable of the URL of the Postgres database, it holds its password
+const EnvDatabaseURL = "DATABASE_URL"
+
+// setting is a value of the configuration that can be overridden by an environment variable and a flag
+type setting struct {
+	// path is the path of the value in the YAML file, the flag is named after it: temporal.host_port is -temporal-host-port
+	path  string
+	env   string
+	usage string
+	value func(c *Config) any
+}
+
+// flag returns the name of the flag of the setting
+func (s setting) flag() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.path)
+}
+
+// settings are the values that can be overridden, the environment variables kept their names from before the YAML file
+var settings = []setting{
+	{"server.addr", "SERVER_ADDR", "address of the API", func(c *Config) any { return &c.Server.Addr }},
+	{"server.answer_timeout", "ANSWER_TIMEOUT", "time a synchronous question has to be answered", func(c *Config) any { return &c.Server.AnswerTimeout }},
+	{"server.shutdown_timeout", "SHUTDOWN_TIMEOUT", "time given to the in-flight requests on shutdown", func(c *Config) any { return &c.Server.ShutdownTimeout }},
+	{"server.auth_mode", "AUTH_MODE", "authentication of the callers: jwt, accounts or none", func(c *Config) any { return &c.Server.AuthMode }},
+	{"server.password_reset_url", "PASSWORD_RESET_URL", "link of the password reset emails, the token is appended to it", func(c *Config) any { return &c.Server.PasswordResetURL }},
+	{"server.rate_limits.ip_per_minute", "RATE_LIMIT_IP_PER_MINUTE", "requests per minute of each client IP, 0 disables the limit", func(c *Config) any { return &c.Server.RateLimits.IPPerMinute }},
+	{"server.rate_limits.user_per_minute", "RATE_LIMIT_USER_PER_MINUTE", "requests per minute of each user, 0 disables the limit", func(c *Config) any { return &c.Server.RateLimits.UserPerMinute }},
+	{"server.rate_limits.trusted_proxies", "TRUSTED_PROXIES", "comma separated networks of the proxies giving the client IP", func(c *Config) any { return &c.Server.RateLimits.TrustedProxies }},
+	{"worker.health_addr", "HEALTH_ADDR", "address of the probes of the worker", func(c *Config) any { return &c.Worker.HealthAddr }},
+	{"worker.metrics_addr", "METRICS_ADDR", "address of the metrics of the worker", func(c *Config) any { return &c.Worker.MetricsAddr }},
+	{"worker.pools", "WORKER_POOLS", "comma separated pools run by the worker: workflows, llm", func(c *Config) any { return &c.Worker.Pools }},
+	{"worker.sticky_cache_size", "WORKER_STICKY_CACHE_SIZE", "workflows kept in the cache of the worker", func(c *Config) any { return &c.Worker.StickyCacheSize }},
+	{"worker.build_id", "WORKER_BUILD_ID", "build ID of the code of the worker", func(c *Config) any { return &c.Worker.BuildID }},
+	{"worker.use_build_id_versioning", "WORKER_USE_BUILD_ID_VERSIONING", "run the workflows on the workers of a compatible build ID", func(c *Config) any { return &c.Worker.UseBuildIDVersioning }},
+	{"worker.register_search_attributes", "WORKER_REGISTER_SEARCH_ATTRIBUTES", "add the missing search attributes to the namespace at startup", func(c *Config) any { return &c.Worker.RegisterSearchAttributes }},
+	{"worker.workflows.max_concurrent_workflow_tasks", "", "workflow tasks run at once by the workflows pool", func(c *Config) any { return &c.Worker.Workflows.MaxConcurrentWorkflowTasks }},
+	{"worker.workflows.max_concurrent_activities", "", "activities run at once by the workflows pool", func(c *Config) any { return &c.Worker.Workflows.MaxConcurrentActivities }},
+	{"worker.llm.max_concurrent_activities", "WORKER_LLM_MAX_CONCURRENT_ACTIVITIES", "calls to the models run at once by the LLM pool", func(c *Config) any { return &c.Worker.LLM.MaxConcurrentActivities }},
+	{"worker.llm.worker_activities_per_second", "", "calls to the models started per second by the LLM pool of the process", func(c *Config) any { return &c.Worker.LLM.WorkerActivitiesPerSecond }},
+	{"worker.llm.task_queue_activities_per_second", "WORKER_LLM_ACTIVITIES_PER_SECOND", "calls to the models started per second by every LLM pool", func(c *Config) any { return &c.Worker.LLM.TaskQueueActivitiesPerSecond }},
+	{"worker.batch_dir", "BATCH_DIR", "directory of the input and output files of the batches", func(c *Config) any { return &c.Worker.BatchDir }},
+	{"worker.batch_hosts", "BATCH_HOSTS", "comma separated hosts of the URLs the batches read and write", func(c *Config) any { return &c.Worker.BatchHosts }},
+	{"worker.webhook_hosts", "WEBHOOK_HOSTS", "comma separated hosts the answers of the queued questions are posted to", func(c *Config) any { return &c.Worker.WebhookHosts }},
+	{"temporal.host_port", "TEMPORAL_HOST_PORT", "address of the Temporal frontend", func(c *Config) any { return &c.Temporal.HostPort }},
+	{"temporal.namespace", "TEMPORAL_NAMESPACE", "Temporal namespace", func(c *Config) any { return &c.Temporal.Namespace }},
+	{"temporal.tls.enabled", "TEMPORAL_TLS", "connect to Temporal with TLS", func(c *Config) any { return &c.Temporal.TLS.Enabled }},
+	{"temporal.tls.cert_file", "TEMPORAL_TLS_CERT", "client certificate of Temporal", func(c *Config) any { return &c.Temporal.TLS.CertFile }},
+	{"temporal.tls.key_file", "TEMPORAL_TLS_KEY", "key of the client certificate of Temporal", func(c *Config) any { return &c.Temporal.TLS.KeyFile }},
+	{"temporal.tls.ca_file", "TEMPORAL_TLS_CA", "CA of the Temporal server", func(c *Config) any { return &c.Temporal.TLS.CAFile }},
+	{"temporal.tls.server_name", "TEMPORAL_TLS_SERVER_NAME", "name in the certificate of the Temporal server", func(c *Config) any { return &c.Temporal.TLS.ServerName }},
+	{"temporal.api_key_file", "TEMPORAL_API_KEY_FILE", "file of the API key of Temporal Cloud", func(c *Config) any { return &c.Temporal.APIKeyFile }},
+	{"temporal.task_queues.chat", "TEMPORAL_TASK_QUEUE", "task queue of the chat workflows", func(c *Config) any { return &c.Temporal.TaskQueues.Chat }},
+	{"temporal.task_queues.llm", "TEMPORAL_LLM_TASK_QUEUE", "task queue of the calls to the models", func(c *Config) any { return &c.Temporal.TaskQueues.LLM }},
+	{"llm.provider", "LLM_PROVIDER", "provider tried first, openai or local", func(c *Config) any { return &c.LLM.Provider }},
+	{"llm.model", "OPENAI_MODEL", "OpenAI model", func(c *Config) any { return &c.LLM.Model }},
+	{"llm.fallback_model", "OPENAI_FALLBACK_MODEL", "OpenAI model tried when the first one fails", func(c *Config) any { return &c.LLM.FallbackModel }},
+	{"llm.temperature", "LLM_TEMPERATURE", "temperature of the completions, 0 for the default of the provider", func(c *Config) any { return &c.LLM.Temperature }},
+	{"llm.max_tokens", "LLM_MAX_TOKENS", "maximum tokens of the completions, 0 for the default of the provider", func(c *Config) any { return &c.LLM.MaxTokens }},
+	{"llm.local.base_url", "LOCAL_LLM_BASE_URL", "base URL of the local OpenAI compatible model", func(c *Config) any { return &c.LLM.Local.BaseURL }},
+	{"llm.local.model", "LOCAL_LLM_MODEL", "local model", func(c *Config) any { return &c.LLM.Local.Model }},
+	{"policies.chat.start_to_close_timeout", "", "timeout of each call to a provider", func(c *Config) any { return &c.Policies.Chat.StartToCloseTimeout }},
+	{"policies.chat.schedule_to_close_timeout", "", "timeout of the calls to a provider, retries included", func(c *Config) any { return &c.Policies.Chat.ScheduleToCloseTimeout }},
+	{"policies.chat.retry.maximum_attempts", "", "attempts per provider", func(c *Config) any { return &c.Policies.Chat.Retry.MaximumAttempts }},
+}
+
+// Load returns the configuration of the binary: the defaults, overridden by the YAML file named by -config or CONFIG_FILE,
+// then by the environment variables and last by the flags of the command line. The configuration is validated.
+func Load(name string, args []string) (Config, error) {
+	c := Default()
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	file := flags.String("config", os.Getenv(EnvFile), "YAML configuration file (env "+EnvFile+")")
+	for _, s := range settings {
+		usage := s.usage
+		if s.env != "" {
+			usage += " (env " + s.env + ")"
+		}
+		flags.String(s.flag(), format(s.value(&c)), usage)
+	}
+	if err := flags.Parse(args); err != nil {
+		return c, err
+	}
+
+	if *file != "" {
+		if err := c.readFile(*file); err != nil {
+			return c, err
+		}
+	}
+
+	var errs []error
+	for _, s := range settings {
+		if value := os.Getenv(s.env); s.env != "" && value != "" {
+			if err := set(s.value(&c), value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+			}
+		}
+	}
+	c.Temporal.APIKey = os.Getenv(EnvTemporalAPIKey)
+	c.DatabaseURL = os.Getenv(EnvDatabaseURL)
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag() == f.Name {
+				if err := set(s.value(&c), f.Value.String()); err != nil {
+					errs = append(errs, fmt.Errorf("-%s: %w", f.Name, err))
+				}
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return c, errors.Join(errs...)
+	}
+
+	return c, c.Validate()
+}
+
+// readFile overrides the configuration with the YAML file, the unknown keys are rejected to catch the typos
+func (c *Config) readFile(name string) error {
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("unable to read the configuration: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// format returns the value of the pointer of a setting as it is set
+func format(target any) string {
+	if values, ok := target.(*[]string); ok {
+		return strings.Join(*values, ",")
+	}
+	return fmt.Sprint(reflect.ValueOf(target).Elem())
+}
+
+// set parses the value into the pointer of a setting
+func set(target any, value string) error {
+	var err error
+	switch target := target.(type) {
+	case *string:
+		*target = value
+	case *bool:
+		*target, err = strconv.ParseBool(value)
+	case *int:
+		*target, err = strconv.Atoi(value)
+	case *int32:
+		var parsed int64
+		parsed, err = strconv.ParseInt(value, 10, 32)
+		*target = int32(parsed)
+	case *float32:
+		var parsed float64
+		parsed, err = strconv.ParseFloat(value, 32)
+		*target = float32(parsed)
+	case *float64:
+		*target, err = strconv.ParseFloat(value, 64)
+	case *[]string:
+		*target = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*target = append(*target, item)
+			}
+		}
+	case *time.Duration:
+		*target, err = time.ParseDuration(value)
+	default:
+		panic(fmt.Sprintf("unsupported setting %T", target))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value %q", value)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+)
+
+// Validate returns every invalid value of the configuration, each error names the path of the value in the YAML file
+func (c Config) Validate() error {
+	var v validator
+	v.address("server.addr", c.Server.Addr)
+	v.positive("server.read_header_timeout", c.Server.ReadHeaderTimeout)
+	v.positive("server.read_timeout", c.Server.ReadTimeout)
+	v.positive("server.write_timeout", c.Server.WriteTimeout)
+	v.positive("server.idle_timeout", c.Server.IdleTimeout)
+	v.positive("server.answer_timeout", c.Server.AnswerTimeout)
+	v.positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+	v.check(c.Server.AnswerTimeout < c.Server.WriteTimeout, "server.answer_timeout", "must be shorter than server.write_timeout %s to write the timeout response", c.Server.WriteTimeout)
+	v.check(slices.Contains([]string{AuthJWT, AuthAccounts, AuthNone}, c.Server.AuthMode), "server.auth_mode", "must be %s, %s or %s, not %q", AuthJWT, AuthAccounts, AuthNone, c.Server.AuthMode)
+	if c.Server.PasswordResetURL != "" {
+		parsed, err := url.Parse(c.Server.PasswordResetURL)
+		v.check(err == nil && parsed.Scheme != "" && parsed.Host != "", "server.password_reset_url", "must be the URL of the reset page, not %q", c.Server.PasswordResetURL)
+	}
+	v.check(c.Server.RateLimits.IPPerMinute >= 0, "server.rate_limits.ip_per_minute", "must not be negative")
+	v.check(c.Server.RateLimits.UserPerMinute >= 0, "server.rate_limits.user_per_minute", "must not be negative")
+	for _, network := range c.Server.RateLimits.TrustedProxies {
+		_, err := netip.ParsePrefix(network)
+		v.check(err == nil, "server.rate_limits.trusted_proxies", "%q is not a network", network)
+	}
+
+	v.address("worker.health_addr", c.Worker.HealthAddr)
+	v.address("worker.metrics_addr", c.Worker.MetricsAddr)
+	v.check(len(c.Worker.Pools) > 0, "worker.pools", "must name at least one pool")
+	for i, pool := range c.Worker.Pools {
+		v.check(pool == PoolWorkflows || pool == PoolLLM, "worker.pools", "must be %s or %s, not %q", PoolWorkflows, PoolLLM, pool)
+		v.check(!slices.Contains(c.Worker.Pools[:i], pool), "worker.pools", "%s is repeated", pool)
+	}
+	v.check(c.Worker.StickyCacheSize >= 0, "worker.sticky_cache_size", "must not be negative")
+	v.check(c.Worker.BuildID != "" || !c.Worker.UseBuildIDVersioning, "worker.build_id", "is required by use_build_id_versioning")
+	v.pool("worker.workflows", c.Worker.Workflows)
+	v.pool("worker.llm", c.Worker.LLM)
+	v.required("worker.batch_dir", c.Worker.BatchDir)
+
+	v.address("temporal.host_port", c.Temporal.HostPort)
+	v.required("temporal.namespace", c.Temporal.Namespace)
+	v.required("temporal.task_queues.chat", c.Temporal.TaskQueues.Chat)
+	v.required("temporal.task_queues.llm", c.Temporal.TaskQueues.LLM)
+	v.check(c.Temporal.TaskQueues.LLM != c.Temporal.TaskQueues.Chat, "temporal.task_queues.llm", "must not be the task queue of the workflows")
+	if tls := c.Temporal.TLS; tls.Enabled {
+		v.check((tls.CertFile == "") == (tls.KeyFile == ""), "temporal.tls", "cert_file and key_file must be set together")
+		v.file("temporal.tls.cert_file", tls.CertFile)
+		v.file("temporal.tls.key_file", tls.KeyFile)
+		v.file("temporal.tls.ca_file", tls.CAFile)
+	}
+	v.file("temporal.api_key_file", c.Temporal.APIKeyFile)
+	v.check(c.Temporal.APIKey == "" || c.Temporal.APIKeyFile == "", "temporal.api_key_file", "must not be set with "+EnvTemporalAPIKey)
+	v.check(c.Temporal.TLS.Enabled || (c.Temporal.APIKey == "" && c.Temporal.APIKeyFile == ""), "temporal.tls.enabled", "must be true to send the API key")
+
+	v.check(c.LLM.Provider == ProviderOpenAI || c.LLM.Provider == ProviderLocal, "llm.provider", "must be %s or %s, not %q", ProviderOpenAI, ProviderLocal, c.LLM.Provider)
+	v.required("llm.model", c.LLM.Model)
+	v.check(c.LLM.Temperature >= 0 && c.LLM.Temperature <= 2, "llm.temperature", "must be between 0 and 2")
+	v.check(c.LLM.MaxTokens >= 0, "llm.max_tokens", "must not be negative")
+	if c.LLM.Provider == ProviderLocal || c.LLM.Local.BaseURL != "" {
+		parsed, err := url.Parse(c.LLM.Local.BaseURL)
+		v.check(err == nil && parsed.Scheme != "" && parsed.Host != "", "llm.local.base_url", "must be the URL of the local model, not %q", c.LLM.Local.BaseURL)
+		v.required("llm.local.model", c.LLM.Local.Model)
+	}
+
+	v.activity("policies.chat", c.Policies.Chat)
+	return errors.Join(v.errs...)
+}
+
+// validator collects the errors of the invalid values
+type validator struct {
+	errs []error
+}
+
+// check records the error of the value when the condition does not hold
+func (v *validator) check(ok bool, path, format string, args ...any) {
+	if !ok {
+		v.errs = append(v.errs, fmt.Errorf("%s: "+format, append([]any{path}, args...)...))
+	}
+}
+
+// required checks the value is set
+func (v *validator) required(path, value string) {
+	v.check(value != "", path, "is required")
+}
+
+// positive checks the duration is set
+func (v *validator) positive(path string, d time.Duration) {
+	v.check(d > 0, path, "must be a positive duration")
+}
+
+// address checks the value is a host:port address, the host may be empty to listen on every interface
+func (v *validator) address(path, value string) {
+	_, _, err := net.SplitHostPort(value)
+	v.check(err == nil, path, "must be a host:port address, not %q", value)
+}
+
+// file checks the file exists when it is set
+func (v *validator) file(path, name string) {
+	if name == "" {
+		return
+	}
+	_, err := os.Stat(name)
+	v.check(err == nil, path, "%v", err)
+}
+
+// pool checks the options of the worker of the pool
+func (v *validator) pool(path string, p Pool) {
+	v.check(p.MaxConcurrentWorkflowTasks >= 0, path+".max_concurrent_workflow_tasks", "must not be negative")
+	v.check(p.MaxConcurrentActivities >= 0, path+".max_concurrent_activities", "must not be negative")
+	v.check(p.WorkerActivitiesPerSecond >= 0, path+".worker_activities_per_second", "must not be negative")
+	v.check(p.TaskQueueActivitiesPerSecond >= 0, path+".task_queue_activities_per_second", "must not be negative")
+}
+
+// activity checks the timeouts and the retry policy of the activity
+func (v *validator) activity(path string, a Activity) {
+	v.positive(path+".start_to_close_timeout", a.StartToCloseTimeout)
+	v.check(a.ScheduleToCloseTimeout >= a.StartToCloseTimeout, path+".schedule_to_close_timeout", "must not be shorter than start_to_close_timeout")
+	v.positive(path+".retry.initial_interval", a.Retry.InitialInterval)
+	v.check(a.Retry.BackoffCoefficient >= 1, path+".retry.backoff_coefficient", "must be at least 1")
+	v.check(a.Retry.MaximumInterval >= a.Retry.InitialInterval, path+".retry.maximum_interval", "must not be shorter than initial_interval")
+	v.check(a.Retry.MaximumAttempts >= 1, path+".retry.maximum_attempts", "must be at least 1")
+}
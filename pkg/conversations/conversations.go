@@ -0,0 +1,35 @@
+package conversations
+
+import (
+	"context"
+	"time"
+)
+
+// Entry is a question of a user with the answer it received
+type Entry struct {
+	ID        string    `json:"id"`
+	User      string    `json:"user"`
+	Question  string    `json:"question"`
+	Answer    string    `json:"answer"`
+	Status    string    `json:"status"`
+	Model     string    `json:"model,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store keeps the conversation history of the users
+type Store interface {
+	// Save records the entry, an entry with the same ID is replaced
+	Save(ctx context.Context, entry Entry) error
+
+	// History returns the entries of the user, oldest first
+	History(ctx context.Context, user string) ([]Entry, error)
+}
+
+// Open returns the Postgres store when the URL of the database is set and an in memory store otherwise.
+// The in memory store is not shared between the API and the workers, it is only meant for local runs.
+func Open(ctx context.Context, url string) (Store, error) {
+	if url == "" {
+		return NewMemoryStore(), nil
+	}
+	return OpenPostgres(ctx, url)
+}
@@ -0,0 +1,47 @@
+package conversations
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryStore keeps the conversation history in memory
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// NewMemoryStore creates an empty in memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]Entry{}}
+}
+
+// Save records the entry, an entry with the same ID is replaced
+func (s *MemoryStore) Save(_ context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[entry.ID] = entry
+	return nil
+}
+
+// History returns the entries of the user, oldest first
+func (s *MemoryStore) History(_ context.Context, user string) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := []Entry{}
+	for _, entry := range s.entries {
+		if entry.User == user {
+			history = append(history, entry)
+		}
+	}
+	sort.Slice(history, func(i, j int) bool {
+		if history[i].CreatedAt.Equal(history[j].CreatedAt) {
+			return history[i].ID < history[j].ID
+		}
+		return history[i].CreatedAt.Before(history[j].CreatedAt)
+	})
+	return history, nil
+}
@@ -0,0 +1,32 @@
+package conversations
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_MemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, store.Save(ctx, Entry{ID: "2", User: "alice", Question: "Second", Status: "queued", CreatedAt: now.Add(time.Minute)}))
+	require.NoError(t, store.Save(ctx, Entry{ID: "1", User: "alice", Question: "First", Status: "answered", CreatedAt: now}))
+	require.NoError(t, store.Save(ctx, Entry{ID: "3", User: "bob", Question: "Other user", CreatedAt: now}))
+
+	// Saving the same ID replaces the entry
+	require.NoError(t, store.Save(ctx, Entry{ID: "2", User: "alice", Question: "Second", Answer: "Done", Status: "answered", CreatedAt: now.Add(time.Minute)}))
+
+	history, err := store.History(ctx, "alice")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "First", history[0].Question)
+	assert.Equal(t, "Done", history[1].Answer)
+
+	history, err = store.History(ctx, "nobody")
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
@@ -0,0 +1,75 @@
+package conversations
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// schema creates the conversation history table when it does not exist yet
+const schema = `
+CREATE TABLE IF NOT EXISTS conversations (
+	id         TEXT PRIMARY KEY,
+	user_id    TEXT NOT NULL,
+	question   TEXT NOT NULL,
+	answer     TEXT NOT NULL,
+	status     TEXT NOT NULL,
+	model      TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS conversations_user_id_created_at ON conversations (user_id, created_at);
+`
+
+// PostgresStore keeps the conversation history in Postgres so it is shared by the API and the workers
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// OpenPostgres connects to the database and creates the conversation history table
+func OpenPostgres(ctx context.Context, url string) (*PostgresStore, error) {
+	db, err := sql.Open("pgx", url)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// Save records the entry, an entry with the same ID is replaced
+func (s *PostgresStore) Save(ctx context.Context, entry Entry) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO conversations (id, user_id, question, answer, status, model, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (id) DO UPDATE SET answer = EXCLUDED.answer, status = EXCLUDED.status, model = EXCLUDED.model`,
+		entry.ID, entry.User, entry.Question, entry.Answer, entry.Status, entry.Model, entry.CreatedAt)
+	return err
+}
+
+// History returns the entries of the user, oldest first
+func (s *PostgresStore) History(ctx context.Context, user string) ([]Entry, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, question, answer, status, model, created_at
+		FROM conversations WHERE user_id = $1 ORDER BY created_at, id`, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []Entry{}
+	for rows.Next() {
+		var entry Entry
+		if err := rows.Scan(&entry.ID, &entry.User, &entry.Question, &entry.Answer, &entry.Status, &entry.Model, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
+
+// Close closes the database connections
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,78 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when no letter has the ID
+var ErrNotFound = errors.New("dead letter not found")
+
+// Letter is a question whose workflow failed for good, it is kept to be inspected and replayed
+type Letter struct {
+	// ID is the ID of the run of the workflow that failed
+	ID         string `json:"id"`
+	WorkflowID string `json:"workflow_id"`
+	User       string `json:"user"`
+	Question   string `json:"question"`
+
+	// Input is the input of the workflow as JSON, the replays start a new workflow with it
+	Input json.RawMessage `json:"input"`
+
+	// ErrorType is the type of the failure, such as RequestRejected or Timeout, and Error its message
+	ErrorType string `json:"error_type"`
+	Error     string `json:"error"`
+
+	// Attempts is the attempt of the workflow that failed, the calls to the providers were retried before by their policy
+	Attempts  int       `json:"attempts"`
+	StartedAt time.Time `json:"started_at"`
+	FailedAt  time.Time `json:"failed_at"`
+
+	// ReplayedAt and ReplayWorkflowID are set once the question is replayed, by the last replay
+	ReplayedAt       *time.Time `json:"replayed_at,omitempty"`
+	ReplayWorkflowID string     `json:"replay_workflow_id,omitempty"`
+}
+
+// Filter selects the letters, the zero fields match every letter
+type Filter struct {
+	User      string
+	ErrorType string
+
+	// UserPrefix restricts the letters to the users of an organization, such as "acme:"
+	UserPrefix string
+
+	// From, inclusive, and To, exclusive, bound the time of the failure
+	From, To time.Time
+
+	// Pending only matches the letters not replayed yet
+	Pending bool
+
+	// Limit is the maximum number of letters returned, every letter when zero
+	Limit int
+}
+
+// Store keeps the dead letters
+type Store interface {
+	// Record adds the letter, a letter with the same ID is replaced so the activity can be retried
+	Record(ctx context.Context, letter Letter) error
+
+	// Get returns the letter of the ID or ErrNotFound
+	Get(ctx context.Context, id string) (*Letter, error)
+
+	// List returns the letters matching the filter, most recent failure first
+	List(ctx context.Context, filter Filter) ([]Letter, error)
+
+	// MarkReplayed records the workflow replaying the letter, or returns ErrNotFound
+	MarkReplayed(ctx context.Context, id, workflowID string, at time.Time) error
+}
+
+// Open returns the Postgres store when the URL of the database is set and an in memory store otherwise.
+// The in memory store is not shared between the API and the workers, it is only meant for local runs.
+func Open(ctx context.Context, url string) (Store, error) {
+	if url == "" {
+		return NewMemoryStore(), nil
+	}
+	return OpenPostgres(ctx, url)
+}
@@ -0,0 +1,92 @@
+package deadletter
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the dead letters in memory
+type MemoryStore struct {
+	mu      sync.Mutex
+	letters map[string]Letter
+}
+
+// NewMemoryStore creates an empty in memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{letters: map[string]Letter{}}
+}
+
+// Record adds the letter, a letter with the same ID is replaced
+func (s *MemoryStore) Record(_ context.Context, letter Letter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.letters[letter.ID] = letter
+	return nil
+}
+
+// Get returns the letter of the ID
+func (s *MemoryStore) Get(_ context.Context, id string) (*Letter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letter, ok := s.letters[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &letter, nil
+}
+
+// List returns the letters matching the filter, most recent failure first
+func (s *MemoryStore) List(_ context.Context, filter Filter) ([]Letter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letters := []Letter{}
+	for _, letter := range s.letters {
+		if matches(filter, letter) {
+			letters = append(letters, letter)
+		}
+	}
+	sort.Slice(letters, func(i, j int) bool {
+		if letters[i].FailedAt.Equal(letters[j].FailedAt) {
+			return letters[i].ID < letters[j].ID
+		}
+		return letters[i].FailedAt.After(letters[j].FailedAt)
+	})
+	if filter.Limit > 0 && len(letters) > filter.Limit {
+		letters = letters[:filter.Limit]
+	}
+	return letters, nil
+}
+
+// matches returns whether the letter matches the filter
+func matches(filter Filter, letter Letter) bool {
+	switch {
+	case filter.User != "" && letter.User != filter.User,
+		filter.ErrorType != "" && letter.ErrorType != filter.ErrorType,
+		!strings.HasPrefix(letter.User, filter.UserPrefix),
+		!filter.From.IsZero() && letter.FailedAt.Before(filter.From),
+		!filter.To.IsZero() && !letter.FailedAt.Before(filter.To),
+		filter.Pending && letter.ReplayedAt != nil:
+		return false
+	}
+	return true
+}
+
+// MarkReplayed records the workflow replaying the letter
+func (s *MemoryStore) MarkReplayed(_ context.Context, id, workflowID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letter, ok := s.letters[id]
+	if !ok {
+		return ErrNotFound
+	}
+	letter.ReplayedAt, letter.ReplayWorkflowID = &at, workflowID
+	s.letters[id] = letter
+	return nil
+}
@@ -0,0 +1,54 @@
+package deadletter
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_MemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	letters := []Letter{
+		{ID: "run_1", User: "acme:alice", ErrorType: "AllProvidersFailed", FailedAt: day.Add(time.Hour)},
+		{ID: "run_2", User: "acme:bob", ErrorType: "Timeout", FailedAt: day.Add(2 * time.Hour)},
+		{ID: "run_3", User: "other:carol", ErrorType: "AllProvidersFailed", FailedAt: day.Add(3 * time.Hour)},
+		{ID: "run_4", User: "acme:alice", ErrorType: "AllProvidersFailed", FailedAt: day.Add(25 * time.Hour)},
+	}
+	for _, letter := range letters {
+		require.NoError(t, store.Record(ctx, letter))
+	}
+
+	// The most recent failures come first
+	listed, err := store.List(ctx, Filter{UserPrefix: "acme:"})
+	require.NoError(t, err)
+	assert.Equal(t, []Letter{letters[3], letters[1], letters[0]}, listed)
+
+	listed, err = store.List(ctx, Filter{ErrorType: "AllProvidersFailed", From: day, To: day.Add(24 * time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, []Letter{letters[2], letters[0]}, listed)
+
+	listed, err = store.List(ctx, Filter{User: "acme:alice", Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []Letter{letters[3]}, listed)
+
+	// The replayed letters are no longer pending
+	replayedAt := day.Add(48 * time.Hour)
+	require.NoError(t, store.MarkReplayed(ctx, "run_4", "chat_5", replayedAt))
+	letter, err := store.Get(ctx, "run_4")
+	require.NoError(t, err)
+	assert.Equal(t, "chat_5", letter.ReplayWorkflowID)
+	assert.Equal(t, &replayedAt, letter.ReplayedAt)
+
+	listed, err = store.List(ctx, Filter{User: "acme:alice", Pending: true})
+	require.NoError(t, err)
+	assert.Equal(t, []Letter{letters[0]}, listed)
+
+	_, err = store.Get(ctx, "run_5")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.MarkReplayed(ctx, "run_5", "chat_6", replayedAt), ErrNotFound)
+}
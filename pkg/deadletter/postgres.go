@@ -0,0 +1,163 @@
+package deadletter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"strings"
+	"time"
+)
+
+// schema creates the dead-letter table when it does not exist yet
+const schema = `
+CREATE TABLE IF NOT EXISTS dead_letters (
+	id                 TEXT PRIMARY KEY,
+	workflow_id        TEXT NOT NULL,
+	user_id            TEXT NOT NULL,
+	question           TEXT NOT NULL,
+	input              JSONB NOT NULL,
+	error_type         TEXT NOT NULL,
+	error              TEXT NOT NULL,
+	attempts           INTEGER NOT NULL,
+	started_at         TIMESTAMPTZ NOT NULL,
+	failed_at          TIMESTAMPTZ NOT NULL,
+	replayed_at        TIMESTAMPTZ,
+	replay_workflow_id TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS dead_letters_failed_at ON dead_letters (failed_at);
+CREATE INDEX IF NOT EXISTS dead_letters_user_id_failed_at ON dead_letters (user_id, failed_at);
+`
+
+// columns are the columns of the letters in the order of scan
+const columns = `id, workflow_id, user_id, question, input, error_type, error, attempts, started_at, failed_at,
+	replayed_at, replay_workflow_id`
+
+// PostgresStore keeps the dead letters in Postgres so they are shared by the API and the workers
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// OpenPostgres connects to the database and creates the dead-letter table
+func OpenPostgres(ctx context.Context, url string) (*PostgresStore, error) {
+	db, err := sql.Open("pgx", url)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// Record adds the letter, a letter with the same ID is replaced
+func (s *PostgresStore) Record(ctx context.Context, letter Letter) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO dead_letters (id, workflow_id, user_id, question, input, error_type, error, attempts, started_at, failed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (id) DO UPDATE SET error_type = EXCLUDED.error_type, error = EXCLUDED.error, failed_at = EXCLUDED.failed_at`,
+		letter.ID, letter.WorkflowID, letter.User, letter.Question, string(letter.Input), letter.ErrorType, letter.Error,
+		letter.Attempts, letter.StartedAt, letter.FailedAt)
+	return err
+}
+
+// Get returns the letter of the ID
+func (s *PostgresStore) Get(ctx context.Context, id string) (*Letter, error) {
+	letter, err := scan(s.db.QueryRowContext(ctx, `SELECT `+columns+` FROM dead_letters WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return letter, err
+}
+
+// List returns the letters matching the filter, most recent failure first
+func (s *PostgresStore) List(ctx context.Context, filter Filter) ([]Letter, error) {
+	conditions := []string{"TRUE"}
+	var args []any
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.User != "" {
+		where("user_id = $%d", filter.User)
+	}
+	if filter.ErrorType != "" {
+		where("error_type = $%d", filter.ErrorType)
+	}
+	if filter.UserPrefix != "" {
+		where("starts_with(user_id, $%d)", filter.UserPrefix)
+	}
+	if !filter.From.IsZero() {
+		where("failed_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		where("failed_at < $%d", filter.To)
+	}
+	if filter.Pending {
+		conditions = append(conditions, "replayed_at IS NULL")
+	}
+	query := `SELECT ` + columns + ` FROM dead_letters WHERE ` + strings.Join(conditions, " AND ") + ` ORDER BY failed_at DESC, id`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	letters := []Letter{}
+	for rows.Next() {
+		letter, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, *letter)
+	}
+	return letters, rows.Err()
+}
+
+// MarkReplayed records the workflow replaying the letter
+func (s *PostgresStore) MarkReplayed(ctx context.Context, id, workflowID string, at time.Time) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE dead_letters SET replayed_at = $2, replay_workflow_id = $3 WHERE id = $1`, id, at, workflowID)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return err
+	} else if updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// scanner is a row of the letters
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scan reads the letter of the row
+func scan(row scanner) (*Letter, error) {
+	var letter Letter
+	var input []byte
+	var replayedAt sql.NullTime
+	err := row.Scan(&letter.ID, &letter.WorkflowID, &letter.User, &letter.Question, &input, &letter.ErrorType, &letter.Error,
+		&letter.Attempts, &letter.StartedAt, &letter.FailedAt, &replayedAt, &letter.ReplayWorkflowID)
+	if err != nil {
+		return nil, err
+	}
+	letter.Input = input
+	if replayedAt.Valid {
+		letter.ReplayedAt = &replayedAt.Time
+	}
+	return &letter, nil
+}
+
+// Close closes the database connections
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,87 @@
+package health
+
+import (
+	"code-challenge/pkg/openai"
+	"context"
+	"fmt"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"sync"
+	"time"
+)
+
+// providerCacheTTL is the time the reachability of the LLM providers is cached, the probes must not hit their API each time
+const providerCacheTTL = 30 * time.Second
+
+// Queue is a task queue whose pollers are checked, with the kinds of tasks its workers poll
+type Queue struct {
+	Name  string
+	Kinds []enums.TaskQueueType
+}
+
+// WorkflowQueue returns the queue of workers polling the workflow and the activity tasks
+func WorkflowQueue(name string) Queue {
+	return Queue{Name: name, Kinds: []enums.TaskQueueType{enums.TASK_QUEUE_TYPE_WORKFLOW, enums.TASK_QUEUE_TYPE_ACTIVITY}}
+}
+
+// ActivityQueue returns the queue of workers only polling activity tasks
+func ActivityQueue(name string) Queue {
+	return Queue{Name: name, Kinds: []enums.TaskQueueType{enums.TASK_QUEUE_TYPE_ACTIVITY}}
+}
+
+// Dependencies returns the checks of the services running the chat bot workflows: the Temporal namespace is critical,
+// the pollers of the task queues and the LLM providers only degrade the service since the questions are queued meanwhile
+func Dependencies(temporal client.Client, namespace string, queues ...Queue) []Check {
+	checks := []Check{{Name: "temporal", Critical: true, Run: Temporal(temporal, namespace)}}
+	for _, queue := range queues {
+		checks = append(checks, Check{Name: "task_queue:" + queue.Name, Run: TaskQueue(temporal, queue.Name, queue.Kinds...)})
+	}
+	for _, provider := range openai.Providers() {
+		checks = append(checks, Check{Name: "llm:" + provider.Name, Run: Cached(providerCacheTTL, provider.Ping)})
+	}
+	return checks
+}
+
+// Temporal checks the Temporal server is reachable by describing the namespace
+func Temporal(temporal client.Client, namespace string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := temporal.WorkflowService().DescribeNamespace(ctx, &workflowservice.DescribeNamespaceRequest{Namespace: namespace})
+		return err
+	}
+}
+
+// TaskQueue checks workers are polling the kinds of tasks of the task queue
+func TaskQueue(temporal client.Client, taskQueue string, kinds ...enums.TaskQueueType) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		for _, kind := range kinds {
+			response, err := temporal.DescribeTaskQueue(ctx, taskQueue, kind)
+			if err != nil {
+				return err
+			}
+			if len(response.GetPollers()) == 0 {
+				return fmt.Errorf("no worker polls the %s tasks of %s", kind, taskQueue)
+			}
+		}
+		return nil
+	}
+}
+
+// Cached runs the check at most once per ttl and returns its last error meanwhile,
+// the concurrent probes wait for the run in progress
+func Cached(ttl time.Duration, run func(ctx context.Context) error) func(ctx context.Context) error {
+	var (
+		mu  sync.Mutex
+		at  time.Time
+		err error
+	)
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if time.Since(at) >= ttl {
+			err = run(ctx)
+			at = time.Now()
+		}
+		return err
+	}
+}
@@ -0,0 +1,137 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status of a check or of the whole report
+type Status string
+
+const (
+	StatusOK Status = "ok"
+
+	// StatusDegraded is reported when only non critical checks failed, the service keeps receiving traffic
+	StatusDegraded Status = "degraded"
+	StatusFail     Status = "fail"
+)
+
+// DefaultTimeout is the time each check has to complete, below the timeout of the Kubernetes probes
+const DefaultTimeout = 3 * time.Second
+
+// Check is a dependency of the service
+type Check struct {
+	Name string
+
+	// Critical checks make the service not ready when they fail, the others only degrade it
+	Critical bool
+	Run      func(ctx context.Context) error
+}
+
+// Result is the outcome of a check
+type Result struct {
+	Status   Status `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+	Duration int64  `json:"duration_ms"`
+}
+
+// Report is the readiness of the service with the result of each check
+type Report struct {
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker runs the checks of the service
+type Checker struct {
+	checks []Check
+
+	// Timeout is the time each check has to complete
+	Timeout time.Duration
+}
+
+// NewChecker creates a checker running the checks, it reports ok when there is none
+func NewChecker(checks ...Check) *Checker {
+	return &Checker{checks: checks, Timeout: DefaultTimeout}
+}
+
+// Run runs the checks concurrently, the report fails when a critical check fails
+func (c *Checker) Run(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			results[i] = Result{Status: StatusOK, Critical: check.Critical}
+			if err := check.Run(ctx); err != nil {
+				results[i].Status, results[i].Error = StatusFail, err.Error()
+			}
+			results[i].Duration = time.Since(start).Milliseconds()
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.checks))}
+	for i, check := range c.checks {
+		result := results[i]
+		report.Checks[check.Name] = result
+		switch {
+		case result.Status == StatusOK:
+		case result.Critical:
+			report.Status = StatusFail
+		case report.Status == StatusOK:
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+// Names returns the names of the checks in alphabetical order
+func (c *Checker) Names() []string {
+	names := make([]string, 0, len(c.checks))
+	for _, check := range c.checks {
+		names = append(names, check.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Live answers the liveness probes, the process is alive as long as it serves HTTP so the dependencies are not checked
+func Live(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, Report{Status: StatusOK, Checks: map[string]Result{}})
+}
+
+// Ready answers the readiness probes with the report of the checks, with 503 when a critical check fails
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	report := c.Run(r.Context())
+	status := http.StatusOK
+	if report.Status == StatusFail {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// Handler serves /healthz and /readyz, it is used by the binaries without API
+func (c *Checker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", Live)
+	mux.HandleFunc("GET /readyz", c.Ready)
+	return mux
+}
+
+// writeJSON writes the report, the probes must not be cached
+func writeJSON(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,73 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/taskqueue/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/mocks"
+	"testing"
+	"time"
+)
+
+func Test_Checker(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	failing := func(context.Context) error { return errors.New("unavailable") }
+
+	report := NewChecker().Run(context.Background())
+	assert.Equal(t, StatusOK, report.Status)
+	assert.Empty(t, report.Checks)
+
+	report = NewChecker(Check{Name: "temporal", Critical: true, Run: ok}, Check{Name: "llm", Run: failing}).Run(context.Background())
+	assert.Equal(t, StatusDegraded, report.Status)
+	assert.Equal(t, Result{Status: StatusFail, Error: "unavailable"}, report.Checks["llm"])
+	assert.Equal(t, StatusOK, report.Checks["temporal"].Status)
+	assert.True(t, report.Checks["temporal"].Critical)
+
+	report = NewChecker(Check{Name: "temporal", Critical: true, Run: failing}, Check{Name: "llm", Run: failing}).Run(context.Background())
+	assert.Equal(t, StatusFail, report.Status)
+}
+
+func Test_Checker_Timeout(t *testing.T) {
+	checker := NewChecker(Check{Name: "slow", Critical: true, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	checker.Timeout = 10 * time.Millisecond
+
+	report := checker.Run(context.Background())
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"].Error)
+}
+
+func Test_Cached(t *testing.T) {
+	runs := 0
+	check := Cached(time.Hour, func(context.Context) error {
+		runs++
+		return errors.New("unavailable")
+	})
+
+	require.Error(t, check(context.Background()))
+	require.Error(t, check(context.Background()))
+	assert.Equal(t, 1, runs)
+}
+
+func Test_TaskQueue(t *testing.T) {
+	temporal := mocks.NewClient(t)
+	temporal.On("DescribeTaskQueue", mock.Anything, "chat", enums.TASK_QUEUE_TYPE_WORKFLOW).
+		Return(&workflowservice.DescribeTaskQueueResponse{Pollers: []*taskqueue.PollerInfo{{Identity: "worker-1"}}}, nil)
+	temporal.On("DescribeTaskQueue", mock.Anything, "chat", enums.TASK_QUEUE_TYPE_ACTIVITY).
+		Return(&workflowservice.DescribeTaskQueueResponse{}, nil)
+
+	err := TaskQueue(temporal, "chat", WorkflowQueue("chat").Kinds...)(context.Background())
+	assert.EqualError(t, err, "no worker polls the Activity tasks of chat")
+
+	// The LLM pool only polls activity tasks
+	temporal.On("DescribeTaskQueue", mock.Anything, "llm", enums.TASK_QUEUE_TYPE_ACTIVITY).
+		Return(&workflowservice.DescribeTaskQueueResponse{Pollers: []*taskqueue.PollerInfo{{Identity: "worker-2"}}}, nil)
+	assert.NoError(t, TaskQueue(temporal, "llm", ActivityQueue("llm").Kinds...)(context.Background()))
+}
@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"regexp"
+)
+
+// HeaderRequestID is the HTTP header carrying the request ID, it is accepted from the callers and returned to them
+const HeaderRequestID = "X-Request-ID"
+
+// Correlation identifies the request a log line belongs to, it is propagated from the API to the workflows and activities
+type Correlation struct {
+	RequestID string `json:"request_id,omitempty"`
+	User      string `json:"user,omitempty"`
+}
+
+type correlationKey struct{}
+
+// validRequestID matches the request IDs accepted from the callers, the others are replaced so they cannot forge log lines
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID returns the request ID sent by the caller when it is valid, or a new one
+func RequestID(header string) string {
+	if validRequestID.MatchString(header) {
+		return header
+	}
+	return uuid.NewString()
+}
+
+// WithCorrelation returns the context carrying the correlation
+func WithCorrelation(ctx context.Context, correlation Correlation) context.Context {
+	return context.WithValue(ctx, correlationKey{}, correlation)
+}
+
+// WithRequestID returns the context carrying the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	correlation := CorrelationFrom(ctx)
+	correlation.RequestID = requestID
+	return WithCorrelation(ctx, correlation)
+}
+
+// WithUser returns the context carrying the user asking the question
+func WithUser(ctx context.Context, user string) context.Context {
+	correlation := CorrelationFrom(ctx)
+	correlation.User = user
+	return WithCorrelation(ctx, correlation)
+}
+
+// CorrelationFrom returns the correlation of the context, it is empty when the context carries none
+func CorrelationFrom(ctx context.Context) Correlation {
+	correlation, _ := ctx.Value(correlationKey{}).(Correlation)
+	return correlation
+}
+
+// traceID returns the ID of the trace of the context, empty when it is not traced
+func traceID(ctx context.Context) string {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		return spanContext.TraceID().String()
+	}
+	return ""
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Keys of the correlation attributes, they follow the keys of the Temporal loggers such as WorkflowID and RunID
+const (
+	KeyRequestID = "RequestID"
+	KeyUser      = "User"
+	KeyTraceID   = "TraceID"
+)
+
+// Redacted replaces the values of the sensitive attributes
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are the attribute keys whose value is redacted, compared in lower case without separators
+var sensitiveKeys = map[string]bool{
+	"password": true, "newpassword": true, "secret": true, "clientsecret": true,
+	"token": true, "accesstoken": true, "refreshtoken": true, "sessiontoken": true,
+	"authorization": true, "cookie": true, "setcookie": true, "apikey": true, "xapikey": true,
+	"email": true, "question": true, "answer": true,
+}
+
+// Setup makes the JSON logger writing to stdout the default logger, the log package then writes through it as well.
+// The level is taken from LOG_LEVEL: debug, info, the default, warn or error.
+func Setup() *slog.Logger {
+	logger := New(os.Stdout, level(os.Getenv("LOG_LEVEL")))
+	slog.SetDefault(logger)
+	return logger
+}
+
+// New creates a JSON logger redacting the sensitive attributes and adding the correlation attributes of the context
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level, ReplaceAttr: redact})
+	return slog.New(contextHandler{handler})
+}
+
+// level parses the name of a level, info when it is not valid
+func level(name string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+// redact replaces the value of the sensitive attributes
+func redact(_ []string, attr slog.Attr) slog.Attr {
+	if IsSensitive(attr.Key) && attr.Value.Kind() != slog.KindGroup {
+		return slog.String(attr.Key, Redacted)
+	}
+	return attr
+}
+
+// IsSensitive returns whether the value of the attribute key must not be logged
+func IsSensitive(key string) bool {
+	return sensitiveKeys[strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))]
+}
+
+// contextHandler adds the correlation attributes of the context to the records
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if ctx != nil {
+		correlation := CorrelationFrom(ctx)
+		if correlation.RequestID != "" {
+			record.AddAttrs(slog.String(KeyRequestID, correlation.RequestID))
+		}
+		if correlation.User != "" {
+			record.AddAttrs(slog.String(KeyUser, correlation.User))
+		}
+		if traceID := traceID(ctx); traceID != "" {
+			record.AddAttrs(slog.String(KeyTraceID, traceID))
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// Fatal logs the error and exits, it replaces log.Fatalln in the main functions
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
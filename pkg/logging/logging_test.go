@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// logLines decodes the JSON lines written by the logger
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var decoded map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &decoded), line)
+		lines = append(lines, decoded)
+	}
+	return lines
+}
+
+func Test_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	ctx := WithUser(WithRequestID(context.Background(), "req-1"), "alice")
+	logger.InfoContext(ctx, "Signed in", "Password", "hunter2", "api_key", "ck_secret", "Question", "my passport number", "Tokens", 12)
+	logger.Debug("Hidden")
+
+	lines := logLines(t, &buf)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "Signed in", lines[0]["msg"])
+	assert.Equal(t, "req-1", lines[0][KeyRequestID])
+	assert.Equal(t, "alice", lines[0][KeyUser])
+	assert.Equal(t, Redacted, lines[0]["Password"])
+	assert.Equal(t, Redacted, lines[0]["api_key"])
+	assert.Equal(t, Redacted, lines[0]["Question"])
+	assert.Equal(t, 12.0, lines[0]["Tokens"])
+}
+
+func Test_RequestID(t *testing.T) {
+	assert.Equal(t, "0b7c9a1e-req", RequestID("0b7c9a1e-req"))
+
+	// The request IDs that could forge log lines are replaced
+	for _, header := range []string{"", "line\nbreak", `"quoted"`, strings.Repeat("a", 129)} {
+		generated := RequestID(header)
+		assert.NotEqual(t, header, generated)
+		assert.Len(t, generated, 36)
+	}
+}
+
+func Test_Temporal(t *testing.T) {
+	var buf bytes.Buffer
+	var suite testsuite.WorkflowTestSuite
+	suite.SetLogger(TemporalLogger(New(&buf, slog.LevelInfo)))
+
+	logged := func(ctx context.Context) error {
+		activity.GetLogger(ctx).Info("Activity log")
+		return nil
+	}
+	loggedWorkflow := func(ctx workflow.Context) error {
+		workflow.GetLogger(ctx).Info("Workflow log")
+		ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{StartToCloseTimeout: time.Minute})
+		return workflow.ExecuteActivity(ctx, logged).Get(ctx, nil)
+	}
+
+	// The API writes the correlation of the request to the header of the workflow
+	header := &commonpb.Header{Fields: map[string]*commonpb.Payload{}}
+	ctx := WithCorrelation(context.Background(), Correlation{RequestID: "req-1", User: "alice"})
+	require.NoError(t, NewPropagator().Inject(ctx, headerWriter(header.Fields)))
+
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetContextPropagators([]workflow.ContextPropagator{NewPropagator()})
+	env.SetWorkerOptions(worker.Options{Interceptors: []interceptor.WorkerInterceptor{NewInterceptor()}})
+	env.SetHeader(header)
+	env.RegisterWorkflowWithOptions(loggedWorkflow, workflow.RegisterOptions{Name: "LoggedWorkflow"})
+	env.RegisterActivity(logged)
+	env.ExecuteWorkflow(loggedWorkflow)
+	require.NoError(t, env.GetWorkflowError())
+
+	var messages []string
+	for _, line := range logLines(t, &buf) {
+		switch line["msg"] {
+		case "Workflow log", "Activity log":
+			messages = append(messages, line["msg"].(string))
+			assert.Equal(t, "req-1", line[KeyRequestID])
+			assert.Equal(t, "alice", line[KeyUser])
+		}
+		// The test environment only adds the workflow ID and the run ID to the loggers of the activities
+		if line["msg"] == "Activity log" {
+			assert.NotEmpty(t, line["WorkflowID"])
+			assert.NotEmpty(t, line["RunID"])
+		}
+	}
+	assert.Equal(t, []string{"Workflow log", "Activity log"}, messages)
+}
+
+// headerWriter writes the header fields
+type headerWriter map[string]*commonpb.Payload
+
+func (h headerWriter) Set(key string, value *commonpb.Payload) {
+	h[key] = value
+}
@@ -0,0 +1,152 @@
+package logging
+
+import (
+	"context"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/workflow"
+	"log/slog"
+)
+
+// headerKey is the Temporal header carrying the correlation from the API to the workflows and from them to the activities
+const headerKey = "correlation"
+
+// MemoRequestID is the memo of the workflows holding the request ID, it is shown by the Temporal UI
+const MemoRequestID = "RequestID"
+
+// TemporalLogger returns the logger of the Temporal SDK writing through the logger
+func TemporalLogger(logger *slog.Logger) log.Logger {
+	return log.NewStructuredLogger(logger)
+}
+
+// Propagator propagates the correlation of the contexts in the headers of the workflows and activities
+type Propagator struct{}
+
+// NewPropagator creates the propagator set on the Temporal clients of the API and the worker
+func NewPropagator() workflow.ContextPropagator {
+	return Propagator{}
+}
+
+// Inject writes the correlation of the context of the client to the header of the workflow
+func (Propagator) Inject(ctx context.Context, writer workflow.HeaderWriter) error {
+	return inject(CorrelationFrom(ctx), writer)
+}
+
+// InjectFromWorkflow writes the correlation of the workflow to the header of its activities and child workflows
+func (Propagator) InjectFromWorkflow(ctx workflow.Context, writer workflow.HeaderWriter) error {
+	return inject(workflowCorrelation(ctx), writer)
+}
+
+// Extract reads the correlation of the header into the context of the activity
+func (Propagator) Extract(ctx context.Context, reader workflow.HeaderReader) (context.Context, error) {
+	correlation, err := extract(reader)
+	if err != nil || correlation == (Correlation{}) {
+		return ctx, err
+	}
+	return WithCorrelation(ctx, correlation), nil
+}
+
+// ExtractToWorkflow reads the correlation of the header into the context of the workflow
+func (Propagator) ExtractToWorkflow(ctx workflow.Context, reader workflow.HeaderReader) (workflow.Context, error) {
+	correlation, err := extract(reader)
+	if err != nil || correlation == (Correlation{}) {
+		return ctx, err
+	}
+	return workflow.WithValue(ctx, correlationKey{}, correlation), nil
+}
+
+// inject writes the correlation to the header, nothing is written when it is empty
+func inject(correlation Correlation, writer workflow.HeaderWriter) error {
+	if correlation == (Correlation{}) {
+		return nil
+	}
+	payload, err := converter.GetDefaultDataConverter().ToPayload(correlation)
+	if err != nil {
+		return err
+	}
+	writer.Set(headerKey, payload)
+	return nil
+}
+
+// extract reads the correlation of the header, it is empty when the header has none
+func extract(reader workflow.HeaderReader) (Correlation, error) {
+	var correlation Correlation
+	if payload, ok := reader.Get(headerKey); ok {
+		if err := converter.GetDefaultDataConverter().FromPayload(payload, &correlation); err != nil {
+			return Correlation{}, err
+		}
+	}
+	return correlation, nil
+}
+
+// workflowCorrelation returns the correlation of the workflow context
+func workflowCorrelation(ctx workflow.Context) Correlation {
+	correlation, _ := ctx.Value(correlationKey{}).(Correlation)
+	return correlation
+}
+
+// withCorrelation adds the correlation to the Temporal logger, it already has the workflow ID and run ID
+func withCorrelation(logger log.Logger, correlation Correlation) log.Logger {
+	var keyvals []interface{}
+	if correlation.RequestID != "" {
+		keyvals = append(keyvals, KeyRequestID, correlation.RequestID)
+	}
+	if correlation.User != "" {
+		keyvals = append(keyvals, KeyUser, correlation.User)
+	}
+	if len(keyvals) == 0 {
+		return logger
+	}
+	return log.With(logger, keyvals...)
+}
+
+// Interceptor adds the correlation to the loggers of the workflows and activities
+type Interceptor struct {
+	interceptor.WorkerInterceptorBase
+}
+
+// NewInterceptor creates the interceptor set on the worker
+func NewInterceptor() interceptor.WorkerInterceptor {
+	return &Interceptor{}
+}
+
+func (i *Interceptor) InterceptWorkflow(_ workflow.Context, next interceptor.WorkflowInboundInterceptor) interceptor.WorkflowInboundInterceptor {
+	return &workflowInbound{WorkflowInboundInterceptorBase: interceptor.WorkflowInboundInterceptorBase{Next: next}}
+}
+
+func (i *Interceptor) InterceptActivity(_ context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	return &activityInbound{ActivityInboundInterceptorBase: interceptor.ActivityInboundInterceptorBase{Next: next}}
+}
+
+type workflowInbound struct {
+	interceptor.WorkflowInboundInterceptorBase
+}
+
+func (w *workflowInbound) Init(outbound interceptor.WorkflowOutboundInterceptor) error {
+	return w.Next.Init(&workflowOutbound{WorkflowOutboundInterceptorBase: interceptor.WorkflowOutboundInterceptorBase{Next: outbound}})
+}
+
+type workflowOutbound struct {
+	interceptor.WorkflowOutboundInterceptorBase
+}
+
+func (w *workflowOutbound) GetLogger(ctx workflow.Context) log.Logger {
+	return withCorrelation(w.Next.GetLogger(ctx), workflowCorrelation(ctx))
+}
+
+type activityInbound struct {
+	interceptor.ActivityInboundInterceptorBase
+}
+
+func (a *activityInbound) Init(outbound interceptor.ActivityOutboundInterceptor) error {
+	return a.Next.Init(&activityOutbound{ActivityOutboundInterceptorBase: interceptor.ActivityOutboundInterceptorBase{Next: outbound}})
+}
+
+type activityOutbound struct {
+	interceptor.ActivityOutboundInterceptorBase
+}
+
+func (a *activityOutbound) GetLogger(ctx context.Context) log.Logger {
+	return withCorrelation(a.Next.GetLogger(ctx), CorrelationFrom(ctx))
+}
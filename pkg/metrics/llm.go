@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+// Token types of the LLM token counter, the cached prompt tokens are also counted as prompt tokens
+const (
+	TokensPrompt       = "prompt"
+	TokensCompletion   = "completion"
+	TokensCachedPrompt = "cached_prompt"
+)
+
+// Reasons of the LLM refusals
+const (
+	// RefusalModel is the model declining to answer, RefusalContentFilter the provider filtering the answer out
+	RefusalModel         = "refusal"
+	RefusalContentFilter = "content_filter"
+)
+
+var (
+	llmDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_request_duration_seconds",
+		Help:      "Latency of the chat completion calls by provider, model and outcome.",
+		Buckets:   []float64{0.25, 0.5, 1, 2, 4, 8, 15, 30, 60, 120},
+	}, []string{"provider", "model", "outcome"})
+
+	llmTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens_total",
+		Help:      "Tokens consumed by provider, model and type: prompt, completion or cached_prompt.",
+	}, []string{"provider", "model", "type"})
+
+	llmCost = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_cost_usd_total",
+		Help:      "Estimated cost in US dollars of the tokens consumed by provider and model.",
+	}, []string{"provider", "model"})
+
+	llmCacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_cache_hits_total",
+		Help:      "Chat completion calls whose prompt was partly served from the prompt cache of the provider.",
+	}, []string{"provider", "model"})
+
+	llmRefusals = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_refusals_total",
+		Help:      "Answers refused by the model or filtered out by the provider guardrails, by reason.",
+	}, []string{"provider", "model", "reason"})
+
+	llmErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_errors_total",
+		Help:      "Failed chat completion calls by provider, model and error type.",
+	}, []string{"provider", "model", "type"})
+)
+
+// LLMCall records the latency of a chat completion call, errorType is empty when it succeeded
+func LLMCall(provider, model string, elapsed time.Duration, errorType string) {
+	outcome := "success"
+	if errorType != "" {
+		outcome = "error"
+		LLMError(provider, model, errorType)
+	}
+	llmDuration.WithLabelValues(provider, model, outcome).Observe(elapsed.Seconds())
+}
+
+// LLMError records a chat completion call that failed, or was not made because the circuit of the provider is open
+func LLMError(provider, model, errorType string) {
+	llmErrors.WithLabelValues(provider, model, errorType).Inc()
+}
+
+// LLMUsage records the tokens consumed by a chat completion call and their cost
+func LLMUsage(provider, model string, prompt, completion, cachedPrompt int, cost float64) {
+	llmTokens.WithLabelValues(provider, model, TokensPrompt).Add(float64(prompt))
+	llmTokens.WithLabelValues(provider, model, TokensCompletion).Add(float64(completion))
+	llmTokens.WithLabelValues(provider, model, TokensCachedPrompt).Add(float64(cachedPrompt))
+	llmCost.WithLabelValues(provider, model).Add(cost)
+	if cachedPrompt > 0 {
+		llmCacheHits.WithLabelValues(provider, model).Inc()
+	}
+}
+
+// LLMRefusal records an answer refused for the reason
+func LLMRefusal(provider, model, reason string) {
+	llmRefusals.WithLabelValues(provider, model, reason).Inc()
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Instrument(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/conversations/{user}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	handler := Instrument(mux, mux)
+
+	for _, path := range []string{"/v1/conversations/alice", "/v1/conversations/bob", "/unknown/1", "/unknown/2"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PURGE", "/v1/conversations/alice", nil))
+
+	// The requests are labeled with the route pattern, not with their path
+	assert.Equal(t, 2.0, testutil.ToFloat64(httpRequests.WithLabelValues("GET /v1/conversations/{user}", http.MethodGet, "403")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(httpRequests.WithLabelValues(unmatchedRoute, http.MethodGet, "404")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(httpRequests.WithLabelValues(unmatchedRoute, "OTHER", "405")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(httpInFlight))
+}
+
+func Test_Handler(t *testing.T) {
+	LLMUsage("openai", "gpt-4o", 100, 20, 0, 0.0003)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain"))
+	assert.Contains(t, rec.Body.String(), `chatbot_llm_tokens_total{model="gpt-4o",provider="openai",type="prompt"} 100`)
+	assert.Contains(t, rec.Body.String(), "go_goroutines")
+}
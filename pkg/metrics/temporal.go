@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"github.com/uber-go/tally/v4"
+	tallyprometheus "github.com/uber-go/tally/v4/prometheus"
+	"go.temporal.io/sdk/client"
+	sdktally "go.temporal.io/sdk/contrib/tally"
+	"io"
+	"time"
+)
+
+// temporalReportInterval is how often the metrics of the Temporal SDK are reported to the registry
+const temporalReportInterval = time.Second
+
+// TemporalHandler returns the handler reporting the metrics of the Temporal SDK to the registry,
+// the closer flushes them and must be closed after the client
+func TemporalHandler() (client.MetricsHandler, io.Closer) {
+	reporter := tallyprometheus.NewReporter(tallyprometheus.Options{
+		Registerer:       Registry,
+		Gatherer:         Registry,
+		DefaultTimerType: tallyprometheus.HistogramTimerType,
+	})
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		CachedReporter:  reporter,
+		Separator:       tallyprometheus.DefaultSeparator,
+		SanitizeOptions: &sdktally.PrometheusSanitizeOptions,
+	}, temporalReportInterval)
+	return sdktally.NewMetricsHandler(sdktally.NewPrometheusNamingScope(scope)), closer
+}
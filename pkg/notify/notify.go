@@ -0,0 +1,166 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/netip"
+	"net/smtp"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrRefused is returned when the webhook is not on an allowed host or reaches an internal address, retrying does not help
+var ErrRefused = errors.New("the webhook is refused")
+
+// Channel is where a user wants to receive the answers that could not be given right away.
+// The answers are always recorded in the conversation history, the webhook and the email are optional.
+type Channel struct {
+	Webhook string `json:"webhook,omitempty"`
+	Email   string `json:"email,omitempty"`
+}
+
+// Message is an answer delivered to the user after the question was queued
+type Message struct {
+	ID       string `json:"id"`
+	User     string `json:"user"`
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+	Status   string `json:"status"`
+}
+
+// Notifier delivers messages through webhooks and emails
+type Notifier struct {
+	HTTPClient *http.Client
+
+	// WebhookHosts are the only hosts the webhooks are posted to, including after a redirect, so the workers do not reach
+	// the internal services nor the metadata endpoint of the cloud. The webhooks are refused when it is empty.
+	WebhookHosts []string
+
+	// SMTPAddr is the host:port of the SMTP server, MailHog locally
+	SMTPAddr string
+	From     string
+}
+
+// FromEnv creates a notifier using SMTP_ADDR and SMTP_FROM, by default the local MailHog server.
+// Its webhooks only connect to public addresses, whatever their host resolves to.
+func FromEnv() *Notifier {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: dialPublic}).DialContext
+
+	return &Notifier{
+		HTTPClient: &http.Client{Timeout: 10 * time.Second, Transport: transport},
+		SMTPAddr:   getenv("SMTP_ADDR", "localhost:1025"),
+		From:       getenv("SMTP_FROM", "no-reply@my-beacon.local"),
+	}
+}
+
+// AllowedWebhook returns an ErrRefused error when the webhook is not an http or https URL of one of the hosts
+func AllowedWebhook(webhook string, hosts []string) error {
+	u, err := url.Parse(webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: it must be an http or https URL", ErrRefused)
+	}
+	if !slices.ContainsFunc(hosts, func(host string) bool { return strings.EqualFold(host, u.Hostname()) }) {
+		return fmt.Errorf("%w: the host %s is not allowed", ErrRefused, u.Hostname())
+	}
+	return nil
+}
+
+// dialPublic refuses the connections to the loopback, private, link-local and unspecified addresses.
+// It runs once the host is resolved, so an allowed host cannot point the workers to an internal address.
+func dialPublic(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s is not a public address", ErrRefused, ip)
+	}
+	return nil
+}
+
+// Webhook posts the message as JSON to the URL, the message ID is sent as idempotency key so retries can be detected.
+// The URL and its redirects must be on the WebhookHosts.
+func (n *Notifier) Webhook(ctx context.Context, url string, message Message) error {
+	if err := AllowedWebhook(url, n.WebhookHosts); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", message.ID)
+
+	client := *n.HTTPClient
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return AllowedWebhook(req.URL.String(), n.WebhookHosts)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s returned status %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+// Email sends the answer as a plain text email
+func (n *Notifier) Email(to string, message Message) error {
+	return n.SendEmail(to, "Answer to your question", "You asked: "+message.Question+"\n\n"+message.Answer)
+}
+
+// SendEmail sends a plain text email, only the address of the recipient is kept when it is given with a display name
+func (n *Notifier) SendEmail(to, subject, body string) error {
+	address, err := mail.ParseAddress(to)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString("From: " + n.From + "\r\n")
+	b.WriteString("To: " + address.Address + "\r\n")
+	b.WriteString("Subject: " + subject + "\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n") + "\r\n")
+
+	return smtp.SendMail(n.SMTPAddr, nil, n.From, []string{address.Address}, []byte(b.String()))
+}
+
+// getenv returns the environment variable or the fallback when it is not set
+func getenv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
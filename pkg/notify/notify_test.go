@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_AllowedWebhook(t *testing.T) {
+	hosts := []string{"hooks.example.com"}
+
+	assert.NoError(t, AllowedWebhook("https://HOOKS.example.com/answers", hosts))
+	assert.ErrorIs(t, AllowedWebhook("https://other.example.com/answers", hosts), ErrRefused)
+	assert.ErrorIs(t, AllowedWebhook("http://169.254.169.254/latest/meta-data", hosts), ErrRefused)
+	assert.ErrorIs(t, AllowedWebhook("ftp://hooks.example.com/answers", hosts), ErrRefused)
+	assert.ErrorIs(t, AllowedWebhook("https://hooks.example.com/answers", nil), ErrRefused)
+}
+
+func Test_Webhook(t *testing.T) {
+	ctx := context.Background()
+	received := 0
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://localhost/internal", http.StatusTemporaryRedirect)
+			return
+		}
+		received++
+	}))
+	defer webhook.Close()
+
+	notifier := &Notifier{HTTPClient: webhook.Client(), WebhookHosts: []string{"127.0.0.1"}}
+	require.NoError(t, notifier.Webhook(ctx, webhook.URL, Message{ID: "1"}))
+	assert.Equal(t, 1, received)
+
+	// The redirects to the other hosts are not followed
+	assert.ErrorIs(t, notifier.Webhook(ctx, webhook.URL+"/redirect", Message{ID: "1"}), ErrRefused)
+
+	// The notifier of the workers refuses the internal addresses even on an allowed host
+	notifier = FromEnv()
+	notifier.WebhookHosts = []string{"127.0.0.1"}
+	assert.ErrorIs(t, notifier.Webhook(ctx, webhook.URL, Message{ID: "1"}), ErrRefused)
+	assert.Equal(t, 1, received)
+}
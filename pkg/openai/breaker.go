@@ -0,0 +1,123 @@
+package openai
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the provider while its circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Circuit breaker states
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half_open"
+)
+
+// BreakerStats is a snapshot of the failure statistics of a provider
+type BreakerStats struct {
+	Provider            string    `json:"provider"`
+	State               string    `json:"state"`
+	Successes           int64     `json:"successes"`
+	Failures            int64     `json:"failures"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	OpenedAt            time.Time `json:"opened_at,omitempty"`
+}
+
+// Breaker stops calling a provider after consecutive failures and lets a single probe request through after a cooldown
+type Breaker struct {
+	mu sync.Mutex
+
+	// failureThreshold is the number of consecutive failures opening the circuit
+	failureThreshold int
+
+	// cooldown is the time the circuit stays open before a probe request is allowed
+	cooldown time.Duration
+
+	// now returns the current time, it is replaced in tests
+	now func() time.Time
+
+	stats   BreakerStats
+	probing bool
+}
+
+// NewBreaker creates a closed circuit breaker for the provider
+func NewBreaker(provider string, failureThreshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+		now:              time.Now,
+		stats:            BreakerStats{Provider: provider, State: StateClosed},
+	}
+}
+
+// Allow reports whether a request can be sent to the provider
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.stats.State {
+	case StateOpen:
+		// Once the cooldown elapsed a single request probes the provider
+		if b.now().Sub(b.stats.OpenedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.stats.State = StateHalfOpen
+		b.probing = true
+		return nil
+
+	case StateHalfOpen:
+		// Only the probe request is allowed until it completes
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+
+	default:
+		return nil
+	}
+}
+
+// Success records a successful request and closes the circuit
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stats.Successes++
+	b.stats.ConsecutiveFailures = 0
+	b.stats.State = StateClosed
+	b.stats.OpenedAt = time.Time{}
+	b.probing = false
+}
+
+// Failure records a failed request and opens the circuit when the threshold is reached or the probe failed
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stats.Failures++
+	b.stats.ConsecutiveFailures++
+	if b.stats.State == StateHalfOpen || b.stats.ConsecutiveFailures >= b.failureThreshold {
+		b.stats.State = StateOpen
+		b.stats.OpenedAt = b.now()
+	}
+	b.probing = false
+}
+
+// Release records a request that was abandoned before the provider answered, a probe can be sent again
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// Stats returns a snapshot of the failure statistics
+func (b *Breaker) Stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
@@ -0,0 +1,96 @@
+package openai
+
+import (
+	"code-challenge/pkg/config"
+	"context"
+	"errors"
+	openai2 "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_Breaker(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	b := NewBreaker(ProviderOpenAI, 2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	// The circuit opens after the consecutive failures
+	assert.NoError(t, b.Allow())
+	b.Failure()
+	assert.NoError(t, b.Allow())
+	b.Failure()
+	assert.ErrorIs(t, b.Allow(), ErrCircuitOpen)
+	assert.Equal(t, StateOpen, b.Stats().State)
+
+	// After the cooldown a single probe is allowed, its failure opens the circuit again
+	now = now.Add(time.Minute)
+	assert.NoError(t, b.Allow())
+	assert.ErrorIs(t, b.Allow(), ErrCircuitOpen)
+	b.Failure()
+	assert.ErrorIs(t, b.Allow(), ErrCircuitOpen)
+
+	// A successful probe closes the circuit
+	now = now.Add(time.Minute)
+	assert.NoError(t, b.Allow())
+	b.Success()
+	assert.NoError(t, b.Allow())
+
+	stats := b.Stats()
+	assert.Equal(t, StateClosed, stats.State)
+	assert.Equal(t, int64(1), stats.Successes)
+	assert.Equal(t, int64(3), stats.Failures)
+	assert.Equal(t, 0, stats.ConsecutiveFailures)
+}
+
+func Test_IsProviderFailure(t *testing.T) {
+	assert.True(t, IsProviderFailure(errors.New("connection refused")))
+	assert.True(t, IsProviderFailure(&openai2.APIError{HTTPStatusCode: http.StatusServiceUnavailable}))
+	assert.True(t, IsProviderFailure(&openai2.RequestError{HTTPStatusCode: http.StatusTooManyRequests}))
+	assert.False(t, IsProviderFailure(&openai2.APIError{HTTPStatusCode: http.StatusBadRequest}))
+	assert.False(t, IsProviderFailure(ErrCircuitOpen))
+	assert.False(t, IsProviderFailure(ErrUnknownProvider))
+}
+
+func Test_Providers(t *testing.T) {
+	assert.Equal(t, []string{ProviderOpenAI, ProviderOpenAIMini}, ProviderNames())
+
+	llm := configure(t, config.Local{BaseURL: "http://localhost:11434/v1", Model: "mistral"})
+	providers := Providers()
+	assert.Len(t, providers, 3)
+	assert.Equal(t, Provider{Name: ProviderLocal, Model: "mistral", BaseURL: "http://localhost:11434/v1"}, providers[2])
+
+	// The local model is tried first when it is the configured provider, without fallback model
+	llm.Provider, llm.FallbackModel, llm.Temperature = config.ProviderLocal, "", 0.2
+	Configure(llm)
+	assert.Equal(t, []string{ProviderLocal, ProviderOpenAI}, ProviderNames())
+	assert.Equal(t, float32(0.2), Providers()[1].Temperature)
+
+	_, err := provider("unknown")
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+}
+
+func Test_GetCompletionFromGpt_Canceled(t *testing.T) {
+	// The local provider does not answer before the end of the test
+	release := make(chan struct{})
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer provider.Close()
+	defer close(release)
+	configure(t, config.Local{BaseURL: provider.URL, Model: "llama3.1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// The call is aborted with the context and is not counted as a failure of the provider
+	_, err := GetCompletionFromGpt(ctx, ChatRequest{Provider: ProviderLocal, Messages: []Message{{Role: RoleUser, Content: "Hi"}}})
+	assert.Error(t, err)
+	assert.Equal(t, int64(0), breaker(ProviderLocal).Stats().Failures)
+	assert.NoError(t, breaker(ProviderLocal).Allow())
+}
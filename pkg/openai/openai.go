@@ -1,34 +1,271 @@
 package openai
 
 import (
+	"code-challenge/pkg/metrics"
 	"context"
+	"encoding/json"
 	"fmt"
 	openai2 "github.com/sashabaranov/go-openai"
-	"os"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"time"
 )
 
-// GetAnswerFromGpt calls ChatGPT api using GPT-4 to get an advanced answer to the user's question
-func GetAnswerFromGpt(question string) (*string, error) {
-	// Create a new client instance using the provided API key
-	client := openai2.NewClient(os.Getenv("OPENAI_API_KEY"))
-
-	// Make a request to the OpenAI API to create a chat completion
-	// The request includes the model to use and the user's question
-	resp, err := client.CreateChatCompletion(context.Background(), openai2.ChatCompletionRequest{
-		Model: openai2.GPT4o20240513,
-		Messages: []openai2.ChatCompletionMessage{{
-			Role:    openai2.ChatMessageRoleUser,
-			Content: question,
-		}},
+// tracer starts the spans of the chat completion calls, the HTTP requests they send are traced as their children
+var tracer = otel.Tracer("code-challenge/pkg/openai")
+
+// Roles of the messages exchanged with the model
+const (
+	RoleSystem    = openai2.ChatMessageRoleSystem
+	RoleUser      = openai2.ChatMessageRoleUser
+	RoleAssistant = openai2.ChatMessageRoleAssistant
+	RoleTool      = openai2.ChatMessageRoleTool
+)
+
+// Message is a chat message exchanged with the model
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// ToolCall is a request from the model to call one of the tools it was offered
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Tool is a function the model can decide to call, its parameters are described by a JSON schema
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// ResponseFormat constrains the content of the model answer to a JSON schema
+type ResponseFormat struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+// ChatRequest is the conversation sent to the model with the tools it may call
+type ChatRequest struct {
+	Messages []Message `json:"messages"`
+	Tools    []Tool    `json:"tools,omitempty"`
+
+	// ResponseFormat is set when the answer must be JSON matching a schema instead of free-form text
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+
+	// Provider is the name of the provider the request is sent to, the first configured provider when empty
+	Provider string `json:"provider,omitempty"`
+
+	// Model is the configured model the request is meant for, such as to replay a failed question against the fallback model.
+	// ErrModelNotServed is returned when the provider serves another model.
+	Model string `json:"model,omitempty"`
+}
+
+// Completion is the reply of the model with the provider and the model that produced it
+type Completion struct {
+	Message  Message `json:"message"`
+	Provider string  `json:"provider"`
+	Model    string  `json:"model"`
+}
+
+// GetCompletionFromGpt calls the chat completion API of the requested provider with the conversation so far and the tools the model may call.
+// The returned message either holds the answer or the tool calls requested by the model.
+// ErrCircuitOpen is returned without calling the provider while it is considered unavailable.
+// The call is aborted when the context is canceled so abandoned questions stop consuming tokens.
+func GetCompletionFromGpt(ctx context.Context, request ChatRequest) (*Completion, error) {
+	provider, err := provider(request.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	// The model only selects the provider serving it, the metrics are labeled with the configured models
+	if request.Model != "" && request.Model != provider.Model {
+		return nil, fmt.Errorf("%s: %w: %s", provider.Name, ErrModelNotServed, request.Model)
+	}
+
+	// Skip the provider while its circuit is open
+	b := breaker(provider.Name)
+	if err := b.Allow(); err != nil {
+		metrics.LLMError(provider.Name, provider.Model, ErrorType(err))
+		return nil, fmt.Errorf("%s: %w", provider.Name, err)
+	}
+
+	start := time.Now()
+	completion, err := provider.complete(ctx, request)
+	metrics.LLMCall(provider.Name, provider.Model, time.Since(start), ErrorType(err))
+
+	// Only the errors showing the provider is unavailable open the circuit
+	switch {
+	case err == nil:
+		b.Success()
+	case ctx.Err() != nil:
+		// The call was abandoned, it says nothing about the provider
+		b.Release()
+	case IsProviderFailure(err):
+		b.Failure()
+	default:
+		b.Success()
+	}
+	return completion, err
+}
+
+// complete sends the request to the provider
+func (p Provider) complete(ctx context.Context, request ChatRequest) (completion *Completion, err error) {
+	// The span follows the OpenTelemetry conventions of the generative AI calls
+	ctx, span := tracer.Start(ctx, "chat "+p.Model, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("gen_ai.system", "openai"),
+		attribute.String("gen_ai.operation.name", "chat"),
+		attribute.String("gen_ai.request.model", p.Model),
+		attribute.String("chatbot.llm.provider", p.Name),
+		attribute.Int("chatbot.llm.tools", len(request.Tools)),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, ErrorType(err))
+		}
+		span.End()
+	}()
+
+	// Create a new client instance for the provider
+	client := p.client()
+
+	// Make a request to the provider to create a chat completion
+	// The request includes the model to use, the conversation and the available tools
+	resp, err := client.CreateChatCompletion(ctx, openai2.ChatCompletionRequest{
+		Model:          p.Model,
+		Messages:       toChatMessages(request.Messages),
+		Tools:          toTools(request.Tools),
+		ResponseFormat: toResponseFormat(request.ResponseFormat),
+		Temperature:    p.Temperature,
+		MaxTokens:      p.MaxTokens,
 	})
 
 	// Check if there was an error during the API call
 	if err != nil {
-		// Print the error to the console and return nil along with the error
-		fmt.Println("Error: ", err)
+		// Log the error with the request ID of the context and return nil along with the error
+		slog.ErrorContext(ctx, "Chat completion failed", "Provider", p.Name, "Model", p.Model, "Error", err)
 		return nil, err
 	}
 
-	// Return the content of the first choice in the response message
-	return &resp.Choices[0].Message.Content, nil
+	// Check the model returned at least one choice before reading it
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no choices returned by model %s", resp.Model)
+	}
+
+	observeResponse(p, resp)
+	span.SetAttributes(
+		attribute.String("gen_ai.response.model", resp.Model),
+		attribute.StringSlice("gen_ai.response.finish_reasons", []string{string(resp.Choices[0].FinishReason)}),
+		attribute.Int("gen_ai.usage.input_tokens", resp.Usage.PromptTokens),
+		attribute.Int("gen_ai.usage.output_tokens", resp.Usage.CompletionTokens),
+	)
+
+	// Return the first choice in the response message
+	return &Completion{
+		Message:  fromChatMessage(resp.Choices[0].Message),
+		Provider: p.Name,
+		Model:    p.Model,
+	}, nil
+}
+
+// observeResponse records the tokens consumed by the call, their cost and the refusals of the model
+func observeResponse(p Provider, resp openai2.ChatCompletionResponse) {
+	cachedPrompt := 0
+	if resp.Usage.PromptTokensDetails != nil {
+		cachedPrompt = resp.Usage.PromptTokensDetails.CachedTokens
+	}
+	cost := Cost(p.Model, resp.Usage.PromptTokens, cachedPrompt, resp.Usage.CompletionTokens)
+	metrics.LLMUsage(p.Name, p.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens, cachedPrompt, cost)
+
+	switch choice := resp.Choices[0]; {
+	case choice.FinishReason == openai2.FinishReasonContentFilter:
+		metrics.LLMRefusal(p.Name, p.Model, metrics.RefusalContentFilter)
+	case choice.Message.Refusal != "":
+		metrics.LLMRefusal(p.Name, p.Model, metrics.RefusalModel)
+	}
+}
+
+// toChatMessages converts the messages to the OpenAI client representation
+func toChatMessages(messages []Message) []openai2.ChatCompletionMessage {
+	chatMessages := make([]openai2.ChatCompletionMessage, 0, len(messages))
+	for _, message := range messages {
+		chatMessage := openai2.ChatCompletionMessage{
+			Role:       message.Role,
+			Content:    message.Content,
+			ToolCallID: message.ToolCallID,
+		}
+		for _, call := range message.ToolCalls {
+			chatMessage.ToolCalls = append(chatMessage.ToolCalls, openai2.ToolCall{
+				ID:   call.ID,
+				Type: openai2.ToolTypeFunction,
+				Function: openai2.FunctionCall{
+					Name:      call.Name,
+					Arguments: call.Arguments,
+				},
+			})
+		}
+		chatMessages = append(chatMessages, chatMessage)
+	}
+	return chatMessages
+}
+
+// fromChatMessage converts a message returned by the OpenAI client
+func fromChatMessage(chatMessage openai2.ChatCompletionMessage) Message {
+	message := Message{
+		Role:    chatMessage.Role,
+		Content: chatMessage.Content,
+	}
+	for _, call := range chatMessage.ToolCalls {
+		message.ToolCalls = append(message.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return message
+}
+
+// toTools converts the tools to the OpenAI function definitions
+func toTools(tools []Tool) []openai2.Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+
+	definitions := make([]openai2.Tool, 0, len(tools))
+	for _, tool := range tools {
+		definitions = append(definitions, openai2.Tool{
+			Type: openai2.ToolTypeFunction,
+			Function: &openai2.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	return definitions
+}
+
+// toResponseFormat converts the response format to a strict OpenAI JSON schema response format
+func toResponseFormat(format *ResponseFormat) *openai2.ChatCompletionResponseFormat {
+	if format == nil {
+		return nil
+	}
+
+	return &openai2.ChatCompletionResponseFormat{
+		Type: openai2.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai2.ChatCompletionResponseFormatJSONSchema{
+			Name:   format.Name,
+			Schema: format.Schema,
+			Strict: true,
+		},
+	}
 }
@@ -0,0 +1,154 @@
+package openai
+
+import (
+	"code-challenge/pkg/config"
+	"code-challenge/pkg/metrics"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	openai2 "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// metricValue returns the value of the counter of the registry with the labels, 0 when it was not recorded
+func metricValue(t *testing.T, name string, labels map[string]string) float64 {
+	families, err := metrics.Registry.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if value, ok := labels[label.GetName()]; ok && value != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+// configure adds the local model to the default providers until the end of the test and returns their configuration
+func configure(t *testing.T, local config.Local) config.LLM {
+	llm := config.Default().LLM
+	llm.Local = local
+	Configure(llm)
+	t.Cleanup(func() { Configure(config.Default().LLM) })
+	return llm
+}
+
+func Test_GetCompletionFromGpt_Telemetry(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(openai2.ChatCompletionResponse{
+			Model: "metrics-model",
+			Choices: []openai2.ChatCompletionChoice{{
+				Message:      openai2.ChatCompletionMessage{Role: RoleAssistant},
+				FinishReason: openai2.FinishReasonContentFilter,
+			}},
+			Usage: openai2.Usage{
+				PromptTokens: 1200, CompletionTokens: 30,
+				PromptTokensDetails: &openai2.PromptTokensDetails{CachedTokens: 1024},
+			},
+		})
+	}))
+	defer provider.Close()
+	configure(t, config.Local{BaseURL: provider.URL, Model: "metrics-model"})
+
+	_, err := GetCompletionFromGpt(context.Background(), ChatRequest{Provider: ProviderLocal, Messages: []Message{{Role: RoleUser, Content: "Hi"}}})
+	require.NoError(t, err)
+
+	labels := map[string]string{"provider": ProviderLocal, "model": "metrics-model"}
+	with := func(name, value string) map[string]string {
+		merged := map[string]string{name: value}
+		for k, v := range labels {
+			merged[k] = v
+		}
+		return merged
+	}
+	assert.Equal(t, 1200.0, metricValue(t, "chatbot_llm_tokens_total", with("type", metrics.TokensPrompt)))
+	assert.Equal(t, 30.0, metricValue(t, "chatbot_llm_tokens_total", with("type", metrics.TokensCompletion)))
+	assert.Equal(t, 1024.0, metricValue(t, "chatbot_llm_tokens_total", with("type", metrics.TokensCachedPrompt)))
+	assert.Equal(t, 1.0, metricValue(t, "chatbot_llm_cache_hits_total", labels))
+	assert.Equal(t, 1.0, metricValue(t, "chatbot_llm_refusals_total", with("reason", metrics.RefusalContentFilter)))
+
+	// The call is traced with its model and tokens, the HTTP request is its child
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	request, chat := spans[0], spans[1]
+	assert.Equal(t, "chat metrics-model", chat.Name())
+	assert.Equal(t, chat.SpanContext().SpanID(), request.Parent().SpanID())
+	assert.Contains(t, chat.Attributes(), attribute.String("gen_ai.request.model", "metrics-model"))
+	assert.Contains(t, chat.Attributes(), attribute.Int("gen_ai.usage.input_tokens", 1200))
+	assert.Contains(t, chat.Attributes(), attribute.Int("gen_ai.usage.output_tokens", 30))
+}
+
+func Test_GetCompletionFromGpt_Model(t *testing.T) {
+	calls := 0
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var body openai2.ChatCompletionRequest
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(openai2.ChatCompletionResponse{
+			Model:   body.Model,
+			Choices: []openai2.ChatCompletionChoice{{Message: openai2.ChatCompletionMessage{Role: RoleAssistant, Content: "Ottawa"}}},
+		})
+	}))
+	defer provider.Close()
+	configure(t, config.Local{BaseURL: provider.URL, Model: "local-model"})
+
+	assert.Equal(t, []string{"gpt-4o-2024-05-13", "gpt-4o-mini", "local-model"}, Models())
+	assert.Equal(t, []string{ProviderLocal}, ProvidersOf("local-model"))
+	assert.Empty(t, ProvidersOf("replay-model"))
+
+	// The provider serving the model answers
+	messages := []Message{{Role: RoleUser, Content: "What is the capital of Canada?"}}
+	completion, err := GetCompletionFromGpt(context.Background(), ChatRequest{Provider: ProviderLocal, Model: "local-model", Messages: messages})
+	require.NoError(t, err)
+	assert.Equal(t, "local-model", completion.Model)
+
+	// The others are not called with a model they are not configured with
+	_, err = GetCompletionFromGpt(context.Background(), ChatRequest{Provider: ProviderLocal, Model: "replay-model", Messages: messages})
+	assert.ErrorIs(t, err, ErrModelNotServed)
+	assert.False(t, IsProviderFailure(err))
+	assert.Equal(t, 1, calls)
+}
+
+func Test_ErrorType(t *testing.T) {
+	assert.Empty(t, ErrorType(nil))
+	for err, expected := range map[error]string{
+		ErrCircuitOpen:           "circuit_open",
+		context.Canceled:         "canceled",
+		context.DeadlineExceeded: "timeout",
+		&openai2.APIError{HTTPStatusCode: http.StatusTooManyRequests}:         "rate_limited",
+		&openai2.APIError{HTTPStatusCode: http.StatusUnauthorized}:            "unauthorized",
+		&openai2.APIError{HTTPStatusCode: http.StatusBadGateway}:              "server_error",
+		&openai2.RequestError{HTTPStatusCode: http.StatusUnprocessableEntity}: "invalid_request",
+		errors.New("no choices"): "other",
+	} {
+		assert.Equal(t, expected, ErrorType(fmt.Errorf("openai: %w", err)), "%v", err)
+	}
+}
+
+func Test_Cost(t *testing.T) {
+	// The cached prompt tokens are billed at half price
+	assert.InDelta(t, 0.0025+0.00125+0.01, Cost(openai2.GPT4o, 2000, 1000, 1000), 1e-9)
+	assert.Zero(t, Cost("llama3.1", 2000, 0, 1000))
+}
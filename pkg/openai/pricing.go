@@ -0,0 +1,27 @@
+package openai
+
+import (
+	openai2 "github.com/sashabaranov/go-openai"
+)
+
+// Price is the price in US dollars per million tokens of a model
+type Price struct {
+	Prompt       float64
+	CachedPrompt float64
+	Completion   float64
+}
+
+// prices of the OpenAI models, the other models, such as the local one, are considered free
+var prices = map[string]Price{
+	openai2.GPT4o:             {Prompt: 2.5, CachedPrompt: 1.25, Completion: 10},
+	openai2.GPT4o20240806:     {Prompt: 2.5, CachedPrompt: 1.25, Completion: 10},
+	openai2.GPT4o20240513:     {Prompt: 5, CachedPrompt: 5, Completion: 15},
+	openai2.GPT4oMini:         {Prompt: 0.15, CachedPrompt: 0.075, Completion: 0.6},
+	openai2.GPT4oMini20240718: {Prompt: 0.15, CachedPrompt: 0.075, Completion: 0.6},
+}
+
+// Cost returns the estimated cost of the tokens of a call to the model, the cached prompt tokens are part of the prompt tokens
+func Cost(model string, prompt, cachedPrompt, completion int) float64 {
+	price := prices[model]
+	return (float64(prompt-cachedPrompt)*price.Prompt + float64(cachedPrompt)*price.CachedPrompt + float64(completion)*price.Completion) / 1e6
+}
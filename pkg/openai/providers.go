@@ -0,0 +1,254 @@
+package openai
+
+import (
+	"code-challenge/pkg/config"
+	"code-challenge/pkg/tracing"
+	"context"
+	"errors"
+	"fmt"
+	openai2 "github.com/sashabaranov/go-openai"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Names of the providers, in the order they are tried
+const (
+	ProviderOpenAI     = "openai"
+	ProviderOpenAIMini = "openai-mini"
+	ProviderLocal      = "local"
+)
+
+// breakerFailureThreshold is the number of consecutive failures opening the circuit of a provider
+const breakerFailureThreshold = 5
+
+// breakerCooldown is the time a provider is skipped once its circuit opened
+const breakerCooldown = 30 * time.Second
+
+// ErrUnknownProvider is returned when the request names a provider that is not configured
+var ErrUnknownProvider = errors.New("unknown provider")
+
+// ErrModelNotServed is returned when the request asks a provider for another model than the one it is configured with
+var ErrModelNotServed = errors.New("the provider does not serve the model")
+
+// Provider is an OpenAI compatible chat completion API serving a model
+type Provider struct {
+	Name  string
+	Model string
+
+	// BaseURL is empty for the OpenAI API
+	BaseURL string
+	APIKey  string
+
+	// Temperature and MaxTokens of the completions, 0 keeps the default of the provider
+	Temperature float32
+	MaxTokens   int
+}
+
+// settings configures the providers, it is replaced by Configure when the binaries start
+var settings atomic.Pointer[config.LLM]
+
+func init() {
+	defaults := config.Default().LLM
+	settings.Store(&defaults)
+}
+
+// Configure sets the models of the providers, the API keys are read from OPENAI_API_KEY and LOCAL_LLM_API_KEY
+func Configure(llm config.LLM) {
+	settings.Store(&llm)
+}
+
+// Providers returns the configured providers in fallback order: the OpenAI model, the cheaper fallback model and,
+// when its base URL is set, a local OpenAI compatible model, which is tried first when it is the configured provider
+func Providers() []Provider {
+	llm := settings.Load()
+	providers := []Provider{{Name: ProviderOpenAI, Model: llm.Model, APIKey: os.Getenv("OPENAI_API_KEY")}}
+	if llm.FallbackModel != "" {
+		providers = append(providers, Provider{Name: ProviderOpenAIMini, Model: llm.FallbackModel, APIKey: os.Getenv("OPENAI_API_KEY")})
+	}
+
+	if llm.Local.BaseURL != "" {
+		local := Provider{
+			Name:    ProviderLocal,
+			Model:   llm.Local.Model,
+			BaseURL: llm.Local.BaseURL,
+			APIKey:  os.Getenv("LOCAL_LLM_API_KEY"),
+		}
+		if llm.Provider == config.ProviderLocal {
+			providers = append([]Provider{local}, providers...)
+		} else {
+			providers = append(providers, local)
+		}
+	}
+
+	for i := range providers {
+		providers[i].Temperature, providers[i].MaxTokens = llm.Temperature, llm.MaxTokens
+	}
+	return providers
+}
+
+// ProviderNames returns the names of the configured providers in fallback order
+func ProviderNames() []string {
+	providers := Providers()
+	names := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		names = append(names, provider.Name)
+	}
+	return names
+}
+
+// Models returns the configured models in fallback order, the only ones a question may ask for
+func Models() []string {
+	var models []string
+	for _, provider := range Providers() {
+		if provider.Model != "" && !slices.Contains(models, provider.Model) {
+			models = append(models, provider.Model)
+		}
+	}
+	return models
+}
+
+// ProvidersOf returns the names of the configured providers serving the model in fallback order, none when it is not configured
+func ProvidersOf(model string) []string {
+	var names []string
+	for _, provider := range Providers() {
+		if provider.Model == model {
+			names = append(names, provider.Name)
+		}
+	}
+	return names
+}
+
+// provider returns the configured provider with the given name, the first one when the name is empty
+func provider(name string) (Provider, error) {
+	providers := Providers()
+	if name == "" {
+		return providers[0], nil
+	}
+	for _, provider := range providers {
+		if provider.Name == name {
+			return provider, nil
+		}
+	}
+	return Provider{}, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+}
+
+// client creates an API client for the provider, its requests are traced
+func (p Provider) client() *openai2.Client {
+	clientConfig := openai2.DefaultConfig(p.APIKey)
+	if p.BaseURL != "" {
+		clientConfig.BaseURL = p.BaseURL
+	}
+	clientConfig.HTTPClient = &http.Client{Transport: tracing.Transport(nil)}
+	return openai2.NewClientWithConfig(clientConfig)
+}
+
+// Ping checks the provider is reachable and accepts the API key by listing its models, it does not use the breaker
+func (p Provider) Ping(ctx context.Context) error {
+	_, err := p.client().ListModels(ctx)
+	return err
+}
+
+// breakers holds the circuit breaker of each provider, they are shared by every activity running on the worker
+var breakers = struct {
+	sync.Mutex
+	byProvider map[string]*Breaker
+}{byProvider: map[string]*Breaker{}}
+
+// breaker returns the circuit breaker of the provider, creating it on first use
+func breaker(provider string) *Breaker {
+	breakers.Lock()
+	defer breakers.Unlock()
+
+	b, ok := breakers.byProvider[provider]
+	if !ok {
+		b = NewBreaker(provider, breakerFailureThreshold, breakerCooldown)
+		breakers.byProvider[provider] = b
+	}
+	return b
+}
+
+// ProviderStats returns the failure statistics of the configured providers in fallback order
+func ProviderStats() []BreakerStats {
+	names := ProviderNames()
+	stats := make([]BreakerStats, 0, len(names))
+	for _, name := range names {
+		stats = append(stats, breaker(name).Stats())
+	}
+	return stats
+}
+
+// IsProviderFailure reports whether the error means the provider is unavailable, as opposed to the request being rejected.
+// Only provider failures count towards opening the circuit and are worth retrying.
+func IsProviderFailure(err error) bool {
+	if errors.Is(err, ErrUnknownProvider) || errors.Is(err, ErrModelNotServed) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+
+	status := 0
+	var apiErr *openai2.APIError
+	var requestErr *openai2.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &requestErr):
+		status = requestErr.HTTPStatusCode
+	}
+
+	// Errors without a status are network errors or timeouts
+	if status == 0 {
+		return true
+	}
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests || status == http.StatusRequestTimeout
+}
+
+// ErrorType returns the type of the error of a chat completion call, one of a bounded set used as metric label,
+// it is empty when there is no error
+func ErrorType(err error) string {
+	var apiErr *openai2.APIError
+	var requestErr *openai2.RequestError
+	status := 0
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, ErrUnknownProvider):
+		return "unknown_provider"
+	case errors.Is(err, ErrModelNotServed):
+		return "model_not_served"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &requestErr):
+		status = requestErr.HTTPStatusCode
+	}
+
+	switch {
+	case status == http.StatusTooManyRequests:
+		return "rate_limited"
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return "unauthorized"
+	case status >= http.StatusInternalServerError:
+		return "server_error"
+	case status >= http.StatusBadRequest:
+		return "invalid_request"
+	}
+
+	// Errors without a status are network errors
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	}
+	return "other"
+}
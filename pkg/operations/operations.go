@@ -0,0 +1,204 @@
+package operations
+
+import (
+	"code-challenge/pkg/search"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	batchpb "go.temporal.io/api/batch/v1"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"maps"
+	"time"
+)
+
+// identity identifies the operations in the history of the workflows and of the batch jobs
+const identity = "chatbot-admin-api"
+
+// Execution describes a conversation workflow for the operators
+type Execution struct {
+	Conversation search.Conversation `json:"conversation"`
+	TaskQueue    string              `json:"task_queue"`
+
+	// HistoryLength is the number of events, the reset takes the ID of a WorkflowTaskCompleted event
+	HistoryLength     int64             `json:"history_length"`
+	PendingActivities []PendingActivity `json:"pending_activities"`
+}
+
+// PendingActivity is an activity scheduled or running, such as a call to the model being retried
+type PendingActivity struct {
+	ActivityID   string `json:"activity_id"`
+	ActivityType string `json:"activity_type"`
+
+	// State is Scheduled, Started or CancelRequested
+	State   string `json:"state"`
+	Attempt int32  `json:"attempt"`
+
+	// LastFailure is the message of the failure of the previous attempt
+	LastFailure string `json:"last_failure,omitempty"`
+}
+
+// Describe returns the run of the workflow, its latest run when runID is empty
+func Describe(ctx context.Context, c client.Client, workflowID, runID string) (*Execution, error) {
+	resp, err := c.DescribeWorkflowExecution(ctx, workflowID, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	info := resp.GetWorkflowExecutionInfo()
+	execution := &Execution{
+		Conversation:      search.NewConversation(info),
+		TaskQueue:         info.GetTaskQueue(),
+		HistoryLength:     info.GetHistoryLength(),
+		PendingActivities: []PendingActivity{},
+	}
+	for _, activity := range resp.GetPendingActivities() {
+		execution.PendingActivities = append(execution.PendingActivities, PendingActivity{
+			ActivityID:   activity.GetActivityId(),
+			ActivityType: activity.GetActivityType().GetName(),
+			State:        activity.GetState().String(),
+			Attempt:      activity.GetAttempt(),
+			LastFailure:  activity.GetLastFailure().GetMessage(),
+		})
+	}
+	return execution, nil
+}
+
+// Cancel requests the cancellation of the run, the workflow stops its activities and records its outcome as canceled
+func Cancel(ctx context.Context, c client.Client, namespace, workflowID, runID, reason string) error {
+	_, err := c.WorkflowService().RequestCancelWorkflowExecution(ctx, &workflowservice.RequestCancelWorkflowExecutionRequest{
+		Namespace:         namespace,
+		WorkflowExecution: &commonpb.WorkflowExecution{WorkflowId: workflowID, RunId: runID},
+		Identity:          identity,
+		RequestId:         uuid.NewString(),
+		Reason:            reason,
+	})
+	return err
+}
+
+// Terminate stops the run at once, the workflow code does not run anymore
+func Terminate(ctx context.Context, c client.Client, workflowID, runID, reason string) error {
+	return c.TerminateWorkflow(ctx, workflowID, runID, reason)
+}
+
+// Reset starts a new run of the workflow from the WorkflowTaskCompleted event of the run, the events after it are
+// replayed with the current workers. The zero event resets to the first workflow task, running the workflow again.
+// It returns the ID of the new run.
+func Reset(ctx context.Context, c client.Client, namespace, workflowID, runID string, eventID int64, reason string) (string, error) {
+	if eventID == 0 {
+		var err error
+		if eventID, err = firstWorkflowTask(ctx, c, workflowID, runID); err != nil {
+			return "", err
+		}
+	}
+
+	resp, err := c.ResetWorkflowExecution(ctx, &workflowservice.ResetWorkflowExecutionRequest{
+		Namespace:                 namespace,
+		WorkflowExecution:         &commonpb.WorkflowExecution{WorkflowId: workflowID, RunId: runID},
+		Reason:                    reason,
+		WorkflowTaskFinishEventId: eventID,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.GetRunId(), nil
+}
+
+// firstWorkflowTask returns the ID of the first WorkflowTaskCompleted event of the run
+func firstWorkflowTask(ctx context.Context, c client.Client, workflowID, runID string) (int64, error) {
+	events := c.GetWorkflowHistory(ctx, workflowID, runID, false, enums.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	for events.HasNext() {
+		event, err := events.Next()
+		if err != nil {
+			return 0, err
+		}
+		if event.GetEventType() == enums.EVENT_TYPE_WORKFLOW_TASK_COMPLETED {
+			return event.GetEventId(), nil
+		}
+	}
+	return 0, serviceerror.NewFailedPrecondition("the workflow has not completed a workflow task")
+}
+
+// Batch is a batch job of Temporal acting on the workflows matching a visibility query
+type Batch struct {
+	JobID string `json:"job_id"`
+
+	// State is Running, Completed or Failed
+	State     string     `json:"state"`
+	Reason    string     `json:"reason"`
+	StartTime time.Time  `json:"start_time"`
+	CloseTime *time.Time `json:"close_time,omitempty"`
+
+	// The operations are counted once the job found the workflows
+	Total     int64 `json:"total"`
+	Completed int64 `json:"completed"`
+	Failed    int64 `json:"failed"`
+}
+
+// ErrNothingToRetry is returned when no failed conversation matches the filter of a retry
+var ErrNothingToRetry = errors.New("no failed conversation matches the query")
+
+// RetryFailed starts a batch job resetting the failed conversations matching the filter to their first workflow task,
+// each of them runs again in a new run with the current workers. The job ID starts with the prefix.
+func RetryFailed(ctx context.Context, c client.Client, namespace string, filter search.Filter, prefix, reason string) (string, error) {
+	values := maps.Clone(filter.Values)
+	if values == nil {
+		values = map[string][]string{}
+	}
+	values["ExecutionStatus"] = []string{enums.WORKFLOW_EXECUTION_STATUS_FAILED.String()}
+	filter.Values = values
+	query := filter.Query()
+
+	// The batch jobs of an empty query fail late, the operators are told at once
+	count, err := c.CountWorkflow(ctx, &workflowservice.CountWorkflowExecutionsRequest{Namespace: namespace, Query: query})
+	if err != nil {
+		return "", err
+	}
+	if count.GetCount() == 0 {
+		return "", ErrNothingToRetry
+	}
+
+	jobID := prefix + uuid.NewString()
+	_, err = c.WorkflowService().StartBatchOperation(ctx, &workflowservice.StartBatchOperationRequest{
+		Namespace:       namespace,
+		VisibilityQuery: query,
+		JobId:           jobID,
+		Reason:          reason,
+		Operation: &workflowservice.StartBatchOperationRequest_ResetOperation{ResetOperation: &batchpb.BatchOperationReset{
+			Identity: identity,
+			Options:  &commonpb.ResetOptions{Target: &commonpb.ResetOptions_FirstWorkflowTask{FirstWorkflowTask: &emptypb.Empty{}}},
+		}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to start the batch job: %w", err)
+	}
+	return jobID, nil
+}
+
+// DescribeBatch returns the progress of the batch job
+func DescribeBatch(ctx context.Context, c client.Client, namespace, jobID string) (*Batch, error) {
+	resp, err := c.WorkflowService().DescribeBatchOperation(ctx, &workflowservice.DescribeBatchOperationRequest{Namespace: namespace, JobId: jobID})
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &Batch{
+		JobID:     resp.GetJobId(),
+		State:     resp.GetState().String(),
+		Reason:    resp.GetReason(),
+		StartTime: resp.GetStartTime().AsTime(),
+		Total:     resp.GetTotalOperationCount(),
+		Completed: resp.GetCompleteOperationCount(),
+		Failed:    resp.GetFailureOperationCount(),
+	}
+	if resp.GetCloseTime() != nil {
+		closeTime := resp.GetCloseTime().AsTime()
+		batch.CloseTime = &closeTime
+	}
+	return batch, nil
+}
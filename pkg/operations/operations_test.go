@@ -0,0 +1,232 @@
+package operations
+
+import (
+	"code-challenge/pkg/deadletter"
+	"code-challenge/pkg/search"
+	codingchallenge "code-challenge/pkg/workflow"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	failurepb "go.temporal.io/api/failure/v1"
+	historypb "go.temporal.io/api/history/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/mocks"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"strings"
+	"testing"
+	"time"
+)
+
+// workflowService records the requests of the operations missing from the client
+type workflowService struct {
+	workflowservice.WorkflowServiceClient
+	cancel *workflowservice.RequestCancelWorkflowExecutionRequest
+	batch  *workflowservice.StartBatchOperationRequest
+}
+
+func (s *workflowService) RequestCancelWorkflowExecution(_ context.Context, req *workflowservice.RequestCancelWorkflowExecutionRequest, _ ...grpc.CallOption) (*workflowservice.RequestCancelWorkflowExecutionResponse, error) {
+	s.cancel = req
+	return &workflowservice.RequestCancelWorkflowExecutionResponse{}, nil
+}
+
+func (s *workflowService) StartBatchOperation(_ context.Context, req *workflowservice.StartBatchOperationRequest, _ ...grpc.CallOption) (*workflowservice.StartBatchOperationResponse, error) {
+	s.batch = req
+	return &workflowservice.StartBatchOperationResponse{}, nil
+}
+
+func (s *workflowService) DescribeBatchOperation(_ context.Context, req *workflowservice.DescribeBatchOperationRequest, _ ...grpc.CallOption) (*workflowservice.DescribeBatchOperationResponse, error) {
+	return &workflowservice.DescribeBatchOperationResponse{
+		JobId:                  req.GetJobId(),
+		State:                  enums.BATCH_OPERATION_STATE_RUNNING,
+		Reason:                 "provider outage",
+		StartTime:              timestamppb.New(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)),
+		TotalOperationCount:    3,
+		CompleteOperationCount: 1,
+	}, nil
+}
+
+func Test_Describe(t *testing.T) {
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	c := &mocks.Client{}
+	c.On("DescribeWorkflowExecution", mock.Anything, "chat_1", "").Return(&workflowservice.DescribeWorkflowExecutionResponse{
+		WorkflowExecutionInfo: &workflowpb.WorkflowExecutionInfo{
+			Execution:     &commonpb.WorkflowExecution{WorkflowId: "chat_1", RunId: "run_1"},
+			Type:          &commonpb.WorkflowType{Name: "ChatBotWorkflow"},
+			Status:        enums.WORKFLOW_EXECUTION_STATUS_RUNNING,
+			StartTime:     timestamppb.New(started),
+			TaskQueue:     "chat_bot_workflow_task_queue",
+			HistoryLength: 12,
+		},
+		PendingActivities: []*workflowpb.PendingActivityInfo{{
+			ActivityId:   "5",
+			ActivityType: &commonpb.ActivityType{Name: "ChatActivity"},
+			State:        enums.PENDING_ACTIVITY_STATE_STARTED,
+			Attempt:      3,
+			LastFailure:  &failurepb.Failure{Message: "rate limited"},
+		}},
+	}, nil)
+
+	execution, err := Describe(context.Background(), c, "chat_1", "")
+	require.NoError(t, err)
+	assert.Equal(t, &Execution{
+		Conversation:  search.Conversation{WorkflowID: "chat_1", RunID: "run_1", WorkflowType: "ChatBotWorkflow", Status: "Running", StartTime: started},
+		TaskQueue:     "chat_bot_workflow_task_queue",
+		HistoryLength: 12,
+		PendingActivities: []PendingActivity{
+			{ActivityID: "5", ActivityType: "ChatActivity", State: "Started", Attempt: 3, LastFailure: "rate limited"},
+		},
+	}, execution)
+}
+
+func Test_Cancel(t *testing.T) {
+	service := &workflowService{}
+	c := &mocks.Client{}
+	c.On("WorkflowService").Return(service)
+
+	require.NoError(t, Cancel(context.Background(), c, "chatbot", "chat_1", "run_1", "stuck on the provider"))
+	assert.Equal(t, "chatbot", service.cancel.GetNamespace())
+	assert.Equal(t, "run_1", service.cancel.GetWorkflowExecution().GetRunId())
+	assert.Equal(t, "stuck on the provider", service.cancel.GetReason())
+	assert.NotEmpty(t, service.cancel.GetRequestId())
+}
+
+func Test_Reset(t *testing.T) {
+	events := &mocks.HistoryEventIterator{}
+	events.On("HasNext").Return(true).Times(3)
+	events.On("Next").Return(&historypb.HistoryEvent{EventId: 1, EventType: enums.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED}, nil).Once()
+	events.On("Next").Return(&historypb.HistoryEvent{EventId: 3, EventType: enums.EVENT_TYPE_WORKFLOW_TASK_STARTED}, nil).Once()
+	events.On("Next").Return(&historypb.HistoryEvent{EventId: 4, EventType: enums.EVENT_TYPE_WORKFLOW_TASK_COMPLETED}, nil).Once()
+
+	c := &mocks.Client{}
+	c.On("GetWorkflowHistory", mock.Anything, "chat_1", "run_1", false, enums.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT).Return(events)
+	c.On("ResetWorkflowExecution", mock.Anything, &workflowservice.ResetWorkflowExecutionRequest{
+		Namespace:                 "chatbot",
+		WorkflowExecution:         &commonpb.WorkflowExecution{WorkflowId: "chat_1", RunId: "run_1"},
+		Reason:                    "bad answer",
+		WorkflowTaskFinishEventId: 4,
+	}).Return(&workflowservice.ResetWorkflowExecutionResponse{RunId: "run_2"}, nil).Once()
+	c.On("ResetWorkflowExecution", mock.Anything, mock.MatchedBy(func(req *workflowservice.ResetWorkflowExecutionRequest) bool {
+		return req.GetWorkflowTaskFinishEventId() == 10
+	})).Return(&workflowservice.ResetWorkflowExecutionResponse{RunId: "run_3"}, nil).Once()
+
+	// The first workflow task is found in the history by default
+	runID, err := Reset(context.Background(), c, "chatbot", "chat_1", "run_1", 0, "bad answer")
+	require.NoError(t, err)
+	assert.Equal(t, "run_2", runID)
+
+	runID, err = Reset(context.Background(), c, "chatbot", "chat_1", "run_1", 10, "bad answer")
+	require.NoError(t, err)
+	assert.Equal(t, "run_3", runID)
+	c.AssertExpectations(t)
+}
+
+func Test_RetryFailed(t *testing.T) {
+	service := &workflowService{}
+	c := &mocks.Client{}
+	c.On("WorkflowService").Return(service)
+	query := "WorkflowType = 'ChatBotWorkflow' AND ExecutionStatus = 'Failed' AND Intent = 'work' AND User STARTS_WITH 'acme:'"
+	c.On("CountWorkflow", mock.Anything, &workflowservice.CountWorkflowExecutionsRequest{Namespace: "chatbot", Query: query}).
+		Return(&workflowservice.CountWorkflowExecutionsResponse{Count: 2}, nil).Once()
+
+	filter := search.Filter{WorkflowTypes: []string{"ChatBotWorkflow"}, Values: map[string][]string{"Intent": {"work"}}, UserPrefix: "acme:"}
+	jobID, err := RetryFailed(context.Background(), c, "chatbot", filter, "acme:retry-", "provider outage")
+	require.NoError(t, err)
+	assert.Regexp(t, "^acme:retry-[0-9a-f-]{36}$", jobID)
+
+	// The failed conversations are reset to their first workflow task
+	assert.Equal(t, jobID, service.batch.GetJobId())
+	assert.Equal(t, query, service.batch.GetVisibilityQuery())
+	assert.Equal(t, "provider outage", service.batch.GetReason())
+	assert.NotNil(t, service.batch.GetResetOperation().GetOptions().GetFirstWorkflowTask())
+
+	// No batch job is started when nothing failed
+	service.batch = nil
+	c.On("CountWorkflow", mock.Anything, mock.Anything).Return(&workflowservice.CountWorkflowExecutionsResponse{}, nil).Once()
+	_, err = RetryFailed(context.Background(), c, "chatbot", search.Filter{}, "retry-", "provider outage")
+	assert.ErrorIs(t, err, ErrNothingToRetry)
+	assert.Nil(t, service.batch)
+}
+
+func Test_DescribeBatch(t *testing.T) {
+	c := &mocks.Client{}
+	c.On("WorkflowService").Return(&workflowService{})
+
+	batch, err := DescribeBatch(context.Background(), c, "chatbot", "retry-1")
+	require.NoError(t, err)
+	assert.Equal(t, &Batch{
+		JobID: "retry-1", State: "Running", Reason: "provider outage", StartTime: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Total: 3, Completed: 1,
+	}, batch)
+}
+
+func Test_Replay(t *testing.T) {
+	store := deadletter.NewMemoryStore()
+	letter := deadletter.Letter{
+		ID:       "run_1",
+		User:     "acme:alice",
+		Question: "How do I renew my visa?",
+		Input:    json.RawMessage(`{"User":"acme:alice","Question":"How do I renew my visa?","Locale":"pt-BR","Model":"gpt-4o"}`),
+	}
+	require.NoError(t, store.Record(context.Background(), letter))
+
+	// The question is asked again with the other prompt version and keeps its model and its profile
+	run := &mocks.WorkflowRun{}
+	run.On("GetID").Return("chat_bot_workflow_2")
+	c := &mocks.Client{}
+	c.On("ExecuteWorkflow", mock.Anything, mock.MatchedBy(func(options client.StartWorkflowOptions) bool {
+		return strings.HasPrefix(options.ID, "chat_bot_workflow_") && options.TaskQueue == "chat" && options.Memo[memoDeadLetter] == "run_1"
+	}), mock.Anything, codingchallenge.ChatBotQuestion{
+		User: "acme:alice", Question: "How do I renew my visa?", Locale: "pt-BR", Model: "gpt-4o", PromptVersion: codingchallenge.PromptV2,
+	}).Return(run, nil).Once()
+
+	workflowID, err := Replay(context.Background(), c, store, "chat", &letter, ReplayOptions{PromptVersion: codingchallenge.PromptV2})
+	require.NoError(t, err)
+	assert.Equal(t, "chat_bot_workflow_2", workflowID)
+	c.AssertExpectations(t)
+
+	replayed, err := store.Get(context.Background(), "run_1")
+	require.NoError(t, err)
+	assert.Equal(t, "chat_bot_workflow_2", replayed.ReplayWorkflowID)
+	assert.NotNil(t, replayed.ReplayedAt)
+}
+
+func Test_DescribeQuestionBatch(t *testing.T) {
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	describe := func(name string) *workflowservice.DescribeWorkflowExecutionResponse {
+		return &workflowservice.DescribeWorkflowExecutionResponse{WorkflowExecutionInfo: &workflowpb.WorkflowExecutionInfo{
+			Execution: &commonpb.WorkflowExecution{WorkflowId: "batch-1", RunId: "run_1"},
+			Type:      &commonpb.WorkflowType{Name: name},
+			Status:    enums.WORKFLOW_EXECUTION_STATUS_RUNNING,
+			StartTime: timestamppb.New(started),
+		}}
+	}
+
+	// The progress of the running batch is queried
+	value := &mocks.Value{}
+	value.On("Get", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*codingchallenge.BatchProgress) = codingchallenge.BatchProgress{Input: "in.jsonl", Output: "out.jsonl", Questions: 120, Answered: 100, Running: 5}
+	}).Return(nil)
+	c := &mocks.Client{}
+	c.On("DescribeWorkflowExecution", mock.Anything, "batch-1", "").Return(describe("BatchQuestionsWorkflow"), nil)
+	c.On("QueryWorkflow", mock.Anything, "batch-1", "", codingchallenge.BatchProgressQuery).Return(value, nil)
+
+	batch, err := DescribeQuestionBatch(context.Background(), c, "batch-1")
+	require.NoError(t, err)
+	assert.Equal(t, &QuestionBatch{
+		BatchID: "batch-1", Status: "Running", StartTime: started,
+		Progress: &QuestionProgress{Input: "in.jsonl", Output: "out.jsonl", Questions: 120, Answered: 100, Running: 5},
+	}, batch)
+
+	// The conversations are not batches
+	c = &mocks.Client{}
+	c.On("DescribeWorkflowExecution", mock.Anything, "chat_1", "").Return(describe("ChatBotWorkflow"), nil)
+	_, err = DescribeQuestionBatch(context.Background(), c, "chat_1")
+	assert.ErrorIs(t, err, ErrNotQuestionBatch)
+}
@@ -0,0 +1,98 @@
+package operations
+
+import (
+	codingchallenge "code-challenge/pkg/workflow"
+	"context"
+	"errors"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+	"time"
+)
+
+// ErrNotQuestionBatch is returned when the workflow of the batch ID is not a BatchQuestionsWorkflow
+var ErrNotQuestionBatch = errors.New("the workflow is not a batch of questions")
+
+// QuestionBatch is a BatchQuestionsWorkflow answering the questions of a JSON lines file
+type QuestionBatch struct {
+	BatchID string `json:"batch_id"`
+
+	// Status is the status of the workflow, such as Running, Completed or Failed
+	Status    string     `json:"status"`
+	StartTime time.Time  `json:"start_time"`
+	CloseTime *time.Time `json:"close_time,omitempty"`
+
+	// Progress is queried while the batch runs and is its result once completed, it is missing otherwise
+	Progress *QuestionProgress `json:"progress,omitempty"`
+}
+
+// QuestionProgress counts the questions of a batch read so far by outcome
+type QuestionProgress struct {
+	Input     string `json:"input"`
+	Output    string `json:"output"`
+	Questions int    `json:"questions"`
+	Answered  int    `json:"answered"`
+	Queued    int    `json:"queued"`
+	Failed    int    `json:"failed"`
+	Running   int    `json:"running"`
+	Done      bool   `json:"done"`
+}
+
+// StartQuestionBatch starts the BatchQuestionsWorkflow of the batch ID, the ID is the one of the workflow
+func StartQuestionBatch(ctx context.Context, c client.Client, taskQueue, batchID string, input codingchallenge.BatchInput) error {
+	_, err := c.ExecuteWorkflow(ctx, client.StartWorkflowOptions{
+		ID:        batchID,
+		TaskQueue: taskQueue,
+	}, codingchallenge.BatchQuestionsWorkflow, input)
+	return err
+}
+
+// DescribeQuestionBatch returns the status and the progress of the batch
+func DescribeQuestionBatch(ctx context.Context, c client.Client, batchID string) (*QuestionBatch, error) {
+	resp, err := c.DescribeWorkflowExecution(ctx, batchID, "")
+	if err != nil {
+		return nil, err
+	}
+	info := resp.GetWorkflowExecutionInfo()
+	if info.GetType().GetName() != "BatchQuestionsWorkflow" {
+		return nil, ErrNotQuestionBatch
+	}
+
+	batch := &QuestionBatch{
+		BatchID:   batchID,
+		Status:    info.GetStatus().String(),
+		StartTime: info.GetStartTime().AsTime(),
+	}
+	if info.GetCloseTime() != nil {
+		closeTime := info.GetCloseTime().AsTime()
+		batch.CloseTime = &closeTime
+	}
+
+	var progress codingchallenge.BatchProgress
+	switch info.GetStatus() {
+	case enums.WORKFLOW_EXECUTION_STATUS_RUNNING:
+		value, err := c.QueryWorkflow(ctx, batchID, "", codingchallenge.BatchProgressQuery)
+		if err != nil {
+			return nil, err
+		}
+		if err := value.Get(&progress); err != nil {
+			return nil, err
+		}
+	case enums.WORKFLOW_EXECUTION_STATUS_COMPLETED:
+		if err := c.GetWorkflow(ctx, batchID, "").Get(ctx, &progress); err != nil {
+			return nil, err
+		}
+	default:
+		return batch, nil
+	}
+	batch.Progress = &QuestionProgress{
+		Input:     progress.Input,
+		Output:    progress.Output,
+		Questions: progress.Questions,
+		Answered:  progress.Answered,
+		Queued:    progress.Queued,
+		Failed:    progress.Failed,
+		Running:   progress.Running,
+		Done:      progress.Done,
+	}
+	return batch, nil
+}
@@ -0,0 +1,51 @@
+package operations
+
+import (
+	"code-challenge/pkg/deadletter"
+	codingchallenge "code-challenge/pkg/workflow"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/client"
+	"time"
+)
+
+// memoDeadLetter is the memo of the replayed workflows holding the ID of the dead letter, shown in the Temporal UI
+const memoDeadLetter = "DeadLetter"
+
+// ReplayOptions override the model and the prompt version of the replayed question, the empty ones keep the question's
+type ReplayOptions struct {
+	Model         string `json:"model,omitempty"`
+	PromptVersion string `json:"prompt_version,omitempty"`
+}
+
+// Replay asks the question of the dead letter again through a new ChatBotWorkflow and marks the letter replayed.
+// It returns the ID of the new workflow, which is recorded as a new dead letter if it fails again.
+func Replay(ctx context.Context, c client.Client, store deadletter.Store, taskQueue string, letter *deadletter.Letter, options ReplayOptions) (string, error) {
+	var question codingchallenge.ChatBotQuestion
+	if err := json.Unmarshal(letter.Input, &question); err != nil {
+		return "", fmt.Errorf("unable to decode the question of the dead letter: %w", err)
+	}
+	if options.Model != "" {
+		question.Model = options.Model
+	}
+	if options.PromptVersion != "" {
+		question.PromptVersion = options.PromptVersion
+	}
+
+	run, err := c.ExecuteWorkflow(ctx, client.StartWorkflowOptions{
+		ID:        "chat_bot_workflow_" + uuid.NewString(),
+		TaskQueue: taskQueue,
+		Memo:      map[string]interface{}{memoDeadLetter: letter.ID},
+	}, codingchallenge.ChatBotWorkflow, question)
+	if err != nil {
+		return "", err
+	}
+
+	// The workflow is started, failing to mark the letter only lets it be replayed twice
+	if err := store.MarkReplayed(ctx, letter.ID, run.GetID(), time.Now().UTC()); err != nil {
+		return run.GetID(), fmt.Errorf("unable to mark the dead letter replayed: %w", err)
+	}
+	return run.GetID(), nil
+}
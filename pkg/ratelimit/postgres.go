@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"time"
+)
+
+// schema creates the buckets table when it does not exist yet
+const schema = `
+CREATE TABLE IF NOT EXISTS rate_limits (
+	key        TEXT PRIMARY KEY,
+	tokens     DOUBLE PRECISION NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS rate_limits_updated_at ON rate_limits (updated_at);
+`
+
+// PostgresLimiter keeps the buckets in Postgres so every replica of the API applies the same limits
+type PostgresLimiter struct {
+	db *sql.DB
+
+	// now returns the current time, it is replaced in tests
+	now func() time.Time
+}
+
+// OpenPostgres connects to the database and creates the buckets table
+func OpenPostgres(ctx context.Context, url string) (*PostgresLimiter, error) {
+	db, err := sql.Open("pgx", url)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &PostgresLimiter{db: db, now: time.Now}, nil
+}
+
+// Allow takes a token from the bucket of the key when one is left.
+// The bucket row is locked for the update so the concurrent requests of the replicas are counted once each.
+func (l *PostgresLimiter) Allow(ctx context.Context, key string, limit Limit) (Decision, error) {
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Decision{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := l.now().UTC()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO rate_limits (key, tokens, updated_at) VALUES ($1, $2, $3) ON CONFLICT (key) DO NOTHING`,
+		key, float64(limit.Requests), now)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	var tokens float64
+	var updatedAt time.Time
+	err = tx.QueryRowContext(ctx, `SELECT tokens, updated_at FROM rate_limits WHERE key = $1 FOR UPDATE`, key).
+		Scan(&tokens, &updatedAt)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	tokens, decision := take(tokens, now.Sub(updatedAt), limit)
+	if _, err := tx.ExecContext(ctx, `UPDATE rate_limits SET tokens = $2, updated_at = $3 WHERE key = $1`, key, tokens, now); err != nil {
+		return Decision{}, err
+	}
+	return decision, tx.Commit()
+}
+
+// Prune deletes the buckets untouched since before the time, they are full again and the same as new buckets
+func (l *PostgresLimiter) Prune(ctx context.Context, before time.Time) error {
+	_, err := l.db.ExecContext(ctx, `DELETE FROM rate_limits WHERE updated_at < $1`, before)
+	return err
+}
+
+// Close closes the database connections
+func (l *PostgresLimiter) Close() error {
+	return l.db.Close()
+}
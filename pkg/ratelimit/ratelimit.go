@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit allows Requests per Period, the unused requests accumulate up to Requests so short bursts are allowed
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// PerMinute returns the limit allowing the requests per minute
+func PerMinute(requests int) Limit {
+	return Limit{Requests: requests, Period: time.Minute}
+}
+
+// Enabled returns whether the limit allows a finite number of requests, the zero limit does not limit anything
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// Decision is the outcome of a request checked against its limit
+type Decision struct {
+	Allowed bool
+
+	// Limit is the size of the bucket and Remaining the requests left in it
+	Limit     int
+	Remaining int
+
+	// RetryAfter is the wait before the next request is allowed, it is zero when the request is allowed
+	RetryAfter time.Duration
+
+	// Reset is the wait until the bucket is full again
+	Reset time.Duration
+}
+
+// Limiter checks the requests against their limit with a token bucket per key
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// Open returns the Postgres limiter when the URL of the database is set, so the replicas of the API share the buckets,
+// and an in memory limiter otherwise
+func Open(ctx context.Context, url string) (Limiter, error) {
+	if url == "" {
+		return NewMemoryLimiter(), nil
+	}
+	return OpenPostgres(ctx, url)
+}
+
+// maxMemoryBuckets is the number of buckets above which the full ones are dropped, they are the same as new buckets
+const maxMemoryBuckets = 10000
+
+// bucket holds the tokens left at the time of the last request
+type bucket struct {
+	tokens float64
+	at     time.Time
+	limit  Limit
+}
+
+// MemoryLimiter keeps the buckets in memory, each replica of the API then applies the limits on its own
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+
+	// now returns the current time, it is replaced in tests
+	now func() time.Time
+}
+
+// NewMemoryLimiter creates a limiter with empty buckets
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+// Allow takes a token from the bucket of the key when one is left
+func (l *MemoryLimiter) Allow(_ context.Context, key string, limit Limit) (Decision, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxMemoryBuckets {
+			l.prune(now)
+		}
+		b = &bucket{tokens: float64(limit.Requests), at: now}
+		l.buckets[key] = b
+	}
+
+	var decision Decision
+	b.tokens, decision = take(b.tokens, now.Sub(b.at), limit)
+	b.at, b.limit = now, limit
+	return decision, nil
+}
+
+// prune drops the buckets that are full again
+func (l *MemoryLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if refill(b.tokens, now.Sub(b.at), b.limit) >= float64(b.limit.Requests) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// take refills the bucket for the elapsed time and takes a token when one is left, it returns the tokens left
+func take(tokens float64, elapsed time.Duration, limit Limit) (float64, Decision) {
+	tokens = refill(tokens, elapsed, limit)
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+	return tokens, decide(allowed, tokens, limit)
+}
+
+// refill returns the tokens of the bucket after the elapsed time
+func refill(tokens float64, elapsed time.Duration, limit Limit) float64 {
+	rate := float64(limit.Requests) / float64(limit.Period)
+	return math.Min(float64(limit.Requests), tokens+rate*float64(max(elapsed, 0)))
+}
+
+// decide returns the decision for the tokens left in the bucket
+func decide(allowed bool, tokens float64, limit Limit) Decision {
+	interval := limit.Period / time.Duration(limit.Requests)
+	decision := Decision{
+		Allowed:   allowed,
+		Limit:     limit.Requests,
+		Remaining: int(tokens),
+		Reset:     time.Duration((float64(limit.Requests) - tokens) * float64(interval)),
+	}
+	if !allowed {
+		decision.RetryAfter = time.Duration((1 - tokens) * float64(interval))
+	}
+	return decision
+}
@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_MemoryLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewMemoryLimiter()
+	limiter.now = func() time.Time { return now }
+	limit := PerMinute(3)
+
+	// The bucket starts full
+	for i := 2; i >= 0; i-- {
+		decision, err := limiter.Allow(ctx, "key-1", limit)
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, i, decision.Remaining)
+	}
+
+	decision, err := limiter.Allow(ctx, "key-1", limit)
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 20*time.Second, decision.RetryAfter)
+	assert.Equal(t, time.Minute, decision.Reset)
+
+	// The other keys have their own bucket
+	decision, _ = limiter.Allow(ctx, "key-2", limit)
+	assert.True(t, decision.Allowed)
+
+	// A token is added every 20 seconds
+	now = now.Add(20 * time.Second)
+	decision, _ = limiter.Allow(ctx, "key-1", limit)
+	assert.True(t, decision.Allowed)
+	decision, _ = limiter.Allow(ctx, "key-1", limit)
+	assert.False(t, decision.Allowed)
+}
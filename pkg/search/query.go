@@ -0,0 +1,129 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"go.temporal.io/api/enums/v1"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// filters maps the names of the filters of a query to the search attributes they match
+var filters = map[string]string{
+	"user":     User.GetName(),
+	"intent":   Intent.GetName(),
+	"country":  DestinationCountry.GetName(),
+	"language": Language.GetName(),
+	"outcome":  Outcome.GetName(),
+	"model":    Model.GetName(),
+	"status":   "ExecutionStatus",
+}
+
+// validValue matches the values that are quoted in the visibility query as they are
+var validValue = regexp.MustCompile(`^[\p{L}\p{N}_.:@+-]+$`)
+
+// Filter selects the conversations: each search attribute matches one of its values and the period bounds the start time.
+// The zero fields match every conversation.
+type Filter struct {
+	WorkflowTypes []string
+	Values        map[string][]string
+	From, To      time.Time
+
+	// UserPrefix restricts the conversations to the users of an organization, such as "acme:"
+	UserPrefix string
+}
+
+// ParseQuery returns the filter of a query made of name:value terms, such as "user:alice outcome:failed country:canada
+// from:2024-05-01 to:2024-05-01". A name repeated matches any of its values, from and to are days, both inclusive.
+func ParseQuery(query string) (Filter, error) {
+	filter := Filter{Values: map[string][]string{}}
+	for _, term := range strings.Fields(query) {
+		name, value, ok := strings.Cut(term, ":")
+		if !ok || value == "" {
+			return filter, fmt.Errorf("%q must be formatted as name:value", term)
+		}
+
+		switch name = strings.ToLower(name); name {
+		case "from", "to":
+			day, err := time.Parse(time.DateOnly, value)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be a date formatted as YYYY-MM-DD", name)
+			}
+			if name == "from" {
+				filter.From = day
+			} else {
+				filter.To = day.Add(24 * time.Hour)
+			}
+		case "status":
+			status, err := executionStatus(value)
+			if err != nil {
+				return filter, err
+			}
+			filter.Values[filters[name]] = append(filter.Values[filters[name]], status)
+		default:
+			attribute, ok := filters[name]
+			if !ok {
+				return filter, fmt.Errorf("unknown filter %q, the filters are %s, from and to", name, strings.Join(slices.Sorted(maps.Keys(filters)), ", "))
+			}
+			if !validValue.MatchString(value) {
+				return filter, fmt.Errorf("%s must only contain letters, digits and _.:@+-", name)
+			}
+			filter.Values[attribute] = append(filter.Values[attribute], value)
+		}
+	}
+	return filter, nil
+}
+
+// executionStatus returns the name of the status of a workflow execution, such as Running or Failed
+func executionStatus(value string) (string, error) {
+	for name, status := range enums.WorkflowExecutionStatus_shorthandValue {
+		if strings.EqualFold(name, value) && status != int32(enums.WORKFLOW_EXECUTION_STATUS_UNSPECIFIED) {
+			return name, nil
+		}
+	}
+	return "", errors.New("status must be the status of a workflow, such as running, completed or failed")
+}
+
+// Query returns the visibility query of the filter
+func (f Filter) Query() string {
+	var conditions []string
+	if len(f.WorkflowTypes) > 0 {
+		conditions = append(conditions, in("WorkflowType", f.WorkflowTypes))
+	}
+	for _, attribute := range slices.Sorted(maps.Keys(f.Values)) {
+		conditions = append(conditions, in(attribute, f.Values[attribute]))
+	}
+	if f.UserPrefix != "" {
+		conditions = append(conditions, fmt.Sprintf("%s STARTS_WITH %s", User.GetName(), quote(f.UserPrefix)))
+	}
+	if !f.From.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("StartTime >= '%s'", f.From.UTC().Format(time.RFC3339)))
+	}
+	if !f.To.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("StartTime < '%s'", f.To.UTC().Format(time.RFC3339)))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// in returns the condition matching any of the values of the attribute
+func in(attribute string, values []string) string {
+	if len(values) == 1 {
+		return fmt.Sprintf("%s = %s", attribute, quote(values[0]))
+	}
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, quote(value))
+	}
+	return fmt.Sprintf("%s IN (%s)", attribute, strings.Join(quoted, ", "))
+}
+
+// quoter escapes the quotes and the backslashes of the string literals of the visibility queries
+var quoter = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// quote returns the value as a string literal
+func quote(value string) string {
+	return "'" + quoter.Replace(value) + "'"
+}
@@ -0,0 +1,133 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/operatorservice/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"log/slog"
+	"time"
+)
+
+// Search attributes of the conversation workflows, upserted by the workflows and registered in the namespace by Register
+var (
+	User               = temporal.NewSearchAttributeKeyKeyword("User")
+	Intent             = temporal.NewSearchAttributeKeyKeyword("Intent")
+	DestinationCountry = temporal.NewSearchAttributeKeyKeyword("DestinationCountry")
+	Language           = temporal.NewSearchAttributeKeyKeyword("Language")
+	Outcome            = temporal.NewSearchAttributeKeyKeyword("Outcome")
+	Model              = temporal.NewSearchAttributeKeyKeyword("Model")
+)
+
+// attributes are the custom search attributes of the conversations
+var attributes = []temporal.SearchAttributeKeyKeyword{User, Intent, DestinationCountry, Language, Outcome, Model}
+
+// Missing returns the names of the search attributes missing from the namespace, a workflow upserting an unknown attribute is blocked
+func Missing(ctx context.Context, c client.Client, namespace string) ([]string, error) {
+	registered, err := c.OperatorService().ListSearchAttributes(ctx, &operatorservice.ListSearchAttributesRequest{Namespace: namespace})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the search attributes: %w", err)
+	}
+
+	var missing []string
+	for _, key := range attributes {
+		if _, ok := registered.GetCustomAttributes()[key.GetName()]; !ok {
+			missing = append(missing, key.GetName())
+		}
+	}
+	return missing, nil
+}
+
+// Register adds the search attributes missing from the namespace.
+// The API keys of Temporal Cloud may not be allowed to add them: they are then created beforehand with tcld.
+func Register(ctx context.Context, c client.Client, namespace string) error {
+	names, err := Missing(ctx, c, namespace)
+	if err != nil || len(names) == 0 {
+		return err
+	}
+
+	missing := map[string]enums.IndexedValueType{}
+	for _, name := range names {
+		missing[name] = enums.INDEXED_VALUE_TYPE_KEYWORD
+	}
+	_, err = c.OperatorService().AddSearchAttributes(ctx, &operatorservice.AddSearchAttributesRequest{Namespace: namespace, SearchAttributes: missing})
+	if err != nil {
+		return fmt.Errorf("unable to register the search attributes: %w", err)
+	}
+	slog.InfoContext(ctx, "Registered the search attributes", "Namespace", namespace, "Count", len(missing))
+	return nil
+}
+
+// Conversation is a conversation workflow found by a search
+type Conversation struct {
+	WorkflowID   string     `json:"workflow_id"`
+	RunID        string     `json:"run_id"`
+	WorkflowType string     `json:"workflow_type"`
+	Status       string     `json:"status"`
+	StartTime    time.Time  `json:"start_time"`
+	CloseTime    *time.Time `json:"close_time,omitempty"`
+
+	User               string `json:"user"`
+	Intent             string `json:"intent"`
+	DestinationCountry string `json:"destination_country"`
+	Language           string `json:"language"`
+	Outcome            string `json:"outcome"`
+	Model              string `json:"model"`
+}
+
+// List returns a page of the conversations matching the filter and the token of the next page, empty on the last one
+func List(ctx context.Context, c client.Client, filter Filter, pageSize int, pageToken []byte) ([]Conversation, []byte, error) {
+	resp, err := c.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+		PageSize:      int32(pageSize),
+		NextPageToken: pageToken,
+		Query:         filter.Query(),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conversations := make([]Conversation, 0, len(resp.GetExecutions()))
+	for _, info := range resp.GetExecutions() {
+		conversations = append(conversations, NewConversation(info))
+	}
+	return conversations, resp.GetNextPageToken(), nil
+}
+
+// NewConversation returns the conversation of the workflow execution
+func NewConversation(info *workflowpb.WorkflowExecutionInfo) Conversation {
+	attrs := info.GetSearchAttributes()
+	c := Conversation{
+		WorkflowID:   info.GetExecution().GetWorkflowId(),
+		RunID:        info.GetExecution().GetRunId(),
+		WorkflowType: info.GetType().GetName(),
+		Status:       info.GetStatus().String(),
+		StartTime:    info.GetStartTime().AsTime(),
+
+		User:               keyword(attrs, User),
+		Intent:             keyword(attrs, Intent),
+		DestinationCountry: keyword(attrs, DestinationCountry),
+		Language:           keyword(attrs, Language),
+		Outcome:            keyword(attrs, Outcome),
+		Model:              keyword(attrs, Model),
+	}
+	if info.GetCloseTime() != nil {
+		closeTime := info.GetCloseTime().AsTime()
+		c.CloseTime = &closeTime
+	}
+	return c
+}
+
+// keyword returns the value of the keyword attribute, empty when it is not set
+func keyword(attrs *commonpb.SearchAttributes, key temporal.SearchAttributeKeyKeyword) string {
+	var value string
+	if payload, ok := attrs.GetIndexedFields()[key.GetName()]; ok {
+		_ = converter.GetDefaultDataConverter().FromPayload(payload, &value)
+	}
+	return value
+}
@@ -0,0 +1,154 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/operatorservice/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/mocks"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing"
+	"time"
+)
+
+func Test_ParseQuery(t *testing.T) {
+	filter, err := ParseQuery("user:acme:alice outcome:failed country:canada country:united_states status:running from:2024-05-01 to:2024-05-01")
+	require.NoError(t, err)
+	filter.WorkflowTypes = []string{"ChatBotWorkflow"}
+
+	assert.Equal(t, "WorkflowType = 'ChatBotWorkflow' AND DestinationCountry IN ('canada', 'united_states') AND "+
+		"ExecutionStatus = 'Running' AND Outcome = 'failed' AND User = 'acme:alice' AND "+
+		"StartTime >= '2024-05-01T00:00:00Z' AND StartTime < '2024-05-02T00:00:00Z'", filter.Query())
+
+	// The organizations only find the conversations of their users
+	filter = Filter{Values: map[string][]string{User.GetName(): {`o'brien\`}}, UserPrefix: "acme:"}
+	assert.Equal(t, `User = 'o\'brien\\' AND User STARTS_WITH 'acme:'`, filter.Query())
+
+	// The empty query matches every conversation
+	filter, err = ParseQuery("")
+	require.NoError(t, err)
+	assert.Empty(t, filter.Query())
+
+	for query, message := range map[string]string{
+		"alice":                   `"alice" must be formatted as name:value`,
+		"user:":                   `"user:" must be formatted as name:value`,
+		"workflowid:chat_1":       `unknown filter "workflowid", the filters are country, intent, language, model, outcome, status, user, from and to`,
+		"user:alice'OR'1'='1":     "user must only contain letters, digits and _.:@+-",
+		"from:yesterday":          "from must be a date formatted as YYYY-MM-DD",
+		"status:stuck":            "status must be the status of a workflow, such as running, completed or failed",
+		"status:unspecified":      "status must be the status of a workflow, such as running, completed or failed",
+		"model:gpt-4o user:\"a\"": "user must only contain letters, digits and _.:@+-",
+	} {
+		_, err := ParseQuery(query)
+		assert.EqualError(t, err, message, query)
+	}
+}
+
+// operatorService records the search attributes added to the namespace
+type operatorService struct {
+	operatorservice.OperatorServiceClient
+	registered map[string]enums.IndexedValueType
+	added      map[string]enums.IndexedValueType
+}
+
+func (s *operatorService) ListSearchAttributes(context.Context, *operatorservice.ListSearchAttributesRequest, ...grpc.CallOption) (*operatorservice.ListSearchAttributesResponse, error) {
+	return &operatorservice.ListSearchAttributesResponse{CustomAttributes: s.registered}, nil
+}
+
+func (s *operatorService) AddSearchAttributes(_ context.Context, req *operatorservice.AddSearchAttributesRequest, _ ...grpc.CallOption) (*operatorservice.AddSearchAttributesResponse, error) {
+	if req.GetNamespace() != "chatbot" {
+		return nil, errors.New("namespace not found")
+	}
+	s.added = req.GetSearchAttributes()
+	return &operatorservice.AddSearchAttributesResponse{}, nil
+}
+
+func Test_Register(t *testing.T) {
+	operator := &operatorService{registered: map[string]enums.IndexedValueType{
+		"User":  enums.INDEXED_VALUE_TYPE_KEYWORD,
+		"Other": enums.INDEXED_VALUE_TYPE_TEXT,
+	}}
+	c := &mocks.Client{}
+	c.On("OperatorService").Return(operator)
+
+	missing, err := Missing(context.Background(), c, "chatbot")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Intent", "DestinationCountry", "Language", "Outcome", "Model"}, missing)
+
+	// Only the missing attributes are added
+	require.NoError(t, Register(context.Background(), c, "chatbot"))
+	assert.Equal(t, map[string]enums.IndexedValueType{
+		"Intent":             enums.INDEXED_VALUE_TYPE_KEYWORD,
+		"DestinationCountry": enums.INDEXED_VALUE_TYPE_KEYWORD,
+		"Language":           enums.INDEXED_VALUE_TYPE_KEYWORD,
+		"Outcome":            enums.INDEXED_VALUE_TYPE_KEYWORD,
+		"Model":              enums.INDEXED_VALUE_TYPE_KEYWORD,
+	}, operator.added)
+
+	assert.ErrorContains(t, Register(context.Background(), c, "unknown"), "unable to register the search attributes: namespace not found")
+
+	// Nothing is added once they are all registered
+	operator.registered, operator.added = map[string]enums.IndexedValueType{}, nil
+	for _, key := range attributes {
+		operator.registered[key.GetName()] = enums.INDEXED_VALUE_TYPE_KEYWORD
+	}
+	require.NoError(t, Register(context.Background(), c, "unknown"))
+	assert.Nil(t, operator.added)
+}
+
+func Test_List(t *testing.T) {
+	keyword := func(value string) *commonpb.Payload {
+		payload, err := converter.GetDefaultDataConverter().ToPayload(value)
+		require.NoError(t, err)
+		return payload
+	}
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	c := &mocks.Client{}
+	c.On("ListWorkflow", mock.Anything, &workflowservice.ListWorkflowExecutionsRequest{
+		PageSize:      20,
+		NextPageToken: []byte("page-2"),
+		Query:         "Outcome = 'failed'",
+	}).Return(&workflowservice.ListWorkflowExecutionsResponse{
+		Executions: []*workflowpb.WorkflowExecutionInfo{{
+			Execution: &commonpb.WorkflowExecution{WorkflowId: "chat_1", RunId: "run_1"},
+			Type:      &commonpb.WorkflowType{Name: "ChatBotWorkflow"},
+			Status:    enums.WORKFLOW_EXECUTION_STATUS_FAILED,
+			StartTime: timestamppb.New(started),
+			CloseTime: timestamppb.New(started.Add(time.Minute)),
+			SearchAttributes: &commonpb.SearchAttributes{IndexedFields: map[string]*commonpb.Payload{
+				"User":    keyword("acme:alice"),
+				"Intent":  keyword("work"),
+				"Outcome": keyword("failed"),
+			}},
+		}, {
+			Execution: &commonpb.WorkflowExecution{WorkflowId: "chat_2", RunId: "run_2"},
+			Type:      &commonpb.WorkflowType{Name: "ChatBotWorkflow"},
+			Status:    enums.WORKFLOW_EXECUTION_STATUS_RUNNING,
+			StartTime: timestamppb.New(started),
+		}},
+		NextPageToken: []byte("page-3"),
+	}, nil)
+
+	filter, err := ParseQuery("outcome:failed")
+	require.NoError(t, err)
+	conversations, next, err := List(context.Background(), c, filter, 20, []byte("page-2"))
+	require.NoError(t, err)
+
+	closed := started.Add(time.Minute)
+	assert.Equal(t, []Conversation{{
+		WorkflowID: "chat_1", RunID: "run_1", WorkflowType: "ChatBotWorkflow", Status: "Failed", StartTime: started, CloseTime: &closed,
+		User: "acme:alice", Intent: "work", Outcome: "failed",
+	}, {
+		WorkflowID: "chat_2", RunID: "run_2", WorkflowType: "ChatBotWorkflow", Status: "Running", StartTime: started,
+	}}, conversations)
+	assert.Equal(t, []byte("page-3"), next)
+}
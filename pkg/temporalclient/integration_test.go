@@ -0,0 +1,150 @@
+//go:build integration
+
+package temporalclient
+
+import (
+	"code-challenge/pkg/config"
+	"context"
+	"crypto/tls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mtlsProxy terminates the mTLS connections in front of the dev server, which only serves plain gRPC,
+// and records the names of the client certificates
+type mtlsProxy struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	clients []string
+}
+
+// startMTLSProxy forwards the connections authenticated by a client certificate of the client CA to the backend
+func startMTLSProxy(t *testing.T, backend string, serverCA, clientCA *testCA) *mtlsProxy {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCA.keyPair(t, "temporal")},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCA.pool(),
+		NextProtos:   []string{"h2"},
+	})
+	require.NoError(t, err)
+	proxy := &mtlsProxy{listener: listener}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go proxy.forward(conn.(*tls.Conn), backend)
+		}
+	}()
+	return proxy
+}
+
+// forward copies the connection to the backend once the client certificate is verified
+func (p *mtlsProxy) forward(conn *tls.Conn, backend string) {
+	defer conn.Close()
+	if err := conn.Handshake(); err != nil {
+		return
+	}
+	p.mu.Lock()
+	p.clients = append(p.clients, conn.ConnectionState().PeerCertificates[0].Subject.CommonName)
+	p.mu.Unlock()
+
+	upstream, err := net.Dial("tcp", backend)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+	go func() { _, _ = io.Copy(upstream, conn) }()
+	_, _ = io.Copy(conn, upstream)
+}
+
+// hostPort returns the address of the proxy with the name of its certificate
+func (p *mtlsProxy) hostPort() string {
+	_, port, _ := net.SplitHostPort(p.listener.Addr().String())
+	return net.JoinHostPort("localhost", port)
+}
+
+// seen returns the names of the client certificates of the connections
+func (p *mtlsProxy) seen() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.clients...)
+}
+
+// echoWorkflow is run by the worker connected over mTLS
+func echoWorkflow(_ workflow.Context, name string) (string, error) {
+	return "Hello " + name, nil
+}
+
+// Test_DevServer_MTLS connects the client and the worker to a local dev server through mTLS with self-signed certificates.
+// It runs the Temporal CLI of TEMPORAL_CLI_PATH, or downloads it on first run: go test -tags integration ./pkg/temporalclient
+func Test_DevServer_MTLS(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	server, err := testsuite.StartDevServer(ctx, testsuite.DevServerOptions{ExistingPath: os.Getenv("TEMPORAL_CLI_PATH"), LogLevel: "error"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = server.Stop() })
+
+	clientCA, serverCA := newTestCA(t, "client-ca"), newTestCA(t, "server-ca")
+	proxy := startMTLSProxy(t, server.FrontendHostPort(), serverCA, clientCA)
+
+	files := newTLSFiles(t)
+	files.rotate(t, clientCA, "worker-1", serverCA)
+	cfg := config.Temporal{
+		HostPort:  proxy.hostPort(),
+		Namespace: "default",
+		TLS:       config.TLS{Enabled: true, CertFile: files.cert, KeyFile: files.key, CAFile: files.ca},
+	}
+	options, err := Options(cfg)
+	require.NoError(t, err)
+
+	c, err := client.Dial(options)
+	require.NoError(t, err)
+	defer c.Close()
+
+	// A workflow runs end to end over the mTLS connection
+	w := worker.New(c, "mtls", worker.Options{})
+	w.RegisterWorkflow(echoWorkflow)
+	require.NoError(t, w.Start())
+	defer w.Stop()
+
+	run, err := c.ExecuteWorkflow(ctx, client.StartWorkflowOptions{TaskQueue: "mtls"}, echoWorkflow, "Temporal")
+	require.NoError(t, err)
+	var greeting string
+	require.NoError(t, run.Get(ctx, &greeting))
+	assert.Equal(t, "Hello Temporal", greeting)
+
+	// The rotated certificate is used by the next connections
+	files.rotate(t, clientCA, "worker-2", serverCA)
+	rotated, err := client.Dial(options)
+	require.NoError(t, err)
+	defer rotated.Close()
+	_, err = rotated.CheckHealth(ctx, &client.CheckHealthRequest{})
+	require.NoError(t, err)
+	assert.Contains(t, proxy.seen(), "worker-1")
+	assert.Contains(t, proxy.seen(), "worker-2")
+
+	// The connections without client certificate are rejected
+	cfg.TLS.CertFile, cfg.TLS.KeyFile = "", ""
+	options, err = Options(cfg)
+	require.NoError(t, err)
+	dialCtx, cancelDial := context.WithTimeout(ctx, 5*time.Second)
+	defer cancelDial()
+	_, err = client.DialContext(dialCtx, options)
+	assert.Error(t, err)
+}
@@ -0,0 +1,161 @@
+package temporalclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// watchedFiles holds the value parsed from files, they are parsed again when one of them changes.
+// A rotation may be observed half written: the last valid value is then kept until the files are valid again.
+type watchedFiles[T any] struct {
+	names []string
+	parse func(contents [][]byte) (T, error)
+
+	mu      sync.Mutex
+	value   T
+	loaded  bool
+	version string
+}
+
+// watch returns the value parsed from the files, they must be valid at first
+func watch[T any](parse func(contents [][]byte) (T, error), names ...string) (*watchedFiles[T], error) {
+	w := &watchedFiles[T]{names: names, parse: parse}
+	if _, err := w.Load(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Load returns the value, parsing the files again when their size or modification time changed.
+// The changes are detected on use: the files are checked when a connection is opened or a request is sent.
+func (w *watchedFiles[T]) Load() (T, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	version, err := w.stat()
+	if err == nil && w.loaded && version == w.version {
+		return w.value, nil
+	}
+
+	var value T
+	if err == nil {
+		value, err = w.read()
+	}
+	if err != nil {
+		if w.loaded {
+			slog.Warn("Unable to reload the files, keeping the previous ones", "Files", w.names, "Error", err)
+			return w.value, nil
+		}
+		return value, err
+	}
+
+	if w.loaded {
+		slog.Info("Reloaded the rotated files", "Files", w.names)
+	}
+	w.value, w.loaded, w.version = value, true, version
+	return value, nil
+}
+
+// stat returns the size and the modification time of the files
+func (w *watchedFiles[T]) stat() (string, error) {
+	version := ""
+	for _, name := range w.names {
+		info, err := os.Stat(name)
+		if err != nil {
+			return "", err
+		}
+		version += fmt.Sprintf("%s:%d:%d;", name, info.Size(), info.ModTime().UnixNano())
+	}
+	return version, nil
+}
+
+// read parses the content of the files
+func (w *watchedFiles[T]) read() (T, error) {
+	contents := make([][]byte, 0, len(w.names))
+	for _, name := range w.names {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		contents = append(contents, content)
+	}
+	return w.parse(contents)
+}
+
+// parseKeyPair parses the client certificate and its key
+func parseKeyPair(contents [][]byte) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(contents[0], contents[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid client certificate: %w", err)
+	}
+	return &cert, nil
+}
+
+// parseCABundle parses the CA bundle verifying the certificate of the server
+func parseCABundle(contents [][]byte) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(contents[0]) {
+		return nil, errors.New("no certificate found in the CA bundle")
+	}
+	return pool, nil
+}
+
+// reloadingTLS returns the TLS configuration of the connection to the server, the client certificate and the CA bundle
+// are reloaded when their files are rotated so the new connections use them without restarting the process
+func reloadingTLS(serverName, certFile, keyFile, caFile string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+
+	if certFile != "" {
+		cert, err := watch(parseKeyPair, certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return cert.Load()
+		}
+	}
+
+	if caFile != "" {
+		ca, err := watch(parseCABundle, caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		// The roots of a tls.Config cannot change, the verification of the server is done by VerifyConnection instead
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			roots, err := ca.Load()
+			if err != nil {
+				return err
+			}
+			return verifyServer(state, roots, serverName)
+		}
+	}
+	return config, nil
+}
+
+// verifyServer verifies the certificate chain of the server against the roots and the name of the server,
+// as the TLS handshake does when the roots are set
+func verifyServer(state tls.ConnectionState, roots *x509.CertPool, serverName string) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("the server sent no certificate")
+	}
+	options := x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   time.Now(),
+	}
+	for _, cert := range state.PeerCertificates[1:] {
+		options.Intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(options)
+	return err
+}
@@ -0,0 +1,54 @@
+package temporalclient
+
+import (
+	"code-challenge/pkg/config"
+	"context"
+	"errors"
+	"go.temporal.io/sdk/client"
+	"net"
+	"strings"
+)
+
+// Options returns the options connecting the API and the worker to Temporal: the address and the namespace,
+// the TLS connection to a secured cluster or to Temporal Cloud and the API key credentials.
+// The client certificate, the CA bundle and the API key file are reloaded when they are rotated.
+func Options(cfg config.Temporal) (client.Options, error) {
+	options := client.Options{HostPort: cfg.HostPort, Namespace: cfg.Namespace}
+
+	if cfg.TLS.Enabled {
+		// The name of the server is verified against the host of the address unless it is overridden
+		serverName := cfg.TLS.ServerName
+		if serverName == "" {
+			serverName, _, _ = net.SplitHostPort(cfg.HostPort)
+		}
+
+		tlsConfig, err := reloadingTLS(serverName, cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.CAFile)
+		if err != nil {
+			return options, err
+		}
+		options.ConnectionOptions.TLS = tlsConfig
+	}
+
+	switch {
+	case cfg.APIKeyFile != "":
+		apiKey, err := watch(parseAPIKey, cfg.APIKeyFile)
+		if err != nil {
+			return options, err
+		}
+		options.Credentials = client.NewAPIKeyDynamicCredentials(func(context.Context) (string, error) {
+			return apiKey.Load()
+		})
+	case cfg.APIKey != "":
+		options.Credentials = client.NewAPIKeyStaticCredentials(cfg.APIKey)
+	}
+	return options, nil
+}
+
+// parseAPIKey returns the API key of the file without its trailing new line
+func parseAPIKey(contents [][]byte) (string, error) {
+	apiKey := strings.TrimSpace(string(contents[0]))
+	if apiKey == "" {
+		return "", errors.New("the API key file is empty")
+	}
+	return apiKey, nil
+}
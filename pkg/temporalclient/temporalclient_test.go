@@ -0,0 +1,144 @@
+package temporalclient
+
+import (
+	"code-challenge/pkg/config"
+	"crypto/tls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// newMTLSServer starts a server requiring a client certificate of the client CA, it answers with the name of the client.
+// The certificate of the server is the one stored in cert when the connection is opened.
+func newMTLSServer(t *testing.T, clientCA *testCA, cert *atomic.Pointer[tls.Certificate]) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCA.pool(),
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return cert.Load(), nil
+		},
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// connect opens a new connection to the server with the TLS configuration and returns the name of the client
+func connect(t *testing.T, server *httptest.Server, config *tls.Config) (string, error) {
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: config, DisableKeepAlives: true}}
+	resp, err := httpClient.Get(server.URL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body), nil
+}
+
+func Test_Options(t *testing.T) {
+	options, err := Options(config.Default().Temporal)
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:7233", options.HostPort)
+	assert.Equal(t, "default", options.Namespace)
+	assert.Nil(t, options.ConnectionOptions.TLS)
+	assert.Nil(t, options.Credentials)
+
+	// Temporal Cloud is reached with TLS and the API key, the server is verified with the public CAs
+	cfg := config.Default().Temporal
+	cfg.HostPort, cfg.TLS.Enabled, cfg.APIKey = "us-east-1.aws.api.temporal.io:7233", true, "secret"
+	options, err = Options(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "us-east-1.aws.api.temporal.io", options.ConnectionOptions.TLS.ServerName)
+	assert.False(t, options.ConnectionOptions.TLS.InsecureSkipVerify)
+	assert.NotNil(t, options.Credentials)
+
+	// The files must be valid at startup
+	cfg.TLS.CAFile = filepath.Join(t.TempDir(), "ca.crt")
+	write(t, cfg.TLS.CAFile, []byte("not a certificate"))
+	_, err = Options(cfg)
+	assert.ErrorContains(t, err, "no certificate found in the CA bundle")
+}
+
+func Test_Options_ReloadsRotatedFiles(t *testing.T) {
+	clientCA, serverCA, rotatedServerCA := newTestCA(t, "client-ca"), newTestCA(t, "server-ca"), newTestCA(t, "rotated-server-ca")
+
+	var serverCert atomic.Pointer[tls.Certificate]
+	cert := serverCA.keyPair(t, "temporal")
+	serverCert.Store(&cert)
+	server := newMTLSServer(t, clientCA, &serverCert)
+
+	files := newTLSFiles(t)
+	files.rotate(t, clientCA, "worker-1", serverCA)
+	options, err := Options(config.Temporal{
+		HostPort: server.Listener.Addr().String(),
+		TLS:      config.TLS{Enabled: true, CertFile: files.cert, KeyFile: files.key, CAFile: files.ca, ServerName: "localhost"},
+	})
+	require.NoError(t, err)
+	tlsConfig := options.ConnectionOptions.TLS
+
+	name, err := connect(t, server, tlsConfig)
+	require.NoError(t, err)
+	assert.Equal(t, "worker-1", name)
+
+	// The server certificate is verified against the CA bundle
+	rotated := rotatedServerCA.keyPair(t, "temporal")
+	serverCert.Store(&rotated)
+	_, err = connect(t, server, tlsConfig)
+	assert.ErrorContains(t, err, "certificate signed by unknown authority")
+
+	// Once the files are rotated the new connections use the new client certificate and trust the new CA
+	files.rotate(t, clientCA, "worker-2", rotatedServerCA)
+	name, err = connect(t, server, tlsConfig)
+	require.NoError(t, err)
+	assert.Equal(t, "worker-2", name)
+
+	// The name of the server is verified
+	options, err = Options(config.Temporal{
+		HostPort: server.Listener.Addr().String(),
+		TLS:      config.TLS{Enabled: true, CertFile: files.cert, KeyFile: files.key, CAFile: files.ca, ServerName: "temporal.example.com"},
+	})
+	require.NoError(t, err)
+	_, err = connect(t, server, options.ConnectionOptions.TLS)
+	assert.ErrorContains(t, err, "certificate is valid for localhost")
+
+	// A rotation observed half written keeps the previous files
+	write(t, files.cert, []byte("partial"))
+	name, err = connect(t, server, tlsConfig)
+	require.NoError(t, err)
+	assert.Equal(t, "worker-2", name)
+}
+
+func Test_APIKeyFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "api-key")
+	write(t, name, []byte("first-key\n"))
+	apiKey, err := watch(parseAPIKey, name)
+	require.NoError(t, err)
+
+	key, err := apiKey.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "first-key", key)
+
+	write(t, name, []byte("rotated-key\n"))
+	key, err = apiKey.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "rotated-key", key)
+
+	// The last key is kept while the file is missing during the rotation
+	require.NoError(t, os.Remove(name))
+	key, err = apiKey.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "rotated-key", key)
+
+	_, err = watch(parseAPIKey, name)
+	assert.Error(t, err)
+}
@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"code-challenge/pkg/calculators"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// calculatorPrefix prefixes the name of the tools backed by a calculator
+const calculatorPrefix = "calculate_"
+
+func init() {
+	// Every calculator is exposed as its own tool so the model sees its input schema
+	for _, calculator := range calculators.All() {
+		register(Tool{
+			Name:                calculatorPrefix + calculator.Name(),
+			Description:         calculator.Description(),
+			Parameters:          calculator.InputSchema(),
+			StartToCloseTimeout: 10 * time.Second,
+			RetryPolicy:         lookupRetryPolicy(),
+			Handler:             calculatorHandler(calculator),
+		})
+	}
+}
+
+// calculatorHandler runs the calculator, invalid inputs are reported as invalid arguments
+func calculatorHandler(calculator calculators.Calculator) Handler {
+	return func(_ context.Context, arguments json.RawMessage) (any, error) {
+		result, err := calculator.Calculate(arguments)
+		if errors.Is(err, calculators.ErrInvalidInput) {
+			return nil, errors.Join(ErrInvalidArguments, err)
+		}
+		return result, err
+	}
+}
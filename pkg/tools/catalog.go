@@ -0,0 +1,284 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Visa describes a visa or immigration program of the catalog
+type Visa struct {
+	Country      string   `json:"country"`
+	Code         string   `json:"code"`
+	Name         string   `json:"name"`
+	Category     string   `json:"category"`
+	Description  string   `json:"description"`
+	Requirements []string `json:"requirements"`
+	OfficialURL  string   `json:"official_url"`
+}
+
+// FeeItem is a single government fee paid for an applicant
+type FeeItem struct {
+	Applicant string `json:"applicant"`
+	Name      string `json:"name"`
+	Amount    int    `json:"amount"`
+}
+
+// visaFees lists the government fees of a visa
+type visaFees struct {
+	Country  string    `json:"country"`
+	Code     string    `json:"code"`
+	Currency string    `json:"currency"`
+	Items    []FeeItem `json:"items"`
+}
+
+// ProcessingTime is the typical processing time of a visa
+type ProcessingTime struct {
+	Country string `json:"country"`
+	Code    string `json:"code"`
+	Typical string `json:"typical"`
+	Notes   string `json:"notes"`
+}
+
+// catalog holds the visa data embedded in the binary
+var catalog struct {
+	AsOf            string
+	Visas           []Visa
+	Fees            []visaFees
+	ProcessingTimes []ProcessingTime
+}
+
+// countryAliases maps the common names of the countries to their catalog identifier
+var countryAliases = map[string]string{
+	"ca":            "canada",
+	"us":            "united_states",
+	"usa":           "united_states",
+	"america":       "united_states",
+	"united states": "united_states",
+	"uk":            "united_kingdom",
+	"gb":            "united_kingdom",
+	"great britain": "united_kingdom",
+	"britain":       "united_kingdom",
+	"england":       "united_kingdom",
+	"au":            "australia",
+}
+
+func init() {
+	var visas struct {
+		AsOf  string `json:"as_of"`
+		Visas []Visa `json:"visas"`
+	}
+	mustReadJSON("data/visas.json", &visas)
+
+	var fees struct {
+		AsOf string     `json:"as_of"`
+		Fees []visaFees `json:"fees"`
+	}
+	mustReadJSON("data/fees.json", &fees)
+
+	var processingTimes struct {
+		AsOf            string           `json:"as_of"`
+		ProcessingTimes []ProcessingTime `json:"processing_times"`
+	}
+	mustReadJSON("data/processing_times.json", &processingTimes)
+
+	catalog.AsOf = visas.AsOf
+	catalog.Visas = visas.Visas
+	catalog.Fees = fees.Fees
+	catalog.ProcessingTimes = processingTimes.ProcessingTimes
+
+	register(Tool{
+		Name:                "visa_catalog_lookup",
+		Description:         "Looks up visas and immigration programs by destination country, category or code, with their requirements and official links.",
+		Parameters:          json.RawMessage(visaCatalogSchema),
+		StartToCloseTimeout: 5 * time.Second,
+		RetryPolicy:         lookupRetryPolicy(),
+		Handler:             lookupVisas,
+	})
+	register(Tool{
+		Name:                "fee_calculator",
+		Description:         "Calculates the government fees of a visa application for the principal applicant, an accompanying spouse and children. Call visa_catalog_lookup first to find the visa code.",
+		Parameters:          json.RawMessage(feeCalculatorSchema),
+		StartToCloseTimeout: 5 * time.Second,
+		RetryPolicy:         lookupRetryPolicy(),
+		Handler:             calculateFees,
+	})
+	register(Tool{
+		Name:                "processing_time_lookup",
+		Description:         "Returns the typical processing time of a visa application. Call visa_catalog_lookup first to find the visa code.",
+		Parameters:          json.RawMessage(processingTimeSchema),
+		StartToCloseTimeout: 5 * time.Second,
+		RetryPolicy:         lookupRetryPolicy(),
+		Handler:             lookupProcessingTime,
+	})
+}
+
+const visaCatalogSchema = `{
+	"type": "object",
+	"additionalProperties": false,
+	"properties": {
+		"country": {"type": "string", "description": "Destination country, such as canada, united_states, united_kingdom or australia."},
+		"category": {"type": "string", "enum": ["permanent_residence", "work", "study", "visit"]},
+		"code": {"type": "string", "description": "Visa code returned by a previous lookup."}
+	}
+}`
+
+const feeCalculatorSchema = `{
+	"type": "object",
+	"additionalProperties": false,
+	"required": ["country", "code"],
+	"properties": {
+		"country": {"type": "string"},
+		"code": {"type": "string"},
+		"include_spouse": {"type": "boolean"},
+		"children": {"type": "integer", "minimum": 0, "maximum": 20}
+	}
+}`
+
+const processingTimeSchema = `{
+	"type": "object",
+	"additionalProperties": false,
+	"required": ["country", "code"],
+	"properties": {
+		"country": {"type": "string"},
+		"code": {"type": "string"}
+	}
+}`
+
+// lookupVisas returns the visas matching every given filter
+func lookupVisas(_ context.Context, arguments json.RawMessage) (any, error) {
+	var args struct {
+		Country  string `json:"country"`
+		Category string `json:"category"`
+		Code     string `json:"code"`
+	}
+	if err := decodeArguments(arguments, &args); err != nil {
+		return nil, err
+	}
+
+	country := normalizeCountry(args.Country)
+	matches := []Visa{}
+	for _, visa := range catalog.Visas {
+		if country != "" && visa.Country != country {
+			continue
+		}
+		if args.Category != "" && visa.Category != args.Category {
+			continue
+		}
+		if args.Code != "" && visa.Code != strings.ToLower(args.Code) {
+			continue
+		}
+		matches = append(matches, visa)
+	}
+
+	return map[string]any{"as_of": catalog.AsOf, "visas": matches}, nil
+}
+
+// calculateFees adds up the fees paid for every applicant of the application
+func calculateFees(_ context.Context, arguments json.RawMessage) (any, error) {
+	var args struct {
+		Country       string `json:"country"`
+		Code          string `json:"code"`
+		IncludeSpouse bool   `json:"include_spouse"`
+		Children      int    `json:"children"`
+	}
+	if err := decodeArguments(arguments, &args); err != nil {
+		return nil, err
+	}
+	if args.Children < 0 || args.Children > 20 {
+		return nil, fmt.Errorf("%w: children must be between 0 and 20", ErrInvalidArguments)
+	}
+
+	fees, ok := findFees(normalizeCountry(args.Country), strings.ToLower(args.Code))
+	if !ok {
+		return nil, fmt.Errorf("%w: no fees known for visa %q in %q", ErrInvalidArguments, args.Code, args.Country)
+	}
+
+	// Count the applicants of each type to multiply their fees
+	applicants := map[string]int{"principal": 1, "child": args.Children}
+	if args.IncludeSpouse {
+		applicants["spouse"] = 1
+	}
+
+	type line struct {
+		FeeItem
+		Quantity int `json:"quantity"`
+		Subtotal int `json:"subtotal"`
+	}
+	lines := []line{}
+	total := 0
+	for _, item := range fees.Items {
+		quantity := applicants[item.Applicant]
+		if quantity == 0 {
+			continue
+		}
+		lines = append(lines, line{FeeItem: item, Quantity: quantity, Subtotal: quantity * item.Amount})
+		total += quantity * item.Amount
+	}
+
+	return map[string]any{
+		"as_of":    catalog.AsOf,
+		"currency": fees.Currency,
+		"items":    lines,
+		"total":    total,
+	}, nil
+}
+
+// lookupProcessingTime returns the typical processing time of the visa
+func lookupProcessingTime(_ context.Context, arguments json.RawMessage) (any, error) {
+	var args struct {
+		Country string `json:"country"`
+		Code    string `json:"code"`
+	}
+	if err := decodeArguments(arguments, &args); err != nil {
+		return nil, err
+	}
+
+	country, code := normalizeCountry(args.Country), strings.ToLower(args.Code)
+	for _, processingTime := range catalog.ProcessingTimes {
+		if processingTime.Country == country && processingTime.Code == code {
+			return map[string]any{"as_of": catalog.AsOf, "processing_time": processingTime}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no processing time known for visa %q in %q", ErrInvalidArguments, args.Code, args.Country)
+}
+
+// findFees returns the fees of the visa
+func findFees(country, code string) (visaFees, bool) {
+	for _, fees := range catalog.Fees {
+		if fees.Country == country && fees.Code == code {
+			return fees, true
+		}
+	}
+	return visaFees{}, false
+}
+
+// normalizeCountry maps the country name sent by the model to its catalog identifier
+func normalizeCountry(country string) string {
+	country = strings.ToLower(strings.TrimSpace(country))
+	if alias, ok := countryAliases[country]; ok {
+		return alias
+	}
+	return strings.ReplaceAll(country, " ", "_")
+}
+
+// mustReadJSON strictly decodes an embedded data file, the data is part of the binary so errors are programming errors
+func mustReadJSON(name string, v any) {
+	content, err := data.ReadFile(name)
+	if err != nil {
+		panic(err)
+	}
+	if err := strictUnmarshal(content, v); err != nil {
+		panic(fmt.Errorf("%s: %w", name, err))
+	}
+}
+
+// strictUnmarshal decodes JSON and rejects unknown fields
+func strictUnmarshal(content []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
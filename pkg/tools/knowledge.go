@@ -0,0 +1,148 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Article is an entry of the knowledge base
+type Article struct {
+	ID      string   `json:"id"`
+	Title   string   `json:"title"`
+	Tags    []string `json:"tags"`
+	Content string   `json:"content"`
+}
+
+// articles holds the knowledge base embedded in the binary
+var articles []Article
+
+// defaultSearchLimit is the number of articles returned when the model does not ask for a limit
+const defaultSearchLimit = 3
+
+func init() {
+	var knowledgeBase struct {
+		Articles []Article `json:"articles"`
+	}
+	mustReadJSON("data/knowledge_base.json", &knowledgeBase)
+	articles = knowledgeBase.Articles
+
+	register(Tool{
+		Name:                "knowledge_base_search",
+		Description:         "Searches the immigration knowledge base for articles answering common questions and returns the most relevant ones.",
+		Parameters:          json.RawMessage(knowledgeBaseSchema),
+		StartToCloseTimeout: 5 * time.Second,
+		RetryPolicy:         lookupRetryPolicy(),
+		Handler:             searchKnowledgeBase,
+	})
+}
+
+const knowledgeBaseSchema = `{
+	"type": "object",
+	"additionalProperties": false,
+	"required": ["query"],
+	"properties": {
+		"query": {"type": "string", "description": "Keywords describing the question."},
+		"limit": {"type": "integer", "minimum": 1, "maximum": 10}
+	}
+}`
+
+// searchKnowledgeBase ranks the articles by the number of query terms they contain
+func searchKnowledgeBase(_ context.Context, arguments json.RawMessage) (any, error) {
+	var args struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	if err := decodeArguments(arguments, &args); err != nil {
+		return nil, err
+	}
+
+	terms := tokenize(args.Query)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("%w: query must contain at least one word", ErrInvalidArguments)
+	}
+
+	limit := args.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	type hit struct {
+		Article
+		Score int `json:"score"`
+	}
+	hits := []hit{}
+	for _, article := range articles {
+		if score := scoreArticle(article, terms); score > 0 {
+			hits = append(hits, hit{Article: article, Score: score})
+		}
+	}
+
+	// Sort by descending score, then by identifier so equal scores keep a stable order
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return map[string]any{"articles": hits}, nil
+}
+
+// scoreArticle weights the terms found in the title and the tags higher than the ones found in the content
+func scoreArticle(article Article, terms []string) int {
+	title := termSet(article.Title)
+	tags := termSet(strings.Join(article.Tags, " "))
+	content := termSet(article.Content)
+
+	score := 0
+	for _, term := range terms {
+		if title[term] {
+			score += 3
+		}
+		if tags[term] {
+			score += 2
+		}
+		if content[term] {
+			score++
+		}
+	}
+	return score
+}
+
+// stopWords are ignored when searching
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "can": true, "do": true, "for": true, "how": true, "i": true,
+	"in": true, "is": true, "my": true, "of": true, "on": true, "or": true, "the": true, "to": true, "what": true, "with": true,
+}
+
+// tokenize splits the text into lower case words without stop words
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	})
+
+	var terms []string
+	for _, word := range words {
+		if !stopWords[word] {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// termSet returns the set of words of the text
+func termSet(text string) map[string]bool {
+	set := map[string]bool{}
+	for _, term := range tokenize(text) {
+		set[term] = true
+	}
+	return set
+}
@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"code-challenge/pkg/openai"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+	"sort"
+	"time"
+)
+
+// data holds the catalogs and the knowledge base the tools look up
+//
+//go:embed data
+var data embed.FS
+
+// ErrInvalidArguments is returned by handlers when the model sent arguments the tool cannot use
+var ErrInvalidArguments = errors.New("invalid tool arguments")
+
+// InvalidArgumentsErrorType is the Temporal error type of invalid arguments failures, they are never retried
+const InvalidArgumentsErrorType = "InvalidToolArguments"
+
+// activityPrefix prefixes the name of the activity registered for each tool
+const activityPrefix = "Tool_"
+
+// Handler runs a tool with the JSON arguments sent by the model and returns a JSON serializable result
+type Handler func(ctx context.Context, arguments json.RawMessage) (any, error)
+
+// Tool is a function the model can call, it runs as its own Temporal activity
+type Tool struct {
+	// Name is the function name exposed to the model
+	Name string
+
+	// Description explains to the model when the tool should be used
+	Description string
+
+	// Parameters is the JSON schema of the arguments
+	Parameters json.RawMessage
+
+	// StartToCloseTimeout is the timeout of each attempt of the tool activity
+	StartToCloseTimeout time.Duration
+
+	// RetryPolicy is the retry policy of the tool activity
+	RetryPolicy *temporal.RetryPolicy
+
+	// Handler runs the tool
+	Handler Handler
+}
+
+// registry holds every tool indexed by name
+var registry = map[string]Tool{}
+
+// register adds the tool to the registry, it is meant to be called from init functions
+func register(tool Tool) {
+	registry[tool.Name] = tool
+}
+
+// Get returns the tool registered under the given name
+func Get(name string) (Tool, bool) {
+	tool, ok := registry[name]
+	return tool, ok
+}
+
+// All returns every registered tool sorted by name so the model is always offered the tools in the same order
+func All() []Tool {
+	all := make([]Tool, 0, len(registry))
+	for _, tool := range registry {
+		all = append(all, tool)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// Definitions returns the definitions of every registered tool as offered to the model
+func Definitions() []openai.Tool {
+	var definitions []openai.Tool
+	for _, tool := range All() {
+		definitions = append(definitions, tool.Definition())
+	}
+	return definitions
+}
+
+// RegisterActivities registers the activity of every tool with the worker
+func RegisterActivities(registry worker.ActivityRegistry) {
+	for _, tool := range All() {
+		registry.RegisterActivityWithOptions(tool.Execute, activity.RegisterOptions{Name: tool.ActivityName()})
+	}
+}
+
+// Definition returns the tool definition offered to the model
+func (t Tool) Definition() openai.Tool {
+	return openai.Tool{
+		Name:        t.Name,
+		Description: t.Description,
+		Parameters:  t.Parameters,
+	}
+}
+
+// ActivityName returns the name the tool activity is registered under
+func (t Tool) ActivityName() string {
+	return activityPrefix + t.Name
+}
+
+// ActivityOptions returns the options the workflow uses to execute the tool activity
+func (t Tool) ActivityOptions() workflow.ActivityOptions {
+	retryPolicy := *t.RetryPolicy
+	// Invalid arguments are never retried, whatever the tool retry policy
+	retryPolicy.NonRetryableErrorTypes = append([]string{InvalidArgumentsErrorType}, retryPolicy.NonRetryableErrorTypes...)
+
+	return workflow.ActivityOptions{
+		StartToCloseTimeout: t.StartToCloseTimeout,
+		RetryPolicy:         &retryPolicy,
+	}
+}
+
+// Execute is the Temporal activity running the tool, it returns the JSON encoded result sent back to the model
+func (t Tool) Execute(ctx context.Context, arguments string) (string, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Tool activity started.", "Tool", t.Name)
+
+	result, err := t.Handler(ctx, json.RawMessage(arguments))
+	if errors.Is(err, ErrInvalidArguments) {
+		// Retrying cannot fix the arguments, the model has to send new ones
+		return "", temporal.NewNonRetryableApplicationError(err.Error(), InvalidArgumentsErrorType, err)
+	}
+
+	if err != nil {
+		logger.Error("Not able to run tool.", "Tool", t.Name, "Error", err)
+		return "", err
+	}
+
+	content, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// decodeArguments strictly decodes the arguments sent by the model
+func decodeArguments(arguments json.RawMessage, v any) error {
+	// Tools without required arguments may be called without any
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	if err := strictUnmarshal(arguments, v); err != nil {
+		return errors.Join(ErrInvalidArguments, err)
+	}
+	return nil
+}
+
+// lookupRetryPolicy is the retry policy of the tools reading embedded data, only a few attempts make sense
+func lookupRetryPolicy() *temporal.RetryPolicy {
+	return &temporal.RetryPolicy{
+		InitialInterval:    time.Second,
+		BackoffCoefficient: 2.0,
+		MaximumAttempts:    3,
+	}
+}
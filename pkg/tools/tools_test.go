@@ -0,0 +1,123 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+	"testing"
+)
+
+// run calls the handler of the named tool and decodes its result
+func run(t *testing.T, name string, arguments string) (map[string]any, error) {
+	t.Helper()
+	tool, ok := Get(name)
+	require.True(t, ok, name)
+
+	result, err := tool.Handler(context.Background(), json.RawMessage(arguments))
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := json.Marshal(result)
+	require.NoError(t, err)
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(content, &decoded))
+	return decoded, nil
+}
+
+func Test_Registry(t *testing.T) {
+	var names []string
+	for _, tool := range All() {
+		names = append(names, tool.Name)
+
+		// Every tool declares a valid schema and a retry policy
+		var schema map[string]any
+		assert.NoError(t, json.Unmarshal(tool.Parameters, &schema), tool.Name)
+		assert.NotNil(t, tool.RetryPolicy, tool.Name)
+		assert.Positive(t, tool.StartToCloseTimeout, tool.Name)
+		assert.Contains(t, tool.ActivityOptions().RetryPolicy.NonRetryableErrorTypes, InvalidArgumentsErrorType)
+	}
+
+	assert.Equal(t, []string{"calculate_crs", "fee_calculator", "knowledge_base_search", "processing_time_lookup", "visa_catalog_lookup"}, names)
+	assert.Len(t, Definitions(), len(names))
+}
+
+func Test_VisaCatalogLookup(t *testing.T) {
+	result, err := run(t, "visa_catalog_lookup", `{"country": "USA", "category": "study"}`)
+	require.NoError(t, err)
+
+	visas := result["visas"].([]any)
+	require.Len(t, visas, 1)
+	assert.Equal(t, "f-1", visas[0].(map[string]any)["code"])
+
+	result, err = run(t, "visa_catalog_lookup", ``)
+	require.NoError(t, err)
+	assert.Len(t, result["visas"], 10)
+
+	_, err = run(t, "visa_catalog_lookup", `{"nationality": "brazil"}`)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+}
+
+func Test_FeeCalculator(t *testing.T) {
+	result, err := run(t, "fee_calculator", `{"country": "canada", "code": "express-entry", "include_spouse": true, "children": 2}`)
+	require.NoError(t, err)
+	assert.Equal(t, "CAD", result["currency"])
+	assert.Equal(t, float64(950+575+85+950+575+85+2*260), result["total"])
+
+	result, err = run(t, "fee_calculator", `{"country": "canada", "code": "express-entry"}`)
+	require.NoError(t, err)
+	assert.Equal(t, float64(950+575+85), result["total"])
+
+	_, err = run(t, "fee_calculator", `{"country": "canada", "code": "unknown"}`)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+
+	_, err = run(t, "fee_calculator", `{"country": "canada", "code": "express-entry", "children": -1}`)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+}
+
+func Test_ProcessingTimeLookup(t *testing.T) {
+	result, err := run(t, "processing_time_lookup", `{"country": "United Kingdom", "code": "skilled-worker"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "3 weeks", result["processing_time"].(map[string]any)["typical"])
+
+	_, err = run(t, "processing_time_lookup", `{"country": "canada", "code": "h-1b"}`)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+}
+
+func Test_KnowledgeBaseSearch(t *testing.T) {
+	result, err := run(t, "knowledge_base_search", `{"query": "How do I improve my CRS score?"}`)
+	require.NoError(t, err)
+
+	articles := result["articles"].([]any)
+	require.NotEmpty(t, articles)
+	assert.Equal(t, "crs-improve-score", articles[0].(map[string]any)["id"])
+
+	result, err = run(t, "knowledge_base_search", `{"query": "work", "limit": 1}`)
+	require.NoError(t, err)
+	assert.Len(t, result["articles"], 1)
+
+	_, err = run(t, "knowledge_base_search", `{"query": "the of"}`)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+}
+
+func Test_Execute_Activity(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestActivityEnvironment()
+	RegisterActivities(env)
+
+	tool, _ := Get("calculate_crs")
+	value, err := env.ExecuteActivity(tool.ActivityName(), `{"age": 29, "education": "masters", "canadian_work_years": 1,
+		"foreign_work_years": 3, "first_language": {"language": "english", "reading": 9, "writing": 9, "speaking": 9, "listening": 9}}`)
+	require.NoError(t, err)
+
+	var content string
+	require.NoError(t, value.Get(&content))
+	assert.Contains(t, content, `"total":509`)
+
+	// Invalid arguments fail with a non retryable error
+	_, err = env.ExecuteActivity(tool.ActivityName(), `{"age": "unknown"}`)
+	assert.ErrorContains(t, err, InvalidArgumentsErrorType)
+}
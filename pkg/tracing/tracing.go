@@ -0,0 +1,107 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	temporalotel "go.temporal.io/sdk/contrib/opentelemetry"
+	"go.temporal.io/sdk/interceptor"
+	"net/http"
+	"os"
+)
+
+// Exporters of the spans, selected with OTEL_TRACES_EXPORTER
+const (
+	ExporterOTLP = "otlp"
+
+	// ExporterStdout prints the spans for local development, "console" is accepted as well
+	ExporterStdout = "stdout"
+	ExporterNone   = "none"
+)
+
+// ErrUnknownExporter is returned when OTEL_TRACES_EXPORTER names an exporter that is not supported
+var ErrUnknownExporter = errors.New("unknown traces exporter")
+
+// Setup installs the global tracer provider of the service and the W3C trace context propagator, it returns the function
+// flushing the spans on shutdown. The exporter is taken from OTEL_TRACES_EXPORTER: otlp, the default when
+// OTEL_EXPORTER_OTLP_ENDPOINT is set, stdout, or none, the default otherwise. The OTLP exporter is configured
+// with the standard OTEL_EXPORTER_OTLP_* variables and the sampler with OTEL_TRACES_SAMPLER.
+func Setup(ctx context.Context, service string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	exporter, err := newExporter(ctx, exporterName())
+	if err != nil || exporter == nil {
+		return func(context.Context) error { return nil }, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the name of the service
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(service)))
+	if err != nil {
+		return nil, err
+	}
+	if res, err = resource.Merge(res, resource.Environment()); err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// exporterName returns the exporter configured by the environment
+func exporterName() string {
+	switch name := os.Getenv("OTEL_TRACES_EXPORTER"); {
+	case name == "console":
+		return ExporterStdout
+	case name != "":
+		return name
+	case os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "":
+		return ExporterOTLP
+	}
+	return ExporterNone
+}
+
+// newExporter creates the exporter, it is nil when the spans are not exported
+func newExporter(ctx context.Context, name string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case ExporterOTLP:
+		return otlptracehttp.New(ctx)
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterNone:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownExporter, name)
+}
+
+// TemporalInterceptor propagates the spans from the clients starting the workflows to the workflows and their activities
+func TemporalInterceptor() (interceptor.Interceptor, error) {
+	return temporalotel.NewTracingInterceptor(temporalotel.TracerOptions{})
+}
+
+// Instrument starts a server span per request named after the pattern of the mux route it matches,
+// continuing the trace of the caller
+func Instrument(mux *http.ServeMux, next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http", otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			return pattern
+		}
+		return r.Method + " unmatched"
+	}))
+}
+
+// Transport traces the outbound requests sent with the base transport, http.DefaultTransport when it is nil
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(base)
+}
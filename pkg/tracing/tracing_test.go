@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// recordSpans installs a tracer provider recording the ended spans
+func recordSpans() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return recorder
+}
+
+// spanNames returns the names of the spans
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+	return names
+}
+
+func Test_ExporterName(t *testing.T) {
+	assert.Equal(t, ExporterNone, exporterName())
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+	assert.Equal(t, ExporterOTLP, exporterName())
+
+	t.Setenv("OTEL_TRACES_EXPORTER", "console")
+	assert.Equal(t, ExporterStdout, exporterName())
+
+	_, err := newExporter(context.Background(), "zipkin")
+	assert.ErrorIs(t, err, ErrUnknownExporter)
+}
+
+func Test_Instrument(t *testing.T) {
+	recorder := recordSpans()
+
+	var handlerSpan trace.SpanContext
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/conversations/{user}", func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = trace.SpanContextFromContext(r.Context())
+	})
+	handler := Instrument(mux, mux)
+
+	// The trace of the caller is continued and the span is named after the route pattern
+	req := httptest.NewRequest(http.MethodGet, "/v1/conversations/alice", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+	spans := recorder.Ended()
+	assert.Equal(t, []string{"GET /v1/conversations/{user}", "GET unmatched"}, spanNames(spans))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, spans[0].SpanContext().SpanID(), handlerSpan.SpanID())
+}
+
+func Test_TemporalInterceptor(t *testing.T) {
+	recorder := recordSpans()
+	tracingInterceptor, err := TemporalInterceptor()
+	require.NoError(t, err)
+
+	var activitySpan trace.SpanContext
+	activity := func(ctx context.Context) error {
+		activitySpan = trace.SpanContextFromContext(ctx)
+		return nil
+	}
+	tracedWorkflow := func(ctx workflow.Context) error {
+		ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{StartToCloseTimeout: time.Minute})
+		return workflow.ExecuteActivity(ctx, activity).Get(ctx, nil)
+	}
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(worker.Options{Interceptors: []interceptor.WorkerInterceptor{tracingInterceptor}})
+	env.RegisterWorkflowWithOptions(tracedWorkflow, workflow.RegisterOptions{Name: "TracedWorkflow"})
+	env.RegisterActivity(activity)
+	env.ExecuteWorkflow(tracedWorkflow)
+	require.NoError(t, env.GetWorkflowError())
+
+	// The activity runs in the trace of the workflow
+	spans := recorder.Ended()
+	require.NotEmpty(t, spans)
+	assert.Contains(t, spanNames(spans), "RunWorkflow:TracedWorkflow")
+	assert.True(t, activitySpan.IsValid())
+	for _, span := range spans {
+		assert.Equal(t, activitySpan.TraceID(), span.SpanContext().TraceID(), span.Name())
+	}
+}
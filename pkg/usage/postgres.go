@@ -0,0 +1,76 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"time"
+)
+
+// schema creates the usage table when it does not exist yet
+const schema = `
+CREATE TABLE IF NOT EXISTS api_usage (
+	organization TEXT NOT NULL,
+	key_id       TEXT NOT NULL,
+	route        TEXT NOT NULL,
+	status       INTEGER NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS api_usage_organization_created_at ON api_usage (organization, created_at);
+`
+
+// PostgresStore keeps the usage in Postgres so it is shared by the replicas of the API
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// OpenPostgres connects to the database and creates the usage table
+func OpenPostgres(ctx context.Context, url string) (*PostgresStore, error) {
+	db, err := sql.Open("pgx", url)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// Record records the call
+func (s *PostgresStore) Record(ctx context.Context, record Record) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO api_usage (organization, key_id, route, status, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		record.Organization, record.KeyID, record.Route, record.Status, record.CreatedAt)
+	return err
+}
+
+// Summary returns the daily usage of the organization between from and to
+func (s *PostgresStore) Summary(ctx context.Context, organization string, from, to time.Time) ([]Summary, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT organization, to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day, route,
+			count(*), count(*) FILTER (WHERE status >= 400)
+		FROM api_usage
+		WHERE ($1 = '' OR organization = $1) AND created_at >= $2 AND created_at < $3
+		GROUP BY organization, day, route
+		ORDER BY day, organization, route`, organization, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := []Summary{}
+	for rows.Next() {
+		var summary Summary
+		if err := rows.Scan(&summary.Organization, &summary.Day, &summary.Route, &summary.Requests, &summary.Errors); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
+
+// Close closes the database connections
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
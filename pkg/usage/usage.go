@@ -0,0 +1,110 @@
+package usage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Record is a call of the API accounted to an organization
+type Record struct {
+	Organization string
+	KeyID        string
+
+	// Route is the pattern of the route, such as "POST /chat"
+	Route     string
+	Status    int
+	CreatedAt time.Time
+}
+
+// Summary counts the calls of an organization to a route during a day
+type Summary struct {
+	Organization string `json:"organization"`
+
+	// Day is the UTC date of the calls, formatted as 2006-01-02
+	Day      string `json:"day"`
+	Route    string `json:"route"`
+	Requests int    `json:"requests"`
+
+	// Errors are the calls answered with a 4xx or 5xx status
+	Errors int `json:"errors"`
+}
+
+// Store keeps the usage of the organizations
+type Store interface {
+	Record(ctx context.Context, record Record) error
+
+	// Summary returns the daily usage between from, inclusive, and to, exclusive, of the organization,
+	// or of every organization when it is empty, ordered by day, organization and route
+	Summary(ctx context.Context, organization string, from, to time.Time) ([]Summary, error)
+}
+
+// Open returns the Postgres store when the URL of the database is set and an in memory store otherwise
+func Open(ctx context.Context, url string) (Store, error) {
+	if url == "" {
+		return NewMemoryStore(), nil
+	}
+	return OpenPostgres(ctx, url)
+}
+
+// MemoryStore keeps the usage in memory, it is lost when the API stops
+type MemoryStore struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+// NewMemoryStore creates an empty in memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Record records the call
+func (s *MemoryStore) Record(_ context.Context, record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, record)
+	return nil
+}
+
+// Summary returns the daily usage of the organization between from and to
+func (s *MemoryStore) Summary(_ context.Context, organization string, from, to time.Time) ([]Summary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type group struct{ organization, day, route string }
+	counts := map[group]*Summary{}
+	for _, record := range s.records {
+		if (organization != "" && record.Organization != organization) || record.CreatedAt.Before(from) || !record.CreatedAt.Before(to) {
+			continue
+		}
+
+		g := group{record.Organization, record.CreatedAt.UTC().Format(time.DateOnly), record.Route}
+		summary, ok := counts[g]
+		if !ok {
+			summary = &Summary{Organization: g.organization, Day: g.day, Route: g.route}
+			counts[g] = summary
+		}
+		summary.Requests++
+		if record.Status >= 400 {
+			summary.Errors++
+		}
+	}
+
+	summaries := []Summary{}
+	for _, summary := range counts {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.Organization != b.Organization {
+			return a.Organization < b.Organization
+		}
+		return a.Route < b.Route
+	})
+	return summaries, nil
+}
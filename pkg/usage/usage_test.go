@@ -0,0 +1,38 @@
+package usage
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_MemoryStore_Summary(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	records := []Record{
+		{Organization: "acme", KeyID: "key-1", Route: "POST /chat", Status: 200, CreatedAt: day.Add(time.Hour)},
+		{Organization: "acme", KeyID: "key-2", Route: "POST /chat", Status: 429, CreatedAt: day.Add(2 * time.Hour)},
+		{Organization: "acme", KeyID: "key-1", Route: "POST /chat", Status: 200, CreatedAt: day.Add(25 * time.Hour)},
+		{Organization: "other", KeyID: "key-3", Route: "POST /chat", Status: 200, CreatedAt: day.Add(time.Hour)},
+		{Organization: "acme", KeyID: "key-1", Route: "POST /chat", Status: 200, CreatedAt: day.Add(-time.Hour)},
+	}
+	for _, record := range records {
+		require.NoError(t, store.Record(ctx, record))
+	}
+
+	summaries, err := store.Summary(ctx, "acme", day, day.Add(48*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []Summary{
+		{Organization: "acme", Day: "2024-05-01", Route: "POST /chat", Requests: 2, Errors: 1},
+		{Organization: "acme", Day: "2024-05-02", Route: "POST /chat", Requests: 1},
+	}, summaries)
+
+	// Every organization is summarized when none is given
+	summaries, err = store.Summary(ctx, "", day, day.Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, summaries, 2)
+}
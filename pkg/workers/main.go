@@ -1,39 +1,214 @@
 package main
 
 import (
+	"code-challenge/pkg/batch"
+	"code-challenge/pkg/config"
+	"code-challenge/pkg/conversations"
+	"code-challenge/pkg/deadletter"
+	"code-challenge/pkg/health"
+	"code-challenge/pkg/logging"
+	"code-challenge/pkg/metrics"
+	"code-challenge/pkg/notify"
+	"code-challenge/pkg/openai"
+	"code-challenge/pkg/search"
+	"code-challenge/pkg/temporalclient"
+	"code-challenge/pkg/tools"
+	"code-challenge/pkg/tracing"
 	codingchallenge "code-challenge/pkg/workflow"
+	"context"
+	"errors"
+	"flag"
 	client2 "go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
 	"go.temporal.io/sdk/worker"
-	"log"
+	"go.temporal.io/sdk/workflow"
+	"log/slog"
+	"net/http"
 	"os"
+	"time"
 )
 
-// Starts the worker that listens to the task queue "chat_bot_workflow_task_queue"
+// Starts the pools of the worker selected by -worker-pools, each listening to its own task queue
 func main() {
+	// Log JSON lines correlated by request ID, the Temporal client and the workers log through the same logger
+	logger := logging.Setup()
+
+	// Load the configuration from the YAML file, the environment and the flags, the errors list every invalid value
+	cfg, err := config.Load("chatbot-worker", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		logging.Fatal("Invalid configuration", "Error", err)
+	}
+	openai.Configure(cfg.LLM)
+	codingchallenge.Configure(cfg.Policies, cfg.Temporal.TaskQueues)
+
+	// Trace the questions from the HTTP request to the calls to the model
+	shutdownTracing, err := tracing.Setup(context.Background(), "chatbot-worker")
+	if err != nil {
+		logging.Fatal("Unable to configure tracing", "Error", err)
+	}
+	defer shutdownTracing(context.Background())
+	tracingInterceptor, err := tracing.TemporalInterceptor()
+	if err != nil {
+		logging.Fatal("Unable to configure tracing", "Error", err)
+	}
+
+	// Connect to Temporal with TLS and the API key of Temporal Cloud when they are configured,
+	// the rotated certificates and API key are reloaded without restarting
+	options, err := temporalclient.Options(cfg.Temporal)
+	if err != nil {
+		logging.Fatal("Unable to configure the connection to Temporal", "Error", err)
+	}
+
+	// The metrics of the workflows and activities are reported by the SDK with the LLM metrics
+	metricsHandler, metricsCloser := metrics.TemporalHandler()
+	defer metricsCloser.Close()
+	options.MetricsHandler = metricsHandler
+	options.Interceptors = []interceptor.ClientInterceptor{tracingInterceptor}
+	options.Logger = logging.TemporalLogger(logger)
+	options.ContextPropagators = []workflow.ContextPropagator{logging.NewPropagator()}
+
 	// Dial creates a new Temporal client with the provided options
-	client, err := client2.Dial(client2.Options{
-		HostPort:  os.Getenv("TEMPORAL_HOST_PORT"),
-		Namespace: os.Getenv("TEMPORAL_NAMESPACE"),
-	})
+	client, err := client2.Dial(options)
 	defer client.Close() // Ensure the client is closed when the function exits
 
 	// Check if there was an error initializing the Temporal client
 	if err != nil {
-		log.Fatalln("Unable to initialize client", err)
+		logging.Fatal("Unable to initialize client", "Error", err)
 	}
 
-	// Create a new worker that listens to the specified task queue
-	w := worker.New(client, "chat_bot_workflow_task_queue", worker.Options{})
+	// The workflows kept in memory are shared by the pools of the process
+	worker.SetStickyWorkflowCacheSize(cfg.Worker.StickyCacheSize)
 
-	// Register the ChatBotWorkflow with the worker
-	w.RegisterWorkflow(codingchallenge.ChatBotWorkflow)
+	// Create a worker per pool run by the process, each listening to its own task queue
+	var workers []worker.Worker
+	var queues []health.Queue
+	if cfg.Worker.Runs(config.PoolWorkflows) {
+		// With build ID versioning the workflows in flight keep running on the workers of a compatible build ID,
+		// the activities of the LLM pool run on any worker
+		options := workerOptions(cfg.Worker.Workflows, cfg.Worker.BuildID)
+		options.UseBuildIDForVersioning = cfg.Worker.UseBuildIDVersioning
+		w := worker.New(client, cfg.Temporal.TaskQueues.Chat, options)
 
-	// Register the ChatActivity with the worker
-	w.RegisterActivity(codingchallenge.ChatActivity)
+		// The workflows upsert the search attributes of the conversations, a workflow upserting an unknown one is blocked.
+		// They are only added when enabled, the API keys of Temporal Cloud are not allowed to, otherwise the missing ones are logged.
+		searchCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if cfg.Worker.RegisterSearchAttributes {
+			if err := search.Register(searchCtx, client, cfg.Temporal.Namespace); err != nil {
+				logging.Fatal("Unable to register the search attributes, disable worker.register_search_attributes and create them beforehand", "Error", err)
+			}
+		} else if missing, err := search.Missing(searchCtx, client, cfg.Temporal.Namespace); err != nil {
+			slog.Warn("Unable to check the search attributes", "Error", err)
+		} else if len(missing) > 0 {
+			slog.Error("Search attributes missing from the namespace, the conversations are blocked until they are created",
+				"Namespace", cfg.Temporal.Namespace, "Missing", missing)
+		}
+		cancel()
 
-	// Run the worker and listen for interrupt signals
-	err = w.Run(worker.InterruptCh())
-	if err != nil {
-		log.Fatalln("Unable to start worker", err)
+		// Register the ChatBotWorkflow with the worker
+		w.RegisterWorkflow(codingchallenge.ChatBotWorkflow)
+
+		// Register the DeferredAnswerWorkflow answering the questions queued during outages
+		w.RegisterWorkflow(codingchallenge.DeferredAnswerWorkflow)
+
+		// Register the DeliverAnswer activity with the conversation history and the notification channels
+		store, err := conversations.Open(context.Background(), cfg.DatabaseURL)
+		if err != nil {
+			logging.Fatal("Unable to open conversation history", "Error", err)
+		}
+		notifier := notify.FromEnv()
+		notifier.WebhookHosts = cfg.Worker.WebhookHosts
+		w.RegisterActivity(&codingchallenge.Deliverer{Store: store, Notifier: notifier})
+
+		// Register the RecordDeadLetter activity keeping the questions that could not be answered
+		letters, err := deadletter.Open(context.Background(), cfg.DatabaseURL)
+		if err != nil {
+			logging.Fatal("Unable to open dead letters", "Error", err)
+		}
+		w.RegisterActivity(&codingchallenge.DeadLetters{Store: letters})
+
+		// Register the BatchQuestionsWorkflow answering the questions of a JSON lines file with its activities,
+		// the results of the batches in flight are kept in the store until their output is written
+		w.RegisterWorkflow(codingchallenge.BatchQuestionsWorkflow)
+		results, err := batch.Open(context.Background(), cfg.DatabaseURL)
+		if err != nil {
+			logging.Fatal("Unable to open batch results", "Error", err)
+		}
+		w.RegisterActivity(&codingchallenge.Batches{Store: results, Files: &batch.Files{
+			Dir:        cfg.Worker.BatchDir,
+			HTTPClient: &http.Client{Timeout: time.Minute},
+			Hosts:      cfg.Worker.BatchHosts,
+		}})
+
+		// Register one activity per tool the model can call
+		tools.RegisterActivities(w)
+
+		workers = append(workers, w)
+		queues = append(queues, health.WorkflowQueue(cfg.Temporal.TaskQueues.Chat))
+	}
+	if cfg.Worker.Runs(config.PoolLLM) {
+		// The calls to the models have their own pool, its rate limit protects the rate limit of the OpenAI organization
+		w := worker.New(client, cfg.Temporal.TaskQueues.LLM, workerOptions(cfg.Worker.LLM, cfg.Worker.BuildID))
+
+		// Register the ChatActivity with the worker
+		w.RegisterActivity(codingchallenge.ChatActivity)
+
+		workers = append(workers, w)
+		queues = append(queues, health.ActivityQueue(cfg.Temporal.TaskQueues.LLM))
+	}
+
+	// Serve the probes of Kubernetes, the worker is ready once Temporal is reachable and the pollers of its pools are seen,
+	// and the metrics scraped by Prometheus
+	healthServer := serve(cfg.Worker.HealthAddr, health.NewChecker(health.Dependencies(client, cfg.Temporal.Namespace, queues...)...).Handler())
+	metricsServer := serve(cfg.Worker.MetricsAddr, metrics.Handler())
+
+	// Start the workers and stop them on interrupt signals
+	for _, w := range workers {
+		if err := w.Start(); err != nil {
+			logging.Fatal("Unable to start worker", "Error", err)
+		}
+	}
+	slog.Info("Worker started", "Pools", cfg.Worker.Pools, "BuildID", cfg.Worker.BuildID)
+	<-worker.InterruptCh()
+	for _, w := range workers {
+		w.Stop()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, server := range []*http.Server{healthServer, metricsServer} {
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("Unable to stop server", "Addr", server.Addr, "Error", err)
+		}
+	}
+}
+
+// workerOptions returns the options of the worker of a pool, the loggers of the workflows and activities
+// include the request ID and the user propagated by the API
+func workerOptions(pool config.Pool, buildID string) worker.Options {
+	return worker.Options{
+		BuildID:                                buildID,
+		MaxConcurrentWorkflowTaskExecutionSize: pool.MaxConcurrentWorkflowTasks,
+		MaxConcurrentActivityExecutionSize:     pool.MaxConcurrentActivities,
+		WorkerActivitiesPerSecond:              pool.WorkerActivitiesPerSecond,
+		TaskQueueActivitiesPerSecond:           pool.TaskQueueActivitiesPerSecond,
+		Interceptors:                           []interceptor.WorkerInterceptor{logging.NewInterceptor()},
+	}
+}
+
+// serve serves the handler on the address in the background
+func serve(addr string, handler http.Handler) *http.Server {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
 	}
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			logging.Fatal("Unable to serve", "Addr", addr, "Error", err)
+		}
+	}()
+	return server
 }
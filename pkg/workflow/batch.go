@@ -0,0 +1,283 @@
+package workflow
+
+import (
+	"code-challenge/pkg/batch"
+	"context"
+	"encoding/json"
+	"errors"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BatchProgressQuery is the query returning the BatchProgress of a BatchQuestionsWorkflow
+const BatchProgressQuery = "progress"
+
+// Concurrency of the BatchQuestionsWorkflow: the questions answered at once
+const (
+	DefaultBatchConcurrency = 5
+	MaxBatchConcurrency     = 50
+)
+
+// batchChunkSize is the number of questions read and answered by a run, the next ones are answered by a new run
+// started with continue-as-new so the history of a batch does not grow with its input
+const batchChunkSize = 100
+
+// BatchInputErrorType is the error type of the inputs and outputs that do not exist or refuse the requests
+const BatchInputErrorType = "BatchInput"
+
+// BatchInput is the input to the BatchQuestionsWorkflow
+type BatchInput struct {
+	// Input is the JSON lines of ChatBotQuestion and Output where the results are written as JSON lines,
+	// both are paths in the batch directory of the worker or http and https URLs
+	Input  string
+	Output string
+
+	// Concurrency is the number of questions answered at once, DefaultBatchConcurrency when zero
+	Concurrency int
+
+	// UserPrefix is added to the user of each question, such as "acme:" for the questions of an organization
+	UserPrefix string
+
+	// Offset and Line are the position in the input of the next question, they are set with the Progress
+	// by the previous run when the workflow continues as new
+	Offset   int64
+	Line     int
+	Progress BatchProgress
+}
+
+// BatchProgress is the progress of a BatchQuestionsWorkflow, returned by the progress query and as its result
+type BatchProgress struct {
+	Input  string
+	Output string
+
+	// Questions is the number of questions read so far, Running the ones being answered
+	Questions int
+	Answered  int
+	Queued    int
+	Failed    int
+	Running   int
+
+	// Done is set once the results are written to the output
+	Done bool
+}
+
+// BatchRead is the input to the ReadQuestions activity
+type BatchRead struct {
+	Input  string
+	Offset int64
+	Line   int
+	Limit  int
+}
+
+// BatchResults is the input to the SaveResults activity
+type BatchResults struct {
+	BatchID string
+	Results []batch.Result
+}
+
+// BatchOutput is the input to the WriteOutput activity
+type BatchOutput struct {
+	BatchID string
+	Output  string
+}
+
+// Batches holds the dependencies of the activities of the BatchQuestionsWorkflow
+type Batches struct {
+	Store batch.Store
+	Files *batch.Files
+}
+
+// BatchQuestionsWorkflow is a Temporal workflow that answers the questions of a JSON lines input, each of them by a
+// ChatBotWorkflow child with at most Concurrency children at once, and writes their results to the output in the order
+// of the input. The questions are read by chunks, each chunk is answered by a run of the workflow which continues as
+// new with the next one: the results of the chunks are saved in the batch store until the output is written.
+// A batch survives the crashes of the workers, the children and the results already saved are not run again.
+func BatchQuestionsWorkflow(ctx workflow.Context, input BatchInput) (*BatchProgress, error) {
+	logger := workflow.GetLogger(ctx)
+	batchID := workflow.GetInfo(ctx).WorkflowExecution.ID
+
+	progress := input.Progress
+	progress.Input, progress.Output = input.Input, input.Output
+	if err := workflow.SetQueryHandler(ctx, BatchProgressQuery, func() (BatchProgress, error) {
+		return progress, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	activityCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout:    5 * time.Minute,
+		ScheduleToCloseTimeout: time.Hour,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:        time.Second,
+			BackoffCoefficient:     2.0,
+			MaximumInterval:        time.Minute,
+			NonRetryableErrorTypes: []string{BatchInputErrorType},
+		},
+	})
+	var b *Batches
+
+	// Read the next chunk of questions
+	var chunk batch.Chunk
+	read := BatchRead{Input: input.Input, Offset: input.Offset, Line: input.Line, Limit: batchChunkSize}
+	if err := workflow.ExecuteActivity(activityCtx, b.ReadQuestions, read).Get(ctx, &chunk); err != nil {
+		logger.Error("Unable to read the questions.", "Input", input.Input, "Error", err)
+		return nil, err
+	}
+
+	// Answer the questions of the chunk, at most concurrency of them at once
+	concurrency := input.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+	results := make([]batch.Result, 0, len(chunk.Lines))
+	selector := workflow.NewSelector(ctx)
+	running := 0
+	for _, line := range chunk.Lines {
+		progress.Questions++
+		question, err := batchQuestion(line, input.UserPrefix)
+		if err != nil {
+			progress.Failed++
+			results = append(results, batch.Result{Line: line.Number, Status: StatusFailed, Error: err.Error()})
+			continue
+		}
+
+		for ; running >= concurrency; running-- {
+			selector.Select(ctx)
+		}
+
+		// The ID of the child is derived from the line, a batch resumed or reset does not ask it twice at once
+		childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+			WorkflowID:        batchID + "_" + strconv.Itoa(line.Number),
+			ParentClosePolicy: enums.PARENT_CLOSE_POLICY_REQUEST_CANCEL,
+		})
+		child := workflow.ExecuteChildWorkflow(childCtx, ChatBotWorkflow, question)
+		running++
+		progress.Running++
+		selector.AddFuture(child, func(f workflow.Future) {
+			progress.Running--
+			result := batchResult(ctx, line.Number, question, child, f)
+			switch result.Status {
+			case StatusAnswered:
+				progress.Answered++
+			case StatusQueued:
+				progress.Queued++
+			default:
+				progress.Failed++
+			}
+			results = append(results, result)
+		})
+	}
+	for ; running > 0; running-- {
+		selector.Select(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, temporal.NewCanceledError()
+	}
+
+	// Keep the results of the chunk until the output is written
+	sort.Slice(results, func(i, j int) bool { return results[i].Line < results[j].Line })
+	if err := workflow.ExecuteActivity(activityCtx, b.SaveResults, BatchResults{BatchID: batchID, Results: results}).Get(ctx, nil); err != nil {
+		logger.Error("Unable to save the results.", "Error", err)
+		return nil, err
+	}
+
+	// Answer the next chunk in a new run
+	if !chunk.EOF {
+		logger.Info("Chunk answered, continuing with the next one.", "Questions", progress.Questions, "Line", chunk.Line)
+		input.Offset, input.Line, input.Progress = chunk.Offset, chunk.Line, progress
+		return nil, workflow.NewContinueAsNewError(ctx, BatchQuestionsWorkflow, input)
+	}
+
+	if err := workflow.ExecuteActivity(activityCtx, b.WriteOutput, BatchOutput{BatchID: batchID, Output: input.Output}).Get(ctx, nil); err != nil {
+		logger.Error("Unable to write the output.", "Output", input.Output, "Error", err)
+		return nil, err
+	}
+	progress.Done = true
+
+	logger.Info("BatchQuestionsWorkflow completed.", "Questions", progress.Questions, "Answered", progress.Answered,
+		"Queued", progress.Queued, "Failed", progress.Failed)
+	return &progress, nil
+}
+
+// batchQuestion decodes the question of the line and adds the prefix to its user
+func batchQuestion(line batch.Line, userPrefix string) (ChatBotQuestion, error) {
+	var question ChatBotQuestion
+	if line.Error != "" {
+		return question, errors.New(line.Error)
+	}
+	if err := json.Unmarshal(line.Data, &question); err != nil {
+		return question, errors.New("the line is not a question")
+	}
+
+	question.User, question.Question = strings.TrimSpace(question.User), strings.TrimSpace(question.Question)
+	switch {
+	case question.Question == "":
+		return question, errors.New("question must not be empty")
+	case question.User == "":
+		return question, errors.New("user must not be empty")
+	case question.Format != "" && question.Format != FormatText && question.Format != FormatStructured:
+		return question, errors.New(`format must be "text" or "structured"`)
+	case !ValidPromptVersion(question.PromptVersion):
+		return question, errors.New("prompt version must be one of " + strings.Join(PromptVersions(), ", "))
+	}
+	question.User = userPrefix + question.User
+	return question, nil
+}
+
+// batchResult returns the result of the child answering the question of the line
+func batchResult(ctx workflow.Context, line int, question ChatBotQuestion, child workflow.ChildWorkflowFuture, f workflow.Future) batch.Result {
+	result := batch.Result{Line: line, User: question.User, Question: question.Question}
+
+	var execution workflow.Execution
+	if err := child.GetChildWorkflowExecution().Get(ctx, &execution); err == nil {
+		result.WorkflowID = execution.ID
+	}
+
+	var answer ChatBotAnswer
+	if err := f.Get(ctx, &answer); err != nil {
+		result.Status, result.Error = StatusFailed, err.Error()
+		return result
+	}
+	result.Status, result.Answer, result.Model, result.DeferredWorkflowID = answer.Status, answer.Answer, answer.Model, answer.DeferredWorkflowID
+	return result
+}
+
+// ReadQuestions is a Temporal activity that reads a chunk of the questions of the input
+func (b *Batches) ReadQuestions(ctx context.Context, read BatchRead) (*batch.Chunk, error) {
+	chunk, err := b.Files.Read(ctx, read.Input, read.Offset, read.Line, read.Limit)
+	if errors.Is(err, batch.ErrRejected) {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), BatchInputErrorType, err)
+	}
+	return chunk, err
+}
+
+// SaveResults is a Temporal activity that keeps the results of a chunk until the output is written
+func (b *Batches) SaveResults(ctx context.Context, results BatchResults) error {
+	return b.Store.Save(ctx, results.BatchID, results.Results)
+}
+
+// WriteOutput is a Temporal activity that writes the results of the batch to the output then removes them from the store
+func (b *Batches) WriteOutput(ctx context.Context, output BatchOutput) error {
+	results, err := b.Store.Results(ctx, output.BatchID)
+	if err != nil {
+		return err
+	}
+	if err := b.Files.Write(ctx, output.Output, results); err != nil {
+		if errors.Is(err, batch.ErrRejected) {
+			return temporal.NewNonRetryableApplicationError(err.Error(), BatchInputErrorType, err)
+		}
+		return err
+	}
+
+	// The output holds the results, a failure to remove them only keeps them in the store
+	if err := b.Store.Delete(ctx, output.BatchID); err != nil {
+		activity.GetLogger(ctx).Warn("Unable to delete the results of the batch.", "BatchID", output.BatchID, "Error", err)
+	}
+	return nil
+}
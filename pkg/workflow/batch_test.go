@@ -0,0 +1,145 @@
+package workflow
+
+import (
+	"code-challenge/pkg/batch"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// answerBatchQuestion answers the questions of the batch, the questions ending with "later" are queued
+// and the ones ending with "fail" fail
+func answerBatchQuestion(_ workflow.Context, question ChatBotQuestion) (*ChatBotAnswer, error) {
+	switch {
+	case strings.HasSuffix(question.Question, "fail"):
+		return nil, errors.New("API error")
+	case strings.HasSuffix(question.Question, "later"):
+		return &ChatBotAnswer{User: question.User, Status: StatusQueued, DeferredWorkflowID: "deferred_1"}, nil
+	}
+	return &ChatBotAnswer{User: question.User, Answer: "Answer to " + question.Question, Status: StatusAnswered, Model: "gpt-4o"}, nil
+}
+
+func Test_BatchQuestionsWorkflow(t *testing.T) {
+	dir := t.TempDir()
+	input := `{"user": "alice", "question": "How do I renew my visa?"}
+
+{"user": "bob", "question": "Ask me later"}
+not json
+{"user": "carol", "question": ""}
+{"user": "dave", "question": "This will fail"}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "input.jsonl"), []byte(input), 0o600))
+
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+	store := batch.NewMemoryStore()
+	env.RegisterActivity(&Batches{Store: store, Files: &batch.Files{Dir: dir}})
+	env.OnWorkflow(ChatBotWorkflow, mock.Anything, mock.Anything).Return(answerBatchQuestion)
+
+	env.ExecuteWorkflow(BatchQuestionsWorkflow, BatchInput{Input: "input.jsonl", Output: "output.jsonl", Concurrency: 2, UserPrefix: "acme:"})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	var progress BatchProgress
+	require.NoError(t, env.GetWorkflowResult(&progress))
+	assert.Equal(t, BatchProgress{Input: "input.jsonl", Output: "output.jsonl", Questions: 5, Answered: 1, Queued: 1, Failed: 3, Done: true}, progress)
+
+	// The output has a result per question in the order of the input, the results are removed from the store
+	data, err := os.ReadFile(filepath.Join(dir, "output.jsonl"))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 5)
+
+	var results []batch.Result
+	for _, line := range lines {
+		var result batch.Result
+		require.NoError(t, json.Unmarshal([]byte(line), &result))
+		results = append(results, result)
+	}
+	assert.Equal(t, batch.Result{Line: 1, User: "acme:alice", Question: "How do I renew my visa?", WorkflowID: "default-test-workflow-id_1",
+		Status: StatusAnswered, Answer: "Answer to How do I renew my visa?", Model: "gpt-4o"}, results[0])
+	assert.Equal(t, batch.Result{Line: 3, User: "acme:bob", Question: "Ask me later", WorkflowID: "default-test-workflow-id_3",
+		Status: StatusQueued, DeferredWorkflowID: "deferred_1"}, results[1])
+	assert.Equal(t, batch.Result{Line: 4, Status: StatusFailed, Error: "the line is not valid JSON"}, results[2])
+	assert.Equal(t, batch.Result{Line: 5, Status: StatusFailed, Error: "question must not be empty"}, results[3])
+	assert.Equal(t, 6, results[4].Line)
+	assert.Equal(t, StatusFailed, results[4].Status)
+	assert.Contains(t, results[4].Error, "API error")
+
+	saved, err := store.Results(context.Background(), "default-test-workflow-id")
+	require.NoError(t, err)
+	assert.Empty(t, saved)
+}
+
+func Test_BatchQuestionsWorkflow_ContinueAsNew(t *testing.T) {
+	dir := t.TempDir()
+	var input strings.Builder
+	for i := 1; i <= batchChunkSize+20; i++ {
+		fmt.Fprintf(&input, `{"user": "user_%d", "question": "Question %d"}`+"\n", i, i)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "input.jsonl"), []byte(input.String()), 0o600))
+	store := batch.NewMemoryStore()
+
+	// The first run answers a chunk, saves its results and continues with the next one
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+	env.RegisterActivity(&Batches{Store: store, Files: &batch.Files{Dir: dir}})
+	env.OnWorkflow(ChatBotWorkflow, mock.Anything, mock.Anything).Return(answerBatchQuestion)
+	env.ExecuteWorkflow(BatchQuestionsWorkflow, BatchInput{Input: "input.jsonl", Output: "output.jsonl"})
+
+	var continueAsNew *workflow.ContinueAsNewError
+	require.ErrorAs(t, env.GetWorkflowError(), &continueAsNew)
+	var next BatchInput
+	require.NoError(t, converter.GetDefaultDataConverter().FromPayloads(continueAsNew.Input, &next))
+	assert.Equal(t, batchChunkSize, next.Line)
+	assert.Equal(t, BatchProgress{Input: "input.jsonl", Output: "output.jsonl", Questions: batchChunkSize, Answered: batchChunkSize}, next.Progress)
+
+	saved, err := store.Results(context.Background(), "default-test-workflow-id")
+	require.NoError(t, err)
+	assert.Len(t, saved, batchChunkSize)
+
+	// The next run answers the rest from the offset and writes every result
+	env = ts.NewTestWorkflowEnvironment()
+	env.RegisterActivity(&Batches{Store: store, Files: &batch.Files{Dir: dir}})
+	env.OnWorkflow(ChatBotWorkflow, mock.Anything, mock.MatchedBy(func(question ChatBotQuestion) bool {
+		return question.Question != "Question 1"
+	})).Return(answerBatchQuestion).Times(20)
+	env.ExecuteWorkflow(BatchQuestionsWorkflow, next)
+
+	require.NoError(t, env.GetWorkflowError())
+	var progress BatchProgress
+	require.NoError(t, env.GetWorkflowResult(&progress))
+	assert.Equal(t, batchChunkSize+20, progress.Answered)
+	env.AssertExpectations(t)
+
+	data, err := os.ReadFile(filepath.Join(dir, "output.jsonl"))
+	require.NoError(t, err)
+	assert.Equal(t, batchChunkSize+20, strings.Count(string(data), "\n"))
+}
+
+func Test_BatchQuestionsWorkflow_InputNotFound(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+	env.RegisterActivity(&Batches{Store: batch.NewMemoryStore(), Files: &batch.Files{Dir: t.TempDir()}})
+
+	env.ExecuteWorkflow(BatchQuestionsWorkflow, BatchInput{Input: "missing.jsonl", Output: "output.jsonl"})
+
+	// The missing input is not retried
+	assert.True(t, env.IsWorkflowCompleted())
+	var applicationErr *temporal.ApplicationError
+	require.ErrorAs(t, env.GetWorkflowError(), &applicationErr)
+	assert.Equal(t, BatchInputErrorType, applicationErr.Type())
+}
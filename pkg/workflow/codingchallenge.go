@@ -1,83 +1,393 @@
 package workflow
 
 import (
+	"code-challenge/pkg/answers"
+	"code-challenge/pkg/config"
+	"code-challenge/pkg/notify"
 	"code-challenge/pkg/openai"
+	"code-challenge/pkg/tools"
 	"context"
+	"errors"
+	"fmt"
 	"go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// maxSteps limits how many times the model is called in a conversation, the last call has no tool so the model has to answer
+const maxSteps = 6
+
+// heartbeatInterval is the interval of the ChatActivity heartbeats, the cancellation of the workflow is delivered with them
+const heartbeatInterval = 5 * time.Second
+
+// maxRepairs limits how many times the model is asked to fix a structured answer that does not match the schema
+const maxRepairs = 2
+
+// chatOptions holds the options of the ChatActivity: the task queue of the LLM pool, the timeouts and the retry policy.
+// They are replaced by Configure when the worker starts and are read by the workflows without side effect:
+// the task queue and the options of the activities are not checked when a workflow is replayed.
+var chatOptions atomic.Pointer[workflow.ActivityOptions]
+
+func init() {
+	defaults := config.Default()
+	Configure(defaults.Policies, defaults.Temporal.TaskQueues)
+}
+
+// Configure sets the task queues, the timeouts and the retry policies of the activities
+func Configure(policies config.Policies, taskQueues config.TaskQueues) {
+	// Once the attempts on a provider are exhausted the next provider is tried
+	opts := activityOptions(&policies.Chat)
+	opts.TaskQueue = taskQueues.LLM
+	opts.HeartbeatTimeout = 2 * heartbeatInterval // Detects lost workers and delivers cancellations
+	opts.RetryPolicy.NonRetryableErrorTypes = []string{CircuitOpenErrorType, RequestRejectedErrorType}
+	chatOptions.Store(&opts)
+}
+
+// activityOptions returns the options of an activity with the policy
+func activityOptions(policy *config.Activity) workflow.ActivityOptions {
+	return workflow.ActivityOptions{
+		StartToCloseTimeout:    policy.StartToCloseTimeout,
+		ScheduleToCloseTimeout: policy.ScheduleToCloseTimeout,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    policy.Retry.InitialInterval,
+			BackoffCoefficient: policy.Retry.BackoffCoefficient,
+			MaximumInterval:    policy.Retry.MaximumInterval,
+			MaximumAttempts:    policy.Retry.MaximumAttempts,
+		},
+	}
+}
+
+// Answer formats accepted in ChatBotQuestion.Format
+const (
+	FormatText       = "text"
+	FormatStructured = "structured"
+)
+
+// Statuses of ChatBotAnswer
+const (
+	StatusAnswered = "answered"
+	StatusQueued   = "queued"
+	StatusFailed   = "failed"
+)
+
 // ChatBotQuestion is the input to the ChatBotWorkflow.
 type ChatBotQuestion struct {
 	User     string
 	Question string
+
+	// Format is FormatText, the default, or FormatStructured to also get the answer as sections
+	Format string
+
+	// Notify is where the answer is delivered when the question is queued during an outage
+	Notify notify.Channel
+
+	// Name and Locale come from the profile of the authenticated user and personalize the answer
+	Name   string
+	Locale string
+
+	// Model restricts the answer to the providers serving this configured model and PromptVersion overrides the
+	// default system prompt, such as to replay a failed question against the fallback model
+	Model         string
+	PromptVersion string
 }
 
 // ChatBotAnswer is the response from the ChatBotWorkflow.
 type ChatBotAnswer struct {
 	User   string
 	Answer string
+
+	// Status is StatusAnswered, or StatusQueued when the answer is delivered later by the DeferredWorkflowID workflow
+	Status             string
+	DeferredWorkflowID string
+
+	// Structured is set when the structured format was requested and the model output matched the schema
+	Structured *answers.StructuredAnswer
+
+	// Provider and Model answered the question, they differ from the primary ones when it was unavailable
+	Provider string
+	Model    string
 }
 
-// ChatActivity is a Temporal activity that calls the OpenAI API to get an answer to a question.
-func ChatActivity(ctx context.Context, question string) (*string, error) {
+// Error types of the ChatActivity that are not retried, the workflow falls through to the next provider instead
+const (
+	CircuitOpenErrorType     = "CircuitOpen"
+	RequestRejectedErrorType = "RequestRejected"
+)
+
+// AllProvidersFailedErrorType is the error type returned when no provider could answer
+const AllProvidersFailedErrorType = "AllProvidersFailed"
+
+// ChatActivity is a Temporal activity that calls the requested provider with the conversation and the tools the model may call.
+func ChatActivity(ctx context.Context, request openai.ChatRequest) (*openai.Completion, error) {
 	logger := activity.GetLogger(ctx)
-	logger.Info("ChatActivity started.", "Question", question)
+	logger.Info("ChatActivity started.", "Provider", request.Provider, "Messages", len(request.Messages), "Tools", len(request.Tools))
+
+	// Heartbeat while waiting for the model so the cancellation of the workflow reaches the activity
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go heartbeat(ctx, heartbeatInterval)
 
-	ans, err := openai.GetAnswerFromGpt(question)
+	ans, err := openai.GetCompletionFromGpt(ctx, request)
 
 	if err != nil {
-		logger.Error("Not able to retrieve answers from GPT.", "Error", err)
+		// The workflow was canceled, the model call was aborted
+		if ctx.Err() != nil {
+			logger.Info("ChatActivity canceled.", "Provider", request.Provider)
+			return nil, ctx.Err()
+		}
+
+		logger.Error("Not able to retrieve answers from GPT.", "Provider", request.Provider, "Error", err)
+
+		// Retrying a provider whose circuit is open or which rejected the request does not help
+		if errors.Is(err, openai.ErrCircuitOpen) {
+			return nil, temporal.NewNonRetryableApplicationError(err.Error(), CircuitOpenErrorType, err)
+		}
+		if !openai.IsProviderFailure(err) {
+			return nil, temporal.NewNonRetryableApplicationError(err.Error(), RequestRejectedErrorType, err)
+		}
 		return nil, err
 	}
 
 	return ans, nil
 }
 
+// heartbeat records a heartbeat of the activity every interval until the context is done
+func heartbeat(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			activity.RecordHeartbeat(ctx)
+		}
+	}
+}
+
 // ChatBotWorkflow is a Temporal workflow that orchestrates the ChatActivity to get an answer to a question.
-func ChatBotWorkflow(ctx workflow.Context, input ChatBotQuestion) (*ChatBotAnswer, error) {
-	// Define a retry policy for the workflow activities
-	retryPolicy := &temporal.RetryPolicy{
-		InitialInterval:        time.Second,       // Initial interval between retries
-		BackoffCoefficient:     2.0,               // Exponential backoff coefficient
-		MaximumInterval:        time.Second * 100, // Maximum interval between retries
-		MaximumAttempts:        0,                 // Unlimited retry attempts
-		NonRetryableErrorTypes: []string{},        // List of non-retryable error types
+// The model can call the tools it is offered, each call runs as an activity and its result is sent back to the model.
+// When no provider can answer the question is queued: a DeferredAnswerWorkflow keeps trying and delivers the answer later.
+// A question that fails is recorded as a dead letter to be replayed.
+// The conversation is searchable by its user, the topic of the question and its outcome.
+func ChatBotWorkflow(ctx workflow.Context, input ChatBotQuestion) (answer *ChatBotAnswer, err error) {
+	// Get a logger instance for the workflow context
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting ChatBotWorkflow", "User", input.User, "QuestionLength", len(input.Question), "Format", input.Format)
+
+	if workflow.GetVersion(ctx, changeSearchAttributes, workflow.DefaultVersion, 1) == 1 {
+		upsertQuestion(ctx, input)
+		defer func() { upsertOutcome(ctx, answer, err) }()
+	}
+
+	// The question that could not be answered is kept to be replayed
+	if workflow.GetVersion(ctx, changeDeadLetter, workflow.DefaultVersion, 1) == 1 {
+		info := workflow.GetInfo(ctx)
+		defer func() { recordDeadLetter(ctx, input, info.WorkflowStartTime, int(info.Attempt), err) }()
 	}
 
-	// Set activity options including timeouts and retry policy
-	opts := workflow.ActivityOptions{
-		StartToCloseTimeout:    30 * time.Second,  // Timeout for each activity execution
-		ScheduleToCloseTimeout: 180 * time.Second, // Total timeout for the activity
-		RetryPolicy:            retryPolicy,       // Apply the defined retry policy
+	workflowResult, err := answerQuestion(ctx, input)
+
+	// Every provider is down, answer later instead of failing
+	if isAllProvidersFailed(err) {
+		logger.Warn("No provider could answer, queuing the question.", "Error", err)
+		return deferAnswer(ctx, input)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	return workflowResult, nil
+}
+
+// answerQuestion runs the conversation with the model until it answers the question
+func answerQuestion(ctx workflow.Context, input ChatBotQuestion) (*ChatBotAnswer, error) {
+	// Set activity options including the task queue of the LLM pool, the timeouts and the retry policy of the calls to a provider
+	opts := *chatOptions.Load()
 
 	// Get a logger instance for the workflow context
 	logger := workflow.GetLogger(ctx)
-	logger.Info("Starting ChatBotWorkflow", "User", input.User, "Question", input.Question)
 
 	// Apply the activity options to the workflow context
-	ctx = workflow.WithActivityOptions(ctx, opts)
+	chatCtx := workflow.WithActivityOptions(ctx, opts)
 
-	var resultAnswer string
-	// Execute the ChatActivity with the provided question and get the result
-	err := workflow.ExecuteActivity(ctx, ChatActivity, input.Question).Get(ctx, &resultAnswer)
-
-	if err != nil {
-		// Log the error if the activity execution fails
-		logger.Error("Activity failed.", "Error", err)
+	// Read the provider chain once, the configuration of the worker may change while the workflow runs.
+	// A question asked for a model is only sent to the providers serving it.
+	var providers []string
+	if err := workflow.SideEffect(ctx, func(ctx workflow.Context) any {
+		if input.Model != "" {
+			return openai.ProvidersOf(input.Model)
+		}
+		return openai.ProviderNames()
+	}).Get(&providers); err != nil {
 		return nil, err
 	}
+	if len(providers) == 0 {
+		return nil, temporal.NewNonRetryableApplicationError("no provider serves the model "+input.Model, RequestRejectedErrorType, nil)
+	}
+
+	// Start the conversation with the user question and offer every registered tool
+	request := openai.ChatRequest{
+		Messages: []openai.Message{{Role: openai.RoleUser, Content: input.Question}},
+		Tools:    tools.Definitions(),
+		Model:    input.Model,
+	}
+
+	// Give the instructions of the prompt version and tell the model who is asking when the profile of the user is known
+	if prompt := systemPrompt(input); prompt != "" {
+		request.Messages = append([]openai.Message{{Role: openai.RoleSystem, Content: prompt}}, request.Messages...)
+	}
+
+	// Constrain the answer to the answer schema when the structured format is requested
+	if input.Format == FormatStructured {
+		request.ResponseFormat = &openai.ResponseFormat{Name: answers.SchemaName, Schema: answers.Schema()}
+	}
+
+	var reply *openai.Completion
+	for step := 1; ; step++ {
+		// Once the model reached the last step it has to answer with what it has
+		if step == maxSteps {
+			request.Tools = nil
+		}
+
+		// Execute the ChatActivity with the conversation so far and get the model reply
+		var err error
+		reply, err = complete(chatCtx, providers, request)
 
-	// Log the successful completion of the workflow
-	logger.Info("ChatBotWorkflow completed.", "User", input.User, "Answer", resultAnswer)
+		if err != nil {
+			// Log the error if the activity execution fails
+			logger.Error("Activity failed.", "Error", err)
+			return nil, err
+		}
+
+		// The model answered the question without asking for tools
+		if len(reply.Message.ToolCalls) == 0 {
+			break
+		}
+
+		// Run the requested tools and send their results back to the model
+		logger.Info("Model requested tools.", "Step", step, "ToolCalls", len(reply.Message.ToolCalls))
+		request.Messages = append(request.Messages, reply.Message)
+		request.Messages = append(request.Messages, executeToolCalls(ctx, reply.Message.ToolCalls)...)
+	}
 
 	// Create the workflow result with the user and the answer
 	workflowResult := &ChatBotAnswer{
-		User:   input.User,
-		Answer: resultAnswer,
+		User:     input.User,
+		Status:   StatusAnswered,
+		Answer:   reply.Message.Content,
+		Provider: reply.Provider,
+		Model:    reply.Model,
 	}
+
+	if input.Format == FormatStructured {
+		structured, repaired, err := structuredAnswer(chatCtx, providers, request, reply)
+		if err != nil {
+			// Log the error if the activity execution fails while repairing the answer
+			logger.Error("Activity failed.", "Error", err)
+			return nil, err
+		}
+
+		// Without a valid structured answer the raw model output is still returned as plain text
+		if structured != nil {
+			workflowResult.Structured = structured
+			workflowResult.Answer = structured.Text()
+
+			// The repaired answer may come from another provider
+			workflowResult.Provider, workflowResult.Model = repaired.Provider, repaired.Model
+		}
+	}
+
 	return workflowResult, nil
 }
+
+// profilePrompt returns the system prompt personalizing the answer with the profile of the user, empty without profile
+func profilePrompt(input ChatBotQuestion) string {
+	var prompt []string
+	if input.Name != "" {
+		prompt = append(prompt, fmt.Sprintf("The user's name is %s, address them by their name.", input.Name))
+	}
+	if input.Locale != "" {
+		prompt = append(prompt, fmt.Sprintf("The user's locale is %s, answer in its language.", input.Locale))
+	}
+	return strings.Join(prompt, " ")
+}
+
+// complete sends the request to each provider in turn until one of them answers.
+// The error of the last provider is returned as an AllProvidersFailedErrorType error when none of them answered,
+// or as is when it rejected the request: queuing a question every provider rejects does not help.
+func complete(ctx workflow.Context, providers []string, request openai.ChatRequest) (*openai.Completion, error) {
+	logger := workflow.GetLogger(ctx)
+
+	var lastErr error
+	allRejected := true
+	for _, provider := range providers {
+		request.Provider = provider
+
+		var reply openai.Completion
+		err := workflow.ExecuteActivity(ctx, ChatActivity, request).Get(ctx, &reply)
+		if err == nil {
+			return &reply, nil
+		}
+
+		// The workflow was canceled, no other provider is tried
+		if temporal.IsCanceledError(err) {
+			return nil, err
+		}
+
+		logger.Warn("Provider failed, falling back to the next one.", "Provider", provider, "Error", err)
+		lastErr = err
+		allRejected = allRejected && isRequestRejected(err)
+	}
+
+	// The request is only failed when every provider rejected it, an unavailable provider may answer it later
+	if isRequestRejected(lastErr) {
+		version := workflow.GetVersion(ctx, changeRequestRejected, workflow.DefaultVersion, 2)
+		if version == 1 || (version == 2 && allRejected) {
+			return nil, lastErr
+		}
+	}
+	return nil, temporal.NewApplicationError("no provider could answer", AllProvidersFailedErrorType, lastErr)
+}
+
+// isRequestRejected reports whether the error means the provider rejected the request, as opposed to being unavailable
+func isRequestRejected(err error) bool {
+	var applicationErr *temporal.ApplicationError
+	return errors.As(err, &applicationErr) && applicationErr.Type() == RequestRejectedErrorType
+}
+
+// structuredAnswer validates the model output against the answer schema and asks the model to repair it when it does not match.
+// It returns a nil answer when the output still does not match the schema after maxRepairs attempts, along with the last reply.
+func structuredAnswer(ctx workflow.Context, providers []string, request openai.ChatRequest, reply *openai.Completion) (*answers.StructuredAnswer, *openai.Completion, error) {
+	logger := workflow.GetLogger(ctx)
+
+	// The model already gathered what it needed, it only has to fix the format
+	request.Tools = nil
+
+	for repair := 1; ; repair++ {
+		structured, err := answers.Parse(reply.Message.Content)
+		if err == nil {
+			return structured, reply, nil
+		}
+
+		if repair > maxRepairs {
+			logger.Warn("Structured answer still invalid, returning plain text.", "Error", err)
+			return nil, reply, nil
+		}
+
+		// Send the validation error back to the model so it can fix its output
+		logger.Warn("Structured answer invalid, asking the model to repair it.", "Repair", repair, "Error", err)
+		request.Messages = append(request.Messages, reply.Message, openai.Message{
+			Role:    openai.RoleUser,
+			Content: fmt.Sprintf("Your answer does not match the required JSON schema: %v. Reply again with the complete answer as JSON matching the schema.", err),
+		})
+
+		if reply, err = complete(ctx, providers, request); err != nil {
+			return nil, nil, err
+		}
+	}
+}
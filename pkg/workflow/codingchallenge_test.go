@@ -1,22 +1,54 @@
 package workflow
 
 import (
+	"code-challenge/pkg/answers"
+	"code-challenge/pkg/classifier"
+	"code-challenge/pkg/config"
+	"code-challenge/pkg/deadletter"
+	"code-challenge/pkg/notify"
+	"code-challenge/pkg/openai"
+	"code-challenge/pkg/search"
+	"code-challenge/pkg/tools"
+	"context"
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/testsuite"
+	"strings"
 	"testing"
+	"time"
 )
 
+// asks matches the chat requests whose conversation only holds the given question
+func asks(question string) any {
+	return mock.MatchedBy(func(request openai.ChatRequest) bool {
+		return len(request.Messages) == 1 && request.Messages[0].Content == question
+	})
+}
+
+// completion is a reply of the primary provider
+func completion(message openai.Message) *openai.Completion {
+	return &openai.Completion{Message: message, Provider: openai.ProviderOpenAI, Model: "gpt-4o"}
+}
+
+// conversation matches the chat requests whose conversation satisfies the predicate
+func conversation(predicate func(messages []openai.Message) bool) any {
+	return mock.MatchedBy(func(request openai.ChatRequest) bool {
+		return predicate(request.Messages)
+	})
+}
+
 func Test_ChatBotWorkflow_Success(t *testing.T) {
 	ts := &testsuite.WorkflowTestSuite{}
 	env := ts.NewTestWorkflowEnvironment()
 
-	var returnValue *string
-	value := "Paris"
-	returnValue = &value
+	returnValue := completion(openai.Message{Role: openai.RoleAssistant, Content: "Paris"})
 
-	env.OnActivity(ChatActivity, mock.Anything, "What is the capital of France?").Return(returnValue, nil)
+	env.OnActivity(ChatActivity, mock.Anything, asks("What is the capital of France?")).Return(returnValue, nil)
 
 	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{
 		User:     "test_user",
@@ -29,33 +61,90 @@ func Test_ChatBotWorkflow_Success(t *testing.T) {
 	var result ChatBotAnswer
 	assert.NoError(t, env.GetWorkflowResult(&result))
 	assert.Equal(t, "test_user", result.User)
-	assert.Equal(t, value, result.Answer)
+	assert.Equal(t, "Paris", result.Answer)
+}
+
+func Test_ChatBotWorkflow_Profile(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+
+	// The profile of the user is sent to the model before the question
+	env.OnActivity(ChatActivity, mock.Anything, conversation(func(messages []openai.Message) bool {
+		return len(messages) == 2 && messages[0].Role == openai.RoleSystem &&
+			strings.Contains(messages[0].Content, "Thiago") && strings.Contains(messages[0].Content, "pt-BR") &&
+			messages[1].Content == "Qual é a capital da França?"
+	})).Return(completion(openai.Message{Role: openai.RoleAssistant, Content: "Paris, Thiago."}), nil).Once()
+
+	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{User: "user-1", Question: "Qual é a capital da França?", Name: "Thiago", Locale: "pt-BR"})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+}
+
+func Test_ChatBotWorkflow_ModelAndPromptVersion(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+
+	// A replayed question is only sent to the provider of the requested model, with the instructions of the prompt version
+	env.OnActivity(ChatActivity, mock.Anything, mock.MatchedBy(func(request openai.ChatRequest) bool {
+		return request.Provider == openai.ProviderOpenAIMini && request.Model == "gpt-4o-mini" && len(request.Messages) == 2 &&
+			request.Messages[0].Role == openai.RoleSystem && strings.HasPrefix(request.Messages[0].Content, prompts[PromptV2]) &&
+			strings.Contains(request.Messages[0].Content, "Thiago")
+	})).Return(completion(openai.Message{Role: openai.RoleAssistant, Content: "Paris"}), nil).Once()
+
+	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{
+		User: "user-1", Question: "What is the capital of France?", Name: "Thiago", Model: "gpt-4o-mini", PromptVersion: PromptV2,
+	})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+
+	// A model that is not configured is rejected without calling any provider
+	env = ts.NewTestWorkflowEnvironment()
+	env.RegisterActivity(&DeadLetters{Store: deadletter.NewMemoryStore()})
+	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{User: "user-1", Question: "What is the capital of France?", Model: "gpt-4.1"})
+
+	var applicationErr *temporal.ApplicationError
+	require.ErrorAs(t, env.GetWorkflowError(), &applicationErr)
+	assert.Equal(t, RequestRejectedErrorType, applicationErr.Type())
+
+	assert.True(t, ValidPromptVersion(""))
+	assert.True(t, ValidPromptVersion(PromptV1))
+	assert.False(t, ValidPromptVersion("v0"))
+	assert.Equal(t, []string{PromptV1, PromptV2}, PromptVersions())
 }
 
 func Test_ChatBotWorkflow_Activity_Failure(t *testing.T) {
 	ts := testsuite.WorkflowTestSuite{}
 	env := ts.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(DeferredAnswerWorkflow)
 
-	env.OnActivity(ChatActivity, mock.Anything, "What is the capital of France?").Return(nil, errors.New("API error"))
+	env.OnActivity(ChatActivity, mock.Anything, asks("What is the capital of France?")).Return(nil, errors.New("API error"))
+	env.OnWorkflow(DeferredAnswerWorkflow, mock.Anything, mock.Anything).Return(nil, nil)
 
 	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{
 		User:     "test_user",
 		Question: "What is the capital of France?",
 	})
 
+	// The question is queued instead of failing the workflow
 	assert.True(t, env.IsWorkflowCompleted())
-	assert.Error(t, env.GetWorkflowError())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result ChatBotAnswer
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, StatusQueued, result.Status)
 }
 
 func TestChatBotWorkflow_RetryPolicy(t *testing.T) {
 	ts := testsuite.WorkflowTestSuite{}
 	env := ts.NewTestWorkflowEnvironment()
 
-	var returnValue *string
-	value := "Paris"
-	returnValue = &value
+	returnValue := completion(openai.Message{Role: openai.RoleAssistant, Content: "Paris"})
 
-	env.OnActivity(ChatActivity, mock.Anything, "What is the capital of France?").Return(nil, errors.New("API error")).Times(3).Return(returnValue, nil)
+	env.OnActivity(ChatActivity, mock.Anything, asks("What is the capital of France?")).Return(nil, errors.New("API error")).Times(3).Return(returnValue, nil)
 
 	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{
 		User:     "test_user",
@@ -68,5 +157,359 @@ func TestChatBotWorkflow_RetryPolicy(t *testing.T) {
 	var result ChatBotAnswer
 	assert.NoError(t, env.GetWorkflowResult(&result))
 	assert.Equal(t, "test_user", result.User)
-	assert.Equal(t, value, result.Answer)
+	assert.Equal(t, "Paris", result.Answer)
+}
+
+func Test_ChatBotWorkflow_Configure(t *testing.T) {
+	policies, taskQueues := config.Default().Policies, config.TaskQueues{Chat: "chat", LLM: "llm-pool"}
+	policies.Chat.Retry.MaximumAttempts = 1
+	Configure(policies, taskQueues)
+	t.Cleanup(func() { Configure(config.Default().Policies, config.Default().Temporal.TaskQueues) })
+
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+
+	// The calls to the models run on the task queue of the LLM pool with the configured attempts
+	var queues []string
+	env.SetOnActivityStartedListener(func(info *activity.Info, _ context.Context, _ converter.EncodedValues) {
+		queues = append(queues, info.TaskQueue)
+	})
+	env.OnActivity(ChatActivity, mock.Anything, provider(openai.ProviderOpenAI)).Return(nil, errors.New("API error")).Once()
+	env.OnActivity(ChatActivity, mock.Anything, provider(openai.ProviderOpenAIMini)).
+		Return(completion(openai.Message{Role: openai.RoleAssistant, Content: "Paris"}), nil).Once()
+
+	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{User: "test_user", Question: "What is the capital of France?"})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+	assert.Equal(t, []string{"llm-pool", "llm-pool"}, queues)
+	env.AssertExpectations(t)
+}
+
+// provider matches the chat requests sent to the given provider
+func provider(name string) any {
+	return mock.MatchedBy(func(request openai.ChatRequest) bool {
+		return request.Provider == name
+	})
+}
+
+func Test_ChatBotWorkflow_ProviderFallback(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+
+	circuitOpen := temporal.NewNonRetryableApplicationError("circuit breaker open", CircuitOpenErrorType, nil)
+	answer := &openai.Completion{
+		Message:  openai.Message{Role: openai.RoleAssistant, Content: "Paris"},
+		Provider: openai.ProviderOpenAIMini,
+		Model:    "gpt-4o-mini",
+	}
+
+	// The open circuit is not retried, the next provider answers
+	env.OnActivity(ChatActivity, mock.Anything, provider(openai.ProviderOpenAI)).Return(nil, circuitOpen).Once()
+	env.OnActivity(ChatActivity, mock.Anything, provider(openai.ProviderOpenAIMini)).Return(answer, nil).Once()
+
+	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{User: "test_user", Question: "What is the capital of France?"})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result ChatBotAnswer
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, "Paris", result.Answer)
+	assert.Equal(t, openai.ProviderOpenAIMini, result.Provider)
+	assert.Equal(t, "gpt-4o-mini", result.Model)
+	env.AssertExpectations(t)
+}
+
+func Test_ChatBotWorkflow_RequestRejected(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(DeferredAnswerWorkflow)
+
+	// Every provider rejects the request, the question is not queued since no recovery would answer it
+	rejected := temporal.NewNonRetryableApplicationError("context length exceeded", RequestRejectedErrorType, nil)
+	env.OnActivity(ChatActivity, mock.Anything, mock.Anything).Return(nil, rejected).Twice()
+
+	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{User: "test_user", Question: "What is the capital of France?"})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	var applicationErr *temporal.ApplicationError
+	require.ErrorAs(t, env.GetWorkflowError(), &applicationErr)
+	assert.Equal(t, RequestRejectedErrorType, applicationErr.Type())
+	env.AssertExpectations(t)
+	env.AssertWorkflowNotCalled(t, "DeferredAnswerWorkflow", mock.Anything, mock.Anything)
+}
+
+func Test_ChatBotWorkflow_FallbackRejected(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(DeferredAnswerWorkflow)
+
+	// The primary provider is unavailable and only the fallback rejects the request, the primary may answer it later
+	unavailable := temporal.NewNonRetryableApplicationError("circuit open", CircuitOpenErrorType, nil)
+	rejected := temporal.NewNonRetryableApplicationError("context length exceeded", RequestRejectedErrorType, nil)
+	env.OnActivity(ChatActivity, mock.Anything, provider(openai.ProviderOpenAI)).Return(nil, unavailable).Once()
+	env.OnActivity(ChatActivity, mock.Anything, provider(openai.ProviderOpenAIMini)).Return(nil, rejected).Once()
+	env.OnWorkflow(DeferredAnswerWorkflow, mock.Anything, mock.Anything).Return(nil, nil).Once()
+
+	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{User: "test_user", Question: "What is the capital of France?"})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var answer ChatBotAnswer
+	require.NoError(t, env.GetWorkflowResult(&answer))
+	assert.Equal(t, StatusQueued, answer.Status)
+	env.AssertExpectations(t)
+}
+
+func Test_ChatBotWorkflow_Canceled(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+
+	// The workflow is canceled while the model is answering, the next provider is not tried
+	env.OnActivity(ChatActivity, mock.Anything, provider(openai.ProviderOpenAI)).After(time.Minute).
+		Return(completion(openai.Message{Role: openai.RoleAssistant, Content: "Paris"}), nil).Maybe()
+	env.RegisterDelayedCallback(env.CancelWorkflow, time.Second)
+
+	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{User: "test_user", Question: "What is the capital of France?"})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.True(t, temporal.IsCanceledError(env.GetWorkflowError()))
+	env.AssertNotCalled(t, "ChatActivity", mock.Anything, provider(openai.ProviderOpenAIMini))
+}
+
+func Test_ChatBotWorkflow_Queued(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(DeferredAnswerWorkflow)
+
+	question := ChatBotQuestion{
+		User:     "test_user",
+		Question: "What is the capital of France?",
+		Notify:   notify.Channel{Email: "user@example.com"},
+	}
+
+	// Every provider fails, the question is handed over to the deferred workflow
+	env.OnActivity(ChatActivity, mock.Anything, mock.Anything).Return(nil, errors.New("API error"))
+	env.OnWorkflow(DeferredAnswerWorkflow, mock.Anything, mock.MatchedBy(func(deferred DeferredQuestion) bool {
+		return deferred.ID == "default-test-workflow-id" && deferred.Question == question
+	})).Return(nil, nil).Once()
+
+	env.ExecuteWorkflow(ChatBotWorkflow, question)
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result ChatBotAnswer
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, StatusQueued, result.Status)
+	assert.Equal(t, queuedMessage, result.Answer)
+	assert.Equal(t, "default-test-workflow-id_deferred", result.DeferredWorkflowID)
+	env.AssertExpectations(t)
+}
+
+func Test_ChatBotWorkflow_SearchAttributes(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+
+	// The conversation is searchable by the topic of the question then by its outcome
+	env.OnActivity(ChatActivity, mock.Anything, mock.Anything).
+		Return(completion(openai.Message{Role: openai.RoleAssistant, Content: "Apply for a work permit."}), nil)
+	env.OnUpsertTypedSearchAttributes(temporal.NewSearchAttributes(
+		search.User.ValueSet("test_user"),
+		search.Intent.ValueSet(classifier.IntentWork),
+		search.DestinationCountry.ValueSet("canada"),
+		search.Language.ValueSet("pt"),
+	)).Return(nil).Once()
+	env.OnUpsertTypedSearchAttributes(temporal.NewSearchAttributes(
+		search.Outcome.ValueSet(StatusAnswered),
+		search.Model.ValueSet("gpt-4o"),
+	)).Return(nil).Once()
+
+	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{User: "test_user", Question: "How can I get a job in Canada?", Locale: "pt-BR"})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+}
+
+func Test_ChatBotWorkflow_SearchAttributes_Canceled(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+
+	env.OnActivity(ChatActivity, mock.Anything, mock.Anything).After(time.Minute).
+		Return(completion(openai.Message{Role: openai.RoleAssistant, Content: "Paris"}), nil).Maybe()
+	env.OnUpsertTypedSearchAttributes(mock.Anything).Return(nil).Once()
+	env.OnUpsertTypedSearchAttributes(temporal.NewSearchAttributes(search.Outcome.ValueSet(outcomeCanceled))).Return(nil).Once()
+	env.RegisterDelayedCallback(env.CancelWorkflow, time.Second)
+
+	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{User: "test_user", Question: "What is the capital of France?"})
+
+	assert.True(t, temporal.IsCanceledError(env.GetWorkflowError()))
+	env.AssertExpectations(t)
+}
+
+func Test_ChatBotWorkflow_CalculatorTool(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+	tools.RegisterActivities(env)
+
+	arguments := `{"age": 29, "education": "masters", "canadian_work_years": 1, "foreign_work_years": 3,
+		"first_language": {"language": "english", "reading": 9, "writing": 9, "speaking": 9, "listening": 9}}`
+	toolCall := completion(openai.Message{
+		Role:      openai.RoleAssistant,
+		ToolCalls: []openai.ToolCall{{ID: "call_1", Name: "calculate_crs", Arguments: arguments}},
+	})
+	answer := completion(openai.Message{Role: openai.RoleAssistant, Content: "Your CRS score is 509."})
+
+	// The model first asks for the calculator, then answers once it received the result
+	env.OnActivity(ChatActivity, mock.Anything, conversation(func(messages []openai.Message) bool {
+		return len(messages) == 1
+	})).Return(toolCall, nil).Once()
+	env.OnActivity(ChatActivity, mock.Anything, conversation(func(messages []openai.Message) bool {
+		return len(messages) == 3 && messages[2].ToolCallID == "call_1" && messages[2].Content != ""
+	})).Return(answer, nil).Once()
+
+	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{
+		User:     "test_user",
+		Question: "What is my CRS score?",
+	})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result ChatBotAnswer
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, "Your CRS score is 509.", result.Answer)
+	env.AssertExpectations(t)
+}
+
+func Test_ChatBotWorkflow_ToolFailureIsSentToModel(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+	tools.RegisterActivities(env)
+
+	toolCalls := completion(openai.Message{
+		Role: openai.RoleAssistant,
+		ToolCalls: []openai.ToolCall{
+			{ID: "call_1", Name: "calculate_crs", Arguments: `{"age": "unknown"}`},
+			{ID: "call_2", Name: "unknown_tool", Arguments: `{}`},
+		},
+	})
+	answer := completion(openai.Message{Role: openai.RoleAssistant, Content: "I could not compute your score."})
+
+	// Both failures are sent back to the model in the order of the calls
+	env.OnActivity(ChatActivity, mock.Anything, conversation(func(messages []openai.Message) bool {
+		return len(messages) == 1
+	})).Return(toolCalls, nil).Once()
+	env.OnActivity(ChatActivity, mock.Anything, conversation(func(messages []openai.Message) bool {
+		return len(messages) == 4 &&
+			messages[2].ToolCallID == "call_1" && strings.Contains(messages[2].Content, "error") &&
+			messages[3].ToolCallID == "call_2" && strings.Contains(messages[3].Content, "unknown tool")
+	})).Return(answer, nil).Once()
+
+	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{User: "test_user", Question: "What is my CRS score?"})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+}
+
+func Test_ChatBotWorkflow_MaxSteps(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+	tools.RegisterActivities(env)
+
+	toolCall := completion(openai.Message{
+		Role:      openai.RoleAssistant,
+		ToolCalls: []openai.ToolCall{{ID: "call", Name: "knowledge_base_search", Arguments: `{"query": "biometrics"}`}},
+	})
+	answer := completion(openai.Message{Role: openai.RoleAssistant, Content: "Biometrics are valid for 10 years."})
+
+	// The model keeps asking for tools while it is offered any, the last step offers none
+	env.OnActivity(ChatActivity, mock.Anything, mock.MatchedBy(func(request openai.ChatRequest) bool {
+		return len(request.Tools) > 0
+	})).Return(toolCall, nil).Times(maxSteps - 1)
+	env.OnActivity(ChatActivity, mock.Anything, mock.MatchedBy(func(request openai.ChatRequest) bool {
+		return len(request.Tools) == 0
+	})).Return(answer, nil).Once()
+
+	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{User: "test_user", Question: "Do I need biometrics?"})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result ChatBotAnswer
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, "Biometrics are valid for 10 years.", result.Answer)
+	env.AssertExpectations(t)
+}
+
+const structuredContent = `{"summary": "Apply through Express Entry.", "steps": [{"number": 1, "title": "Create a profile", "details": ""}],
+	"required_documents": ["Passport"], "estimated_fees": [], "estimated_timeline": "6 months",
+	"disclaimer": "This is not legal advice.", "follow_up_questions": []}`
+
+func Test_ChatBotWorkflow_Structured(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+
+	// The answer schema is sent to the model
+	env.OnActivity(ChatActivity, mock.Anything, mock.MatchedBy(func(request openai.ChatRequest) bool {
+		return request.ResponseFormat != nil && request.ResponseFormat.Name == answers.SchemaName
+	})).Return(completion(openai.Message{Role: openai.RoleAssistant, Content: structuredContent}), nil).Once()
+
+	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{User: "test_user", Question: "How to immigrate to Canada?", Format: FormatStructured})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result ChatBotAnswer
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.NotNil(t, result.Structured)
+	assert.Equal(t, "Apply through Express Entry.", result.Structured.Summary)
+	assert.Contains(t, result.Answer, "1. Create a profile")
+	env.AssertExpectations(t)
+}
+
+func Test_ChatBotWorkflow_Structured_Repair(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+
+	// The first output misses fields, the validation error is sent back to the model which fixes it
+	env.OnActivity(ChatActivity, mock.Anything, conversation(func(messages []openai.Message) bool {
+		return len(messages) == 1
+	})).Return(completion(openai.Message{Role: openai.RoleAssistant, Content: `{"summary": "Apply through Express Entry."}`}), nil).Once()
+	env.OnActivity(ChatActivity, mock.Anything, conversation(func(messages []openai.Message) bool {
+		return len(messages) == 3 && strings.Contains(messages[2].Content, "answer.steps is required")
+	})).Return(completion(openai.Message{Role: openai.RoleAssistant, Content: structuredContent}), nil).Once()
+
+	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{User: "test_user", Question: "How to immigrate to Canada?", Format: FormatStructured})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result ChatBotAnswer
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.NotNil(t, result.Structured)
+	env.AssertExpectations(t)
+}
+
+func Test_ChatBotWorkflow_Structured_FallbackToText(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+
+	// The model never returns a valid output, the first attempt and every repair fail
+	env.OnActivity(ChatActivity, mock.Anything, mock.Anything).
+		Return(completion(openai.Message{Role: openai.RoleAssistant, Content: "Apply through Express Entry."}), nil).Times(1 + maxRepairs)
+
+	env.ExecuteWorkflow(ChatBotWorkflow, ChatBotQuestion{User: "test_user", Question: "How to immigrate to Canada?", Format: FormatStructured})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result ChatBotAnswer
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Nil(t, result.Structured)
+	assert.Equal(t, "Apply through Express Entry.", result.Answer)
+	env.AssertExpectations(t)
 }
@@ -0,0 +1,85 @@
+package workflow
+
+import (
+	"code-challenge/pkg/deadletter"
+	"context"
+	"encoding/json"
+	"errors"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+	"time"
+)
+
+// Error types of the dead letters that are not application errors
+const (
+	TimeoutErrorType = "Timeout"
+	UnknownErrorType = "Unknown"
+)
+
+// DeadLetters holds the dependencies of the RecordDeadLetter activity
+type DeadLetters struct {
+	Store deadletter.Store
+}
+
+// RecordDeadLetter is a Temporal activity that keeps a question that could not be answered so it can be replayed
+func (d *DeadLetters) RecordDeadLetter(ctx context.Context, letter deadletter.Letter) error {
+	return d.Store.Record(ctx, letter)
+}
+
+// recordDeadLetter records the question failed by err after the attempts, a canceled question is not a failure.
+// The failure to record it is only logged, the workflow fails with its own error.
+func recordDeadLetter(ctx workflow.Context, input ChatBotQuestion, startedAt time.Time, attempts int, err error) {
+	if err == nil || temporal.IsCanceledError(err) {
+		return
+	}
+	logger := workflow.GetLogger(ctx)
+
+	payload, marshalErr := json.Marshal(input)
+	if marshalErr != nil {
+		logger.Error("Unable to encode the dead letter.", "Error", marshalErr)
+		return
+	}
+
+	info := workflow.GetInfo(ctx)
+	letter := deadletter.Letter{
+		ID:         info.WorkflowExecution.RunID,
+		WorkflowID: info.WorkflowExecution.ID,
+		User:       input.User,
+		Question:   input.Question,
+		Input:      payload,
+		ErrorType:  errorType(err),
+		Error:      err.Error(),
+		Attempts:   attempts,
+		StartedAt:  startedAt,
+		FailedAt:   workflow.Now(ctx),
+	}
+
+	recordCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout:    30 * time.Second,
+		ScheduleToCloseTimeout: 10 * time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+		},
+	})
+	var d *DeadLetters
+	if err := workflow.ExecuteActivity(recordCtx, d.RecordDeadLetter, letter).Get(ctx, nil); err != nil {
+		logger.Error("Unable to record the dead letter.", "User", input.User, "Error", err)
+		return
+	}
+	logger.Warn("Question recorded as dead letter.", "User", input.User, "ErrorType", letter.ErrorType, "Attempts", attempts)
+}
+
+// errorType returns the type of the failure: the type of the application error or TimeoutErrorType
+func errorType(err error) string {
+	var timeoutErr *temporal.TimeoutError
+	if errors.As(err, &timeoutErr) {
+		return TimeoutErrorType
+	}
+	var applicationErr *temporal.ApplicationError
+	if errors.As(err, &applicationErr) && applicationErr.Type() != "" {
+		return applicationErr.Type()
+	}
+	return UnknownErrorType
+}
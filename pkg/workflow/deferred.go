@@ -0,0 +1,206 @@
+package workflow
+
+import (
+	"code-challenge/pkg/conversations"
+	"code-challenge/pkg/notify"
+	"context"
+	"errors"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+	"time"
+)
+
+// Durable timers of the DeferredAnswerWorkflow: the wait between attempts doubles up to deferredMaxInterval
+// and the question is given up once deferredDeadline elapsed
+const (
+	deferredInitialInterval = time.Minute
+	deferredMaxInterval     = 30 * time.Minute
+	deferredDeadline        = 24 * time.Hour
+)
+
+// queuedMessage is the answer returned right away when the question is queued
+const queuedMessage = "We are not able to answer your question right now. It has been queued and we'll get back to you as soon as possible."
+
+// failedMessage is the answer delivered when the question could not be answered before the deadline
+const failedMessage = "We are sorry, we were not able to answer your question. Please ask it again later."
+
+// DeferredQuestion is the input to the DeferredAnswerWorkflow
+type DeferredQuestion struct {
+	// ID identifies the question in the conversation history, it is the ID of the workflow that queued it
+	ID       string
+	Question ChatBotQuestion
+	QueuedAt time.Time
+}
+
+// Delivery is the input to the DeliverAnswer activity
+type Delivery struct {
+	Question DeferredQuestion
+	Answer   ChatBotAnswer
+}
+
+// Deliverer holds the dependencies of the DeliverAnswer activity
+type Deliverer struct {
+	Store    conversations.Store
+	Notifier *notify.Notifier
+}
+
+// isAllProvidersFailed reports whether the error means no provider could answer
+func isAllProvidersFailed(err error) bool {
+	var applicationErr *temporal.ApplicationError
+	return errors.As(err, &applicationErr) && applicationErr.Type() == AllProvidersFailedErrorType
+}
+
+// deferAnswer starts a DeferredAnswerWorkflow that outlives the current workflow and returns the queued answer
+func deferAnswer(ctx workflow.Context, input ChatBotQuestion) (*ChatBotAnswer, error) {
+	info := workflow.GetInfo(ctx)
+	childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+		WorkflowID:        info.WorkflowExecution.ID + "_deferred",
+		ParentClosePolicy: enums.PARENT_CLOSE_POLICY_ABANDON,
+	})
+
+	deferred := DeferredQuestion{
+		ID:       info.WorkflowExecution.ID,
+		Question: input,
+		QueuedAt: workflow.Now(ctx),
+	}
+
+	// Wait for the child to start, otherwise it would not be created once this workflow completes
+	var execution workflow.Execution
+	child := workflow.ExecuteChildWorkflow(childCtx, DeferredAnswerWorkflow, deferred)
+	if err := child.GetChildWorkflowExecution().Get(ctx, &execution); err != nil {
+		return nil, err
+	}
+
+	return &ChatBotAnswer{
+		User:               input.User,
+		Answer:             queuedMessage,
+		Status:             StatusQueued,
+		DeferredWorkflowID: execution.ID,
+	}, nil
+}
+
+// DeferredAnswerWorkflow is a Temporal workflow that answers a queued question once a provider recovers.
+// It waits with durable timers between attempts and delivers the answer through the channels of the user.
+func DeferredAnswerWorkflow(ctx workflow.Context, input DeferredQuestion) (result *ChatBotAnswer, err error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting DeferredAnswerWorkflow", "ID", input.ID, "User", input.Question.User)
+
+	// The queued question is searchable as the conversation that queued it
+	if workflow.GetVersion(ctx, changeSearchAttributes, workflow.DefaultVersion, 1) == 1 {
+		upsertQuestion(ctx, input.Question)
+		defer func() { upsertOutcome(ctx, result, err) }()
+	}
+
+	// The question given up, or failed, is kept to be replayed with the number of attempts and the error of the last one
+	var attempts int
+	var lastErr error
+	if workflow.GetVersion(ctx, changeDeadLetter, workflow.DefaultVersion, 1) == 1 {
+		defer func() {
+			failure := err
+			if err == nil && result != nil && result.Status == StatusFailed {
+				failure = lastErr
+			}
+			recordDeadLetter(ctx, input.Question, input.QueuedAt, attempts, failure)
+		}()
+	}
+
+	deadline := workflow.Now(ctx).Add(deferredDeadline)
+	interval := deferredInitialInterval
+
+	for attempt := 1; ; attempt++ {
+		// Wait before trying again, the timer survives worker restarts
+		if err := workflow.Sleep(ctx, interval); err != nil {
+			return nil, err
+		}
+
+		attempts = attempt
+		answer, err := answerQuestion(ctx, input.Question)
+		if err == nil {
+			result = answer
+			break
+		}
+		if !isAllProvidersFailed(err) {
+			return nil, err
+		}
+
+		// Give up once the next attempt would be after the deadline
+		interval = min(2*interval, deferredMaxInterval)
+		if workflow.Now(ctx).Add(interval).After(deadline) {
+			logger.Error("No provider recovered before the deadline.", "ID", input.ID, "Attempts", attempt)
+			lastErr = err
+			result = &ChatBotAnswer{User: input.Question.User, Answer: failedMessage, Status: StatusFailed}
+			break
+		}
+		logger.Warn("No provider could answer, waiting before the next attempt.", "ID", input.ID, "Attempt", attempt, "Interval", interval)
+	}
+
+	// Deliver the answer, or the failure, through the channels of the user
+	deliverCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout:    30 * time.Second,
+		ScheduleToCloseTimeout: time.Hour,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    5 * time.Minute,
+		},
+	})
+
+	var d *Deliverer
+	if err := workflow.ExecuteActivity(deliverCtx, d.DeliverAnswer, Delivery{Question: input, Answer: *result}).Get(ctx, nil); err != nil {
+		logger.Error("Unable to deliver the answer.", "ID", input.ID, "Error", err)
+		return nil, err
+	}
+
+	logger.Info("DeferredAnswerWorkflow completed.", "ID", input.ID, "Status", result.Status)
+	return result, nil
+}
+
+// DeliverAnswer is a Temporal activity that records the answer in the conversation history
+// and sends it to the webhook and the email address of the user when they are set
+func (d *Deliverer) DeliverAnswer(ctx context.Context, delivery Delivery) error {
+	logger := activity.GetLogger(ctx)
+
+	message := notify.Message{
+		ID:       delivery.Question.ID,
+		User:     delivery.Answer.User,
+		Question: delivery.Question.Question.Question,
+		Answer:   delivery.Answer.Answer,
+		Status:   delivery.Answer.Status,
+	}
+
+	// The history is written first and replaced on retries, so it always holds the answer
+	err := d.Store.Save(ctx, conversations.Entry{
+		ID:        message.ID,
+		User:      message.User,
+		Question:  message.Question,
+		Answer:    message.Answer,
+		Status:    message.Status,
+		Model:     delivery.Answer.Model,
+		CreatedAt: delivery.Question.QueuedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	channel := delivery.Question.Question.Notify
+	if channel.Webhook != "" {
+		switch err := d.Notifier.Webhook(ctx, channel.Webhook, message); {
+		case errors.Is(err, notify.ErrRefused):
+			// The webhook left the allowed hosts or reaches an internal address, retrying does not help and the answer stays in the history
+			logger.Warn("Webhook refused, the answer is not posted.", "ID", message.ID, "Error", err)
+		case err != nil:
+			return err
+		default:
+			logger.Info("Answer delivered to webhook.", "ID", message.ID)
+		}
+	}
+	if channel.Email != "" {
+		if err := d.Notifier.Email(channel.Email, message); err != nil {
+			return err
+		}
+		logger.Info("Answer delivered by email.", "ID", message.ID)
+	}
+	return nil
+}
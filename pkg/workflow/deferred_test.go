@@ -0,0 +1,122 @@
+package workflow
+
+import (
+	"code-challenge/pkg/conversations"
+	"code-challenge/pkg/deadletter"
+	"code-challenge/pkg/notify"
+	"code-challenge/pkg/openai"
+	"code-challenge/pkg/search"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_DeferredAnswerWorkflow(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+
+	// The webhook receives the answer once a provider recovered
+	delivered := make(chan notify.Message, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message notify.Message
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&message))
+		assert.Equal(t, "chat_1", r.Header.Get("Idempotency-Key"))
+		delivered <- message
+	}))
+	defer webhook.Close()
+
+	store := conversations.NewMemoryStore()
+	env.RegisterActivity(&Deliverer{Store: store, Notifier: &notify.Notifier{HTTPClient: webhook.Client(), WebhookHosts: []string{"127.0.0.1"}}})
+
+	// Both providers are still down on the first attempt, they recover on the second one
+	env.OnActivity(ChatActivity, mock.Anything, mock.Anything).Return(nil, errors.New("API error")).Times(6)
+	env.OnActivity(ChatActivity, mock.Anything, mock.Anything).Return(completion(openai.Message{Role: openai.RoleAssistant, Content: "Paris"}), nil).Once()
+
+	queuedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	env.ExecuteWorkflow(DeferredAnswerWorkflow, DeferredQuestion{
+		ID:       "chat_1",
+		Question: ChatBotQuestion{User: "test_user", Question: "What is the capital of France?", Notify: notify.Channel{Webhook: webhook.URL}},
+		QueuedAt: queuedAt,
+	})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result ChatBotAnswer
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, StatusAnswered, result.Status)
+	assert.Equal(t, "Paris", result.Answer)
+
+	message := <-delivered
+	assert.Equal(t, notify.Message{ID: "chat_1", User: "test_user", Question: "What is the capital of France?", Answer: "Paris", Status: StatusAnswered}, message)
+
+	// The answer is also recorded in the conversation history
+	history, err := store.History(context.Background(), "test_user")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "Paris", history[0].Answer)
+	assert.Equal(t, "gpt-4o", history[0].Model)
+	assert.True(t, history[0].CreatedAt.Equal(queuedAt))
+}
+
+func Test_DeferredAnswerWorkflow_Deadline(t *testing.T) {
+	ts := testsuite.WorkflowTestSuite{}
+	env := ts.NewTestWorkflowEnvironment()
+
+	store, letters := conversations.NewMemoryStore(), deadletter.NewMemoryStore()
+	env.RegisterActivity(&Deliverer{Store: store, Notifier: notify.FromEnv()})
+	env.RegisterActivity(&DeadLetters{Store: letters})
+
+	// The providers never recover, the user is told the question could not be answered
+	env.OnActivity(ChatActivity, mock.Anything, mock.Anything).Return(nil, errors.New("API error"))
+	env.OnUpsertTypedSearchAttributes(mock.Anything).Return(nil).Once()
+	env.OnUpsertTypedSearchAttributes(temporal.NewSearchAttributes(search.Outcome.ValueSet(StatusFailed))).Return(nil).Once()
+
+	queuedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	question := ChatBotQuestion{User: "test_user", Question: "What is the capital of France?", PromptVersion: PromptV2}
+	env.ExecuteWorkflow(DeferredAnswerWorkflow, DeferredQuestion{ID: "chat_1", Question: question, QueuedAt: queuedAt})
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	var result ChatBotAnswer
+	assert.NoError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, StatusFailed, result.Status)
+
+	history, err := store.History(context.Background(), "test_user")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, failedMessage, history[0].Answer)
+	env.AssertExpectations(t)
+
+	// The question given up is kept to be replayed with its input
+	dead, err := letters.List(context.Background(), deadletter.Filter{})
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, "default-test-workflow-id", dead[0].WorkflowID)
+	assert.Equal(t, "test_user", dead[0].User)
+	assert.Equal(t, AllProvidersFailedErrorType, dead[0].ErrorType)
+	assert.Equal(t, 51, dead[0].Attempts)
+	assert.True(t, dead[0].StartedAt.Equal(queuedAt))
+	assert.True(t, dead[0].FailedAt.After(queuedAt))
+
+	var input ChatBotQuestion
+	require.NoError(t, json.Unmarshal(dead[0].Input, &input))
+	assert.Equal(t, question, input)
+}
+
+func Test_ErrorType(t *testing.T) {
+	assert.Equal(t, RequestRejectedErrorType, errorType(temporal.NewNonRetryableApplicationError("rejected", RequestRejectedErrorType, nil)))
+	assert.Equal(t, TimeoutErrorType, errorType(temporal.NewTimeoutError(enums.TIMEOUT_TYPE_START_TO_CLOSE, nil)))
+	assert.Equal(t, UnknownErrorType, errorType(errors.New("unknown")))
+}
@@ -0,0 +1,50 @@
+package workflow
+
+import (
+	"slices"
+	"strings"
+)
+
+// Versions of the system prompt accepted in ChatBotQuestion.PromptVersion, the failed questions may be replayed with another one
+const (
+	PromptV1 = "v1"
+	PromptV2 = "v2"
+)
+
+// DefaultPromptVersion is the version of the questions that do not set one
+const DefaultPromptVersion = PromptV1
+
+// prompts are the instructions of each version sent before the profile of the user, v1 only sends the profile
+var prompts = map[string]string{
+	PromptV1: "",
+	PromptV2: "You are an immigration assistant. Answer questions about visas, application processes and requirements " +
+		"concisely, use the tools to check the facts, and say so when the answer depends on the situation of the user.",
+}
+
+// PromptVersions returns the versions of the system prompt, sorted
+func PromptVersions() []string {
+	versions := make([]string, 0, len(prompts))
+	for version := range prompts {
+		versions = append(versions, version)
+	}
+	slices.Sort(versions)
+	return versions
+}
+
+// ValidPromptVersion reports whether the version is known, the empty version is the default one
+func ValidPromptVersion(version string) bool {
+	_, ok := prompts[version]
+	return version == "" || ok
+}
+
+// systemPrompt returns the instructions of the prompt version followed by the profile of the user, empty when there is none
+func systemPrompt(input ChatBotQuestion) string {
+	var prompt []string
+	if instructions := prompts[input.PromptVersion]; instructions != "" {
+		prompt = append(prompt, instructions)
+	}
+	if profile := profilePrompt(input); profile != "" {
+		prompt = append(prompt, profile)
+	}
+	return strings.Join(prompt, " ")
+}
@@ -0,0 +1,71 @@
+package workflow
+
+import (
+	"code-challenge/pkg/search"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// histories are the histories exported from real executions with: temporal workflow show -w <id> -o json
+const histories = "testdata/histories"
+
+// Test_Replay replays the exported histories with the current workflows, it fails when a change of the workflows
+// breaks the executions in flight: the change must then be wrapped in workflow.GetVersion
+func Test_Replay(t *testing.T) {
+	names, err := filepath.Glob(filepath.Join(histories, "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, names)
+
+	replayer := worker.NewWorkflowReplayer()
+	replayer.RegisterWorkflow(ChatBotWorkflow)
+	replayer.RegisterWorkflow(DeferredAnswerWorkflow)
+	replayer.RegisterWorkflow(BatchQuestionsWorkflow)
+
+	for _, name := range names {
+		t.Run(strings.TrimSuffix(filepath.Base(name), ".json"), func(t *testing.T) {
+			history := loadHistory(t, name)
+
+			// The IDs of the child workflows are derived from the ID of the original execution
+			started := history.Events[0].GetWorkflowExecutionStartedEventAttributes()
+			err := replayer.ReplayWorkflowHistoryWithOptions(nil, history, worker.ReplayWorkflowHistoryOptions{
+				OriginalExecution: workflow.Execution{ID: started.GetWorkflowId(), RunID: started.GetOriginalExecutionRunId()},
+			})
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// loadHistory reads an exported history
+func loadHistory(t *testing.T, name string) *historypb.History {
+	file, err := os.Open(name)
+	require.NoError(t, err)
+	defer file.Close()
+
+	history, err := client.HistoryFromJSON(file, client.HistoryJSONOptions{})
+	require.NoError(t, err)
+	return history
+}
+
+func Test_Replay_DetectsNonDeterminism(t *testing.T) {
+	// Upserting the search attributes without workflow.GetVersion adds a command where the history started a timer
+	unversioned := func(ctx workflow.Context, input DeferredQuestion) (*ChatBotAnswer, error) {
+		if err := workflow.UpsertTypedSearchAttributes(ctx, search.User.ValueSet(input.Question.User)); err != nil {
+			return nil, err
+		}
+		return DeferredAnswerWorkflow(ctx, input)
+	}
+
+	replayer := worker.NewWorkflowReplayer()
+	replayer.RegisterWorkflowWithOptions(unversioned, workflow.RegisterOptions{Name: "DeferredAnswerWorkflow"})
+
+	err := replayer.ReplayWorkflowHistoryFromJSONFile(nil, filepath.Join(histories, "deferred_answer_before_search_attributes.json"))
+	assert.ErrorContains(t, err, "nondeterministic")
+}
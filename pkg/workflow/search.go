@@ -0,0 +1,43 @@
+package workflow
+
+import (
+	"code-challenge/pkg/classifier"
+	"code-challenge/pkg/search"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// outcomeCanceled is the outcome of the conversations canceled before they were answered, the other outcomes are their status
+const outcomeCanceled = "canceled"
+
+// upsertQuestion makes the conversation searchable by its user and by the topic of the question
+func upsertQuestion(ctx workflow.Context, input ChatBotQuestion) {
+	classification := classifier.Classify(input.Question, input.Locale)
+	upsert(ctx,
+		search.User.ValueSet(input.User),
+		search.Intent.ValueSet(classification.Intent),
+		search.DestinationCountry.ValueSet(classification.DestinationCountry),
+		search.Language.ValueSet(classification.Language),
+	)
+}
+
+// upsertOutcome makes the conversation searchable by its outcome and by the model that answered it
+func upsertOutcome(ctx workflow.Context, answer *ChatBotAnswer, err error) {
+	switch {
+	case temporal.IsCanceledError(err):
+		upsert(ctx, search.Outcome.ValueSet(outcomeCanceled))
+	case err != nil || answer == nil:
+		upsert(ctx, search.Outcome.ValueSet(StatusFailed))
+	case answer.Model == "":
+		upsert(ctx, search.Outcome.ValueSet(answer.Status))
+	default:
+		upsert(ctx, search.Outcome.ValueSet(answer.Status), search.Model.ValueSet(answer.Model))
+	}
+}
+
+// upsert sets the search attributes, a failure does not fail the conversation
+func upsert(ctx workflow.Context, attributes ...temporal.SearchAttributeUpdate) {
+	if err := workflow.UpsertTypedSearchAttributes(ctx, attributes...); err != nil {
+		workflow.GetLogger(ctx).Warn("Unable to upsert the search attributes.", "Error", err)
+	}
+}
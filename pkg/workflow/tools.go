@@ -0,0 +1,57 @@
+package workflow
+
+import (
+	"code-challenge/pkg/openai"
+	"code-challenge/pkg/tools"
+	"encoding/json"
+	"fmt"
+	"go.temporal.io/sdk/workflow"
+)
+
+// executeToolCalls runs the tools requested by the model in parallel, each one as its own activity,
+// and returns the tool messages sent back to the model in the order of the calls.
+// Failures are reported to the model so it can fix its arguments or answer without the tool.
+func executeToolCalls(ctx workflow.Context, calls []openai.ToolCall) []openai.Message {
+	logger := workflow.GetLogger(ctx)
+
+	// Start every tool activity before waiting for any of them
+	futures := make([]workflow.Future, len(calls))
+	errs := make([]error, len(calls))
+	for i, call := range calls {
+		tool, ok := tools.Get(call.Name)
+		if !ok {
+			errs[i] = fmt.Errorf("unknown tool %q", call.Name)
+			continue
+		}
+
+		logger.Info("Calling tool.", "Tool", call.Name)
+		toolCtx := workflow.WithActivityOptions(ctx, tool.ActivityOptions())
+		futures[i] = workflow.ExecuteActivity(toolCtx, tool.ActivityName(), call.Arguments)
+	}
+
+	messages := make([]openai.Message, 0, len(calls))
+	for i, call := range calls {
+		content := ""
+		if futures[i] != nil {
+			errs[i] = futures[i].Get(ctx, &content)
+		}
+
+		if errs[i] != nil {
+			logger.Warn("Tool failed.", "Tool", call.Name, "Error", errs[i])
+			content = toolError(errs[i])
+		}
+
+		messages = append(messages, openai.Message{
+			Role:       openai.RoleTool,
+			ToolCallID: call.ID,
+			Content:    content,
+		})
+	}
+	return messages
+}
+
+// toolError encodes a tool failure as the JSON content sent back to the model
+func toolError(err error) string {
+	content, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return string(content)
+}
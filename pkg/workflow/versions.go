@@ -0,0 +1,20 @@
+package workflow
+
+// Change IDs of the workflow.GetVersion calls.
+//
+// The executions in flight are replayed by the new workers, the commands of the new code must match their history:
+// adding, removing or reordering an activity, a timer, a side effect or a child workflow breaks them with a
+// non-determinism error. Such a change is wrapped in workflow.GetVersion with a new change ID, the executions started
+// before it replay with workflow.DefaultVersion and keep the previous code. The previous code is removed, with
+// its fixture in testdata/histories, once no execution started before the change is running.
+const (
+	// changeSearchAttributes upserts the search attributes of the conversations
+	changeSearchAttributes = "search-attributes"
+
+	// changeDeadLetter records the questions that could not be answered as dead letters
+	changeDeadLetter = "dead-letter"
+
+	// changeRequestRejected fails the workflows whose request every provider rejected instead of queuing them.
+	// Version 1 failed them as soon as the last provider rejected the request, even when another one was unavailable.
+	changeRequestRejected = "request-rejected"
+)